@@ -1,12 +1,18 @@
 package apihttpwrapper
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"io"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,13 +21,30 @@ type AccessLogDecorator struct {
 	loggingHeaders      []string
 	rowFillerContextKey interface{}
 	rowFillerFactory    AccessLogRowFillerFactory
+	enrichers           []AccessLogEnricher
+	rowSchema           []string
+	knownFields         map[string]bool
+	warnedFields        sync.Map
+	connTimings         *ConnTimingTracker
+	skipFilter          func(*http.Request) bool
 	logger              *logrus.Logger
+	out                 *accessLogWriter
+	sinks               []*AccessLogSink
+	closeTimeout        time.Duration
+	closeMu             sync.RWMutex
+	closed              bool
+	dropped             int64
 }
 
 type AccessLogRow struct {
-	fields logrus.Fields
+	fields  logrus.Fields
+	skipped bool
 }
 
+// accessLogBuiltinFields are set by the decorator itself, they are always known to the row schema.
+var accessLogBuiltinFields = []string{"begin", "status", "duration", "firstByte", "bytes", "remote", "method", "uri",
+	"proto", "headers", "locales", "tlsHandshake", "connReused"}
+
 type AccessLogRowFiller interface{}
 type AccessLogRowFillerFactory func(*AccessLogRow) AccessLogRowFiller
 
@@ -31,32 +54,172 @@ func (row *AccessLogRow) SetRowField(field string, value string) {
 
 type statusResponseWriter struct {
 	http.ResponseWriter
-	status int
+	status      int
+	wroteHeader bool
+	written     int64
+	firstByte   time.Time
 }
 
+// WriteHeader records the final status, ignoring the informational and superfluous ones.
 func (w *statusResponseWriter) WriteHeader(status int) {
-	w.status = status
+	if !w.wroteHeader && (status >= http.StatusOK || status == http.StatusSwitchingProtocols) {
+		w.status, w.wroteHeader = status, true
+	}
+	if w.firstByte.IsZero() {
+		w.firstByte = time.Now()
+	}
 	w.ResponseWriter.WriteHeader(status)
 }
 
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.firstByte.IsZero() {
+		w.firstByte = time.Now()
+	}
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush and Hijack are passed through, so that streaming and websocket routes keep working behind the wrapper.
+func (w *statusResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the response writer doesn't support hijacking")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+
+	return conn, rw, err
+}
+
 func NewAccessLogDecorator(handler http.Handler, logWriter io.Writer, loggingHeaders []string,
 	rowFillerContextKey interface{}, rowFillerFactory AccessLogRowFillerFactory) *AccessLogDecorator {
 	logger := logrus.New()
 	logger.Formatter = &logrus.TextFormatter{DisableTimestamp: true}
-	logger.Out = logWriter
+	out := &accessLogWriter{w: logWriter}
+	logger.Out = out
 	return &AccessLogDecorator{
 		Handler:             handler,
 		loggingHeaders:      loggingHeaders,
 		rowFillerContextKey: rowFillerContextKey,
 		rowFillerFactory:    rowFillerFactory,
 		logger:              logger,
+		out:                 out,
+	}
+}
+
+// NewAccessLogHandler logs the requests of any handler, see RecordAccessLogField.
+func NewAccessLogHandler(handler http.Handler, loggingHeaders []string, logWriter io.Writer) *AccessLogDecorator {
+	return NewAccessLogDecorator(handler, logWriter, loggingHeaders, ServiceHandlerAccessLogRowFillerContextKey,
+		ServiceHandlerAccessLogRowFillerFactory)
+}
+
+// RecordAccessLogField returns false if the request is not logged by an AccessLogDecorator.
+func RecordAccessLogField(ctx context.Context, field string, value string) bool {
+	logger, ok := ctx.Value(ServiceHandlerAccessLogRowFillerContextKey).(MethodLogger)
+	if ok {
+		logger.Record(field, value)
+	}
+
+	return ok
+}
+
+// SkipAccessLogRow drops the row of the request, like the health checks.
+func SkipAccessLogRow(ctx context.Context) bool {
+	skipper, ok := ctx.Value(ServiceHandlerAccessLogRowFillerContextKey).(interface{ skipRow() })
+	if ok {
+		skipper.skipRow()
+	}
+
+	return ok
+}
+
+// SetEnrichers sets the enrichers which are called in order before each row is written, see AccessLogEnricher.
+func (d *AccessLogDecorator) SetEnrichers(enrichers ...AccessLogEnricher) {
+	d.enrichers = enrichers
+}
+
+// SetEncoder sets the format of the rows, TextAccessLogEncoder by default. the sinks are not affected.
+func (d *AccessLogDecorator) SetEncoder(encoder AccessLogEncoder) {
+	d.logger.Formatter = &accessLogFormatter{encoder}
+}
+
+// AddSink writes the rows into the sink too, it's closed with the decorator.
+func (d *AccessLogDecorator) AddSink(sink *AccessLogSink) {
+	d.logger.AddHook(sink)
+	d.sinks = append(d.sinks, sink)
+}
+
+// SetConnTimingTracker logs the connection timings, see ConnTimingTracker.
+func (d *AccessLogDecorator) SetConnTimingTracker(tracker *ConnTimingTracker) {
+	d.connTimings = tracker
+}
+
+// SetSkipFilter drops the rows of the requests the filter returns true for.
+func (d *AccessLogDecorator) SetSkipFilter(filter func(*http.Request) bool) {
+	d.skipFilter = filter
+}
+
+// SetRowSchema declares the extra fields of the rows, the missing ones are written as nulls.
+func (d *AccessLogDecorator) SetRowSchema(fields ...string) {
+	d.rowSchema = fields
+	d.knownFields = make(map[string]bool)
+	for _, field := range accessLogBuiltinFields {
+		d.knownFields[field] = true
+	}
+
+	for _, field := range fields {
+		d.knownFields[field] = true
+	}
+}
+
+func (d *AccessLogDecorator) applyRowSchema(row *AccessLogRow) {
+	if d.knownFields == nil {
+		return
+	}
+
+	for _, field := range d.rowSchema {
+		if _, ok := row.fields[field]; !ok {
+			row.fields[field] = nil
+		}
+	}
+
+	for field := range row.fields {
+		if d.knownFields[field] {
+			continue
+		}
+
+		if _, warned := d.warnedFields.LoadOrStore(field, true); !warned {
+			d.logger.WithField("field", field).Warn("unknown access log field")
+		}
 	}
 }
 
 func (d *AccessLogDecorator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	beginTime := time.Now()
 	row := &AccessLogRow{
-		fields: make(logrus.Fields),
+		fields:  make(logrus.Fields),
+		skipped: d.skipFilter != nil && d.skipFilter(r),
+	}
+
+	// the nested decorators record into the row of the outermost one.
+	if d.rowFillerContextKey != nil && r.Context().Value(d.rowFillerContextKey) != nil {
+		d.Handler.ServeHTTP(w, r)
+		return
 	}
 
 	if d.rowFillerContextKey != nil && d.rowFillerFactory != nil {
@@ -64,12 +227,25 @@ func (d *AccessLogDecorator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		r = r.WithContext(context.WithValue(r.Context(), d.rowFillerContextKey, rowFiller))
 	}
 
+	var connTiming *ConnTiming
+	if d.connTimings != nil {
+		var ok bool
+		if connTiming, ok = d.connTimings.request(r); ok {
+			for _, segment := range connTiming.serverTimings(beginTime) {
+				w.Header().Add("Server-Timing", segment)
+			}
+		}
+	}
+
 	sw := &statusResponseWriter{
 		ResponseWriter: w,
 		status:         http.StatusOK,
 	}
 
 	d.Handler.ServeHTTP(sw, r)
+	if row.skipped {
+		return
+	}
 
 	headers := make(map[string][]string)
 	for _, k := range d.loggingHeaders {
@@ -83,10 +259,37 @@ func (d *AccessLogDecorator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	row.SetRowField("begin", beginTime.Format("2006-01-02 15:04:05.999999999"))
 	row.SetRowField("status", strconv.Itoa(sw.status))
 	row.SetRowField("duration", strconv.FormatFloat(time.Now().Sub(beginTime).Seconds(), 'f', -1, 64))
+	if !sw.firstByte.IsZero() {
+		row.SetRowField("firstByte", formatSeconds(sw.firstByte.Sub(beginTime)))
+	}
+	row.SetRowField("bytes", strconv.FormatInt(sw.written, 10))
 	row.SetRowField("remote", r.RemoteAddr)
 	row.SetRowField("method", r.Method)
 	row.SetRowField("uri", r.URL.RequestURI())
+	row.SetRowField("proto", r.Proto)
 	row.SetRowField("headers", string(marshaledHeaders))
+	if locales := requestLocales(r); len(locales) > 0 {
+		row.SetRowField("locales", strings.Join(locales, ","))
+	}
+
+	if connTiming != nil {
+		row.SetRowField("connReused", strconv.FormatBool(connTiming.Reused))
+		if !connTiming.TLSHandshakeDone.IsZero() {
+			row.SetRowField("tlsHandshake", formatSeconds(connTiming.TLSHandshakeDone.Sub(connTiming.Accepted)))
+		}
+	}
+
+	for _, enricher := range d.enrichers {
+		enricher.Enrich(r, row)
+	}
+	d.applyRowSchema(row)
+
+	d.closeMu.RLock()
+	defer d.closeMu.RUnlock()
+	if d.closed {
+		atomic.AddInt64(&d.dropped, 1)
+		return
+	}
 
 	if sw.status < http.StatusBadRequest {
 		d.logger.WithFields(row.fields).Info()
@@ -0,0 +1,92 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogResponseFields(t *testing.T) {
+	cases := []struct {
+		handler http.HandlerFunc
+		fields  string
+	}{
+		{func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("hello"))
+		}, `bytes=5 duration=[0-9.e-]+ .*status=200`},
+		{func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusProcessing)
+			w.WriteHeader(http.StatusNotFound)
+			w.WriteHeader(http.StatusInternalServerError)
+		}, `bytes=0 duration=[0-9.e-]+ .*status=404`},
+	}
+
+	for _, c := range cases {
+		buffer := &bytes.Buffer{}
+		d := NewAccessLogDecorator(c.handler, buffer, nil, nil, nil)
+		d.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if !regexp.MustCompile(c.fields).MatchString(buffer.String()) {
+			t.Error(c.fields, buffer.String())
+		}
+	}
+}
+
+func TestAccessLogHandler(t *testing.T) {
+	router, err := NewLoggingHTTPRouter([]*Route{{Method: "GET", Path: "/api", Function: func(ctx *ServiceMethodContext,
+		arg *struct{}) error {
+		ctx.Logger().Record("fromMethod", "1")
+		return nil
+	}}}, nil, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api", router)
+	mux.HandleFunc("/foreign", func(w http.ResponseWriter, r *http.Request) {
+		if !RecordAccessLogField(r.Context(), "fromForeign", "1") {
+			t.Error("the foreign handler can't record")
+		}
+	})
+
+	buffer := &bytes.Buffer{}
+	handler := NewAccessLogHandler(mux, nil, buffer)
+	for _, path := range []string{"/api", "/foreign"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	rows := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(rows) != 2 || !strings.Contains(rows[0], "fromMethod=1") || !strings.Contains(rows[1], "fromForeign=1") {
+		t.Error(buffer.String())
+	}
+
+	if RecordAccessLogField(context.Background(), "field", "value") {
+		t.Error("the field is recorded without a row")
+	}
+}
+
+func TestAccessLogSkipFilter(t *testing.T) {
+	served := 0
+	buffer := &bytes.Buffer{}
+	d := NewAccessLogDecorator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	}), buffer, nil, nil, nil)
+	d.SetSkipFilter(func(r *http.Request) bool {
+		return r.Method == "OPTIONS" || r.URL.Path == "/healthz"
+	})
+
+	for _, request := range []*http.Request{httptest.NewRequest("GET", "/healthz", nil),
+		httptest.NewRequest("OPTIONS", "/api", nil), httptest.NewRequest("GET", "/api", nil)} {
+		d.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	rows := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if served != 3 || len(rows) != 1 || !strings.Contains(rows[0], "uri=/api") ||
+		!strings.Contains(rows[0], "method=GET") {
+		t.Error(served, buffer.String())
+	}
+}
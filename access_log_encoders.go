@@ -0,0 +1,99 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net"
+	"strings"
+	"time"
+)
+
+// AccessLogEncoder formats the rows, the message is only of the warnings of the decorator.
+type AccessLogEncoder interface {
+	Encode(level string, message string, fields map[string]interface{}) ([]byte, error)
+}
+
+// TextAccessLogEncoder is the default logfmt like line format.
+type TextAccessLogEncoder struct{}
+
+// JSONLinesAccessLogEncoder writes each row as a JSON object in a line, with the "level" and the "msg" fields.
+type JSONLinesAccessLogEncoder struct{}
+
+// CombinedAccessLogEncoder writes the Apache Combined Log Format of the logged "Referer" and "User-Agent".
+type CombinedAccessLogEncoder struct{}
+
+// accessLogFormatter adapts the encoder to the logger.
+type accessLogFormatter struct {
+	encoder AccessLogEncoder
+}
+
+var textAccessLogFormatter = &logrus.TextFormatter{DisableTimestamp: true}
+
+func (f *accessLogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	return f.encoder.Encode(entry.Level.String(), entry.Message, entry.Data)
+}
+
+func (TextAccessLogEncoder) Encode(level string, message string, fields map[string]interface{}) ([]byte, error) {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	return textAccessLogFormatter.Format(&logrus.Entry{Data: fields, Level: parsed, Message: message})
+}
+
+func (JSONLinesAccessLogEncoder) Encode(level string, message string, fields map[string]interface{}) ([]byte, error) {
+	line := make(map[string]interface{}, len(fields)+2)
+	for k, v := range fields {
+		line[k] = v
+	}
+
+	line["level"] = level
+	if message != "" {
+		line["msg"] = message
+	}
+
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(line)
+	return buffer.Bytes(), err
+}
+
+func (CombinedAccessLogEncoder) Encode(level string, message string, fields map[string]interface{}) ([]byte, error) {
+	if message != "" {
+		return nil, nil
+	}
+
+	field := func(name string) string {
+		if value, ok := fields[name].(string); ok && value != "" {
+			return value
+		}
+		return "-"
+	}
+
+	host := field("remote")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	begin := field("begin")
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05.999999999", begin, time.Local); err == nil {
+		begin = t.Format("02/Jan/2006:15:04:05 -0700")
+	}
+
+	var headers map[string][]string
+	_ = json.Unmarshal([]byte(field("headers")), &headers)
+	header := func(name string) string {
+		if values := headers[name]; len(values) > 0 {
+			return values[0]
+		}
+		return "-"
+	}
+
+	request := strings.Join([]string{field("method"), field("uri"), field("proto")}, " ")
+	return []byte(fmt.Sprintf("%s - - [%s] %q %s %s %q %q\n", host, begin, request, field("status"), field("bytes"),
+		header("Referer"), header("User-Agent"))), nil
+}
@@ -0,0 +1,48 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogEncoders(t *testing.T) {
+	do := func(encoder AccessLogEncoder) string {
+		buffer := &bytes.Buffer{}
+		d := NewAccessLogDecorator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("hello"))
+		}), buffer, []string{"Referer", "User-Agent"}, nil, nil)
+		if encoder != nil {
+			d.SetEncoder(encoder)
+		}
+
+		r := httptest.NewRequest("GET", "/a?b=c", nil)
+		r.RemoteAddr = "1.2.3.4:5678"
+		r.Header.Set("User-Agent", "curl/7.0")
+		d.ServeHTTP(httptest.NewRecorder(), r)
+		return buffer.String()
+	}
+
+	for _, text := range []string{do(nil), do(TextAccessLogEncoder{})} {
+		if !strings.HasPrefix(text, "level=info begin=") || !strings.Contains(text, " uri=\"/a?b=c\"\n") {
+			t.Error(text)
+		}
+	}
+
+	var row map[string]interface{}
+	if err := json.Unmarshal([]byte(do(JSONLinesAccessLogEncoder{})), &row); err != nil ||
+		row["level"] != "info" || row["uri"] != "/a?b=c" || row["bytes"] != "5" {
+		t.Error(row, err)
+	}
+
+	combined := do(CombinedAccessLogEncoder{})
+	pattern := `^1\.2\.3\.4 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [-+]\d{4}\] "GET /a\?b=c HTTP/1\.1" 200 5 "-" ` +
+		`"curl/7\.0"\n$`
+	if !regexp.MustCompile(pattern).MatchString(combined) {
+		t.Error(combined)
+	}
+}
@@ -0,0 +1,97 @@
+package apihttpwrapper
+
+import (
+	"net"
+	"net/http"
+)
+
+// AccessLogEnricher adds fields to the access log row right before it is written.
+type AccessLogEnricher interface {
+	Enrich(r *http.Request, row *AccessLogRow)
+}
+
+type GeoLocation struct {
+	Country string
+	Region  string
+	City    string
+}
+
+// GeoResolver does the heavy lifting of GeoEnricher, like looking up a GeoIP database.
+type GeoResolver interface {
+	Resolve(ip net.IP) (*GeoLocation, error)
+}
+
+type UserAgentInfo struct {
+	Device  string
+	OS      string
+	Browser string
+}
+
+// UserAgentParser does the heavy lifting of UserAgentEnricher.
+type UserAgentParser interface {
+	Parse(userAgent string) *UserAgentInfo
+}
+
+// GeoEnricher sets the "country", "region" and "city" fields by the client IP.
+type GeoEnricher struct {
+	Resolver GeoResolver
+	// ClientIP extracts the client IP, the host of RemoteAddr by default. set it when behind trusted proxies.
+	ClientIP func(r *http.Request) net.IP
+}
+
+// UserAgentEnricher sets the "device", "os" and "browser" fields by the User-Agent header.
+type UserAgentEnricher struct {
+	Parser UserAgentParser
+}
+
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
+
+func setRowFieldIfNotEmpty(row *AccessLogRow, field string, value string) {
+	if value != "" {
+		row.SetRowField(field, value)
+	}
+}
+
+func (e *GeoEnricher) Enrich(r *http.Request, row *AccessLogRow) {
+	clientIP := e.ClientIP
+	if clientIP == nil {
+		clientIP = remoteIP
+	}
+
+	ip := clientIP(r)
+	if ip == nil {
+		return
+	}
+
+	location, err := e.Resolver.Resolve(ip)
+	if err != nil {
+		row.SetRowField("geoError", err.Error())
+		return
+	}
+
+	if location != nil {
+		setRowFieldIfNotEmpty(row, "country", location.Country)
+		setRowFieldIfNotEmpty(row, "region", location.Region)
+		setRowFieldIfNotEmpty(row, "city", location.City)
+	}
+}
+
+func (e *UserAgentEnricher) Enrich(r *http.Request, row *AccessLogRow) {
+	userAgent := r.UserAgent()
+	if userAgent == "" {
+		return
+	}
+
+	if info := e.Parser.Parse(userAgent); info != nil {
+		setRowFieldIfNotEmpty(row, "device", info.Device)
+		setRowFieldIfNotEmpty(row, "os", info.OS)
+		setRowFieldIfNotEmpty(row, "browser", info.Browser)
+	}
+}
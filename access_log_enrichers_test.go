@@ -0,0 +1,85 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testingGeoResolver map[string]*GeoLocation
+
+type testingUserAgentParser struct{}
+
+func (g testingGeoResolver) Resolve(ip net.IP) (*GeoLocation, error) {
+	if location, ok := g[ip.String()]; ok {
+		return location, nil
+	}
+
+	return nil, fmt.Errorf("unknown ip %s", ip)
+}
+
+func (testingUserAgentParser) Parse(userAgent string) *UserAgentInfo {
+	if strings.Contains(userAgent, "iPhone") {
+		return &UserAgentInfo{Device: "mobile", OS: "iOS"}
+	}
+
+	return nil
+}
+
+func TestAccessLogEnrichers(t *testing.T) {
+	do := func(remoteAddr string, userAgent string) string {
+		buffer := &bytes.Buffer{}
+		d := NewAccessLogDecorator(http.NotFoundHandler(), buffer, nil, nil, nil)
+		d.SetEnrichers(
+			&GeoEnricher{Resolver: testingGeoResolver{"1.2.3.4": {Country: "CN", Region: "Beijing"}}},
+			&UserAgentEnricher{Parser: testingUserAgentParser{}},
+		)
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("User-Agent", userAgent)
+		d.ServeHTTP(httptest.NewRecorder(), r)
+		return buffer.String()
+	}
+
+	row := do("1.2.3.4:5678", "Mozilla/5.0 (iPhone; CPU iPhone OS 14_0 like Mac OS X)")
+	for _, field := range []string{"country=CN", "region=Beijing", "device=mobile", "os=iOS"} {
+		if !strings.Contains(row, field) {
+			t.Error(field, "not in", row)
+		}
+	}
+
+	if strings.Contains(row, "city=") || strings.Contains(row, "browser=") {
+		t.Error("empty fields in", row)
+	}
+
+	row = do("5.6.7.8:5678", "curl/7.0")
+	if !strings.Contains(row, "geoError") || strings.Contains(row, "device=") {
+		t.Error(row)
+	}
+}
+
+func TestAccessLogRowSchema(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	d := NewAccessLogDecorator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Context().Value("filler").(MethodLogger).Record("extra", "1")
+	}), buffer, nil, "filler", ServiceHandlerAccessLogRowFillerFactory)
+	d.SetRowSchema("args", "resp")
+
+	for i := 0; i < 2; i++ {
+		d.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	logs := buffer.String()
+	if strings.Count(logs, "unknown access log field") != 1 || !strings.Contains(logs, "field=extra") {
+		t.Error(logs)
+	}
+
+	if strings.Count(logs, "args=\"<nil>\"") != 2 || strings.Count(logs, "extra=1") != 2 {
+		t.Error(logs)
+	}
+}
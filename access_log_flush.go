@@ -0,0 +1,120 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultAccessLogCloseTimeout bounds AccessLogDecorator.Close, see SetCloseTimeout.
+const DefaultAccessLogCloseTimeout = 10 * time.Second
+
+// accessLogWriter serializes the writes and flushes, and counts the failures.
+type accessLogWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	written int64
+	failed  int64
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n, err := w.w.Write(b)
+	if err != nil {
+		atomic.AddInt64(&w.failed, 1)
+	} else {
+		atomic.AddInt64(&w.written, 1)
+	}
+
+	return n, err
+}
+
+// flush calls Flush() error or Sync() error of the writer if it has one.
+func (w *accessLogWriter) flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch f := w.w.(type) {
+	case interface{ Flush() error }:
+		return f.Flush()
+	case interface{ Sync() error }:
+		return f.Sync()
+	}
+
+	return nil
+}
+
+// SetCloseTimeout sets the wait of Close, DefaultAccessLogCloseTimeout if not positive.
+func (d *AccessLogDecorator) SetCloseTimeout(timeout time.Duration) {
+	d.closeTimeout = timeout
+}
+
+// Stats returns the count of the rows written and dropped, see also AccessLogSink.Stats.
+func (d *AccessLogDecorator) Stats() (written int64, dropped int64) {
+	return atomic.LoadInt64(&d.out.written), atomic.LoadInt64(&d.out.failed) + atomic.LoadInt64(&d.dropped)
+}
+
+// flushWriter leaves the stuck flush in background once ctx is done.
+func (d *AccessLogDecorator) flushWriter(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- d.out.flush()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush flushes the writer and the sinks until ctx is done.
+func (d *AccessLogDecorator) Flush(ctx context.Context) error {
+	err := d.flushWriter(ctx)
+	for _, sink := range d.sinks {
+		if sinkErr := sink.Flush(ctx); err == nil {
+			err = sinkErr
+		}
+	}
+
+	return err
+}
+
+// Close flushes the rows and closes the sinks in the close timeout, call it after the server shuts down.
+func (d *AccessLogDecorator) Close() error {
+	timeout := d.closeTimeout
+	if timeout <= 0 {
+		timeout = DefaultAccessLogCloseTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// the rows being written hold the read lock, which may be stuck by the writer too.
+	locked := make(chan struct{})
+	go func() {
+		d.closeMu.Lock()
+		d.closed = true
+		d.closeMu.Unlock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+	case <-ctx.Done():
+	}
+
+	err := d.flushWriter(ctx)
+	for _, sink := range d.sinks {
+		if sinkErr := sink.Close(ctx); err == nil {
+			err = sinkErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,94 @@
+package apihttpwrapper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestAccessLogFlush(t *testing.T) {
+	buf := &bytes.Buffer{}
+	d := NewAccessLogDecorator(http.NotFoundHandler(), bufio.NewWriterSize(buf, 1<<16), nil, nil, nil)
+	driver := &testingAccessLogDriver{failures: 1}
+	sink := NewAccessLogSink(AccessLogSinkConfig{Driver: driver, FlushInterval: time.Hour,
+		InitialBackoff: 10 * time.Millisecond})
+	d.AddSink(sink)
+
+	serve := func() {
+		d.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	serve()
+	if buf.Len() != 0 {
+		t.Error(buf.String())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "status=404") || len(driver.rows) != 1 {
+		t.Error(buf.String(), len(driver.rows))
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	serve()
+	if written, dropped := d.Stats(); written != 1 || dropped != 1 {
+		t.Error(written, dropped)
+	}
+
+	d = NewAccessLogDecorator(http.NotFoundHandler(), failingWriter{}, nil, nil, nil)
+	serve()
+	if written, dropped := d.Stats(); written != 0 || dropped != 1 {
+		t.Error(written, dropped)
+	}
+}
+
+type stuckWriter chan struct{}
+
+func (w stuckWriter) Write(b []byte) (int, error) {
+	<-w
+	return len(b), nil
+}
+
+func TestAccessLogCloseTimeout(t *testing.T) {
+	stuck := make(stuckWriter)
+	defer close(stuck)
+	d := NewAccessLogDecorator(http.NotFoundHandler(), stuck, nil, nil, nil)
+	d.SetCloseTimeout(50 * time.Millisecond)
+
+	go d.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	// the row is stuck in the writer while closing.
+	time.Sleep(10 * time.Millisecond)
+	closed := make(chan error, 1)
+	go func() {
+		closed <- d.Close()
+	}()
+
+	select {
+	case err := <-closed:
+		if err != context.DeadlineExceeded {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close is not bounded by the timeout")
+	}
+}
@@ -0,0 +1,273 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AccessLogDriver inserts the rows into a store like ClickHouse, the values are strings or nil.
+type AccessLogDriver interface {
+	InsertRows(ctx context.Context, rows []map[string]interface{}) error
+}
+
+type AccessLogSinkConfig struct {
+	Driver        AccessLogDriver
+	BatchSize     int
+	FlushInterval time.Duration
+	// BufferSize limits the rows waiting for insertion, the rows beyond it are dropped.
+	BufferSize     int
+	InsertTimeout  time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	LogWriter      io.Writer
+}
+
+// AccessLogSink inserts the rows in batches in background, see AccessLogDecorator.AddSink.
+type AccessLogSink struct {
+	config      AccessLogSinkConfig
+	logger      *logrus.Logger
+	rows        chan map[string]interface{}
+	flushes     chan chan error
+	stopping    chan struct{}
+	stopped     chan struct{}
+	stopOnce    sync.Once
+	abandon     chan struct{}
+	abandonOnce sync.Once
+	inserted    int64
+	dropped     int64
+}
+
+func NewAccessLogSink(config AccessLogSinkConfig) *AccessLogSink {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1000
+	}
+
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Second
+	}
+
+	if config.BufferSize < config.BatchSize {
+		config.BufferSize = 100 * config.BatchSize
+	}
+
+	if config.InsertTimeout <= 0 {
+		config.InsertTimeout = 30 * time.Second
+	}
+
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = time.Second
+	}
+
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = time.Minute
+	}
+
+	logger := logrus.New()
+	logger.Formatter = &logrus.TextFormatter{DisableTimestamp: true}
+	if config.LogWriter != nil {
+		logger.Out = config.LogWriter
+	} else {
+		logger.Out = ioutil.Discard
+	}
+
+	s := &AccessLogSink{
+		config:   config,
+		logger:   logger,
+		rows:     make(chan map[string]interface{}, config.BufferSize),
+		flushes:  make(chan chan error),
+		stopping: make(chan struct{}),
+		stopped:  make(chan struct{}),
+		abandon:  make(chan struct{}),
+	}
+
+	go s.work()
+	return s
+}
+
+// Levels only takes the rows, not the warnings of the decorator.
+func (s *AccessLogSink) Levels() []logrus.Level {
+	return []logrus.Level{logrus.InfoLevel, logrus.ErrorLevel}
+}
+
+func (s *AccessLogSink) Fire(entry *logrus.Entry) error {
+	row := make(map[string]interface{}, len(entry.Data)+1)
+	for k, v := range entry.Data {
+		row[k] = v
+	}
+	row["level"] = entry.Level.String()
+
+	// the rows are logged on the request path, so they are dropped instead of waiting for a slow store.
+	select {
+	case <-s.stopping:
+		atomic.AddInt64(&s.dropped, 1)
+	case s.rows <- row:
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+	}
+
+	return nil
+}
+
+// Stats returns the count of the rows inserted and dropped so far.
+func (s *AccessLogSink) Stats() (inserted int64, dropped int64) {
+	return atomic.LoadInt64(&s.inserted), atomic.LoadInt64(&s.dropped)
+}
+
+// Close stops taking rows and tries to insert the buffered ones until ctx is done.
+func (s *AccessLogSink) Close(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stopping) })
+	select {
+	case <-s.stopped:
+		return nil
+	case <-ctx.Done():
+		// the rows still buffered are dropped.
+		s.abandonOnce.Do(func() { close(s.abandon) })
+		return ctx.Err()
+	}
+}
+
+// Flush inserts the rows taken so far, retrying the failed batches until ctx is done.
+func (s *AccessLogSink) Flush(ctx context.Context) error {
+	for {
+		done := make(chan error, 1)
+		select {
+		case s.flushes <- done:
+		case <-s.stopped:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		select {
+		case err := <-done:
+			if err == nil {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		select {
+		case <-time.After(s.config.InitialBackoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *AccessLogSink) insert(rows []map[string]interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.InsertTimeout)
+	defer cancel()
+
+	err := s.config.Driver.InsertRows(ctx, rows)
+	if err != nil {
+		s.logger.WithFields(logrus.Fields{"rows": len(rows), "error": err}).Error("insert access log rows failed")
+		return err
+	}
+
+	atomic.AddInt64(&s.inserted, int64(len(rows)))
+	return nil
+}
+
+func (s *AccessLogSink) work() {
+	defer close(s.stopped)
+
+	var pending []map[string]interface{}
+	var retryAt time.Time
+	backoff := s.config.InitialBackoff
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	add := func(row map[string]interface{}) {
+		pending = append(pending, row)
+		if overflow := len(pending) - s.config.BufferSize; overflow > 0 {
+			pending = pending[overflow:]
+			atomic.AddInt64(&s.dropped, int64(overflow))
+		}
+	}
+
+	drain := func() {
+		for {
+			select {
+			case row := <-s.rows:
+				add(row)
+			default:
+				return
+			}
+		}
+	}
+
+	var reported int64
+	reportDropped := func() {
+		dropped := atomic.LoadInt64(&s.dropped)
+		if dropped > reported {
+			s.logger.WithFields(logrus.Fields{"rows": dropped - reported, "total": dropped}).Error(
+				"access log rows dropped")
+			reported = dropped
+		}
+	}
+
+	// flush returns the error of the insertion, the rows are kept pending.
+	flush := func(force bool) error {
+		defer reportDropped()
+		for len(pending) > 0 && (force || len(pending) >= s.config.BatchSize) {
+			if time.Now().Before(retryAt) {
+				return nil
+			}
+
+			n := len(pending)
+			if n > s.config.BatchSize {
+				n = s.config.BatchSize
+			}
+
+			if err := s.insert(pending[:n]); err != nil {
+				retryAt = time.Now().Add(backoff)
+				if backoff *= 2; backoff > s.config.MaxBackoff {
+					backoff = s.config.MaxBackoff
+				}
+				return err
+			}
+
+			pending = pending[n:]
+			retryAt = time.Time{}
+			backoff = s.config.InitialBackoff
+		}
+
+		return nil
+	}
+
+	for {
+		select {
+		case row := <-s.rows:
+			add(row)
+			flush(false)
+		case <-ticker.C:
+			flush(true)
+		case done := <-s.flushes:
+			drain()
+			retryAt = time.Time{}
+			done <- flush(true)
+		case <-s.stopping:
+			drain()
+			for {
+				retryAt = time.Time{}
+				flush(true)
+				if len(pending) == 0 {
+					return
+				}
+
+				select {
+				case <-s.abandon:
+					atomic.AddInt64(&s.dropped, int64(len(pending)))
+					return
+				case <-time.After(backoff):
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testingAccessLogDriver struct {
+	mu       sync.Mutex
+	failures int
+	rows     []map[string]interface{}
+}
+
+func (d *testingAccessLogDriver) InsertRows(_ context.Context, rows []map[string]interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.failures > 0 {
+		d.failures--
+		return errors.New("store unavailable")
+	}
+
+	d.rows = append(d.rows, rows...)
+	return nil
+}
+
+func TestAccessLogSink(t *testing.T) {
+	driver := &testingAccessLogDriver{failures: 2}
+	sink := NewAccessLogSink(AccessLogSinkConfig{
+		Driver:         driver,
+		BatchSize:      2,
+		FlushInterval:  10 * time.Millisecond,
+		InitialBackoff: 10 * time.Millisecond,
+	})
+
+	d := NewAccessLogDecorator(http.NotFoundHandler(), ioutil.Discard, nil, nil, nil)
+	d.AddSink(sink)
+	for i := 0; i < 5; i++ {
+		d.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sink.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	inserted, dropped := sink.Stats()
+	if inserted != 5 || dropped != 0 || len(driver.rows) != 5 {
+		t.Error(inserted, dropped, len(driver.rows))
+	}
+
+	if row := driver.rows[0]; row["level"] != "error" || row["status"] != "404" {
+		t.Error(row)
+	}
+}
+
+type blockingAccessLogDriver chan struct{}
+
+func (d blockingAccessLogDriver) InsertRows(ctx context.Context, rows []map[string]interface{}) error {
+	select {
+	case <-d:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestAccessLogSinkSlowStore(t *testing.T) {
+	release := make(blockingAccessLogDriver)
+	logs := &bytes.Buffer{}
+	sink := NewAccessLogSink(AccessLogSinkConfig{Driver: release, BatchSize: 1, BufferSize: 1, LogWriter: logs})
+	d := NewAccessLogDecorator(http.NotFoundHandler(), ioutil.Discard, nil, nil, nil)
+	d.AddSink(sink)
+
+	// the requests are not blocked while the store is inserting.
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		for i := 0; i < 5; i++ {
+			d.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}
+	}()
+
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the requests are blocked by the sink")
+	}
+	close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sink.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if inserted, dropped := sink.Stats(); dropped < 3 || inserted+dropped != 5 {
+		t.Error(inserted, dropped)
+	}
+
+	if !strings.Contains(logs.String(), "access log rows dropped") {
+		t.Error(logs.String())
+	}
+}
@@ -0,0 +1,122 @@
+// Package apitest provides the helpers of testing the services built on apihttpwrapper.
+package apitest
+
+import (
+	"fmt"
+	"github.com/abadcafe/apihttpwrapper"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// RouteCoverage tracks which routes are hit by the tests, check it after m.Run in TestMain.
+type RouteCoverage struct {
+	mutex  sync.Mutex
+	routes []string
+	hits   map[string]int
+}
+
+func NewRouteCoverage() *RouteCoverage {
+	return &RouteCoverage{hits: make(map[string]int)}
+}
+
+func routeKey(rt *apihttpwrapper.Route) string {
+	return strings.ToUpper(rt.Method) + " " + rt.Path
+}
+
+// Instrument returns the copies of the routes recording the hits.
+func (c *RouteCoverage) Instrument(routes []*apihttpwrapper.Route) []*apihttpwrapper.Route {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	instrumented := make([]*apihttpwrapper.Route, 0, len(routes))
+	for _, rt := range routes {
+		key := routeKey(rt)
+		if _, ok := c.hits[key]; !ok {
+			c.hits[key] = 0
+			c.routes = append(c.routes, key)
+		}
+
+		copied := *rt
+		method := strings.ToUpper(rt.Method)
+		copied.Middlewares = append([]func(http.Handler) http.Handler{func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == method {
+					c.hit(key)
+				}
+				next.ServeHTTP(w, r)
+			})
+		}}, rt.Middlewares...)
+		instrumented = append(instrumented, &copied)
+	}
+
+	return instrumented
+}
+
+func (c *RouteCoverage) hit(key string) {
+	c.mutex.Lock()
+	c.hits[key]++
+	c.mutex.Unlock()
+}
+
+// Hits returns the number of the hits of the route like "GET /users/:id".
+func (c *RouteCoverage) Hits(route string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.hits[route]
+}
+
+// Ratio is 1 if there is no route.
+func (c *RouteCoverage) Ratio() float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.routes) == 0 {
+		return 1
+	}
+
+	covered := 0
+	for _, key := range c.routes {
+		if c.hits[key] > 0 {
+			covered++
+		}
+	}
+
+	return float64(covered) / float64(len(c.routes))
+}
+
+// Missed returns the routes not hit in order.
+func (c *RouteCoverage) Missed() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var missed []string
+	for _, key := range c.routes {
+		if c.hits[key] == 0 {
+			missed = append(missed, key)
+		}
+	}
+
+	sort.Strings(missed)
+	return missed
+}
+
+// Verify returns an error listing the missed routes if the ratio is below min.
+func (c *RouteCoverage) Verify(min float64) error {
+	ratio := c.Ratio()
+	if ratio >= min {
+		return nil
+	}
+
+	return fmt.Errorf("route coverage %.1f%% is below %.1f%%, missed:\n  %s", ratio*100, min*100,
+		strings.Join(c.Missed(), "\n  "))
+}
+
+// Check fails the test if the ratio is below min, for checking the coverage of a group of tests.
+func (c *RouteCoverage) Check(tb testing.TB, min float64) {
+	tb.Helper()
+	if err := c.Verify(min); err != nil {
+		tb.Error(err)
+	}
+}
@@ -0,0 +1,55 @@
+package apitest
+
+import (
+	"github.com/abadcafe/apihttpwrapper"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRouteCoverage(t *testing.T) {
+	method := func(ctx *apihttpwrapper.ServiceMethodContext, arg *struct{}) error {
+		return nil
+	}
+	routes := []*apihttpwrapper.Route{
+		{Method: "GET", Path: "/users", Function: method},
+		{Method: "POST", Path: "/users", Function: method, Preflight: true},
+		{Method: "GET", Path: "/users/:id", Function: method},
+		{Method: "DELETE", Path: "/users/:id", Function: method},
+	}
+
+	coverage := NewRouteCoverage()
+	router, err := apihttpwrapper.NewHTTPRouter(coverage.Instrument(routes))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes[0].Middlewares) != 0 {
+		t.Error("the original route is changed")
+	}
+
+	for _, r := range []struct{ method, path string }{
+		{"GET", "/users"}, {"GET", "/users/1"}, {"GET", "/users/2"}, {"OPTIONS", "/users"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(r.method, r.path, nil))
+	}
+
+	if coverage.Hits("GET /users/:id") != 2 || coverage.Hits("POST /users") != 0 || coverage.Ratio() != 0.5 {
+		t.Error(coverage.Hits("GET /users/:id"), coverage.Hits("POST /users"), coverage.Ratio())
+	}
+
+	if !reflect.DeepEqual(coverage.Missed(), []string{"DELETE /users/:id", "POST /users"}) {
+		t.Error(coverage.Missed())
+	}
+
+	if err := coverage.Verify(0.5); err != nil {
+		t.Error(err)
+	}
+
+	if err := coverage.Verify(0.75); err == nil || !strings.Contains(err.Error(), "DELETE /users/:id") {
+		t.Error(err)
+	}
+
+	coverage.Check(t, 0.5)
+}
@@ -0,0 +1,73 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"github.com/gorilla/schema"
+	"sort"
+	"strings"
+)
+
+// ArgumentError describes a field failed to be parsed, like the query parameter or the JSON path.
+type ArgumentError struct {
+	Field  string         `json:"field" xml:"field"`
+	Source ArgumentSource `json:"source" xml:"source"`
+	Error  string         `json:"error" xml:"error"`
+}
+
+// ArgumentErrors are the details of the 422 response, see SetStructuredParseErrors.
+type ArgumentErrors []*ArgumentError
+
+func (e ArgumentErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fe := range e {
+		messages = append(messages, string(fe.Source)+" "+fe.Field+": "+fe.Error)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// SetStructuredParseErrors responds the parse failures with 422 and ArgumentErrors instead of 400.
+func (h *ServiceHandler) SetStructuredParseErrors(enabled bool) {
+	h.structuredParseErrors = enabled
+}
+
+// argumentErrors returns nil if err is not of the fields.
+func argumentErrors(source ArgumentSource, err error) ArgumentErrors {
+	switch e := err.(type) {
+	case schema.MultiError:
+		result := make(ArgumentErrors, 0, len(e))
+		for key, fieldError := range e {
+			message := fieldError.Error()
+			if conversion, ok := fieldError.(schema.ConversionError); ok && conversion.Err != nil {
+				message = conversion.Err.Error()
+			} else if ok {
+				message = "invalid " + conversion.Type.String()
+			}
+
+			result = append(result, &ArgumentError{Field: key, Source: source, Error: message})
+		}
+
+		// the map has no order.
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].Field < result[j].Field
+		})
+		return result
+	case *json.UnmarshalTypeError:
+		return ArgumentErrors{{Field: e.Field, Source: source,
+			Error: "cannot use " + e.Value + " as " + e.Type.String()}}
+	}
+
+	return nil
+}
+
+// parseFailedResponse is of the errors of parseArgument.
+func parseFailedResponse(err error) *FormattedResponse {
+	switch e := err.(type) {
+	case CSVRowErrors:
+		return &FormattedResponse{400, "parse argument failed", e}
+	case ArgumentErrors:
+		return &FormattedResponse{422, "parse argument failed", e}
+	}
+
+	return &FormattedResponse{400, "parse argument failed", err.Error()}
+}
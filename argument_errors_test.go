@@ -0,0 +1,83 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStructuredParseErrors(t *testing.T) {
+	type itemArguments struct {
+		ID    int    `schema:"id"`
+		Limit int    `schema:"limit"`
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+
+	method := func(ctx *ServiceMethodContext, arg *itemArguments) error {
+		return nil
+	}
+
+	router, err := NewHTTPRouterWithOptions([]*Route{{Method: "PUT", Path: "/items/:id", Function: method}},
+		&RouterOptions{StructuredParseErrors: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path   string
+		body   string
+		code   int
+		errors ArgumentErrors
+	}{
+		{"/items/1?limit=x&id=1", `{}`, 422, ArgumentErrors{{Field: "limit", Source: ArgumentSourceForm}}},
+		{"/items/1", `{"count":"many"}`, 422, ArgumentErrors{{Field: "count", Source: ArgumentSourceBody,
+			Error: "cannot use string as int"}}},
+		{"/items/abc", `{}`, 422, ArgumentErrors{{Field: "id", Source: ArgumentSourcePath}}},
+		{"/items/1", `{"count":`, 400, nil},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", c.path, strings.NewReader(c.body))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, r)
+
+		resp := &struct {
+			Data ArgumentErrors `json:"data"`
+		}{}
+		if w.Code != c.code {
+			t.Error(c.path, w.Code, w.Body.String())
+			continue
+		}
+
+		if c.errors == nil {
+			continue
+		}
+
+		if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil || len(resp.Data) != len(c.errors) {
+			t.Error(c.path, w.Body.String(), err)
+			continue
+		}
+
+		for i, expect := range c.errors {
+			actual := resp.Data[i]
+			if actual.Field != expect.Field || actual.Source != expect.Source || actual.Error == "" ||
+				(expect.Error != "" && actual.Error != expect.Error) {
+				t.Error(c.path, actual)
+			}
+		}
+	}
+
+	legacy, err := NewHTTPRouter([]*Route{{Method: "PUT", Path: "/items/:id", Function: method}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	legacy.ServeHTTP(w, httptest.NewRequest("PUT", "/items/abc", nil))
+	if w.Code != 400 {
+		t.Error("the parse failure is not 400 by default", w.Code)
+	}
+}
@@ -0,0 +1,54 @@
+package apihttpwrapper
+
+import (
+	"fmt"
+)
+
+// ArgumentSource is where the argument fields are bound from, see SetArgumentSources.
+type ArgumentSource string
+
+const (
+	// ArgumentSourceForm is the query string, the form body and the multipart uploads.
+	ArgumentSourceForm ArgumentSource = "form"
+	// ArgumentSourceBody is the JSON or csv body.
+	ArgumentSourceBody ArgumentSource = "body"
+	// ArgumentSourceHeader and ArgumentSourceCookie are the fields having the header and cookie tags.
+	ArgumentSourceHeader ArgumentSource = "header"
+	ArgumentSourceCookie ArgumentSource = "cookie"
+	// ArgumentSourceExtension is the values of the ArgumentParserExtensions.
+	ArgumentSourceExtension ArgumentSource = "extension"
+	// ArgumentSourcePath is the params in the url pattern.
+	ArgumentSourcePath ArgumentSource = "path"
+)
+
+// DefaultArgumentSources is the lowest first, the path params override all others.
+var DefaultArgumentSources = []ArgumentSource{ArgumentSourceForm, ArgumentSourceBody, ArgumentSourceHeader,
+	ArgumentSourceCookie, ArgumentSourceExtension, ArgumentSourcePath}
+
+// SetArgumentSources sets the sources bound, the later ones override, empty means DefaultArgumentSources.
+func (h *ServiceHandler) SetArgumentSources(sources ...ArgumentSource) error {
+	if len(sources) == 0 {
+		h.argumentSources = DefaultArgumentSources
+		return nil
+	}
+
+	seen := make(map[ArgumentSource]bool, len(sources))
+	for _, source := range sources {
+		known := false
+		for _, s := range DefaultArgumentSources {
+			known = known || s == source
+		}
+
+		if !known {
+			return fmt.Errorf("unknown argument source %q", source)
+		}
+
+		if seen[source] {
+			return fmt.Errorf("duplicate argument source %q", source)
+		}
+		seen[source] = true
+	}
+
+	h.argumentSources = sources
+	return nil
+}
@@ -0,0 +1,86 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestArgumentSources(t *testing.T) {
+	type arguments struct {
+		ID   string `json:"id" schema:"id"`
+		Name string `json:"name" schema:"name"`
+	}
+
+	cases := []struct {
+		sources []ArgumentSource
+		id      string
+		name    string
+	}{
+		{nil, "path", "body"},
+		{[]ArgumentSource{ArgumentSourceForm, ArgumentSourcePath, ArgumentSourceBody}, "body", "body"},
+		{[]ArgumentSource{ArgumentSourceBody, ArgumentSourceForm}, "query", "query"},
+		{[]ArgumentSource{ArgumentSourcePath}, "path", ""},
+	}
+
+	for i, c := range cases {
+		var got *arguments
+		router, err := NewHTTPRouter([]*Route{{Method: "POST", Path: "/items/:id", ArgumentSources: c.sources,
+			Function: func(ctx *ServiceMethodContext, arg *arguments) error {
+				got = arg
+				return nil
+			}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := httptest.NewRequest("POST", "/items/path?id=query&name=query", strings.NewReader(
+			`{"id":"body","name":"body"}`))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != 200 || got == nil || got.ID != c.id || got.Name != c.name {
+			t.Error(i, w.Code, w.Body.String(), got)
+		}
+	}
+
+	// the subscription methods bind the arguments by the sources of the route too.
+	for _, c := range []struct {
+		sources []ArgumentSource
+		id      string
+	}{{nil, "1"}, {[]ArgumentSource{ArgumentSourceForm}, "query"}} {
+		var topic string
+		router, err := NewHTTPRouter([]*Route{{Method: "GET", Path: "/items/:id/events", ArgumentSources: c.sources,
+			EventStream: &EventStream{Subscriber: closedSubscriber{}},
+			Function: func(ctx *ServiceMethodContext, arg *arguments) (EventFilter, error) {
+				topic = arg.ID
+				return nil, nil
+			}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items/1/events?id=query", nil))
+		if topic != c.id {
+			t.Error("the subscription argument is not bound", c.sources, topic)
+		}
+	}
+
+	handler, _ := NewServiceHandler(func(ctx *ServiceMethodContext, arg *arguments) error {
+		return nil
+	}, nil, false)
+	for _, sources := range [][]ArgumentSource{{"query"}, {ArgumentSourcePath, ArgumentSourcePath}} {
+		if err := handler.SetArgumentSources(sources...); err == nil {
+			t.Error("the invalid sources are accepted", sources)
+		}
+	}
+}
+
+type closedSubscriber struct{}
+
+func (closedSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *Event, error) {
+	events := make(chan *Event)
+	close(events)
+	return events, nil
+}
@@ -0,0 +1,108 @@
+package apihttpwrapper
+
+import (
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ValidationError describes a field failed the validate tag, the value is not included.
+type ValidationError struct {
+	Field string `json:"field" xml:"field"`
+	Tag   string `json:"tag" xml:"tag"`
+	Param string `json:"param,omitempty" xml:"param,omitempty"`
+}
+
+// ValidationErrors are the details of the 400 response when the argument failed the validation.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fe := range e {
+		messages = append(messages, fe.Field+" failed on "+fe.Tag)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+var defaultValidator = validator.New()
+
+// SetValidator replaces the validator checking the validate tags of the argument, nil disables the validation.
+func (h *ServiceHandler) SetValidator(validate *validator.Validate) {
+	h.validator = validate
+}
+
+func validationErrors(err error, prefix string) (ValidationErrors, error) {
+	fieldErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return nil, err
+	}
+
+	result := make(ValidationErrors, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		// the namespace starts with the struct name, which is meaningless to the clients.
+		field := fe.StructNamespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+
+		result = append(result, &ValidationError{
+			Field: prefix + field,
+			Tag:   fe.Tag(),
+			Param: fe.Param(),
+		})
+	}
+
+	return result, nil
+}
+
+// validateArgument checks the struct argument or the structs in the slice argument, the other arguments are skipped.
+func (h *ServiceHandler) validateArgument(arg reflect.Value) error {
+	if h.validator == nil {
+		return nil
+	}
+
+	v := reflect.Indirect(arg)
+	switch {
+	case v.Kind() == reflect.Struct:
+		if err := h.validator.Struct(v.Interface()); err != nil {
+			errs, err := validationErrors(err, "")
+			if err != nil {
+				return err
+			}
+			return errs
+		}
+	case v.Kind() == reflect.Slice:
+		var result ValidationErrors
+		for i := 0; i < v.Len(); i++ {
+			element := reflect.Indirect(v.Index(i))
+			if element.Kind() != reflect.Struct {
+				continue
+			}
+
+			if err := h.validator.Struct(element.Interface()); err != nil {
+				errs, err := validationErrors(err, "["+strconv.Itoa(i)+"].")
+				if err != nil {
+					return err
+				}
+				result = append(result, errs...)
+			}
+		}
+
+		if len(result) > 0 {
+			return result
+		}
+	}
+
+	return nil
+}
+
+func validationFailedResponse(err error) *FormattedResponse {
+	var data interface{} = err.Error()
+	if validationErrors, ok := err.(ValidationErrors); ok {
+		data = validationErrors
+	}
+
+	return &FormattedResponse{400, "validate argument failed", data}
+}
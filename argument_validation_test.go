@@ -0,0 +1,56 @@
+package apihttpwrapper
+
+import (
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"testing"
+)
+
+func TestValidateArgument(t *testing.T) {
+	type item struct {
+		Name  string `validate:"required"`
+		Count int    `validate:"gt=0"`
+	}
+
+	type argument struct {
+		ID    int `validate:"required,gt=0"`
+		Inner *item
+	}
+
+	h, err := NewServiceHandler(func(*ServiceMethodContext, *argument) error { return nil }, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("struct", func(t *testing.T) {
+		err := h.validateArgument(reflect.ValueOf(&argument{Inner: &item{Name: "a"}}))
+		errs, ok := err.(ValidationErrors)
+		if !ok || len(errs) != 2 || errs[0].Field != "ID" || errs[0].Tag != "required" ||
+			errs[1].Field != "Inner.Count" || errs[1].Tag != "gt" || errs[1].Param != "0" {
+			t.Error(err)
+		}
+
+		if err := h.validateArgument(reflect.ValueOf(&argument{ID: 1})); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("slice", func(t *testing.T) {
+		err := h.validateArgument(reflect.ValueOf([]*item{{"a", 1}, {"", 1}}))
+		errs, ok := err.(ValidationErrors)
+		if !ok || len(errs) != 1 || errs[0].Field != "[1].Name" {
+			t.Error(err)
+		}
+	})
+
+	t.Run("custom validator", func(t *testing.T) {
+		validate := validator.New()
+		validate.SetTagName("check")
+		h.SetValidator(validate)
+		defer h.SetValidator(defaultValidator)
+
+		if err := h.validateArgument(reflect.ValueOf(&argument{})); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// jwksMinRefreshInterval limits the refetching on the unknown key ids, so the forged tokens can't flood the endpoint.
+const jwksMinRefreshInterval = 10 * time.Second
+
+// JWKS caches the RSA public keys of a JWKS endpoint by the key ids.
+type JWKS struct {
+	url             string
+	refreshInterval time.Duration
+	client          *http.Client
+
+	mutex   sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func NewJWKS(url string, refreshInterval time.Duration) *JWKS {
+	return &JWKS{
+		url:             url,
+		refreshInterval: refreshInterval,
+		client:          &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Key returns the RSA public key of the key id.
+func (s *JWKS) Key(kid string) (*rsa.PublicKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, ok := s.keys[kid]
+	since := time.Now().Sub(s.fetched)
+	if (ok && since < s.refreshInterval) || (!ok && since < jwksMinRefreshInterval) {
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key, nil
+	}
+
+	keys, err := s.fetch()
+	if err != nil {
+		// the cached key is still better than failing all requests while the endpoint is down.
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	s.keys, s.fetched = keys, time.Now()
+	if key, ok = keys[kid]; !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	return key, nil
+}
+
+func (s *JWKS) fetch() (map[string]*rsa.PublicKey, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks failed: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %s", k.Kid, err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %s", k.Kid, err)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	return keys, nil
+}
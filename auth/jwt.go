@@ -0,0 +1,136 @@
+// Package auth provides the authentication middlewares attaching the principals to the requests.
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"github.com/abadcafe/apihttpwrapper"
+	"github.com/golang-jwt/jwt/v4"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// JWTOptions are the settings of verifying the bearer tokens, at least one of the keys should be set.
+type JWTOptions struct {
+	// HMACSecret verifies the HS256, HS384 and HS512 tokens.
+	HMACSecret []byte
+	// RSAPublicKey verifies the RS256, RS384 and RS512 tokens without the kid header.
+	RSAPublicKey *rsa.PublicKey
+	// JWKS verifies the RS256, RS384 and RS512 tokens by the kid header.
+	JWKS *JWKS
+	// Issuer and Audience are checked if not empty.
+	Issuer   string
+	Audience string
+	// Leeway tolerates the clock skew of checking exp and nbf.
+	Leeway time.Duration
+	// Optional passes the requests without the Authorization header through, they have no principal.
+	Optional bool
+}
+
+type jwtVerifier struct {
+	options *JWTOptions
+	parser  *jwt.Parser
+}
+
+// NewJWTMiddleware rejects the requests without the valid "Authorization: Bearer" tokens with 401.
+func NewJWTMiddleware(options *JWTOptions) (func(http.Handler) http.Handler, error) {
+	var methods []string
+	if options.HMACSecret != nil {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+
+	if options.RSAPublicKey != nil || options.JWKS != nil {
+		methods = append(methods, "RS256", "RS384", "RS512")
+	}
+
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("the jwt middleware needs a key")
+	}
+
+	v := &jwtVerifier{
+		options: options,
+		// the time based claims are checked with the leeway by verify.
+		parser: &jwt.Parser{ValidMethods: methods, SkipClaimsValidation: true},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" && options.Optional {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+				unauthorized(w, fmt.Errorf("no bearer token"))
+				return
+			}
+
+			principal, err := v.verify(header[7:])
+			if err != nil {
+				unauthorized(w, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(apihttpwrapper.NewPrincipalContext(r.Context(), principal)))
+		})
+	}, nil
+}
+
+func (v *jwtVerifier) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return v.options.HMACSecret, nil
+	case *jwt.SigningMethodRSA:
+		if kid, ok := token.Header["kid"].(string); ok && v.options.JWKS != nil {
+			return v.options.JWKS.Key(kid)
+		}
+
+		if v.options.RSAPublicKey == nil {
+			return nil, fmt.Errorf("no key for the token")
+		}
+		return v.options.RSAPublicKey, nil
+	}
+
+	return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+}
+
+func (v *jwtVerifier) verify(token string) (*apihttpwrapper.Principal, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !claims.VerifyExpiresAt(now.Add(-v.options.Leeway).Unix(), false) {
+		return nil, fmt.Errorf("the token is expired")
+	}
+
+	if !claims.VerifyNotBefore(now.Add(v.options.Leeway).Unix(), false) {
+		return nil, fmt.Errorf("the token is not valid yet")
+	}
+
+	if v.options.Issuer != "" && !claims.VerifyIssuer(v.options.Issuer, true) {
+		return nil, fmt.Errorf("unexpected issuer")
+	}
+
+	if v.options.Audience != "" && !claims.VerifyAudience(v.options.Audience, true) {
+		return nil, fmt.Errorf("unexpected audience")
+	}
+
+	subject, _ := claims["sub"].(string)
+	return &apihttpwrapper.Principal{Subject: subject, Claims: claims}, nil
+}
+
+func unauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	_ = json.NewEncoder(w).Encode(&apihttpwrapper.FormattedResponse{
+		Code: http.StatusUnauthorized,
+		Msg:  "authentication failed",
+		Data: err.Error(),
+	})
+}
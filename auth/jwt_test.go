@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/abadcafe/apihttpwrapper"
+	"github.com/golang-jwt/jwt/v4"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJWTMiddleware(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []interface{}{map[string]string{
+			"kty": "RSA",
+			"kid": "k1",
+			"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+		}}})
+	}))
+	defer jwksServer.Close()
+
+	secret := []byte("secret")
+	middleware, err := NewJWTMiddleware(&JWTOptions{
+		HMACSecret: secret,
+		JWKS:       NewJWKS(jwksServer.URL, time.Hour),
+		Audience:   "api",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router, err := apihttpwrapper.NewHTTPRouter([]*apihttpwrapper.Route{{
+		Method: "GET",
+		Path:   "/me",
+		Function: func(ctx *apihttpwrapper.ServiceMethodContext, arg *struct{}) (*struct{ Subject string }, error) {
+			return &struct{ Subject string }{ctx.Principal.Subject}, nil
+		},
+		Middlewares: []func(http.Handler) http.Handler{middleware},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + signed
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+	cases := []struct {
+		authorization string
+		status        int
+		body          string
+	}{
+		{sign(jwt.SigningMethodHS256, "", secret, jwt.MapClaims{"sub": "alice", "aud": "api", "exp": exp}), 200,
+			"{\"Subject\":\"alice\"}\n"},
+		{sign(jwt.SigningMethodRS256, "k1", rsaKey, jwt.MapClaims{"sub": "bob", "aud": "api"}), 200,
+			"{\"Subject\":\"bob\"}\n"},
+		{sign(jwt.SigningMethodRS256, "k2", rsaKey, jwt.MapClaims{"sub": "bob", "aud": "api"}), 401, ""},
+		{sign(jwt.SigningMethodHS256, "", []byte("forged"), jwt.MapClaims{"sub": "alice", "aud": "api"}), 401, ""},
+		{sign(jwt.SigningMethodHS256, "", secret, jwt.MapClaims{"sub": "alice", "aud": "other"}), 401, ""},
+		{sign(jwt.SigningMethodHS256, "", secret, jwt.MapClaims{"sub": "alice", "aud": "api", "exp": 1}), 401, ""},
+		{"", 401, ""},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/me", nil)
+		if c.authorization != "" {
+			r.Header.Set("Authorization", c.authorization)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		if recorder.Code != c.status || (c.body != "" && recorder.Body.String() != c.body) {
+			t.Error(c.authorization, recorder.Code, recorder.Body.String())
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Command benchgate compares the output of `go test -bench -benchmem` with the baseline, and exits with 1 if any
+// benchmark regresses beyond the thresholds, or is missing. the output of the benchmarks is read from stdin:
+//
+//	go test -run '^$' -bench . -benchmem -count 5 ./benchmarks | benchgate -baseline benchmarks/baseline.txt
+//
+// the medians of the counts are compared. -update writes the medians into the baseline instead.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// result is of a benchmark, the values are the medians of the runs.
+type result struct {
+	nsPerOp     float64
+	bytesPerOp  float64
+	allocsPerOp float64
+}
+
+// benchmarkLine matches the lines of -benchmem, the name is without the GOMAXPROCS suffix.
+var benchmarkLine = regexp.MustCompile(`^(Benchmark\S+?)(?:-\d+)?\s+\d+\s+([0-9.]+) ns/op` +
+	`(?:\s+([0-9.]+) B/op\s+([0-9.]+) allocs/op)?`)
+
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	if n := len(values); n%2 == 1 {
+		return values[n/2]
+	} else if n > 0 {
+		return (values[n/2-1] + values[n/2]) / 2
+	}
+
+	return 0
+}
+
+func parseResults(r io.Reader) (map[string]*result, error) {
+	ns, bytes, allocs := make(map[string][]float64), make(map[string][]float64), make(map[string][]float64)
+	var names []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		match := benchmarkLine.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if match == nil {
+			continue
+		}
+
+		name := match[1]
+		if _, ok := ns[name]; !ok {
+			names = append(names, name)
+		}
+
+		nsPerOp, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		ns[name] = append(ns[name], nsPerOp)
+
+		if match[3] != "" {
+			bytesPerOp, err := strconv.ParseFloat(match[3], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err)
+			}
+			bytes[name] = append(bytes[name], bytesPerOp)
+
+			allocsPerOp, err := strconv.ParseFloat(match[4], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err)
+			}
+			allocs[name] = append(allocs[name], allocsPerOp)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]*result, len(names))
+	for _, name := range names {
+		results[name] = &result{nsPerOp: median(ns[name]), bytesPerOp: median(bytes[name]),
+			allocsPerOp: median(allocs[name])}
+	}
+
+	return results, nil
+}
+
+func formatResults(results map[string]*result) string {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s\t1\t%.0f ns/op\t%.0f B/op\t%.0f allocs/op\n", name, results[name].nsPerOp,
+			results[name].bytesPerOp, results[name].allocsPerOp)
+	}
+
+	return b.String()
+}
+
+// compare returns the regressions, maxNs and maxAllocs are the ratios allowed, like 1.5 for 50% slower. ns/op is noisy
+// on the shared machines, so its threshold is loose, while the allocations are deterministic. B/op is not compared,
+// it's in the baseline for the reviews.
+func compare(baseline map[string]*result, current map[string]*result, maxNs float64, maxAllocs float64) []string {
+	names := make([]string, 0, len(baseline))
+	for name := range baseline {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var regressions []string
+	for _, name := range names {
+		base, cur := baseline[name], current[name]
+		if cur == nil {
+			regressions = append(regressions, fmt.Sprintf("%s: missing", name))
+			continue
+		}
+
+		if base.nsPerOp > 0 && cur.nsPerOp > base.nsPerOp*maxNs {
+			regressions = append(regressions, fmt.Sprintf("%s: %.0f ns/op, %.0f in the baseline (+%.0f%%)", name,
+				cur.nsPerOp, base.nsPerOp, (cur.nsPerOp/base.nsPerOp-1)*100))
+		}
+
+		// the allocations are deterministic, the small counts are allowed one more.
+		if cur.allocsPerOp > base.allocsPerOp*maxAllocs && cur.allocsPerOp > base.allocsPerOp+1 {
+			regressions = append(regressions, fmt.Sprintf("%s: %.0f allocs/op, %.0f in the baseline", name,
+				cur.allocsPerOp, base.allocsPerOp))
+		}
+	}
+
+	return regressions
+}
+
+func main() {
+	baselinePath := flag.String("baseline", "benchmarks/baseline.txt", "the baseline file")
+	maxNs := flag.Float64("max-ns", 1.5, "the ratio of ns/op allowed to the baseline")
+	maxAllocs := flag.Float64("max-allocs", 1.1, "the ratio of allocs/op allowed to the baseline")
+	update := flag.Bool("update", false, "write the results into the baseline instead of comparing")
+	flag.Parse()
+
+	current, err := parseResults(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if len(current) == 0 {
+		fmt.Fprintln(os.Stderr, "no benchmark results in the input")
+		os.Exit(2)
+	}
+
+	if *update {
+		if err := ioutil.WriteFile(*baselinePath, []byte(formatResults(current)), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	file, err := os.Open(*baselinePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defer file.Close()
+
+	baseline, err := parseResults(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	regressions := compare(baseline, current, *maxNs, *maxAllocs)
+	for _, regression := range regressions {
+		fmt.Println(regression)
+	}
+
+	if len(regressions) > 0 {
+		os.Exit(1)
+	}
+
+	fmt.Printf("%d benchmarks are within the baseline\n", len(baseline))
+}
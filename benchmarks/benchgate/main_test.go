@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	baseline, err := parseResults(strings.NewReader("BenchmarkBinding/query/small\t1\t1000 ns/op\t0 B/op\t" +
+		"10 allocs/op\nBenchmarkBinding/body/small\t1\t1000 ns/op\t0 B/op\t2 allocs/op\n"))
+	if err != nil || len(baseline) != 2 {
+		t.Fatal(baseline, err)
+	}
+
+	current, err := parseResults(strings.NewReader(`goos: linux
+BenchmarkBinding/query/small-8   	  100000	      1100 ns/op	    3136 B/op	      10 allocs/op
+BenchmarkBinding/query/small-8   	  100000	      2000 ns/op	    3136 B/op	      10 allocs/op
+BenchmarkBinding/query/small-8   	  100000	      1200 ns/op	    3136 B/op	      10 allocs/op
+BenchmarkBinding/body/small-8    	  100000	      1000 ns/op	    2296 B/op	       3 allocs/op
+PASS`))
+	if err != nil || current["BenchmarkBinding/query/small"].nsPerOp != 1200 {
+		t.Fatal(current, err)
+	}
+
+	if regressions := compare(baseline, current, 1.5, 1.1); len(regressions) != 0 {
+		t.Error("the median within the thresholds regresses", regressions)
+	}
+
+	current["BenchmarkBinding/body/small"].allocsPerOp = 4
+	delete(current, "BenchmarkBinding/query/small")
+	if regressions := compare(baseline, current, 1.5, 1.1); len(regressions) != 2 {
+		t.Error(regressions)
+	}
+
+	if formatted, _ := parseResults(strings.NewReader(formatResults(baseline))); len(formatted) != 2 ||
+		formatted["BenchmarkBinding/body/small"].allocsPerOp != 2 {
+		t.Error("the formatted baseline can't be parsed", formatted)
+	}
+}
@@ -0,0 +1,173 @@
+package benchmarks
+
+import (
+	"github.com/abadcafe/apihttpwrapper"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type smallArguments struct {
+	ID    int    `schema:"id" json:"id"`
+	Name  string `schema:"name" json:"name"`
+	Admin bool   `schema:"admin" json:"admin"`
+}
+
+type mediumArguments struct {
+	ID      int      `schema:"id" json:"id"`
+	Name    string   `schema:"name" json:"name"`
+	Email   string   `schema:"email" json:"email"`
+	Country string   `schema:"country" json:"country"`
+	City    string   `schema:"city" json:"city"`
+	Zip     string   `schema:"zip" json:"zip"`
+	Age     int      `schema:"age" json:"age"`
+	Score   float64  `schema:"score" json:"score"`
+	Active  bool     `schema:"active" json:"active"`
+	Limit   int      `schema:"limit" json:"limit"`
+	Offset  int      `schema:"offset" json:"offset"`
+	Tags    []string `schema:"tags" json:"tags"`
+}
+
+type largeItem struct {
+	SKU      string  `json:"sku"`
+	Quantity int     `json:"quantity"`
+	Price    float64 `json:"price"`
+}
+
+type largeArguments struct {
+	mediumArguments
+	Region    string            `schema:"region" json:"region"`
+	Zone      string            `schema:"zone" json:"zone"`
+	Team      string            `schema:"team" json:"team"`
+	Project   string            `schema:"project" json:"project"`
+	Owner     string            `schema:"owner" json:"owner"`
+	Priority  int               `schema:"priority" json:"priority"`
+	Weight    float64           `schema:"weight" json:"weight"`
+	Archived  bool              `schema:"archived" json:"archived"`
+	Notes     string            `schema:"notes" json:"notes"`
+	Ids       []int             `schema:"ids" json:"ids"`
+	Items     []largeItem       `schema:"-" json:"items"`
+	Labels    map[string]string `schema:"-" json:"labels"`
+	Reference string            `schema:"reference" json:"reference"`
+}
+
+func bindingRoute(path string, arg interface{}) *apihttpwrapper.Route {
+	var function interface{}
+	switch arg.(type) {
+	case *smallArguments:
+		function = func(ctx *apihttpwrapper.ServiceMethodContext, arg *smallArguments) error {
+			return nil
+		}
+	case *mediumArguments:
+		function = func(ctx *apihttpwrapper.ServiceMethodContext, arg *mediumArguments) error {
+			return nil
+		}
+	case *largeArguments:
+		function = func(ctx *apihttpwrapper.ServiceMethodContext, arg *largeArguments) error {
+			return nil
+		}
+	}
+
+	return &apihttpwrapper.Route{Method: "POST", Path: path, Function: function}
+}
+
+const (
+	smallQuery  = "id=1&name=alice&admin=true"
+	mediumQuery = "id=1&name=alice&email=alice%40example.com&country=cn&city=beijing&zip=100000&age=30&" +
+		"score=99.5&active=true&limit=20&offset=40&tags=a&tags=b&tags=c"
+	largeQuery = mediumQuery + "&region=north&zone=a&team=api&project=wrapper&owner=bob&priority=2&weight=0.5&" +
+		"archived=false&notes=benchmark&ids=1&ids=2&ids=3&ids=4&ids=5&reference=abc"
+
+	smallBody  = `{"id":1,"name":"alice","admin":true}`
+	mediumBody = `{"id":1,"name":"alice","email":"alice@example.com","country":"cn","city":"beijing",` +
+		`"zip":"100000","age":30,"score":99.5,"active":true,"limit":20,"offset":40,"tags":["a","b","c"]}`
+)
+
+var largeBody = strings.TrimSuffix(mediumBody, "}") + `,"region":"north","zone":"a","team":"api",` +
+	`"project":"wrapper","owner":"bob","priority":2,"weight":0.5,"archived":false,"notes":"benchmark",` +
+	`"ids":[1,2,3,4,5],"reference":"abc","labels":{"env":"prod","tier":"web"},"items":[` +
+	strings.TrimSuffix(strings.Repeat(`{"sku":"sku-1","quantity":2,"price":9.99},`, 20), ",") + `]}`
+
+type bindingCase struct {
+	name    string
+	pattern string
+	target  string
+	body    string
+	arg     interface{}
+}
+
+var bindingCases = []*bindingCase{
+	{"query/small", "/query/small", "/query/small?" + smallQuery, "", &smallArguments{}},
+	{"query/medium", "/query/medium", "/query/medium?" + mediumQuery, "", &mediumArguments{}},
+	{"query/large", "/query/large", "/query/large?" + largeQuery, "", &largeArguments{}},
+	{"body/small", "/body/small", "/body/small", smallBody, &smallArguments{}},
+	{"body/medium", "/body/medium", "/body/medium", mediumBody, &mediumArguments{}},
+	{"body/large", "/body/large", "/body/large", largeBody, &largeArguments{}},
+	{"path/small", "/path/small/:id", "/path/small/1", "", &smallArguments{}},
+	{"path/medium", "/path/medium/:id/:name/:country/:city", "/path/medium/1/alice/cn/beijing", "",
+		&mediumArguments{}},
+	{"path/large", "/path/large/:id/:name/:country/:city/:region/:zone/:team/:project",
+		"/path/large/1/alice/cn/beijing/north/a/api/wrapper", "", &largeArguments{}},
+}
+
+// discardResponseWriter keeps the cost of recording the responses out of the benchmarks.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(int) {}
+
+func BenchmarkBinding(b *testing.B) {
+	routes := make([]*apihttpwrapper.Route, 0, len(bindingCases))
+	for _, c := range bindingCases {
+		routes = append(routes, bindingRoute(c.pattern, c.arg))
+	}
+
+	router, err := apihttpwrapper.NewHTTPRouter(routes)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, c := range bindingCases {
+		c := c
+		b.Run(c.name, func(b *testing.B) {
+			body := strings.NewReader(c.body)
+			r := httptest.NewRequest("POST", c.target, body)
+			if c.body != "" {
+				r.Header.Set("Content-Type", "application/json")
+			} else {
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+
+			// the status is checked once, the failures would be much cheaper than the bindings.
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, r)
+			if recorder.Code != http.StatusOK {
+				b.Fatal(recorder.Code, recorder.Body.String())
+			}
+
+			w := &discardResponseWriter{header: make(http.Header)}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				body.Reset(c.body)
+				r.Body = ioutil.NopCloser(body)
+				r.Form, r.PostForm = nil, nil
+				for k := range w.header {
+					delete(w.header, k)
+				}
+				router.ServeHTTP(w, r)
+			}
+		})
+	}
+}
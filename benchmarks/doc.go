@@ -0,0 +1,2 @@
+// Package benchmarks has the benchmarks of binding the arguments, see `make bench-gate`.
+package benchmarks
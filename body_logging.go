@@ -0,0 +1,197 @@
+package apihttpwrapper
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// DefaultBodyLoggingMaxBytes caps each logged body if BodyLogging.MaxBytes is 0.
+	DefaultBodyLoggingMaxBytes = 4 << 10
+	// MaxBodyLoggingBytes is the hard cap of the logged bodies, the larger BodyLogging.MaxBytes is lowered to it.
+	MaxBodyLoggingBytes = 64 << 10
+)
+
+// DefaultRedactedBodyFields are always redacted from the logged bodies.
+var DefaultRedactedBodyFields = []string{"password", "passwd", "secret", "token", "access_token", "refresh_token",
+	"authorization", "api_key", "apikey"}
+
+// BodyLogging records the raw bodies of the route as "reqBody" and "respBody", see SetEnabled.
+type BodyLogging struct {
+	// MaxBytes caps each body, DefaultBodyLoggingMaxBytes if 0.
+	MaxBytes int
+	// RedactedFields are redacted besides DefaultRedactedBodyFields, case insensitive.
+	RedactedFields []string
+
+	enabled   int32
+	redacting atomic.Value
+}
+
+func (b *BodyLogging) SetEnabled(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&b.enabled, value)
+}
+
+func (b *BodyLogging) Enabled() bool {
+	return b != nil && atomic.LoadInt32(&b.enabled) == 1
+}
+
+// ServeHTTP toggles it by POST or PUT with "enabled=true" or "enabled=false".
+func (b *BodyLogging) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	setResponseHeader(w)
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(&FormattedResponse{400, "parse enabled failed", err.Error()})
+			return
+		}
+		b.SetEnabled(enabled)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]bool{"enabled": b.Enabled()})
+}
+
+func (b *BodyLogging) maxBytes() int {
+	if b.MaxBytes <= 0 {
+		return DefaultBodyLoggingMaxBytes
+	}
+
+	if b.MaxBytes > MaxBodyLoggingBytes {
+		return MaxBodyLoggingBytes
+	}
+
+	return b.MaxBytes
+}
+
+// redactingPatterns match the values of the redacted fields in the JSON and form bodies, even the truncated ones.
+func (b *BodyLogging) redactingPatterns() []*regexp.Regexp {
+	if patterns, ok := b.redacting.Load().([]*regexp.Regexp); ok {
+		return patterns
+	}
+
+	var names []string
+	for _, field := range append(append([]string{}, DefaultRedactedBodyFields...), b.RedactedFields...) {
+		names = append(names, regexp.QuoteMeta(field))
+	}
+
+	fields := strings.Join(names, "|")
+	patterns := []*regexp.Regexp{
+		regexp.MustCompile(`(?i)("(?:` + fields + `)"\s*:\s*)("(?:[^"\\]|\\.)*("|$)|[^,}\]\s]+)`),
+		regexp.MustCompile(`(?i)((?:^|&)(?:` + fields + `)=)[^&]*`),
+	}
+	b.redacting.Store(patterns)
+	return patterns
+}
+
+func (b *BodyLogging) format(capture *bodyCapture) string {
+	body := capture.buf
+	patterns := b.redactingPatterns()
+	body = patterns[0].ReplaceAll(body, []byte(`$1"******"`))
+	body = patterns[1].ReplaceAll(body, []byte(`$1******`))
+	if capture.total > int64(len(capture.buf)) {
+		return fmt.Sprintf("%s...(truncated, %d bytes)", body, capture.total)
+	}
+
+	return string(body)
+}
+
+// bodyCapture keeps the first limit bytes written.
+type bodyCapture struct {
+	limit int
+	buf   []byte
+	total int64
+}
+
+func (c *bodyCapture) Write(p []byte) (int, error) {
+	c.total += int64(len(p))
+	if room := c.limit - len(c.buf); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		c.buf = append(c.buf, p[:room]...)
+	}
+
+	return len(p), nil
+}
+
+type captureReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type captureResponseWriter struct {
+	http.ResponseWriter
+	capture *bodyCapture
+}
+
+func (w *captureResponseWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	_, _ = w.capture.Write(p[:n])
+	return n, err
+}
+
+func (w *captureResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *captureResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *captureResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the response writer doesn't support hijacking")
+	}
+
+	return hijacker.Hijack()
+}
+
+// SetBodyLogging sets the switch of logging the raw bodies, see BodyLogging.
+func (h *ServiceHandler) SetBodyLogging(logging *BodyLogging) {
+	h.bodyLogging = logging
+}
+
+// captureBodies tees the request and response bodies if the body logging is enabled, the captures are nil if not.
+func (h *ServiceHandler) captureBodies(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *bodyCapture,
+	*bodyCapture) {
+	if !h.bodyLogging.Enabled() || h.loggerContextKey == nil {
+		return w, nil, nil
+	}
+
+	reqCapture := &bodyCapture{limit: h.bodyLogging.maxBytes()}
+	respCapture := &bodyCapture{limit: h.bodyLogging.maxBytes()}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = &captureReadCloser{io.TeeReader(r.Body, reqCapture), r.Body}
+	}
+
+	return &captureResponseWriter{w, respCapture}, reqCapture, respCapture
+}
+
+func (h *ServiceHandler) recordBodies(logger MethodLogger, reqCapture *bodyCapture, respCapture *bodyCapture) {
+	if reqCapture == nil {
+		return
+	}
+
+	logger.Record("reqBody", h.bodyLogging.format(reqCapture))
+	logger.Record("respBody", h.bodyLogging.format(respCapture))
+}
@@ -0,0 +1,68 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyLoggingFormat(t *testing.T) {
+	logging := &BodyLogging{MaxBytes: 50, RedactedFields: []string{"card"}}
+	cases := []struct {
+		body   string
+		logged string
+	}{
+		{`{"name":"a","password":"p\"w","card":1234}`, `{"name":"a","password":"******","card":"******"}`},
+		{`name=a&token=abc&x=1`, `name=a&token=******&x=1`},
+		{`{"name":"abcdefghijklmnopqrstuvwxyz","secret":"abcdefgh"}`,
+			`{"name":"abcdefghijklmnopqrstuvwxyz","secret":"******"...(truncated, 57 bytes)`},
+	}
+
+	for _, c := range cases {
+		capture := &bodyCapture{limit: logging.maxBytes()}
+		_, _ = capture.Write([]byte(c.body))
+		if logged := logging.format(capture); logged != c.logged {
+			t.Error(c.body, logged)
+		}
+	}
+}
+
+func TestBodyLogging(t *testing.T) {
+	logging := &BodyLogging{}
+	buffer := &bytes.Buffer{}
+	router, err := NewLoggingHTTPRouter([]*Route{{
+		Method: "POST",
+		Path:   "/",
+		Function: func(ctx *ServiceMethodContext, arg *struct{ A int }) (*struct{ B int }, error) {
+			return &struct{ B int }{arg.A}, nil
+		},
+		BodyLogging: logging,
+	}}, nil, buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func() string {
+		buffer.Reset()
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"A":1}`))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		return buffer.String()
+	}
+
+	if logs := do(); strings.Contains(logs, "reqBody") {
+		t.Error(logs)
+	}
+
+	recorder := httptest.NewRecorder()
+	logging.ServeHTTP(recorder, httptest.NewRequest("POST", "/?enabled=true", nil))
+	if recorder.Body.String() != "{\"enabled\":true}\n" {
+		t.Error(recorder.Body.String())
+	}
+
+	logs := do()
+	if !strings.Contains(logs, `reqBody="{\"A\":1}"`) || !strings.Contains(logs, `respBody="{\"B\":1}\n"`) {
+		t.Error(logs)
+	}
+}
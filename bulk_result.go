@@ -0,0 +1,82 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// BulkItemResult is the outcome of an item of a bulk operation, Index is the position of the item in the request.
+type BulkItemResult struct {
+	Index int         `json:"index" xml:"index"`
+	Code  int         `json:"code" xml:"code"`
+	Msg   string      `json:"msg" xml:"msg"`
+	Data  interface{} `json:"data,omitempty" xml:"data,omitempty"`
+}
+
+// BulkResult is the item results of the bulk operations, the status is 207 if any item failed.
+type BulkResult struct {
+	mu    sync.Mutex
+	items []*BulkItemResult
+}
+
+func NewBulkResult() *BulkResult {
+	return &BulkResult{}
+}
+
+// Succeed adds the result of a succeeded item.
+func (b *BulkResult) Succeed(index int, data interface{}) {
+	b.Add(&BulkItemResult{Index: index, Code: http.StatusOK, Msg: "ok", Data: data})
+}
+
+// Fail adds the result of a failed item, code should be the http status describing the failure.
+func (b *BulkResult) Fail(index int, code int, err error) {
+	b.Add(&BulkItemResult{Index: index, Code: code, Msg: err.Error()})
+}
+
+func (b *BulkResult) Add(item *BulkItemResult) {
+	b.mu.Lock()
+	b.items = append(b.items, item)
+	b.mu.Unlock()
+}
+
+// Items returns the item results ordered by index.
+func (b *BulkResult) Items() []*BulkItemResult {
+	b.mu.Lock()
+	items := append([]*BulkItemResult(nil), b.items...)
+	b.mu.Unlock()
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Index < items[j].Index })
+	return items
+}
+
+// Status is 207 if any item failed, otherwise 200.
+func (b *BulkResult) Status() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, item := range b.items {
+		if item.Code >= http.StatusBadRequest {
+			return http.StatusMultiStatus
+		}
+	}
+
+	return http.StatusOK
+}
+
+func (b *BulkResult) MarshalJSON() ([]byte, error) {
+	items := b.Items()
+	if items == nil {
+		items = []*BulkItemResult{}
+	}
+
+	return json.Marshal(items)
+}
+
+func (b *BulkResult) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		Items []*BulkItemResult `xml:"item"`
+	}{b.Items()}, start)
+}
@@ -0,0 +1,54 @@
+package apihttpwrapper
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBulkResult(t *testing.T) {
+	type item struct {
+		Name string
+	}
+
+	router, err := NewHTTPRouter([]*Route{{
+		Method: "POST",
+		Path:   "/import",
+		Function: func(_ *ServiceMethodContext, items []*item) (*BulkResult, error) {
+			result := NewBulkResult()
+			for i := len(items) - 1; i >= 0; i-- {
+				if items[i].Name == "" {
+					result.Fail(i, 422, errors.New("name is required"))
+				} else {
+					result.Succeed(i, nil)
+				}
+			}
+			return result, nil
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		body   string
+		status int
+		expect string
+	}{
+		{"[{\"Name\":\"a\"},{}]", 207,
+			"[{\"index\":0,\"code\":200,\"msg\":\"ok\"},{\"index\":1,\"code\":422,\"msg\":\"name is required\"}]\n"},
+		{"[{\"Name\":\"a\"}]", 200, "[{\"index\":0,\"code\":200,\"msg\":\"ok\"}]\n"},
+		{"[]", 200, "[]\n"},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", "/import", strings.NewReader(c.body))
+		r.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		if recorder.Code != c.status || recorder.Body.String() != c.expect {
+			t.Error(c.body, recorder.Code, recorder.Body.String())
+		}
+	}
+}
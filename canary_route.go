@@ -0,0 +1,121 @@
+package apihttpwrapper
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// CanaryRoute sends part of the traffic of a Route to an alternate function, by Header, Cookie or Percentage.
+type CanaryRoute struct {
+	// keep it first to be 64-bit aligned for atomic operations.
+	stats [2]canaryArmCounters
+
+	Function   interface{}
+	Percentage float64
+	Header     string
+	Cookie     string
+}
+
+type CanaryArmStats struct {
+	Requests      uint64
+	Errors        uint64
+	TotalDuration time.Duration
+}
+
+type canaryArmCounters struct {
+	requests uint64
+	errors   uint64
+	duration int64
+}
+
+const (
+	canaryArmStable = iota
+	canaryArmCanary
+)
+
+var canaryArmNames = [2]string{"stable", "canary"}
+
+// Stats returns the counters of both arms collected since the route has been registered.
+func (c *CanaryRoute) Stats() (stable CanaryArmStats, canary CanaryArmStats) {
+	load := func(counters *canaryArmCounters) CanaryArmStats {
+		return CanaryArmStats{
+			Requests:      atomic.LoadUint64(&counters.requests),
+			Errors:        atomic.LoadUint64(&counters.errors),
+			TotalDuration: time.Duration(atomic.LoadInt64(&counters.duration)),
+		}
+	}
+
+	return load(&c.stats[canaryArmStable]), load(&c.stats[canaryArmCanary])
+}
+
+func (c *CanaryRoute) forcedArm(r *http.Request) (arm int, forced bool) {
+	var value string
+	if c.Header != "" {
+		value = r.Header.Get(c.Header)
+	}
+
+	if value == "" && c.Cookie != "" {
+		if cookie, err := r.Cookie(c.Cookie); err == nil {
+			value = cookie.Value
+		}
+	}
+
+	if value == "" {
+		return canaryArmStable, false
+	}
+
+	toCanary, err := strconv.ParseBool(value)
+	if err != nil {
+		return canaryArmStable, false
+	}
+
+	if toCanary {
+		return canaryArmCanary, true
+	}
+
+	return canaryArmStable, true
+}
+
+func (c *CanaryRoute) pickArm(r *http.Request) int {
+	if arm, forced := c.forcedArm(r); forced {
+		return arm
+	}
+
+	if c.Percentage > 0 && rand.Float64()*100 < c.Percentage {
+		return canaryArmCanary
+	}
+
+	return canaryArmStable
+}
+
+func newCanaryHandle(c *CanaryRoute, stable *ServiceHandler, canary *ServiceHandler,
+	loggerContextKey interface{}) httprouter.Handle {
+	handlers := [2]*ServiceHandler{stable, canary}
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		arm := c.pickArm(r)
+		if loggerContextKey != nil {
+			if logger, ok := r.Context().Value(loggerContextKey).(MethodLogger); ok {
+				logger.Record("canaryArm", canaryArmNames[arm])
+			}
+		}
+
+		sw := &statusResponseWriter{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+		}
+
+		beginTime := time.Now()
+		handlers[arm].ServeHTTPWithParams(sw, r, params)
+
+		counters := &c.stats[arm]
+		atomic.AddUint64(&counters.requests, 1)
+		atomic.AddInt64(&counters.duration, int64(time.Now().Sub(beginTime)))
+		if sw.status >= http.StatusInternalServerError {
+			atomic.AddUint64(&counters.errors, 1)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package apihttpwrapper
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanaryRoute(t *testing.T) {
+	canary := &CanaryRoute{
+		Function: func(*ServiceMethodContext, *struct{}) (*struct{ Arm string }, error) {
+			return &struct{ Arm string }{"canary"}, nil
+		},
+		Header: "X-Canary",
+	}
+
+	router, err := NewHTTPRouter([]*Route{{
+		Method: "GET",
+		Path:   "/",
+		Function: func(*ServiceMethodContext, *struct{}) (*struct{ Arm string }, error) {
+			return &struct{ Arm string }{"stable"}, nil
+		},
+		Canary: canary,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(header string) string {
+		r := httptest.NewRequest("GET", "/", nil)
+		if header != "" {
+			r.Header.Set("X-Canary", header)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		return recorder.Body.String()
+	}
+
+	if body := do(""); body != "{\"Arm\":\"stable\"}\n" {
+		t.Error(body)
+	}
+
+	if body := do("true"); body != "{\"Arm\":\"canary\"}\n" {
+		t.Error(body)
+	}
+
+	canary.Percentage = 100
+	if body := do("false"); body != "{\"Arm\":\"stable\"}\n" {
+		t.Error(body)
+	}
+
+	if body := do(""); body != "{\"Arm\":\"canary\"}\n" {
+		t.Error(body)
+	}
+
+	stable, canaryStats := canary.Stats()
+	if stable.Requests != 2 || canaryStats.Requests != 2 || stable.Errors != 0 || canaryStats.Errors != 0 {
+		t.Error(stable, canaryStats)
+	}
+}
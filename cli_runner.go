@@ -0,0 +1,149 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CLIRunner invokes the routes from the command line, like '-X POST -d body.json /users/:id id=1'.
+type CLIRunner struct {
+	handler http.Handler
+	routes  []*Route
+}
+
+func NewCLIRunner(routes []*Route, logWriter io.Writer, options *RouterOptions) (*CLIRunner, error) {
+	handler, err := NewLoggingHTTPRouterWithOptions(routes, nil, logWriter, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CLIRunner{handler: handler, routes: routes}, nil
+}
+
+type cliHeaders http.Header
+
+func (h cliHeaders) String() string {
+	return fmt.Sprint(http.Header(h))
+}
+
+func (h cliHeaders) Set(value string) error {
+	i := strings.Index(value, ":")
+	if i <= 0 {
+		return fmt.Errorf("the header should be like 'Name: value'")
+	}
+
+	http.Header(h).Add(strings.TrimSpace(value[:i]), strings.TrimSpace(value[i+1:]))
+	return nil
+}
+
+// Run writes the response body into stdout, and returns an error if the status is 4xx or 5xx.
+func (c *CLIRunner) Run(args []string, stdin io.Reader, stdout io.Writer) error {
+	header := http.Header{}
+	flags := flag.NewFlagSet("apihttpwrapper", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	method := flags.String("X", "", "the request method")
+	data := flags.String("d", "", "the json file of the request body, - for stdin")
+	flags.Var(cliHeaders(header), "H", "the request header like 'Name: value', repeatable")
+	flags.Usage = func() {
+		fmt.Fprintln(stdout, "usage: [-X method] [-d file] [-H header]... path [key=value]...")
+		flags.PrintDefaults()
+		fmt.Fprintln(stdout, "routes:")
+		for _, rt := range c.routes {
+			fmt.Fprintf(stdout, "  %s %s\n", rt.Method, rt.Path)
+		}
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("no path")
+	}
+
+	query := url.Values{}
+	for _, pair := range flags.Args()[1:] {
+		i := strings.Index(pair, "=")
+		if i <= 0 {
+			return fmt.Errorf("the parameter %q should be like key=value", pair)
+		}
+		query.Add(pair[:i], pair[i+1:])
+	}
+
+	var body []byte
+	var err error
+	switch *data {
+	case "":
+	case "-":
+		body, err = ioutil.ReadAll(stdin)
+	default:
+		body, err = ioutil.ReadFile(*data)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *method == "" {
+		*method = http.MethodGet
+		if body != nil {
+			*method = http.MethodPost
+		}
+	}
+
+	target := flags.Arg(0)
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	r, err := http.NewRequest(strings.ToUpper(*method), target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	r.RemoteAddr = "cli"
+	r.Header = header
+	if body != nil && r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+
+	w := &localResponseWriter{header: http.Header{}, status: http.StatusOK, out: stdout}
+	c.handler.ServeHTTP(w, r)
+	if w.status >= http.StatusBadRequest {
+		return fmt.Errorf("%d %s", w.status, http.StatusText(w.status))
+	}
+
+	return nil
+}
+
+// localResponseWriter serves the in-process requests, the body is written into out as is.
+type localResponseWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	out         io.Writer
+}
+
+func (w *localResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *localResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status >= http.StatusOK {
+		w.status, w.wroteHeader = status, true
+	}
+}
+
+func (w *localResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.out.Write(b)
+}
+
+// Flush is for the event streams, the output is not buffered.
+func (w *localResponseWriter) Flush() {}
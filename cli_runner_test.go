@@ -0,0 +1,67 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCLIRunner(t *testing.T) {
+	type userArg struct {
+		ID   int    `json:"id" schema:"id" validate:"min=1"`
+		Name string `json:"name" schema:"name"`
+	}
+
+	logs := &bytes.Buffer{}
+	runner, err := NewCLIRunner([]*Route{{
+		Method: "GET",
+		Path:   "/users",
+		Function: func(ctx *ServiceMethodContext, arg *userArg) (*userArg, error) {
+			return arg, nil
+		},
+	}, {
+		Method: "POST",
+		Path:   "/users",
+		Function: func(ctx *ServiceMethodContext, arg *userArg) (*userArg, error) {
+			arg.Name = ctx.RequestHeader.Get("X-Operator") + ":" + arg.Name
+			return arg, nil
+		},
+	}}, logs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	if err := runner.Run([]string{"/users", "id=1", "name=a"}, nil, out); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.TrimSpace(out.String()) != `{"id":1,"name":"a"}` {
+		t.Error(out.String())
+	}
+
+	if !strings.Contains(logs.String(), "remote=cli") {
+		t.Error(logs.String())
+	}
+
+	out.Reset()
+	err = runner.Run([]string{"-d", "-", "-H", "X-Operator: ops", "/users"}, strings.NewReader(`{"id":2,"name":"b"}`),
+		out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.TrimSpace(out.String()) != `{"id":2,"name":"ops:b"}` {
+		t.Error(out.String())
+	}
+
+	out.Reset()
+	if err := runner.Run([]string{"/users", "id=0"}, nil, out); err == nil || !strings.HasPrefix(err.Error(), "400") {
+		t.Error(err, out.String())
+	}
+
+	out.Reset()
+	if err := runner.Run(nil, nil, out); err == nil || !strings.Contains(out.String(), "POST /users") {
+		t.Error(err, out.String())
+	}
+}
@@ -0,0 +1,258 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/trace"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Coercion converts a legacy value to the type the canonical argument struct expects.
+type Coercion int
+
+const (
+	CoerceNone Coercion = iota
+	// CoerceBool accepts "1"/"0", "true"/"false", "yes"/"no", "on"/"off" and the numbers 1/0.
+	CoerceBool
+	CoerceInt
+	CoerceFloat
+	CoerceString
+)
+
+// CompatibilityRules rewrite the requests of legacy clients before the argument binding.
+type CompatibilityRules struct {
+	// Match selects the requests the rules apply to, like the ones with an old client version header, nil for all.
+	Match func(r *http.Request) bool
+	// RenameHeaders maps the legacy header names to the canonical ones.
+	RenameHeaders map[string]string
+	// RenameFields maps the legacy field names to the canonical ones.
+	RenameFields map[string]string
+	// EnumValues maps the legacy values of the canonical fields to the canonical values.
+	EnumValues map[string]map[string]string
+	// Coerce lists the conversions of the canonical fields.
+	Coerce map[string]Coercion
+}
+
+func parseLegacyBool(s string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "t", "true", "y", "yes", "on":
+		return true, true
+	case "0", "f", "false", "n", "no", "off", "":
+		return false, true
+	}
+
+	return false, false
+}
+
+// coerceString converts the form values, they are still strings but in the format the form decoder accepts.
+func coerceString(s string, c Coercion) string {
+	switch c {
+	case CoerceBool:
+		if b, ok := parseLegacyBool(s); ok {
+			return strconv.FormatBool(b)
+		}
+	case CoerceInt:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil && f == float64(int64(f)) {
+			return strconv.FormatInt(int64(f), 10)
+		}
+	case CoerceFloat:
+		return strings.TrimSpace(s)
+	}
+
+	return s
+}
+
+// coerceJSON converts the JSON values, the values which can't be converted are kept for the binding to report.
+func coerceJSON(v interface{}, c Coercion) interface{} {
+	switch c {
+	case CoerceBool:
+		switch value := v.(type) {
+		case string:
+			if b, ok := parseLegacyBool(value); ok {
+				return b
+			}
+		case json.Number:
+			if b, ok := parseLegacyBool(value.String()); ok {
+				return b
+			}
+		}
+	case CoerceInt:
+		switch value := v.(type) {
+		case string:
+			s := coerceString(value, c)
+			if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return json.Number(s)
+			}
+		case json.Number:
+			return json.Number(coerceString(value.String(), c))
+		case bool:
+			if value {
+				return json.Number("1")
+			}
+			return json.Number("0")
+		}
+	case CoerceFloat:
+		if value, ok := v.(string); ok {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				return json.Number(strings.TrimSpace(value))
+			}
+		}
+	case CoerceString:
+		switch value := v.(type) {
+		case json.Number:
+			return value.String()
+		case bool:
+			return strconv.FormatBool(value)
+		}
+	}
+
+	return v
+}
+
+func (c *CompatibilityRules) field(name string) string {
+	if canonical, ok := c.RenameFields[name]; ok {
+		return canonical
+	}
+
+	return name
+}
+
+func (c *CompatibilityRules) transformValues(values url.Values) url.Values {
+	result := url.Values{}
+	for k, vs := range values {
+		field := c.field(k)
+		for _, v := range vs {
+			if mapped, ok := c.EnumValues[field][v]; ok {
+				v = mapped
+			}
+
+			result.Add(field, coerceString(v, c.Coerce[field]))
+		}
+	}
+
+	return result
+}
+
+func (c *CompatibilityRules) transformObject(object map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(object))
+	for k, v := range object {
+		field := c.field(k)
+		if enum, ok := c.EnumValues[field]; ok {
+			var key string
+			switch value := v.(type) {
+			case string:
+				key = value
+			case json.Number:
+				key = value.String()
+			}
+
+			if mapped, ok := enum[key]; ok && key != "" {
+				v = mapped
+			}
+		}
+
+		result[field] = coerceJSON(v, c.Coerce[field])
+	}
+
+	return result
+}
+
+// transformJSON rewrites the object or the array of objects, the other documents are returned as is.
+func (c *CompatibilityRules) transformJSON(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return body
+	}
+
+	switch value := doc.(type) {
+	case map[string]interface{}:
+		doc = c.transformObject(value)
+	case []interface{}:
+		for i, element := range value {
+			if object, ok := element.(map[string]interface{}); ok {
+				value[i] = c.transformObject(object)
+			}
+		}
+	default:
+		return body
+	}
+
+	transformed, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+
+	return transformed
+}
+
+func (c *CompatibilityRules) transformBody(r *http.Request, contentType string) error {
+	isJSON := strings.HasPrefix(contentType, "application/json")
+	isForm := strings.HasPrefix(contentType, "application/x-www-form-urlencoded")
+	if r.Body == nil || (!isJSON && !isForm) {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	_ = r.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	if isJSON {
+		body = c.transformJSON(body)
+	} else if values, err := url.ParseQuery(string(body)); err == nil {
+		body = []byte(c.transformValues(values).Encode())
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	return nil
+}
+
+func (c *CompatibilityRules) apply(r *http.Request) error {
+	for legacy, canonical := range c.RenameHeaders {
+		if values, ok := r.Header[http.CanonicalHeaderKey(legacy)]; ok {
+			r.Header.Del(legacy)
+			for _, v := range values {
+				r.Header.Add(canonical, v)
+			}
+		}
+	}
+
+	if r.URL.RawQuery != "" {
+		values, err := url.ParseQuery(r.URL.RawQuery)
+		if err != nil {
+			return err
+		}
+
+		r.URL.RawQuery = c.transformValues(values).Encode()
+	}
+
+	return c.transformBody(r, strings.ToLower(r.Header.Get("Content-Type")))
+}
+
+func newCompatibilityHandle(handle httprouter.Handle, rules *CompatibilityRules) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		if rules.Match != nil && !rules.Match(r) {
+			handle(w, r, params)
+			return
+		}
+
+		if err := rules.apply(r); err != nil {
+			tracer := trace.New(traceFamily, r.URL.Path)
+			writeErrorResponse(w, tracer, &FormattedResponse{400, "apply compatibility rules failed", err.Error()})
+			tracer.Finish()
+			return
+		}
+
+		handle(w, r, params)
+	}
+}
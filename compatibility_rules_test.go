@@ -0,0 +1,70 @@
+package apihttpwrapper
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompatibilityRules(t *testing.T) {
+	type argument struct {
+		Name    string `json:"name" schema:"name"`
+		Enabled bool   `json:"enabled" schema:"enabled"`
+		Count   int    `json:"count" schema:"count"`
+		State   string `json:"state" schema:"state"`
+		Client  string `json:"client"`
+	}
+
+	router, err := NewHTTPRouter([]*Route{{
+		Method: "POST",
+		Path:   "/",
+		Function: func(ctx *ServiceMethodContext, arg *argument) (*argument, error) {
+			arg.Client = ctx.RequestHeader.Get("X-Client-Version")
+			return arg, nil
+		},
+		Compatibility: &CompatibilityRules{
+			RenameHeaders: map[string]string{"X-Version": "X-Client-Version"},
+			RenameFields:  map[string]string{"user_name": "name", "on": "enabled"},
+			EnumValues:    map[string]map[string]string{"state": {"1": "active", "2": "deleted"}},
+			Coerce:        map[string]Coercion{"enabled": CoerceBool, "count": CoerceInt},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(query string, contentType string, body string) string {
+		r := httptest.NewRequest("POST", "/?"+query, strings.NewReader(body))
+		r.Header.Set("X-Version", "1.0")
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		return recorder.Body.String()
+	}
+
+	expected := "{\"name\":\"foo\",\"enabled\":true,\"count\":3,\"state\":\"active\",\"client\":\"1.0\"}\n"
+
+	t.Run("json body", func(t *testing.T) {
+		body := do("", "application/json", `{"user_name":"foo","on":"yes","count":"3","state":1}`)
+		if body != expected {
+			t.Error(body)
+		}
+	})
+
+	t.Run("query string", func(t *testing.T) {
+		body := do("user_name=foo&on=on&count=3.0&state=1", "", "")
+		if body != expected {
+			t.Error(body)
+		}
+	})
+
+	t.Run("form body", func(t *testing.T) {
+		body := do("", "application/x-www-form-urlencoded", "user_name=foo&on=1&count=3&state=1")
+		if body != expected {
+			t.Error(body)
+		}
+	})
+}
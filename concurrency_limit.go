@@ -0,0 +1,223 @@
+package apihttpwrapper
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/trace"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ConcurrencyLimit rejects the requests beyond the limit with 503, it may be shared by the routes.
+type ConcurrencyLimit struct {
+	// MaxConcurrent is the fixed limit, or the initial limit of Algorithm.
+	MaxConcurrent int
+	// Algorithm adjusts the limit by the latencies, the limit is fixed if nil.
+	Algorithm LimitAlgorithm
+	// RetryAfter is the retry hint of the rejected requests, DefaultConcurrencyRetryAfter if not positive.
+	RetryAfter time.Duration
+
+	once     sync.Once
+	mutex    sync.Mutex
+	limit    float64
+	inFlight int
+}
+
+// DefaultConcurrencyRetryAfter is the retry hint of ConcurrencyLimit, the requests finish in seconds usually.
+const DefaultConcurrencyRetryAfter = time.Second
+
+// LimitAlgorithm returns the new limit once a request is finished, dropped means 503 or 504.
+type LimitAlgorithm interface {
+	Update(limit float64, rtt time.Duration, inFlight int, dropped bool) float64
+}
+
+// ConcurrencyLimitCollector is optionally implemented by the MetricsCollector to report the limits.
+type ConcurrencyLimitCollector interface {
+	ConcurrencyLimitChanged(method string, route string, limit int)
+	ConcurrencyLimitRejected(method string, route string)
+}
+
+// AIMDLimit increases the limit by 1 and backs off on the dropped requests.
+type AIMDLimit struct {
+	// MinLimit and MaxLimit bound the limit, 1 and 1000 if not positive.
+	MinLimit int
+	MaxLimit int
+	// BackoffRatio multiplies the limit on the drops, 0.9 if not in (0, 1).
+	BackoffRatio float64
+	// Timeout counts the slower requests as dropped, 0 means only the 503 and 504 ones.
+	Timeout time.Duration
+}
+
+// GradientLimit is the gradient2 of the Netflix concurrency-limits, it can't be shared.
+type GradientLimit struct {
+	// MinLimit and MaxLimit bound the limit, 1 and 1000 if not positive.
+	MinLimit int
+	MaxLimit int
+	// Tolerance is the ratio of the latency to the average that is not considered as queueing, 1.5 if less than 1.
+	Tolerance float64
+	// Smoothing is the weight of each new limit, 0.2 if not in (0, 1].
+	Smoothing float64
+	// Window is the count of the requests averaged as the long term latency, 600 if not positive.
+	Window int
+
+	longRTT float64
+}
+
+func limitBounds(min int, max int) (float64, float64) {
+	if min <= 0 {
+		min = 1
+	}
+
+	if max <= 0 {
+		max = 1000
+	}
+
+	return float64(min), float64(max)
+}
+
+func clampLimit(limit float64, min float64, max float64) float64 {
+	return math.Max(min, math.Min(max, limit))
+}
+
+func (a *AIMDLimit) Update(limit float64, rtt time.Duration, inFlight int, dropped bool) float64 {
+	min, max := limitBounds(a.MinLimit, a.MaxLimit)
+	ratio := a.BackoffRatio
+	if ratio <= 0 || ratio >= 1 {
+		ratio = 0.9
+	}
+
+	if dropped || (a.Timeout > 0 && rtt > a.Timeout) {
+		limit *= ratio
+	} else if float64(inFlight)*2 >= limit {
+		// the limit isn't increased if it's far from used, or it would grow unbounded under the light load.
+		limit++
+	}
+
+	return clampLimit(limit, min, max)
+}
+
+func (g *GradientLimit) Update(limit float64, rtt time.Duration, inFlight int, dropped bool) float64 {
+	min, max := limitBounds(g.MinLimit, g.MaxLimit)
+	tolerance := g.Tolerance
+	if tolerance < 1 {
+		tolerance = 1.5
+	}
+
+	smoothing := g.Smoothing
+	if smoothing <= 0 || smoothing > 1 {
+		smoothing = 0.2
+	}
+
+	window := g.Window
+	if window <= 0 {
+		window = 600
+	}
+
+	sample := float64(rtt)
+	if g.longRTT == 0 {
+		g.longRTT = sample
+	} else {
+		g.longRTT += (sample - g.longRTT) / float64(window)
+	}
+
+	if !dropped && float64(inFlight)*2 < limit {
+		return clampLimit(limit, min, max)
+	}
+
+	gradient := 0.5
+	if !dropped && sample > 0 {
+		gradient = math.Max(0.5, math.Min(1, tolerance*g.longRTT/sample))
+	}
+
+	next := limit*gradient + math.Sqrt(limit)
+	return clampLimit(limit*(1-smoothing)+next*smoothing, min, max)
+}
+
+func (l *ConcurrencyLimit) init() {
+	l.limit = float64(l.MaxConcurrent)
+}
+
+// Limit returns the current limit.
+func (l *ConcurrencyLimit) Limit() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return int(l.limit)
+}
+
+// InFlight returns the count of the requests being served.
+func (l *ConcurrencyLimit) InFlight() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.inFlight
+}
+
+func (l *ConcurrencyLimit) acquire() (int, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.inFlight >= int(l.limit) {
+		return l.inFlight, false
+	}
+
+	l.inFlight++
+	return l.inFlight, true
+}
+
+// release returns the new limit, and whether it's changed.
+func (l *ConcurrencyLimit) release(rtt time.Duration, inFlight int, dropped bool) (int, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.inFlight--
+	if l.Algorithm == nil {
+		return int(l.limit), false
+	}
+
+	previous := int(l.limit)
+	l.limit = l.Algorithm.Update(l.limit, rtt, inFlight, dropped)
+	return int(l.limit), int(l.limit) != previous
+}
+
+// newConcurrencyLimitHandle answers the rejected requests in the format of the handler, with the retry hint headers.
+func newConcurrencyLimitHandle(handle httprouter.Handle, limit *ConcurrencyLimit, handler *ServiceHandler,
+	method string, path string, collector MetricsCollector) httprouter.Handle {
+	limit.once.Do(limit.init)
+	limits, _ := collector.(ConcurrencyLimitCollector)
+	if limits != nil {
+		limits.ConcurrencyLimitChanged(method, path, limit.Limit())
+	}
+
+	retryAfter := limit.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = DefaultConcurrencyRetryAfter
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		inFlight, ok := limit.acquire()
+		if !ok {
+			if limits != nil {
+				limits.ConcurrencyLimitRejected(method, path)
+			}
+
+			tracer := trace.New(traceFamily, r.URL.Path)
+			setRetryHeaders(w.Header(), retryAfter)
+			writeEnvelopedError(w, tracer, handler.negotiateResponseFormat(w, r), &FormattedResponse{
+				http.StatusServiceUnavailable, "concurrency limit exceeded",
+				fmt.Sprintf("the route is serving %d requests", inFlight)})
+			tracer.Finish()
+			return
+		}
+
+		beginTime := time.Now()
+		sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			dropped := sw.status == http.StatusServiceUnavailable || sw.status == http.StatusGatewayTimeout
+			current, changed := limit.release(time.Now().Sub(beginTime), inFlight, dropped)
+			if changed && limits != nil {
+				limits.ConcurrencyLimitChanged(method, path, current)
+			}
+		}()
+
+		handle(sw, r, params)
+	}
+}
@@ -0,0 +1,100 @@
+package apihttpwrapper
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	collector, err := NewPrometheusCollector(nil, "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	limit := &ConcurrencyLimit{MaxConcurrent: 1}
+	router, err := NewHTTPRouterWithOptions([]*Route{{Method: "GET", Path: "/slow", ConcurrencyLimit: limit,
+		Function: func(ctx *ServiceMethodContext, arg *struct{}) error {
+			entered <- struct{}{}
+			<-release
+			return nil
+		}}}, &RouterOptions{Metrics: collector, Enveloper: errnoEnveloper{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	}()
+	<-entered
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+	// the rejections are shaped by the enveloper, with the retry hint headers.
+	if w.Code != 503 || limit.InFlight() != 1 ||
+		w.Body.String() != "{\"errmsg\":\"concurrency limit exceeded\",\"errno\":503}\n" ||
+		w.Header().Get("X-RateLimit-Reset") != "1" || (w.Header().Get("Retry-After") != "1" &&
+		w.Header().Get("Retry-After") != "2") {
+		t.Error(w.Code, w.Body.String(), w.Header())
+	}
+	close(release)
+	<-done
+
+	recorder := httptest.NewRecorder()
+	collector.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	for _, expect := range []string{
+		"api_http_concurrency_limit{method=\"GET\",route=\"/slow\"} 1",
+		"api_http_concurrency_limit_rejections_total{method=\"GET\",route=\"/slow\"} 1",
+	} {
+		if !strings.Contains(recorder.Body.String(), expect) {
+			t.Error(expect, recorder.Body.String())
+		}
+	}
+
+	if err := ValidateRoutes([]*Route{{Method: "GET", Path: "/", Function: func(*ServiceMethodContext,
+		*struct{}) error {
+		return nil
+	}, ConcurrencyLimit: &ConcurrencyLimit{}}}); err == nil {
+		t.Error("the zero limit is accepted")
+	}
+}
+
+func TestLimitAlgorithms(t *testing.T) {
+	aimd := &AIMDLimit{MaxLimit: 11, Timeout: time.Second}
+	if limit := aimd.Update(10, time.Millisecond, 5, false); limit != 11 {
+		t.Error("the busy limit is not increased", limit)
+	}
+
+	if limit := aimd.Update(11, time.Millisecond, 10, false); limit != 11 {
+		t.Error("the limit exceeds the max", limit)
+	}
+
+	if limit := aimd.Update(10, time.Millisecond, 1, false); limit != 10 {
+		t.Error("the idle limit is increased", limit)
+	}
+
+	if limit := aimd.Update(10, 2*time.Second, 10, false); limit != 9 {
+		t.Error("the limit doesn't back off on the timeout", limit)
+	}
+
+	gradient := &GradientLimit{}
+	limit := 16.0
+	for i := 0; i < 10; i++ {
+		limit = gradient.Update(limit, 10*time.Millisecond, int(limit), false)
+	}
+	if limit <= 16 {
+		t.Error("the limit doesn't grow under the stable latencies", limit)
+	}
+
+	grown := limit
+	for i := 0; i < 10; i++ {
+		limit = gradient.Update(limit, 100*time.Millisecond, int(limit), false)
+	}
+	if limit >= grown {
+		t.Error("the limit doesn't shrink while the latencies grow", limit)
+	}
+}
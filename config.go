@@ -0,0 +1,383 @@
+package apihttpwrapper
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Config is the router settings loaded by LoadConfig, the defaults of the routes.
+type Config struct {
+	Timeouts ConfigTimeouts `json:"timeouts"`
+	Limits   ConfigLimits   `json:"limits"`
+	Logging  ConfigLogging  `json:"logging"`
+	Codecs   ConfigCodecs   `json:"codecs"`
+	Metrics  ConfigMetrics  `json:"metrics"`
+	Auth     ConfigAuth     `json:"auth"`
+}
+
+type ConfigTimeouts struct {
+	// Method is Route.Timeout of the routes not having one, except the event stream and websocket routes.
+	Method ConfigDuration `json:"method"`
+	// Write is Route.WriteTimeout of the routes not having one, except the event stream and websocket routes.
+	Write ConfigDuration `json:"write"`
+	// Drain, ReadHeader and Idle are of the server, see NewServerWithConfig.
+	Drain      ConfigDuration `json:"drain"`
+	ReadHeader ConfigDuration `json:"readHeader"`
+	Idle       ConfigDuration `json:"idle"`
+}
+
+type ConfigLimits struct {
+	// MaxBodyBytes, MaxDecompressedBytes and MaxCSVRows are of the routes not having them.
+	MaxBodyBytes         int64 `json:"maxBodyBytes"`
+	MaxDecompressedBytes int64 `json:"maxDecompressedBytes"`
+	MaxCSVRows           int   `json:"maxCSVRows"`
+	// RateLimit is the requests per second of each client, 0 disables it.
+	RateLimit float64 `json:"rateLimit"`
+	RateBurst int     `json:"rateBurst"`
+}
+
+type ConfigLogging struct {
+	// Headers are the request headers logged by NewLoggingHTTPRouterWithConfig.
+	Headers []string `json:"headers"`
+	// Format is "text", "json" or "combined", see AccessLogEncoder.
+	Format string   `json:"format"`
+	Schema []string `json:"schema"`
+}
+
+type ConfigCodecs struct {
+	// XML registers XMLEncoder besides JSON, see EncoderRegistry.
+	XML bool `json:"xml"`
+	// Gzip compresses the responses of NewLoggingHTTPRouterWithConfig at GzipLevel, see CompressionOptions.
+	Gzip                  bool `json:"gzip"`
+	GzipLevel             int  `json:"gzipLevel"`
+	DisallowUnknownFields bool `json:"disallowUnknownFields"`
+	// EnvelopeVersion and WrapSuccessResponses are of the routes not having them.
+	EnvelopeVersion      int  `json:"envelopeVersion"`
+	WrapSuccessResponses bool `json:"wrapSuccessResponses"`
+}
+
+type ConfigMetrics struct {
+	// Prometheus records the metrics by a PrometheusCollector, which are served at Path, "/metrics" by default.
+	Prometheus bool   `json:"prometheus"`
+	Namespace  string `json:"namespace"`
+	Path       string `json:"path"`
+}
+
+type ConfigAuth struct {
+	// RequirePrincipal rejects the requests not authenticated by the middlewares with 401.
+	RequirePrincipal bool `json:"requirePrincipal"`
+	// APIKeyHeader keys the rate limits of Limits.RateLimit by the header instead of the IP.
+	APIKeyHeader string `json:"apiKeyHeader"`
+}
+
+// ConfigDuration is like "1.5s" in the JSON files and the environment, or the number of seconds.
+type ConfigDuration struct {
+	time.Duration
+}
+
+const DefaultMetricsPath = "/metrics"
+
+func (d *ConfigDuration) UnmarshalText(text []byte) error {
+	s := string(text)
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		d.Duration = time.Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	d.Duration = duration
+	return nil
+}
+
+func (d *ConfigDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return d.UnmarshalText(b)
+	}
+
+	return d.UnmarshalText([]byte(s))
+}
+
+func (d ConfigDuration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration.String()), nil
+}
+
+// LoadConfig reads the JSON file and overrides it by the environment, like API_LIMITS_MAX_BODY_BYTES.
+func LoadConfig(path string, envPrefix string) (*Config, error) {
+	config := &Config{}
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		if err := ReadConfig(f, config); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+	}
+
+	if envPrefix != "" {
+		if err := loadConfigEnv(reflect.ValueOf(config).Elem(), strings.ToUpper(envPrefix)); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+// ReadConfig decodes the JSON document into the config, the unknown fields are errors since they are likely typos.
+func ReadConfig(r io.Reader, config *Config) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(config)
+}
+
+// configEnvName converts the field names like MaxBodyBytes into MAX_BODY_BYTES, the acronyms like CSV are kept.
+func configEnvName(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, c := range runes {
+		if i > 0 && unicode.IsUpper(c) && (unicode.IsLower(runes[i-1]) ||
+			(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(c))
+	}
+
+	return b.String()
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func loadConfigEnv(v reflect.Value, prefix string) error {
+	for i := 0; i < v.NumField(); i++ {
+		field, fv := v.Type().Field(i), v.Field(i)
+		name := prefix + "_" + configEnvName(field.Name)
+		if fv.Kind() == reflect.Struct && !fv.Addr().Type().Implements(textUnmarshalerType) {
+			if err := loadConfigEnv(fv, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		if err := setConfigValue(fv, value); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+func setConfigValue(v reflect.Value, value string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+
+	var err error
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(value)
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 64)
+		v.SetInt(n)
+	case reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(value, 64)
+		v.SetFloat(f)
+	case reflect.Slice:
+		var values []string
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+		v.Set(reflect.ValueOf(values))
+	default:
+		err = fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return err
+}
+
+// RouterOptions returns a copy of options having the unset settings of the config.
+func (c *Config) RouterOptions(options *RouterOptions) (*RouterOptions, error) {
+	merged := &RouterOptions{}
+	if options != nil {
+		*merged = *options
+	}
+
+	if merged.Metrics == nil && c.Metrics.Prometheus {
+		collector, err := NewPrometheusCollector(nil, c.Metrics.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		merged.Metrics = collector
+	}
+
+	if merged.Encoders == nil && c.Codecs.XML {
+		merged.Encoders = NewEncoderRegistry()
+		merged.Encoders.Register("application/xml", XMLEncoder{})
+	}
+
+	if merged.Compression == nil && c.Codecs.Gzip {
+		merged.Compression = &CompressionOptions{Level: c.Codecs.GzipLevel}
+	}
+	merged.DisallowUnknownFields = merged.DisallowUnknownFields || c.Codecs.DisallowUnknownFields
+
+	if merged.AccessLogSchema == nil {
+		merged.AccessLogSchema = c.Logging.Schema
+	}
+
+	if merged.AccessLogEncoder == nil {
+		switch c.Logging.Format {
+		case "", "text":
+		case "json":
+			merged.AccessLogEncoder = JSONLinesAccessLogEncoder{}
+		case "combined":
+			merged.AccessLogEncoder = CombinedAccessLogEncoder{}
+		default:
+			return nil, fmt.Errorf("unknown access log format %q", c.Logging.Format)
+		}
+	}
+
+	merged.RequirePrincipal = merged.RequirePrincipal || c.Auth.RequirePrincipal
+
+	return merged, nil
+}
+
+// Routes returns the copies of the routes having the defaults of the config, the routes are not modified.
+func (c *Config) Routes(routes []*Route) []*Route {
+	copies := make([]*Route, 0, len(routes))
+	for _, rt := range routes {
+		if rt == nil {
+			copies = append(copies, rt)
+			continue
+		}
+
+		copied := *rt
+		if copied.Timeout == 0 && copied.EventStream == nil && copied.WebSocket == nil {
+			copied.Timeout = c.Timeouts.Method.Duration
+		}
+
+		if copied.WriteTimeout == 0 && copied.EventStream == nil && copied.WebSocket == nil {
+			copied.WriteTimeout = c.Timeouts.Write.Duration
+		}
+
+		if copied.MaxBodyBytes == 0 {
+			copied.MaxBodyBytes = c.Limits.MaxBodyBytes
+		}
+
+		if copied.MaxDecompressedBytes == 0 {
+			copied.MaxDecompressedBytes = c.Limits.MaxDecompressedBytes
+		}
+
+		if copied.MaxCSVRows == 0 {
+			copied.MaxCSVRows = c.Limits.MaxCSVRows
+		}
+
+		if copied.RateLimit == nil && c.Limits.RateLimit > 0 {
+			copied.RateLimit = &RateLimit{Rate: c.Limits.RateLimit, Burst: c.Limits.RateBurst}
+			if c.Auth.APIKeyHeader != "" {
+				copied.RateLimit.KeyFunc = RateLimitByHeader(c.Auth.APIKeyHeader)
+			}
+		}
+
+		if copied.EnvelopeVersion == 0 {
+			copied.EnvelopeVersion = c.Codecs.EnvelopeVersion
+		}
+		copied.WrapSuccessResponses = copied.WrapSuccessResponses || c.Codecs.WrapSuccessResponses
+		copies = append(copies, &copied)
+	}
+
+	return copies
+}
+
+// registerMetricsRoute serves the metrics of the PrometheusCollector created by the config.
+func (c *Config) registerMetricsRoute(r *httprouter.Router, options *RouterOptions) error {
+	collector, ok := options.Metrics.(*PrometheusCollector)
+	if !c.Metrics.Prometheus || !ok {
+		return nil
+	}
+
+	path := c.Metrics.Path
+	if path == "" {
+		path = DefaultMetricsPath
+	}
+
+	handler := collector.Handler()
+	return handleRoute(r, http.MethodGet, path, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func (c *Config) newRouter(routes []*Route, options *RouterOptions) (*httprouter.Router, error) {
+	router, err := NewHTTPRouterWithOptions(c.Routes(routes), options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.registerMetricsRoute(router, options); err != nil {
+		return nil, err
+	}
+
+	return router, nil
+}
+
+func NewHTTPRouterWithConfig(routes []*Route, config *Config, options *RouterOptions) (*httprouter.Router, error) {
+	options, err := config.RouterOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return config.newRouter(routes, options)
+}
+
+// NewLoggingHTTPRouterWithConfig logs the request headers of Config.Logging.Headers.
+func NewLoggingHTTPRouterWithConfig(routes []*Route, config *Config, logWriter io.Writer,
+	options *RouterOptions) (http.Handler, error) {
+	options, err := config.RouterOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	router, err := config.newRouter(routes, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return newLoggingHandler(router, config.Logging.Headers, logWriter, options)
+}
+
+// NewServerWithConfig is NewServer having the server timeouts of the config.
+func NewServerWithConfig(addr string, handler http.Handler, logWriter io.Writer, config *Config) *Server {
+	s := NewServer(addr, handler, logWriter)
+	s.DrainTimeout = config.Timeouts.Drain.Duration
+	s.HTTPServer.ReadHeaderTimeout = config.Timeouts.ReadHeader.Duration
+	s.HTTPServer.IdleTimeout = config.Timeouts.Idle.Duration
+	return s
+}
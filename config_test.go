@@ -0,0 +1,180 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(path, []byte(`{"timeouts": {"method": "2s", "drain": 10},
+		"limits": {"maxBodyBytes": 100, "maxCSVRows": 5}, "logging": {"format": "json"}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("TESTCFG_LIMITS_MAX_BODY_BYTES", "200")
+	os.Setenv("TESTCFG_LIMITS_MAX_CSV_ROWS", "7")
+	os.Setenv("TESTCFG_LOGGING_HEADERS", "X-Request-Id, User-Agent")
+	os.Setenv("TESTCFG_TIMEOUTS_IDLE", "1m")
+	defer func() {
+		for _, name := range []string{"MAX_BODY_BYTES", "MAX_CSV_ROWS"} {
+			os.Unsetenv("TESTCFG_LIMITS_" + name)
+		}
+		os.Unsetenv("TESTCFG_LOGGING_HEADERS")
+		os.Unsetenv("TESTCFG_TIMEOUTS_IDLE")
+	}()
+
+	config, err := LoadConfig(path, "testcfg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Timeouts.Method.Duration != 2*time.Second || config.Timeouts.Drain.Duration != 10*time.Second ||
+		config.Timeouts.Idle.Duration != time.Minute || config.Limits.MaxBodyBytes != 200 ||
+		config.Limits.MaxCSVRows != 7 || len(config.Logging.Headers) != 2 || config.Logging.Format != "json" {
+		t.Errorf("%+v", config)
+	}
+
+	if err := ReadConfig(strings.NewReader(`{"limits": {"maxBodyByte": 1}}`), &Config{}); err == nil {
+		t.Error("the unknown field is accepted")
+	}
+}
+
+func TestNewLoggingHTTPRouterWithConfig(t *testing.T) {
+	config := &Config{}
+	config.Limits.MaxBodyBytes = 12
+	config.Codecs.XML = true
+	config.Metrics.Prometheus = true
+	config.Auth.RequirePrincipal = true
+	config.Logging.Headers = []string{"X-Request-Id"}
+	config.Logging.Format = "json"
+
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "" {
+				r = r.WithContext(NewPrincipalContext(r.Context(), &Principal{Subject: "u1"}))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	type echo struct {
+		A string
+	}
+	routes := []*Route{{Method: "POST", Path: "/echo",
+		Function: func(ctx *ServiceMethodContext, arg *echo) (*echo, error) {
+			return arg, nil
+		}}}
+	logs := &bytes.Buffer{}
+	handler, err := NewLoggingHTTPRouterWithConfig(routes, config, logs,
+		&RouterOptions{Middlewares: []func(http.Handler) http.Handler{authenticate}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(body string, auth bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/echo", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Accept", "application/xml")
+		r.Header.Set("X-Request-Id", "r1")
+		if auth {
+			r.Header.Set("Authorization", "token")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve(`{"A":"a"}`, false); w.Code != 401 {
+		t.Error("the unauthenticated request is accepted", w.Code)
+	}
+
+	if w := serve(`{"A":"a"}`, true); w.Code != 200 || !strings.Contains(w.Body.String(), "<A>a</A>") {
+		t.Error(w.Code, w.Body.String())
+	}
+
+	if w := serve(`{"A":"too long"}`, true); w.Code != 413 {
+		t.Error("the body limit of the config is not applied", w.Code)
+	}
+
+	if routes[0].MaxBodyBytes != 0 {
+		t.Error("the route is modified")
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", DefaultMetricsPath, nil))
+	if w.Code != 200 || !strings.Contains(w.Body.String(), "http_requests_total") {
+		t.Error(w.Code, w.Body.String())
+	}
+
+	if !strings.Contains(logs.String(), `"headers":"{\"X-Request-Id\":[\"r1\"]}"`) {
+		t.Error(logs.String())
+	}
+}
+
+func TestRequirePrincipalWithRouteAuth(t *testing.T) {
+	config := &Config{}
+	config.Auth.RequirePrincipal = true
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "" {
+				r = r.WithContext(NewPrincipalContext(r.Context(), &Principal{Subject: "u1"}))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	router, err := NewHTTPRouterWithConfig([]*Route{{Method: "GET", Path: "/me",
+		Middlewares: []func(http.Handler) http.Handler{authenticate},
+		Function: func(ctx *ServiceMethodContext, _ *struct{}) (*Principal, error) {
+			return ctx.Principal, nil
+		}}, {Method: "GET", Path: "/open",
+		Function: func(ctx *ServiceMethodContext, _ *struct{}) error {
+			return nil
+		}}, {Method: "GET", Path: "/callback", Enveloper: partnerEnveloper{},
+		Function: func(ctx *ServiceMethodContext, _ *struct{}) error {
+			return nil
+		}}}, config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rejections are shaped by the enveloper of the route.
+	cases := []struct {
+		path   string
+		auth   bool
+		status int
+		body   string
+	}{
+		{"/me", true, 200, ""},
+		{"/me", false, 401, ""},
+		{"/open", true, 401, ""},
+		{"/callback", false, 200, "{\"errcode\":401,\"errmsg\":\"unauthorized\"}\n"},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", c.path, nil)
+		if c.auth {
+			r.Header.Set("Authorization", "token")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.status || (c.body != "" && w.Body.String() != c.body) {
+			t.Error(c.path, c.auth, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package apihttpwrapper
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnTiming is the connection level events of a request, the zero times mean the events didn't happen.
+type ConnTiming struct {
+	Accepted         time.Time
+	TLSHandshakeDone time.Time
+	// Reused is true if the request isn't the first one of the connection.
+	Reused bool
+}
+
+type connTiming struct {
+	accepted         time.Time
+	tlsHandshakeDone atomic.Value
+	requests         int32
+}
+
+// ConnTimingTracker records the accepting and TLS handshake times of the connections of the servers.
+type ConnTimingTracker struct {
+	conns sync.Map
+}
+
+func NewConnTimingTracker() *ConnTimingTracker {
+	return &ConnTimingTracker{}
+}
+
+// Instrument should be called before the server starts, after the certificates are configured.
+func (t *ConnTimingTracker) Instrument(srv *http.Server) {
+	connState := srv.ConnState
+	srv.ConnState = func(conn net.Conn, state http.ConnState) {
+		t.observeConnState(conn, state)
+		if connState != nil {
+			connState(conn, state)
+		}
+	}
+
+	if srv.TLSConfig == nil {
+		return
+	}
+
+	base := srv.TLSConfig
+	getConfigForClient := base.GetConfigForClient
+	srv.TLSConfig = base.Clone()
+	srv.TLSConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		config := base
+		if getConfigForClient != nil {
+			c, err := getConfigForClient(hello)
+			if err != nil {
+				return nil, err
+			}
+
+			if c != nil {
+				config = c
+			}
+		}
+
+		if len(config.Certificates) == 0 && config.GetCertificate == nil {
+			// the certificates are only in the config of the server, which can't be customized per connection.
+			return nil, nil
+		}
+
+		return t.trackHandshake(config, hello.Conn.RemoteAddr().String()), nil
+	}
+}
+
+func (t *ConnTimingTracker) observeConnState(conn net.Conn, state http.ConnState) {
+	addr := conn.RemoteAddr().String()
+	switch state {
+	case http.StateNew:
+		t.conns.Store(addr, &connTiming{accepted: time.Now()})
+	case http.StateClosed, http.StateHijacked:
+		t.conns.Delete(addr)
+	}
+}
+
+// trackHandshake returns a copy of config recording the time the handshake of the connection is done.
+func (t *ConnTimingTracker) trackHandshake(config *tls.Config, addr string) *tls.Config {
+	config = config.Clone()
+	config.GetConfigForClient = nil
+	verifyConnection := config.VerifyConnection
+	config.VerifyConnection = func(state tls.ConnectionState) error {
+		if verifyConnection != nil {
+			if err := verifyConnection(state); err != nil {
+				return err
+			}
+		}
+
+		if v, ok := t.conns.Load(addr); ok {
+			v.(*connTiming).tlsHandshakeDone.Store(time.Now())
+		}
+		return nil
+	}
+
+	return config
+}
+
+// request returns the timing of the connection the request comes from, and counts the request in.
+func (t *ConnTimingTracker) request(r *http.Request) (*ConnTiming, bool) {
+	v, ok := t.conns.Load(r.RemoteAddr)
+	if !ok {
+		return nil, false
+	}
+
+	conn := v.(*connTiming)
+	timing := &ConnTiming{
+		Accepted: conn.accepted,
+		Reused:   atomic.AddInt32(&conn.requests, 1) > 1,
+	}
+	if done, ok := conn.tlsHandshakeDone.Load().(time.Time); ok {
+		timing.TLSHandshakeDone = done
+	}
+
+	return timing, true
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+func formatMilliseconds(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// serverTimings returns the "tls" and "wait" segments of the first request of the connection.
+func (timing *ConnTiming) serverTimings(begin time.Time) []string {
+	if timing.Reused {
+		return nil
+	}
+
+	ready := timing.Accepted
+	var segments []string
+	if !timing.TLSHandshakeDone.IsZero() {
+		ready = timing.TLSHandshakeDone
+		segments = append(segments, "tls;dur="+formatMilliseconds(ready.Sub(timing.Accepted)))
+	}
+
+	return append(segments, "wait;dur="+formatMilliseconds(begin.Sub(ready)))
+}
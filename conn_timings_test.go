@@ -0,0 +1,62 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConnTimings(t *testing.T) {
+	// borrow the certificate of httptest and the client trusting it.
+	certServer := httptest.NewTLSServer(http.NotFoundHandler())
+	certificates := certServer.TLS.Certificates
+	client := certServer.Client()
+	certServer.Close()
+
+	tracker := NewConnTimingTracker()
+	var logs bytes.Buffer
+	router, err := NewLoggingHTTPRouterWithOptions([]*Route{{
+		Method: "GET",
+		Path:   "/",
+		Function: func(*ServiceMethodContext, *struct{}) (*struct{ A int }, error) {
+			return &struct{ A int }{1}, nil
+		},
+	}}, nil, &logs, &RouterOptions{ConnTimings: tracker})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(router)
+	server.Config.TLSConfig = &tls.Config{Certificates: certificates}
+	tracker.Instrument(server.Config)
+	server.TLS = server.Config.TLSConfig
+	server.StartTLS()
+	defer server.Close()
+
+	var timings [][]string
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _ = ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		timings = append(timings, resp.Header["Server-Timing"])
+	}
+
+	if len(timings[0]) != 2 || !strings.HasPrefix(timings[0][0], "tls;dur=") ||
+		!strings.HasPrefix(timings[0][1], "wait;dur=") || len(timings[1]) != 0 {
+		t.Error(timings)
+	}
+
+	rows := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(rows) != 2 || !strings.Contains(rows[0], "tlsHandshake=") || !strings.Contains(rows[0], "connReused=false") ||
+		!strings.Contains(rows[1], "connReused=true") || !strings.Contains(rows[1], "firstByte=") {
+		t.Error(logs.String())
+	}
+}
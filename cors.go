@@ -0,0 +1,158 @@
+package apihttpwrapper
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy allows the other origins to call the routes, the preflights are answered by the router.
+type CORSPolicy struct {
+	// AllowedOrigins are like "https://example.com", "*" allows any origin unless AllowCredentials is set, see Check.
+	AllowedOrigins []string
+	// AllowedMethods are the method of the route if empty.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers besides the CORS-safelisted ones, "*" allows any header.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers readable by the scripts besides the CORS-safelisted ones.
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long the browsers cache the preflight results, the browser default if 0.
+	MaxAge time.Duration
+}
+
+// originAllowed only matches the listed origins if the credentials are allowed, even if Check isn't called.
+func (p *CORSPolicy) originAllowed(origin string) bool {
+	for _, allowed := range p.AllowedOrigins {
+		if (allowed == "*" && !p.AllowCredentials) || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if v == "*" || strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check rejects the wildcard origin allowing the credentials.
+func (p *CORSPolicy) Check() error {
+	if !p.AllowCredentials {
+		return nil
+	}
+
+	for _, origin := range p.AllowedOrigins {
+		if origin == "*" {
+			return fmt.Errorf("the CORS policy allowing the credentials can't allow any origin")
+		}
+	}
+
+	return nil
+}
+
+// allowOrigin sets the origin headers if the request is cross-origin and the origin is allowed.
+func (p *CORSPolicy) allowOrigin(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || !p.originAllowed(origin) {
+		return false
+	}
+
+	// the caches must know the response varies by the origin unless it's the wildcard.
+	if containsFold(p.AllowedOrigins, "*") && !p.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+	}
+
+	if p.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	return true
+}
+
+// newCORSHandle serves the disallowed requests without the headers.
+func newCORSHandle(handle httprouter.Handle, policy *CORSPolicy) httprouter.Handle {
+	exposed := strings.Join(policy.ExposedHeaders, ", ")
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		if policy.allowOrigin(w, r) && exposed != "" {
+			w.Header().Set("Access-Control-Expose-Headers", exposed)
+		}
+
+		handle(w, r, params)
+	}
+}
+
+// newCORSPreflightHandle answers the CORS preflights of the route, the other OPTIONS requests are passed to handle.
+func newCORSPreflightHandle(handle httprouter.Handle, policy *CORSPolicy, method string) httprouter.Handle {
+	methods := policy.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{method}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		requestMethod := r.Header.Get("Access-Control-Request-Method")
+		if r.Header.Get("Origin") == "" || requestMethod == "" {
+			handle(w, r, params)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method, Access-Control-Request-Headers")
+		if !containsFold(methods, requestMethod) || !policy.allowOrigin(w, r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var headers []string
+		for _, value := range r.Header["Access-Control-Request-Headers"] {
+			for _, header := range strings.Split(value, ",") {
+				if header = strings.TrimSpace(header); header != "" {
+					headers = append(headers, header)
+				}
+			}
+		}
+
+		for _, header := range headers {
+			if !containsFold(policy.AllowedHeaders, header) {
+				w.Header().Del("Access-Control-Allow-Origin")
+				w.Header().Del("Access-Control-Allow-Credentials")
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", strings.ToUpper(requestMethod))
+		if len(headers) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+		}
+
+		if policy.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.FormatInt(int64(policy.MaxAge/time.Second), 10))
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func routeCORSPolicy(rt *Route, options *RouterOptions) *CORSPolicy {
+	if rt.CORS != nil {
+		return rt.CORS
+	}
+
+	return options.CORS
+}
+
+func serveNoContent(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	w.WriteHeader(http.StatusNoContent)
+}
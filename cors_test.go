@@ -0,0 +1,130 @@
+package apihttpwrapper
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(401)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	method := func(ctx *ServiceMethodContext, arg *struct{ A int }) error {
+		return nil
+	}
+	router, err := NewHTTPRouterWithOptions([]*Route{
+		{Method: "GET", Path: "/items", Function: method},
+		{Method: "POST", Path: "/items", Function: method, CORS: &CORSPolicy{
+			AllowedOrigins:   []string{"https://admin.example.com"},
+			AllowedHeaders:   []string{"Authorization", "Content-Type"},
+			ExposedHeaders:   []string{"X-Request-Id"},
+			AllowCredentials: true,
+			MaxAge:           time.Minute,
+		}},
+		{Method: "PUT", Path: "/uploads", Function: method, Preflight: true},
+		{Method: "OPTIONS", Path: "/custom", Function: method},
+		{Method: "GET", Path: "/custom", Function: method},
+	}, &RouterOptions{
+		CORS:        &CORSPolicy{AllowedOrigins: []string{"*"}},
+		Middlewares: []func(http.Handler) http.Handler{auth},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		method  string
+		path    string
+		origin  string
+		request string
+		headers string
+		status  int
+		allowed string
+	}{
+		{"OPTIONS", "/items", "https://a.example.com", "GET", "", 204, "*"},
+		{"OPTIONS", "/items", "https://a.example.com", "GET", "Authorization", 403, ""},
+		{"OPTIONS", "/items", "https://a.example.com", "POST", "", 403, ""},
+		{"OPTIONS", "/items", "https://admin.example.com", "POST", "authorization, content-type", 204,
+			"https://admin.example.com"},
+		{"OPTIONS", "/items", "https://admin.example.com", "DELETE", "", 405, ""},
+		{"GET", "/items", "https://a.example.com", "", "", 401, "*"},
+		{"POST", "/items", "https://admin.example.com", "", "", 401, "https://admin.example.com"},
+		{"POST", "/items", "https://a.example.com", "", "", 401, ""},
+		{"OPTIONS", "/custom", "https://a.example.com", "GET", "", 401, "*"},
+		// the CORS preflights aren't passed to the middlewares of Route.Preflight.
+		{"OPTIONS", "/uploads", "https://a.example.com", "PUT", "", 204, "*"},
+		{"OPTIONS", "/uploads", "", "PUT", "", 401, ""},
+	}
+
+	for i, c := range cases {
+		r := httptest.NewRequest(c.method, c.path, nil)
+		r.Header.Set("Origin", c.origin)
+		if c.request != "" {
+			r.Header.Set("Access-Control-Request-Method", c.request)
+		}
+		if c.headers != "" {
+			r.Header.Set("Access-Control-Request-Headers", c.headers)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != c.status || w.Header().Get("Access-Control-Allow-Origin") != c.allowed {
+			t.Error(i, w.Code, w.Header())
+		}
+	}
+
+	r := httptest.NewRequest("OPTIONS", "/items", nil)
+	r.Header.Set("Origin", "https://admin.example.com")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	r.Header.Set("Access-Control-Request-Headers", "Authorization")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Header().Get("Access-Control-Allow-Credentials") != "true" || w.Header().Get("Access-Control-Max-Age") != "60" ||
+		w.Header().Get("Access-Control-Allow-Headers") != "Authorization" ||
+		w.Header().Get("Access-Control-Allow-Methods") != "POST" {
+		t.Error(w.Header())
+	}
+
+	r = httptest.NewRequest("POST", "/items", nil)
+	r.Header.Set("Origin", "https://admin.example.com")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Header().Get("Access-Control-Expose-Headers") != "X-Request-Id" {
+		t.Error(w.Header())
+	}
+}
+
+func TestCORSWildcardCredentials(t *testing.T) {
+	policy := &CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true}
+	method := func(ctx *ServiceMethodContext, arg *struct{}) error {
+		return nil
+	}
+
+	if _, err := NewHTTPRouterWithOptions([]*Route{{Method: "GET", Path: "/", Function: method}},
+		&RouterOptions{CORS: policy}); err == nil {
+		t.Error("the wildcard origin allowing the credentials is accepted")
+	}
+
+	if _, err := NewHTTPRouter([]*Route{{Method: "GET", Path: "/", Function: method, CORS: policy}}); err == nil {
+		t.Error("the wildcard origin allowing the credentials is accepted by the route")
+	}
+
+	// the policy not checked still doesn't echo the origins of the wildcard.
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Origin", "https://evil.example")
+	w := httptest.NewRecorder()
+	newCORSHandle(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {}, policy)(w, r, nil)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" || w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Error(w.Header())
+	}
+}
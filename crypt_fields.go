@@ -0,0 +1,180 @@
+package apihttpwrapper
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Crypter does the cryptography of the fields tagged with `crypt:"key-alias"`, typically by a KMS.
+type Crypter interface {
+	Encrypt(keyAlias string, plaintext []byte) ([]byte, error)
+	Decrypt(keyAlias string, ciphertext []byte) ([]byte, error)
+}
+
+// cryptTransform transforms the value of a crypt field, text tells whether it is a string field.
+type cryptTransform func(keyAlias string, value []byte, text bool) ([]byte, error)
+
+var cryptFieldsCache sync.Map
+
+var bytesType = reflect.TypeOf([]byte(nil))
+
+// hasCryptFields tells whether the values of the type need the transform, so that the others are not copied.
+func hasCryptFields(t reflect.Type) bool {
+	if cached, ok := cryptFieldsCache.Load(t); ok {
+		return cached.(bool)
+	}
+
+	// recursive types are considered having no crypt fields until proved otherwise.
+	cryptFieldsCache.Store(t, false)
+	has := false
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		has = t != bytesType && hasCryptFields(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField() && !has; i++ {
+			field := t.Field(i)
+			if field.PkgPath == "" {
+				has = field.Tag.Get("crypt") != "" || hasCryptFields(field.Type)
+			}
+		}
+	}
+
+	cryptFieldsCache.Store(t, has)
+	return has
+}
+
+func transformCryptField(field reflect.StructField, v reflect.Value, keyAlias string,
+	transform cryptTransform) error {
+	switch {
+	case field.Type.Kind() == reflect.String:
+		if v.String() == "" {
+			return nil
+		}
+
+		result, err := transform(keyAlias, []byte(v.String()), true)
+		if err != nil {
+			return fmt.Errorf("field %s: %s", field.Name, err)
+		}
+		v.SetString(string(result))
+	case field.Type == bytesType:
+		if v.Len() == 0 {
+			return nil
+		}
+
+		result, err := transform(keyAlias, v.Bytes(), false)
+		if err != nil {
+			return fmt.Errorf("field %s: %s", field.Name, err)
+		}
+		v.SetBytes(result)
+	default:
+		return fmt.Errorf("field %s: only string and []byte fields can be tagged with crypt", field.Name)
+	}
+
+	return nil
+}
+
+// transformCryptFields returns a copy of v with the crypt fields transformed.
+func transformCryptFields(v reflect.Value, transform cryptTransform) (reflect.Value, error) {
+	if !hasCryptFields(v.Type()) {
+		return v, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v, nil
+		}
+
+		elem, err := transformCryptFields(v.Elem(), transform)
+		if err != nil {
+			return v, err
+		}
+
+		ptr := reflect.New(v.Type().Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	case reflect.Slice, reflect.Array:
+		var result reflect.Value
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return v, nil
+			}
+			result = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		} else {
+			result = reflect.New(v.Type()).Elem()
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			elem, err := transformCryptFields(v.Index(i), transform)
+			if err != nil {
+				return v, err
+			}
+			result.Index(i).Set(elem)
+		}
+
+		return result, nil
+	case reflect.Struct:
+		result := reflect.New(v.Type()).Elem()
+		result.Set(v)
+
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			if keyAlias := field.Tag.Get("crypt"); keyAlias != "" {
+				if err := transformCryptField(field, result.Field(i), keyAlias, transform); err != nil {
+					return v, err
+				}
+				continue
+			}
+
+			fv, err := transformCryptFields(v.Field(i), transform)
+			if err != nil {
+				return v, err
+			}
+			result.Field(i).Set(fv)
+		}
+
+		return result, nil
+	}
+
+	return v, nil
+}
+
+func (h *ServiceHandler) decryptTransform(keyAlias string, value []byte, text bool) ([]byte, error) {
+	if h.crypter == nil {
+		return nil, fmt.Errorf("no crypter")
+	}
+
+	if text {
+		decoded, err := base64.StdEncoding.DecodeString(string(value))
+		if err != nil {
+			return nil, err
+		}
+		value = decoded
+	}
+
+	return h.crypter.Decrypt(keyAlias, value)
+}
+
+func (h *ServiceHandler) encryptTransform(keyAlias string, value []byte, text bool) ([]byte, error) {
+	if h.crypter == nil {
+		return nil, fmt.Errorf("no crypter")
+	}
+
+	encrypted, err := h.crypter.Encrypt(keyAlias, value)
+	if err != nil || !text {
+		return encrypted, err
+	}
+
+	return []byte(base64.StdEncoding.EncodeToString(encrypted)), nil
+}
+
+func redactCryptField(string, []byte, bool) ([]byte, error) {
+	return []byte("******"), nil
+}
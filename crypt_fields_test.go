@@ -0,0 +1,69 @@
+package apihttpwrapper
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testingCrypter struct{}
+
+func (testingCrypter) Encrypt(keyAlias string, plaintext []byte) ([]byte, error) {
+	return append([]byte(keyAlias+":"), plaintext...), nil
+}
+
+func (testingCrypter) Decrypt(keyAlias string, ciphertext []byte) ([]byte, error) {
+	return []byte(strings.TrimPrefix(string(ciphertext), keyAlias+":")), nil
+}
+
+func TestCryptFields(t *testing.T) {
+	type account struct {
+		Name string `json:"name"`
+		SSN  string `json:"ssn" crypt:"pii"`
+	}
+
+	cached := &account{Name: "foo", SSN: "123-45-6789"}
+	routes := []*Route{{
+		Method: "POST",
+		Path:   "/",
+		Function: func(_ *ServiceMethodContext, arg *account) (*account, error) {
+			if arg.SSN != "987-65-4321" {
+				t.Error(arg)
+			}
+			return cached, nil
+		},
+	}}
+
+	if _, err := NewHTTPRouter(routes); err == nil {
+		t.Error("crypt fields without crypter accepted")
+	}
+
+	router, err := NewHTTPRouterWithOptions(routes, &RouterOptions{Crypter: testingCrypter{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted := base64.StdEncoding.EncodeToString([]byte("pii:987-65-4321"))
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"foo","ssn":"`+encrypted+`"}`))
+	r.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, r)
+
+	expected := `{"name":"foo","ssn":"` + base64.StdEncoding.EncodeToString([]byte("pii:123-45-6789")) + "\"}\n"
+	if recorder.Body.String() != expected {
+		t.Error(recorder.Body.String())
+	}
+
+	if cached.SSN != "123-45-6789" {
+		t.Error("the returned object is modified", cached)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"ssn":"not base64"}`))
+	r.Header.Set("Content-Type", "application/json")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, r)
+	if recorder.Code != 400 {
+		t.Error(recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,237 @@
+package apihttpwrapper
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CSVRowError struct {
+	// Row is the record number in the csv document, the header row is 1.
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Error  string `json:"error"`
+}
+
+// CSVRowErrors is the data of the 400 response of the csv rows which can't be converted.
+type CSVRowErrors []*CSVRowError
+
+const DefaultMaxCSVRows = 10000
+
+// maxCSVRowErrors stops binding the documents having too many bad rows, the errors so far are returned.
+const maxCSVRowErrors = 100
+
+func (errs CSVRowErrors) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, e := range errs {
+		if e.Column != "" {
+			messages = append(messages, fmt.Sprintf("row %d column %q: %s", e.Row, e.Column, e.Error))
+		} else {
+			messages = append(messages, fmt.Sprintf("row %d: %s", e.Row, e.Error))
+		}
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func isCSVBindable(argType reflect.Type) bool {
+	if argType.Kind() != reflect.Ptr || argType.Elem().Kind() != reflect.Slice {
+		return false
+	}
+
+	elemType := argType.Elem().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+
+	return elemType.Kind() == reflect.Struct
+}
+
+func setFieldFromString(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if s == "" {
+			return nil
+		}
+
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType {
+		if s == "" {
+			return nil
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		if s == "" {
+			return nil
+		}
+
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s == "" {
+			return nil
+		}
+
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s == "" {
+			return nil
+		}
+
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		if s == "" {
+			return nil
+		}
+
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(f)
+	default:
+		// composite values are encoded as json in a cell, the same as the csv encoder does.
+		if s == "" {
+			return nil
+		}
+
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+
+	return nil
+}
+
+// fieldByIndexAlloc is like reflect.Value.FieldByIndex, but allocates the nil embedded struct pointers.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for _, x := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v
+}
+
+// decodeCSV binds the rows into the pointer to slice of structs, the columns named like the encoder.
+func decodeCSV(r io.Reader, arg interface{}, maxRows int) error {
+	sliceValue := reflect.ValueOf(arg).Elem()
+	elemType := sliceValue.Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	columnsByName := make(map[string]*tabularColumn)
+	for _, c := range tabularColumns(structType, nil) {
+		columnsByName[c.name] = c
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	columns := make([]*tabularColumn, len(header))
+	for i, name := range header {
+		columns[i] = columnsByName[strings.TrimSpace(name)]
+	}
+
+	var rowErrors CSVRowErrors
+	rows := reflect.MakeSlice(sliceValue.Type(), 0, 0)
+	for line := 2; ; line++ {
+		if len(rowErrors) >= maxCSVRowErrors {
+			return rowErrors
+		}
+
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if maxRows > 0 && line-1 > maxRows {
+			return fmt.Errorf("the csv document has more than %d rows", maxRows)
+		}
+
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); !ok {
+				return err
+			}
+
+			rowErrors = append(rowErrors, &CSVRowError{Row: line, Error: err.Error()})
+			continue
+		}
+
+		row := reflect.New(structType)
+		ok := true
+		for i, cell := range record {
+			if i >= len(columns) || columns[i] == nil {
+				continue
+			}
+
+			err := setFieldFromString(fieldByIndexAlloc(row.Elem(), columns[i].index), cell)
+			if err != nil {
+				ok = false
+				rowErrors = append(rowErrors, &CSVRowError{Row: line, Column: columns[i].name, Error: err.Error()})
+			}
+		}
+
+		if !ok {
+			continue
+		}
+
+		if elemType.Kind() == reflect.Ptr {
+			rows = reflect.Append(rows, row)
+		} else {
+			rows = reflect.Append(rows, row.Elem())
+		}
+	}
+
+	if len(rowErrors) > 0 {
+		return rowErrors
+	}
+
+	sliceValue.Set(rows)
+	return nil
+}
@@ -0,0 +1,36 @@
+package apihttpwrapper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeCSVLimits(t *testing.T) {
+	type row struct {
+		A int
+	}
+
+	cases := []struct {
+		body    string
+		maxRows int
+		rows    int
+		errs    int
+		tooMany bool
+	}{
+		{"A\n1\n2\n", 2, 2, 0, false},
+		{"A\n1\n2\n3\n", 2, 0, 0, true},
+		{"A\n\"1\n\"2\n", 0, 0, 1, false},
+		{"A\n" + strings.Repeat("x\"\n", 10), 2, 0, 0, true},
+		{"A\n" + strings.Repeat("x\n", 1000), 0, 0, maxCSVRowErrors, false},
+	}
+
+	for i, c := range cases {
+		var rows []*row
+		err := decodeCSV(strings.NewReader(c.body), &rows, c.maxRows)
+		rowErrors, _ := err.(CSVRowErrors)
+		tooMany := err != nil && strings.Contains(err.Error(), "more than")
+		if len(rows) != c.rows || len(rowErrors) != c.errs || tooMany != c.tooMany {
+			t.Error(i, len(rows), len(rowErrors), err)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ConfirmTokenHeader carries the token issued by the 428 response.
+	ConfirmTokenHeader = "X-Confirm-Token"
+	// UndoTokenHeader is issued by the successful responses of the destructive routes, and is sent to the undo route.
+	UndoTokenHeader = "X-Undo-Token"
+)
+
+const (
+	DefaultDestructiveTokenTTL = 5 * time.Minute
+	// the larger requests get no undo tokens.
+	maxHashedBodyBytes = 1 << 20
+	maxUndoBodyBytes   = 4 << 10
+)
+
+// DestructiveGuard signs the confirmation tokens of Route.Destructive and the undo tokens of Route.Undo.
+type DestructiveGuard struct {
+	// Secret signs the tokens, the replicas sharing it accept the tokens of each other.
+	Secret []byte
+	// TTL is how long the tokens are valid, DefaultDestructiveTokenTTL if not positive.
+	TTL time.Duration
+	// Store remembers the used tokens, a MemoryNonceStore if nil. it should be shared by the replicas too.
+	Store NonceStore
+	// SkipConfirmation makes the destructive routes only issue the undo tokens.
+	SkipConfirmation bool
+
+	once sync.Once
+}
+
+// UndoRoute is the destructive route undone by the route having it.
+type UndoRoute struct {
+	Guard  *DestructiveGuard
+	Method string
+	Path   string
+}
+
+type destructiveClaims struct {
+	ID      string            `json:"id"`
+	Kind    string            `json:"kind"`
+	Route   string            `json:"route"`
+	Subject string            `json:"sub,omitempty"`
+	Digest  string            `json:"digest,omitempty"`
+	Params  map[string]string `json:"params,omitempty"`
+	Query   string            `json:"query,omitempty"`
+	Type    string            `json:"type,omitempty"`
+	Body    []byte            `json:"body,omitempty"`
+	Expiry  int64             `json:"exp"`
+}
+
+type destructiveConfirmation struct {
+	Token   string `json:"token"`
+	Expires int64  `json:"expires"`
+}
+
+func (g *DestructiveGuard) ttl() time.Duration {
+	if g.TTL <= 0 {
+		return DefaultDestructiveTokenTTL
+	}
+
+	return g.TTL
+}
+
+func (g *DestructiveGuard) init() {
+	if g.Store == nil {
+		g.Store = NewMemoryNonceStore()
+	}
+}
+
+func (g *DestructiveGuard) sign(claims *destructiveClaims) (string, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	claims.ID = hex.EncodeToString(id)
+	claims.Expiry = time.Now().Add(g.ttl()).Unix()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, g.Secret)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// verify is called by consume after the other checks, so the token stays valid on the wrong requests.
+func (g *DestructiveGuard) verify(token string, kind string, route string) (*destructiveClaims, error) {
+	dot := strings.IndexByte(token, '.')
+	if dot < 0 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(token[:dot])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(token[dot+1:])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	mac := hmac.New(sha256.New, g.Secret)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+
+	claims := &destructiveClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	expiry := time.Unix(claims.Expiry, 0)
+	if claims.Kind != kind || claims.Route != route {
+		return nil, fmt.Errorf("the token is not of the route")
+	}
+
+	if time.Now().After(expiry) {
+		return nil, fmt.Errorf("expired token")
+	}
+
+	return claims, nil
+}
+
+func (g *DestructiveGuard) consume(claims *destructiveClaims) error {
+	fresh, err := g.Store.Remember(claims.ID, time.Unix(claims.Expiry, 0))
+	if err != nil {
+		return err
+	}
+
+	if !fresh {
+		return fmt.Errorf("used token")
+	}
+
+	return nil
+}
+
+func destructiveRouteKey(method string, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+func principalSubject(r *http.Request) string {
+	if principal := PrincipalFromContext(r.Context()); principal != nil {
+		return principal.Subject
+	}
+
+	return ""
+}
+
+func requestDigest(r *http.Request, body []byte) string {
+	h := sha256.New()
+	_, _ = io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeFormattedError(w http.ResponseWriter, code int, msg string, data interface{}) {
+	setResponseHeader(w)
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(&FormattedResponse{code, msg, data})
+}
+
+// readHashedBody buffers the body for the digests, and puts it back for the binding.
+func readHashedBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Body == nil {
+		return nil, true
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxHashedBodyBytes+1))
+	if err != nil {
+		writeFormattedError(w, http.StatusBadRequest, "read request body failed", err.Error())
+		return nil, false
+	}
+
+	if len(body) > maxHashedBodyBytes {
+		resp := bodyTooLargeResponse(maxHashedBodyBytes)
+		writeFormattedError(w, resp.Code, resp.Msg, resp.Data)
+		return nil, false
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+func recordLogField(r *http.Request, loggerContextKey interface{}, field string, id string) {
+	if logger, ok := r.Context().Value(loggerContextKey).(MethodLogger); ok {
+		logger.Record(field, id)
+	}
+}
+
+// undoTokenWriter sets the undo token header right before the successful response header is written.
+type undoTokenWriter struct {
+	http.ResponseWriter
+	issue       func() string
+	wroteHeader bool
+}
+
+func (w *undoTokenWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status >= http.StatusOK {
+		w.wroteHeader = true
+		if status < http.StatusMultipleChoices {
+			if token := w.issue(); token != "" {
+				w.Header().Set(UndoTokenHeader, token)
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *undoTokenWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *undoTokenWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *undoTokenWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func newDestructiveHandle(handle httprouter.Handle, rt *Route, loggerContextKey interface{}) httprouter.Handle {
+	guard := rt.Destructive
+	guard.once.Do(guard.init)
+	route := destructiveRouteKey(rt.Method, rt.Path)
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		body, ok := readHashedBody(w, r)
+		if !ok {
+			return
+		}
+
+		subject := principalSubject(r)
+		if !guard.SkipConfirmation {
+			digest := requestDigest(r, body)
+			token := r.Header.Get(ConfirmTokenHeader)
+			if token == "" {
+				claims := &destructiveClaims{Kind: "confirm", Route: route, Subject: subject, Digest: digest}
+				signed, err := guard.sign(claims)
+				if err != nil {
+					writeFormattedError(w, http.StatusInternalServerError, "sign token failed", err.Error())
+					return
+				}
+
+				w.Header().Set(ConfirmTokenHeader, signed)
+				writeFormattedError(w, http.StatusPreconditionRequired, "confirmation required",
+					&destructiveConfirmation{signed, claims.Expiry})
+				return
+			}
+
+			claims, err := guard.verify(token, "confirm", route)
+			if err == nil && (claims.Subject != subject || claims.Digest != digest) {
+				err = fmt.Errorf("the token is not of the request")
+			}
+
+			if err == nil {
+				err = guard.consume(claims)
+			}
+
+			if err != nil {
+				writeFormattedError(w, http.StatusForbidden, "invalid confirmation token", err.Error())
+				return
+			}
+			recordLogField(r, loggerContextKey, "confirmToken", claims.ID)
+		}
+
+		uw := &undoTokenWriter{ResponseWriter: w}
+		uw.issue = func() string {
+			if len(body) > maxUndoBodyBytes {
+				return ""
+			}
+
+			claims := &destructiveClaims{Kind: "undo", Route: route, Subject: subject, Query: r.URL.RawQuery,
+				Type: r.Header.Get("Content-Type"), Body: body, Params: make(map[string]string)}
+			for _, param := range params {
+				claims.Params[param.Key] = param.Value
+			}
+
+			signed, err := guard.sign(claims)
+			if err != nil {
+				return ""
+			}
+
+			recordLogField(r, loggerContextKey, "undoToken", claims.ID)
+			return signed
+		}
+		handle(uw, r, params)
+		// the empty responses are 200 too.
+		if !uw.wroteHeader {
+			uw.WriteHeader(http.StatusOK)
+		}
+	}
+}
+
+// newUndoHandle replaces the query and the body of the request by the ones of the destructive request.
+func newUndoHandle(handle httprouter.Handle, undo *UndoRoute, loggerContextKey interface{}) httprouter.Handle {
+	undo.Guard.once.Do(undo.Guard.init)
+	route := destructiveRouteKey(undo.Method, undo.Path)
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		token := r.Header.Get(UndoTokenHeader)
+		if token == "" {
+			writeFormattedError(w, http.StatusPreconditionRequired, "undo token required", nil)
+			return
+		}
+
+		claims, err := undo.Guard.verify(token, "undo", route)
+		if err == nil && claims.Subject != principalSubject(r) {
+			err = fmt.Errorf("the token is not of the caller")
+		}
+
+		if err == nil {
+			for _, param := range params {
+				if value, ok := claims.Params[param.Key]; ok && value != param.Value {
+					err = fmt.Errorf("the token is not of the resource")
+					break
+				}
+			}
+		}
+
+		if err == nil {
+			err = undo.Guard.consume(claims)
+		}
+
+		if err != nil {
+			writeFormattedError(w, http.StatusForbidden, "invalid undo token", err.Error())
+			return
+		}
+		recordLogField(r, loggerContextKey, "undoneToken", claims.ID)
+
+		// the request is copied, so the access log has the uri of the undo request.
+		undone := r.WithContext(r.Context())
+		u := *r.URL
+		u.RawQuery = claims.Query
+		undone.URL = &u
+		undone.Header = make(http.Header, len(r.Header))
+		for k, v := range r.Header {
+			undone.Header[k] = v
+		}
+		undone.Header.Del("Content-Type")
+		if claims.Type != "" {
+			undone.Header.Set("Content-Type", claims.Type)
+		}
+		undone.Body = ioutil.NopCloser(bytes.NewReader(claims.Body))
+		undone.ContentLength = int64(len(claims.Body))
+		handle(w, undone, params)
+	}
+}
@@ -0,0 +1,96 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDestructiveRoutes(t *testing.T) {
+	guard := &DestructiveGuard{Secret: []byte("secret")}
+	type itemArguments struct {
+		ID    string `uri:"id"`
+		Force bool   `form:"force"`
+	}
+
+	var deleted, restored []string
+	routes := []*Route{
+		{Method: "DELETE", Path: "/items/:id", Destructive: guard,
+			Function: func(ctx *ServiceMethodContext, arg *itemArguments) error {
+				deleted = append(deleted, arg.ID)
+				return nil
+			}},
+		{Method: "POST", Path: "/items/:id/restore", Undo: &UndoRoute{guard, "DELETE", "/items/:id"},
+			Function: func(ctx *ServiceMethodContext, arg *itemArguments) error {
+				if !arg.Force {
+					t.Error("the query of the destructive request is not bound")
+				}
+				restored = append(restored, arg.ID)
+				return nil
+			}},
+	}
+
+	logs := &bytes.Buffer{}
+	router, err := NewLoggingHTTPRouter(routes, nil, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method string, path string, header string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			r.Header.Set(header, token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("DELETE", "/items/1?force=true", "", "")
+	confirm := w.Header().Get(ConfirmTokenHeader)
+	if w.Code != 428 || confirm == "" || !strings.Contains(w.Body.String(), confirm) || len(deleted) != 0 {
+		t.Fatal(w.Code, w.Body.String())
+	}
+
+	if w := serve("DELETE", "/items/2?force=true", ConfirmTokenHeader, confirm); w.Code != 403 || len(deleted) != 0 {
+		t.Error("the token of another request is accepted", w.Code)
+	}
+
+	w = serve("DELETE", "/items/1?force=true", ConfirmTokenHeader, confirm)
+	undo := w.Header().Get(UndoTokenHeader)
+	if w.Code != 200 || undo == "" || len(deleted) != 1 {
+		t.Fatal(w.Code, w.Body.String())
+	}
+
+	if w := serve("DELETE", "/items/1?force=true", ConfirmTokenHeader, confirm); w.Code != 403 || len(deleted) != 1 {
+		t.Error("the used token is accepted", w.Code)
+	}
+
+	if w := serve("POST", "/items/2/restore", UndoTokenHeader, undo); w.Code != 403 || len(restored) != 0 {
+		t.Error("the undo token of another resource is accepted", w.Code)
+	}
+
+	if w := serve("POST", "/items/1/restore", UndoTokenHeader, confirm); w.Code != 403 {
+		t.Error("the confirmation token is accepted as the undo token", w.Code)
+	}
+
+	if w := serve("POST", "/items/1/restore", UndoTokenHeader, undo); w.Code != 200 || len(restored) != 1 {
+		t.Error(w.Code, w.Body.String())
+	}
+
+	if w := serve("POST", "/items/1/restore", UndoTokenHeader, undo); w.Code != 403 || len(restored) != 1 {
+		t.Error("the used undo token is accepted", w.Code)
+	}
+
+	for _, field := range []string{"confirmToken=", "undoToken=", "undoneToken="} {
+		if !strings.Contains(logs.String(), field) {
+			t.Error("the access log has no", field)
+		}
+	}
+
+	if err := ValidateRoutes([]*Route{{Method: "DELETE", Path: "/", Function: routes[0].Function,
+		Destructive: &DestructiveGuard{}}}); err == nil {
+		t.Error("the guard without secret is accepted")
+	}
+}
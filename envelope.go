@@ -0,0 +1,163 @@
+package apihttpwrapper
+
+import (
+	"golang.org/x/net/trace"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// EnvelopeV1 is the legacy shape, the errors are FormattedResponse and the results are written as is.
+	EnvelopeV1 = 1
+	// EnvelopeV2 wraps both the results and the errors into Envelope.
+	EnvelopeV2 = 2
+)
+
+// EnvelopeVersionHeader selects the envelope version per request, it overrides the version of the route.
+const EnvelopeVersionHeader = "X-Envelope-Version"
+
+type Envelope struct {
+	Data     interface{}    `json:"data,omitempty" xml:"data,omitempty"`
+	Error    *EnvelopeError `json:"error,omitempty" xml:"error,omitempty"`
+	Warnings []*Warning     `json:"warnings,omitempty" xml:"warnings>warning,omitempty"`
+	Meta     *EnvelopeMeta  `json:"meta" xml:"meta"`
+}
+
+type EnvelopeError struct {
+	Code    int         `json:"code" xml:"code"`
+	Message string      `json:"message" xml:"message"`
+	Details interface{} `json:"details,omitempty" xml:"details,omitempty"`
+	// Incident is the id of the internal error for looking up the logs, see IncidentHeader.
+	Incident string `json:"incident,omitempty" xml:"incident,omitempty"`
+	// Name and Docs are of the registered code, see ErrorCode.
+	Name string `json:"name,omitempty" xml:"name,omitempty"`
+	Docs string `json:"docs,omitempty" xml:"docs,omitempty"`
+}
+
+// ResponseEnveloper shapes the response bodies by the existing conventions, like JSON:API.
+type ResponseEnveloper interface {
+	WrapSuccess(data interface{}) interface{}
+	// WrapError gets the status of the response, the detail is an *Incident for the 5xx responses.
+	WrapError(status int, msg string, detail interface{}) interface{}
+}
+
+// ErrorStatusEnveloper also chooses the statuses of the errors, like 200 for the partners.
+type ErrorStatusEnveloper interface {
+	ResponseEnveloper
+	// ErrorStatus gets the status of the error, the same as WrapError, and returns the status written.
+	ErrorStatus(status int) int
+}
+
+type EnvelopeMeta struct {
+	Version int `json:"version" xml:"version"`
+	Status  int `json:"status" xml:"status"`
+	// Degraded is set if the data is of the fallback, see ServiceHandler.SetFallback.
+	Degraded bool `json:"degraded,omitempty" xml:"degraded,omitempty"`
+}
+
+// responseEnvelopeVersion must be called before the response header is written.
+func (h *ServiceHandler) responseEnvelopeVersion(w http.ResponseWriter, r *http.Request) int {
+	version := EnvelopeV1
+	if h.enveloper != nil {
+		return version
+	}
+
+	if h.envelopeVersion == EnvelopeV2 {
+		version = EnvelopeV2
+	}
+
+	if value := r.Header.Get(EnvelopeVersionHeader); value != "" {
+		if v, err := strconv.Atoi(value); err == nil && (v == EnvelopeV1 || v == EnvelopeV2) {
+			version = v
+		}
+	}
+
+	if version != EnvelopeV1 {
+		w.Header().Set(EnvelopeVersionHeader, strconv.Itoa(version))
+	}
+
+	return version
+}
+
+func writeEnvelopedError(w http.ResponseWriter, tr trace.Trace, format *responseFormat, resp *FormattedResponse) {
+	writeEnvelopedStatusError(w, tr, format, resp.Code, resp)
+}
+
+// writeEnvelopedStatusError is for the errors whose code differs from the status, see HTTPError.
+func writeEnvelopedStatusError(w http.ResponseWriter, tr trace.Trace, format *responseFormat, status int,
+	resp *FormattedResponse) {
+	tr.LazyPrintf("%s: %+v", resp.Msg, resp.Data)
+	if status >= 400 {
+		tr.SetError()
+	}
+
+	registered := DefaultErrorCodes.Lookup(resp.Code)
+	if registered != nil && registered.DocsURL != "" && format.envelope == EnvelopeV1 {
+		w.Header().Add("Link", "<"+registered.DocsURL+`>; rel="help"`)
+	}
+
+	if format.enveloper != nil {
+		body := format.enveloper.WrapError(status, resp.Msg, resp.Data)
+		if statusEnveloper, ok := format.enveloper.(ErrorStatusEnveloper); ok {
+			status = statusEnveloper.ErrorStatus(status)
+		}
+		writeEncodedResponse(w, format.encoder, status, body)
+		return
+	}
+
+	if format.envelope == EnvelopeV1 {
+		writeEncodedResponse(w, format.encoder, status, resp)
+		return
+	}
+
+	envelopeError := &EnvelopeError{Code: resp.Code, Message: resp.Msg, Details: resp.Data}
+	if incident, ok := resp.Data.(*Incident); ok {
+		envelopeError.Details = nil
+		envelopeError.Incident = incident.ID
+	}
+
+	if registered != nil {
+		envelopeError.Name, envelopeError.Docs = registered.Name, registered.DocsURL
+	}
+
+	writeEncodedResponse(w, format.encoder, status, &Envelope{
+		Error: envelopeError,
+		Meta:  &EnvelopeMeta{Version: format.envelope, Status: status},
+	})
+}
+
+// writeEnvelopedResponse writes the status too unless it has been written by the service method.
+func writeEnvelopedResponse(w http.ResponseWriter, tr trace.Trace, format *responseFormat, status int,
+	statusWritten bool, data interface{}, warnings []*Warning) {
+	tr.LazyPrintf("%+v", data)
+	writeStatus := 0
+	if !statusWritten && status != http.StatusOK {
+		writeStatus = status
+	}
+
+	if format.enveloper != nil {
+		setWarningsHeader(w, warnings)
+		writeEncodedResponse(w, format.encoder, writeStatus, format.enveloper.WrapSuccess(data))
+		return
+	}
+
+	if format.envelope == EnvelopeV1 {
+		setWarningsHeader(w, warnings)
+		if format.wrapSuccess {
+			data = &FormattedResponse{0, "ok", data}
+		}
+		writeEncodedResponse(w, format.encoder, writeStatus, data)
+		return
+	}
+
+	meta := &EnvelopeMeta{Version: format.envelope, Status: status}
+	for _, warning := range warnings {
+		meta.Degraded = meta.Degraded || warning.Code == DegradedWarningCode
+	}
+
+	writeEncodedResponse(w, format.encoder, writeStatus, &Envelope{
+		Data:     data,
+		Warnings: warnings,
+		Meta:     meta,
+	})
+}
@@ -0,0 +1,239 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseEnvelope(t *testing.T) {
+	type result struct {
+		A int `json:"a"`
+	}
+
+	newRouter := func(version int) *httprouter.Router {
+		router, err := NewHTTPRouter([]*Route{{
+			Method: "GET",
+			Path:   "/ok",
+			Function: func(*ServiceMethodContext, *struct{}) (*result, error) {
+				return &result{1}, nil
+			},
+			EnvelopeVersion: version,
+		}, {
+			Method: "GET",
+			Path:   "/error",
+			Function: func(ctx *ServiceMethodContext, _ *struct{}) (*result, error) {
+				ctx.ResponseStatusSetter(403)
+				return nil, errors.New("denied")
+			},
+			EnvelopeVersion: version,
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return router
+	}
+
+	cases := []struct {
+		version int
+		header  string
+		path    string
+		expect  string
+	}{
+		{0, "", "/ok", "{\"a\":1}\n"},
+		{0, "", "/error", "{\"code\":403,\"msg\":\"service method error\",\"data\":\"denied\"}\n"},
+		{0, "2", "/ok", "{\"data\":{\"a\":1},\"meta\":{\"version\":2,\"status\":200}}\n"},
+		{EnvelopeV2, "", "/error", "{\"error\":{\"code\":403,\"message\":\"service method error\"," +
+			"\"details\":\"denied\"},\"meta\":{\"version\":2,\"status\":403}}\n"},
+		{EnvelopeV2, "1", "/ok", "{\"a\":1}\n"},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", c.path, nil)
+		if c.header != "" {
+			r.Header.Set(EnvelopeVersionHeader, c.header)
+		}
+
+		recorder := httptest.NewRecorder()
+		newRouter(c.version).ServeHTTP(recorder, r)
+		if recorder.Body.String() != c.expect {
+			t.Error(c.version, c.header, c.path, recorder.Body.String())
+		}
+	}
+}
+
+func TestResponseWarnings(t *testing.T) {
+	var logs bytes.Buffer
+	router, err := NewLoggingHTTPRouter([]*Route{{
+		Method: "GET",
+		Path:   "/bulk",
+		Function: func(ctx *ServiceMethodContext, _ *struct{}) (*struct{ Done int }, error) {
+			ctx.AddWarning(&Warning{Code: "skipped", Message: "item not found", Target: "2"})
+			return &struct{ Done int }{2}, nil
+		},
+	}}, nil, &logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	warnings := "[{\"code\":\"skipped\",\"message\":\"item not found\",\"target\":\"2\"}]"
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/bulk", nil))
+	if recorder.Header().Get(WarningsHeader) != warnings || recorder.Body.String() != "{\"Done\":2}\n" {
+		t.Error(recorder.Header(), recorder.Body.String())
+	}
+
+	if !strings.Contains(logs.String(), "warnings=\"[{\\\"code\\\":\\\"skipped\\\"") {
+		t.Error(logs.String())
+	}
+
+	r := httptest.NewRequest("GET", "/bulk", nil)
+	r.Header.Set(EnvelopeVersionHeader, "2")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, r)
+	expect := "{\"data\":{\"Done\":2},\"warnings\":" + warnings + ",\"meta\":{\"version\":2,\"status\":200}}\n"
+	if recorder.Header().Get(WarningsHeader) != "" || recorder.Body.String() != expect {
+		t.Error(recorder.Header(), recorder.Body.String())
+	}
+}
+
+type errnoEnveloper struct{}
+
+func (errnoEnveloper) WrapSuccess(data interface{}) interface{} {
+	return map[string]interface{}{"errno": 0, "errmsg": "", "result": data}
+}
+
+func (errnoEnveloper) WrapError(status int, msg string, detail interface{}) interface{} {
+	return map[string]interface{}{"errno": status, "errmsg": msg}
+}
+
+func TestResponseEnveloper(t *testing.T) {
+	router, err := NewHTTPRouterWithOptions([]*Route{{
+		Method: "GET",
+		Path:   "/ok",
+		Function: func(ctx *ServiceMethodContext, _ *struct{}) (*struct{ A int }, error) {
+			ctx.AddWarning(&Warning{Code: "deprecated"})
+			return &struct{ A int }{1}, nil
+		},
+	}, {
+		Method: "GET",
+		Path:   "/error",
+		Function: func(ctx *ServiceMethodContext, _ *struct{}) (*struct{ A int }, error) {
+			ctx.ResponseStatusSetter(403)
+			return nil, errors.New("denied")
+		},
+	}}, &RouterOptions{Enveloper: errnoEnveloper{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/ok", 200, "{\"errmsg\":\"\",\"errno\":0,\"result\":{\"A\":1}}\n"},
+		{"/error", 403, "{\"errmsg\":\"service method error\",\"errno\":403}\n"},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", c.path, nil)
+		r.Header.Set(EnvelopeVersionHeader, "2")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		if recorder.Code != c.status || recorder.Body.String() != c.body ||
+			recorder.Header().Get(EnvelopeVersionHeader) != "" {
+			t.Error(c.path, recorder.Code, recorder.Body.String(), recorder.Header())
+		}
+	}
+}
+
+type partnerEnveloper struct{}
+
+func (partnerEnveloper) WrapSuccess(data interface{}) interface{} {
+	return map[string]interface{}{"errcode": 0, "errmsg": "ok"}
+}
+
+func (partnerEnveloper) WrapError(status int, msg string, detail interface{}) interface{} {
+	return map[string]interface{}{"errcode": status, "errmsg": msg}
+}
+
+func (partnerEnveloper) ErrorStatus(status int) int {
+	return http.StatusOK
+}
+
+func TestRouteEnveloper(t *testing.T) {
+	callback := func(ctx *ServiceMethodContext, arg *struct {
+		Event string `schema:"event"`
+	}) (*struct{}, error) {
+		if arg.Event == "" {
+			return nil, &StatusError{Status: 400, Message: "no event"}
+		}
+		return &struct{}{}, nil
+	}
+
+	router, err := NewHTTPRouter([]*Route{{
+		Method:    "GET",
+		Path:      "/callback",
+		Function:  callback,
+		Enveloper: partnerEnveloper{},
+	}, {
+		Method:   "GET",
+		Path:     "/api",
+		Function: callback,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		uri    string
+		status int
+		body   string
+	}{
+		{"/callback?event=paid", 200, "{\"errcode\":0,\"errmsg\":\"ok\"}\n"},
+		{"/callback", 200, "{\"errcode\":400,\"errmsg\":\"no event\"}\n"},
+		{"/api", 400, "{\"code\":400,\"msg\":\"no event\",\"data\":null}\n"},
+	}
+
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", c.uri, nil))
+		if recorder.Code != c.status || recorder.Body.String() != c.body {
+			t.Error(c.uri, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestWrapSuccessResponses(t *testing.T) {
+	router, err := NewHTTPRouter([]*Route{{
+		Method: "GET",
+		Path:   "/",
+		Function: func(*ServiceMethodContext, *struct{}) (*struct{ A int }, error) {
+			return &struct{ A int }{1}, nil
+		},
+		WrapSuccessResponses: true,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	if body := recorder.Body.String(); body != "{\"code\":0,\"msg\":\"ok\",\"data\":{\"A\":1}}\n" {
+		t.Error(body)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(EnvelopeVersionHeader, "2")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, r)
+	if body := recorder.Body.String(); !strings.HasPrefix(body, "{\"data\":{\"A\":1},\"meta\"") {
+		t.Error(body)
+	}
+}
@@ -0,0 +1,158 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrorCode is a registered machine readable error, it's an HTTPError of its default message.
+type ErrorCode struct {
+	// Code is in the response bodies, it's unique in the registry.
+	Code int `json:"code"`
+	// Name is the constant like "USER_NOT_FOUND", it's unique in the registry.
+	Name        string `json:"name"`
+	Status      int    `json:"status"`
+	Message     string `json:"message"`
+	DocsURL     string `json:"docs,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// ErrorCodeRegistry is the catalog of the error codes, which can be published by WriteMarkdown and WriteJSON.
+type ErrorCodeRegistry struct {
+	mutex sync.RWMutex
+	codes map[int]*ErrorCode
+	names map[string]bool
+}
+
+// DefaultErrorCodes is the registry of RegisterErrorCode, whose codes are looked up by the responses.
+var DefaultErrorCodes = NewErrorCodeRegistry()
+
+func (c *ErrorCode) Error() string {
+	return c.Message
+}
+
+func (c *ErrorCode) StatusCode() int {
+	return c.Status
+}
+
+func (c *ErrorCode) ErrorCode() int {
+	return c.Code
+}
+
+func (c *ErrorCode) PublicMessage() string {
+	return c.Message
+}
+
+// WithMessage returns the error of the code having another public message.
+func (c *ErrorCode) WithMessage(format string, args ...interface{}) HTTPError {
+	return &StatusError{Status: c.Status, Code: c.Code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap returns the error of the code and the default message, the cause is only recorded into the incident of 5xx.
+func (c *ErrorCode) Wrap(err error) HTTPError {
+	return &StatusError{Status: c.Status, Code: c.Code, Message: c.Message, Err: err}
+}
+
+func NewErrorCodeRegistry() *ErrorCodeRegistry {
+	return &ErrorCodeRegistry{codes: make(map[int]*ErrorCode), names: make(map[string]bool)}
+}
+
+func (registry *ErrorCodeRegistry) Register(code *ErrorCode) error {
+	if code.Code == 0 || code.Name == "" || code.Status < 400 || code.Status > 599 {
+		return fmt.Errorf("the error code should have a code, a name and a 4xx or 5xx status")
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if _, ok := registry.codes[code.Code]; ok {
+		return fmt.Errorf("duplicate error code %d", code.Code)
+	}
+
+	if registry.names[code.Name] {
+		return fmt.Errorf("duplicate error code name %s", code.Name)
+	}
+
+	registry.codes[code.Code] = code
+	registry.names[code.Name] = true
+	return nil
+}
+
+// MustRegister is for the package level variables of the error codes, it panics if Register fails.
+func (registry *ErrorCodeRegistry) MustRegister(code *ErrorCode) *ErrorCode {
+	if err := registry.Register(code); err != nil {
+		panic(err)
+	}
+
+	return code
+}
+
+// Lookup returns nil if the code is not registered, a nil registry has no codes.
+func (registry *ErrorCodeRegistry) Lookup(code int) *ErrorCode {
+	if registry == nil {
+		return nil
+	}
+
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	return registry.codes[code]
+}
+
+// Codes returns the registered codes in order.
+func (registry *ErrorCodeRegistry) Codes() []*ErrorCode {
+	registry.mutex.RLock()
+	codes := make([]*ErrorCode, 0, len(registry.codes))
+	for _, code := range registry.codes {
+		codes = append(codes, code)
+	}
+	registry.mutex.RUnlock()
+
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i].Code < codes[j].Code
+	})
+	return codes
+}
+
+// WriteJSON writes the catalog as a JSON array of the codes in order.
+func (registry *ErrorCodeRegistry) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(registry.Codes())
+}
+
+func escapeMarkdownCell(s string) string {
+	return strings.Replace(strings.Replace(s, "|", `\|`, -1), "\n", " ", -1)
+}
+
+// WriteMarkdown writes the catalog as a Markdown table, the names are linked to the docs urls.
+func (registry *ErrorCodeRegistry) WriteMarkdown(w io.Writer) error {
+	_, err := io.WriteString(w, "| Code | Name | Status | Message | Description |\n| --- | --- | --- | --- | --- |\n")
+	if err != nil {
+		return err
+	}
+
+	for _, code := range registry.Codes() {
+		name := "`" + code.Name + "`"
+		if code.DocsURL != "" {
+			name = "[" + name + "](" + code.DocsURL + ")"
+		}
+
+		_, err = fmt.Fprintf(w, "| %d | %s | %d %s | %s | %s |\n", code.Code, name, code.Status,
+			http.StatusText(code.Status), escapeMarkdownCell(code.Message), escapeMarkdownCell(code.Description))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RegisterErrorCode registers the code into DefaultErrorCodes.
+func RegisterErrorCode(code *ErrorCode) *ErrorCode {
+	return DefaultErrorCodes.MustRegister(code)
+}
@@ -0,0 +1,84 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var errTestUserNotFound = RegisterErrorCode(&ErrorCode{Code: 40401, Name: "USER_NOT_FOUND", Status: 404,
+	Message: "user not found", DocsURL: "https://example.com/errors#USER_NOT_FOUND"})
+
+func TestErrorCodeResponses(t *testing.T) {
+	errs := []error{errTestUserNotFound, errTestUserNotFound.WithMessage("user %s not found", "u1"),
+		errTestUserNotFound.Wrap(errors.New("no rows"))}
+	for i, methodErr := range errs {
+		handler, err := NewServiceHandler(func(ctx *ServiceMethodContext, arg *struct{}) error {
+			return methodErr
+		}, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		handler.SetEnvelopeVersion(EnvelopeV2)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		envelope := &Envelope{}
+		if err := json.Unmarshal(w.Body.Bytes(), envelope); err != nil || w.Code != 404 || envelope.Error == nil {
+			t.Fatal(i, w.Code, w.Body.String())
+		}
+
+		e := envelope.Error
+		if e.Code != 40401 || e.Name != "USER_NOT_FOUND" || e.Docs != errTestUserNotFound.DocsURL ||
+			strings.Contains(w.Body.String(), "no rows") {
+			t.Error(i, w.Body.String())
+		}
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(EnvelopeVersionHeader, "1")
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Header().Get("Link") != `<https://example.com/errors#USER_NOT_FOUND>; rel="help"` {
+			t.Error(i, w.Header())
+		}
+	}
+}
+
+func TestErrorCodeRegistry(t *testing.T) {
+	registry := NewErrorCodeRegistry()
+	registry.MustRegister(&ErrorCode{Code: 50001, Name: "STORAGE_DOWN", Status: 503, Message: "storage | down"})
+	registry.MustRegister(&ErrorCode{Code: 40001, Name: "BAD_PAGE", Status: 400, Message: "bad page",
+		DocsURL: "https://example.com/BAD_PAGE"})
+
+	invalid := []*ErrorCode{
+		{Code: 40001, Name: "OTHER", Status: 400},
+		{Code: 40002, Name: "BAD_PAGE", Status: 400},
+		{Code: 40003, Name: "OK", Status: 200},
+	}
+	for _, code := range invalid {
+		if err := registry.Register(code); err == nil {
+			t.Error("the invalid code is registered", code)
+		}
+	}
+
+	markdown := &bytes.Buffer{}
+	if err := registry.WriteMarkdown(markdown); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(markdown.String()), "\n")
+	row := "| 40001 | [`BAD_PAGE`](https://example.com/BAD_PAGE) | 400 Bad Request | bad page |  |"
+	if len(lines) != 4 || lines[2] != row || !strings.Contains(lines[3], `storage \| down`) {
+		t.Error(markdown.String())
+	}
+
+	catalog := &bytes.Buffer{}
+	var codes []*ErrorCode
+	if err := registry.WriteJSON(catalog); err != nil || json.Unmarshal(catalog.Bytes(), &codes) != nil ||
+		len(codes) != 2 || codes[1].Name != "STORAGE_DOWN" {
+		t.Error(catalog.String())
+	}
+}
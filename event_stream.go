@@ -0,0 +1,397 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/trace"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Event struct {
+	ID   string          `json:"id,omitempty"`
+	Type string          `json:"type,omitempty"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Subscriber is the pub/sub backend of the event stream routes, the channel should be closed once ctx is done.
+type Subscriber interface {
+	Subscribe(ctx context.Context, topic string) (<-chan *Event, error)
+}
+
+// EventFilter is returned by 'func(*ServiceMethodContext, *struct) (EventFilter, error)', nil accepts all.
+type EventFilter func(event *Event) bool
+
+// EventStream turns a Route into an SSE or WebSocket (chosen by the handshake) subscription of the Topic.
+type EventStream struct {
+	Subscriber Subscriber
+	Topic      string
+	// BufferSize is the count of events buffered for each client, the clients which can't catch up are disconnected.
+	BufferSize        int
+	HeartbeatInterval time.Duration
+	WriteTimeout      time.Duration
+}
+
+type eventStreamHandler struct {
+	stream           *EventStream
+	handler          *ServiceHandler
+	loggerContextKey interface{}
+	cors             *CORSPolicy
+}
+
+type eventStreamWriter interface {
+	writeEvent(event *Event) error
+	writeHeartbeat() error
+	close(reason string)
+}
+
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+type wsEventWriter struct {
+	conn    *wsConn
+	timeout time.Duration
+}
+
+var eventFilterType = reflect.TypeOf(EventFilter(nil))
+
+const (
+	defaultEventStreamBufferSize = 64
+	defaultHeartbeatInterval     = 30 * time.Second
+	defaultEventWriteTimeout     = 10 * time.Second
+)
+
+func checkEventStreamMethodPrototype(methodType reflect.Type) error {
+	if methodType == nil || methodType.Kind() != reflect.Func {
+		return fmt.Errorf("you should provide a function or object method")
+	}
+
+	if methodType.NumIn() != 2 || !isTypeServiceMethodContext(methodType.In(0)) ||
+		!isStructPointer(methodType.In(1)) {
+		return fmt.Errorf("the subscription method should have arguments (*ServiceMethodContext, *struct)")
+	}
+
+	if methodType.NumOut() != 2 || methodType.Out(0) != eventFilterType ||
+		methodType.Out(1).Kind() != reflect.Interface || methodType.Out(1).Name() != "error" {
+		return fmt.Errorf("the subscription method should return (EventFilter, error)")
+	}
+
+	return nil
+}
+
+func newEventStreamHandle(stream *EventStream, handler *ServiceHandler, loggerContextKey interface{},
+	cors *CORSPolicy) (httprouter.Handle, error) {
+	if stream.Subscriber == nil {
+		return nil, fmt.Errorf("the event stream has no subscriber")
+	}
+
+	h := &eventStreamHandler{stream: stream, handler: handler, loggerContextKey: loggerContextKey, cors: cors}
+	return h.serve, nil
+}
+
+// newStreamServiceHandler makes the ServiceHandler binding the arguments of the long-lived routes.
+func newStreamServiceHandler(rt *Route, loggerContextKey interface{}, options *RouterOptions) (*ServiceHandler, error) {
+	handler := newServiceHandler(rt.Function, loggerContextKey, true)
+	if err := setRouteServiceHandler(handler, rt, options); err != nil {
+		return nil, err
+	}
+
+	return handler, nil
+}
+
+func (h *eventStreamHandler) record(r *http.Request, field string, value string) {
+	if h.loggerContextKey == nil {
+		return
+	}
+
+	if logger, ok := r.Context().Value(h.loggerContextKey).(MethodLogger); ok {
+		logger.Record(field, value)
+	}
+}
+
+func (h *eventStreamHandler) serve(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	tracer := trace.New(traceFamily, r.URL.Path)
+	defer tracer.Finish()
+
+	w, r, endSpan := h.handler.startSpan(w, r)
+	defer endSpan()
+
+	format := h.handler.negotiateResponseFormat(w, r)
+	if isWebSocketRequest(r) && !webSocketOriginAllowed(r, h.cors) {
+		writeEnvelopedError(w, tracer, format, &FormattedResponse{403, "websocket origin not allowed", nil})
+		return
+	}
+
+	methodCtx, cancelMethodCtx, md, err := requestContext(r)
+	if err != nil {
+		writeEnvelopedError(w, tracer, format, &FormattedResponse{400, "parse request metadata failed", err.Error()})
+		return
+	}
+	defer cancelMethodCtx()
+	h.handler.setMappedResponseHeaders(w, methodCtx)
+
+	arg, in := h.handler.method.newArgument()
+	if err := h.handler.parseArgument(r, params, arg.Interface()); err != nil {
+		writeEnvelopedError(w, tracer, format, parseFailedResponse(err))
+		return
+	}
+
+	if err := h.handler.validateArgument(arg); err != nil {
+		writeEnvelopedError(w, tracer, format, validationFailedResponse(err))
+		return
+	}
+
+	goroutines := newGoroutineGroup()
+	defer h.handler.closeGoroutines(w, goroutines, h.handler.methodLogger(r))
+
+	respStatus := http.StatusOK
+	out, methodPanic := doServiceMethodCall(h.handler.method, []reflect.Value{
+		reflect.ValueOf(&ServiceMethodContext{
+			Context:              methodCtx,
+			RemoteAddr:           r.RemoteAddr,
+			RequestHeader:        r.Header,
+			RequestBodyReader:    r.Body,
+			ResponseStatusSetter: func(status int) { respStatus = status },
+			ResponseHeader:       w.Header(),
+			ResponseBodyWriter:   ioutil.Discard,
+			Metadata:             md,
+			Locales:              requestLocales(r),
+			Principal:            PrincipalFromContext(r.Context()),
+			goroutines:           goroutines,
+			logger:               h.handler.methodLogger(r),
+		}),
+		in,
+	})
+
+	if methodPanic != nil {
+		recordSpanPanic(oteltrace.SpanFromContext(methodCtx), methodPanic)
+		writeEnvelopedError(w, tracer, format, h.handler.panicResponse(w, r, tracer, methodPanic))
+		return
+	}
+
+	if methodError, _ := out[1].Interface().(error); methodError != nil {
+		if respStatus == http.StatusOK {
+			respStatus = http.StatusInternalServerError
+		}
+
+		writeEnvelopedError(w, tracer, format,
+			h.handler.reportIncident(w, r, tracer, &FormattedResponse{respStatus, "service method error", methodError.Error()}))
+		return
+	}
+
+	filter, _ := out[0].Interface().(EventFilter)
+
+	// the timeout header only limits the subscription method, not the stream.
+	ctx, cancel := context.WithCancel(NewLocalesContext(NewMetadataContext(r.Context(), md), requestLocales(r)))
+	defer cancel()
+
+	events, err := h.stream.Subscriber.Subscribe(ctx, h.stream.Topic)
+	if err != nil {
+		writeEnvelopedError(w, tracer, format, &FormattedResponse{503, "subscribe failed", err.Error()})
+		return
+	}
+
+	var writer eventStreamWriter
+	closed := make(chan struct{})
+	if isWebSocketRequest(r) {
+		conn, err := upgradeWebSocket(w, r)
+		if err != nil {
+			writeEnvelopedError(w, tracer, format, &FormattedResponse{400, "websocket handshake failed", err.Error()})
+			return
+		}
+
+		defer conn.Close()
+		writer = &wsEventWriter{conn: conn, timeout: h.writeTimeout()}
+
+		// the clients only send control frames, read them to detect disconnections.
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(wsMaxControlPayload); err != nil {
+					return
+				}
+			}
+		}()
+	} else {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeEnvelopedError(w, tracer, format, &FormattedResponse{500, "streaming unsupported", nil})
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		writer = &sseWriter{w: w, flusher: flusher}
+	}
+
+	sent, reason := h.pump(ctx, events, filter, writer, closed)
+	writer.close(reason)
+	tracer.LazyPrintf("stream closed after %d events: %s", sent, reason)
+	h.record(r, "eventsSent", strconv.Itoa(sent))
+	h.record(r, "streamClosed", reason)
+}
+
+func (h *eventStreamHandler) writeTimeout() time.Duration {
+	if h.stream.WriteTimeout > 0 {
+		return h.stream.WriteTimeout
+	}
+
+	return defaultEventWriteTimeout
+}
+
+// pump disconnects the client letting the buffer overflow.
+func (h *eventStreamHandler) pump(ctx context.Context, events <-chan *Event, filter EventFilter,
+	writer eventStreamWriter, clientClosed <-chan struct{}) (sent int, reason string) {
+	bufferSize := h.stream.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultEventStreamBufferSize
+	}
+
+	heartbeatInterval := h.stream.HeartbeatInterval
+	if heartbeatInterval <= 0 {
+		heartbeatInterval = defaultHeartbeatInterval
+	}
+
+	buffer := make(chan *Event, bufferSize)
+	overflow := make(chan struct{})
+	go func() {
+		defer close(buffer)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+
+				if filter != nil && !filter(event) {
+					continue
+				}
+
+				select {
+				case buffer <- event:
+				default:
+					close(overflow)
+					return
+				}
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return sent, "client gone"
+		case <-clientClosed:
+			return sent, "client gone"
+		case <-overflow:
+			return sent, "slow client"
+		case <-heartbeat.C:
+			if err := writer.writeHeartbeat(); err != nil {
+				return sent, "write failed: " + err.Error()
+			}
+		case event, ok := <-buffer:
+			if !ok {
+				select {
+				case <-overflow:
+					return sent, "slow client"
+				default:
+				}
+
+				if ctx.Err() != nil {
+					return sent, "client gone"
+				}
+				return sent, "subscription closed"
+			}
+
+			if err := writer.writeEvent(event); err != nil {
+				return sent, "write failed: " + err.Error()
+			}
+			sent++
+		}
+	}
+}
+
+// the line breaks of SSE are removed from the id and the type and split the data, so they can't inject the fields.
+var (
+	sseLineBreaks     = strings.NewReplacer("\r\n", "", "\r", "", "\n", "")
+	sseDataLineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+)
+
+func (s *sseWriter) writeEvent(event *Event) error {
+	sb := strings.Builder{}
+	if id := sseLineBreaks.Replace(event.ID); id != "" {
+		sb.WriteString("id: " + id + "\n")
+	}
+
+	if eventType := sseLineBreaks.Replace(event.Type); eventType != "" {
+		sb.WriteString("event: " + eventType + "\n")
+	}
+
+	data := sseDataLineBreaks.Replace(string(event.Data))
+	for _, line := range strings.Split(data, "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+
+	if _, err := io.WriteString(s.w, sb.String()); err != nil {
+		return err
+	}
+
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseWriter) writeHeartbeat() error {
+	if _, err := io.WriteString(s.w, ": heartbeat\n\n"); err != nil {
+		return err
+	}
+
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseWriter) close(reason string) {
+	if reason == "slow client" {
+		_, _ = io.WriteString(s.w, "event: error\ndata: {\"msg\":\"slow client\"}\n\n")
+		s.flusher.Flush()
+	}
+}
+
+func (ws *wsEventWriter) writeEvent(event *Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return ws.conn.WriteText(payload, time.Now().Add(ws.timeout))
+}
+
+func (ws *wsEventWriter) writeHeartbeat() error {
+	return ws.conn.WritePing(time.Now().Add(ws.timeout))
+}
+
+func (ws *wsEventWriter) close(reason string) {
+	code := uint16(1000)
+	if reason == "slow client" {
+		code = 1008
+	}
+
+	_ = ws.conn.WriteClose(code, reason, time.Now().Add(time.Second))
+}
@@ -0,0 +1,210 @@
+package apihttpwrapper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// feedSubscriber sends the events, and keeps the subscription open until ctx is done.
+type feedSubscriber []*Event
+
+func (s feedSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *Event, error) {
+	events := make(chan *Event)
+	go func() {
+		defer close(events)
+		for _, event := range s {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+		<-ctx.Done()
+	}()
+
+	return events, nil
+}
+
+// slowEventWriter blocks the first event written, so the events sent meanwhile overflow the buffer.
+type slowEventWriter struct {
+	http.ResponseWriter
+	once sync.Once
+}
+
+func (w *slowEventWriter) Write(p []byte) (int, error) {
+	if bytes.HasPrefix(p, []byte("id: ")) {
+		w.once.Do(func() { time.Sleep(50 * time.Millisecond) })
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *slowEventWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func TestEventStream(t *testing.T) {
+	type roomArguments struct {
+		Room string `uri:"room"`
+	}
+
+	subscriber := feedSubscriber{{ID: "1", Type: "a", Data: []byte(`{"n":1}`)},
+		{ID: "2", Type: "b", Data: []byte(`{"n":2}`)}, {ID: "3", Type: "a", Data: []byte("{\"n\":\n3}")},
+		{ID: "4", Type: "a", Data: []byte(`{"n":4}`)}}
+	subscribe := func(ctx *ServiceMethodContext, arg *roomArguments) (EventFilter, error) {
+		return func(event *Event) bool { return event.Type == arg.Room }, nil
+	}
+	routes := []*Route{
+		{Method: "GET", Path: "/rooms/:room/events", Function: subscribe,
+			EventStream: &EventStream{Subscriber: subscriber}},
+		{Method: "GET", Path: "/slow/:room/events", Function: subscribe,
+			EventStream: &EventStream{Subscriber: subscriber, BufferSize: 1},
+			Middlewares: []func(http.Handler) http.Handler{func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					next.ServeHTTP(&slowEventWriter{ResponseWriter: w}, r)
+				})
+			}}},
+	}
+
+	rows := make(logRowWriter, 1)
+	router, err := NewLoggingHTTPRouter(routes, nil, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// the filtered events are sent by SSE until the client closes the stream.
+	resp, err := http.Get(server.URL + "/rooms/a/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatal(resp.StatusCode, resp.Header)
+	}
+
+	var received []string
+	reader := bufio.NewReader(resp.Body)
+	for !strings.HasPrefix(strings.Join(received, ""), "id: 1\nevent: a\ndata: {\"n\":1}\n\nid: 3\nevent: a\n"+
+		"data: {\"n\":\ndata: 3}\n\nid: 4\nevent: a\ndata: {\"n\":4}\n\n") {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(received, err)
+		}
+		received = append(received, line)
+	}
+	_ = resp.Body.Close()
+
+	row := <-rows
+	for _, field := range []string{"eventsSent=3", `streamClosed="client gone"`} {
+		if !strings.Contains(row, field) {
+			t.Error(field, row)
+		}
+	}
+
+	// the client which lets the buffer overflow is disconnected.
+	resp, err = http.Get(server.URL + "/slow/a/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if !strings.HasPrefix(string(body), "id: 1\n") || strings.Contains(string(body), "id: 4\n") ||
+		!strings.HasSuffix(string(body), "event: error\ndata: {\"msg\":\"slow client\"}\n\n") {
+		t.Errorf("%q", body)
+	}
+
+	if row := <-rows; !strings.Contains(row, `streamClosed="slow client"`) {
+		t.Error(row)
+	}
+
+	// the websocket clients receive the events as the text messages, and close the stream by the close frame.
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	_, err = io.WriteString(conn, "GET /rooms/a/events HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\n"+
+		"Upgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wsReader := bufio.NewReader(conn)
+	handshake, err := http.ReadResponse(wsReader, nil)
+	if err != nil || handshake.StatusCode != 101 {
+		t.Fatal(handshake, err)
+	}
+
+	for _, id := range []string{"1", "3", "4"} {
+		opcode, payload, err := readServerFrame(wsReader)
+		event := &Event{}
+		if err != nil || opcode != wsOpText || json.Unmarshal(payload, event) != nil || event.ID != id {
+			t.Fatal(opcode, string(payload), err)
+		}
+	}
+
+	if err := writeClientFrame(conn, wsOpPing, []byte("p")); err != nil {
+		t.Fatal(err)
+	}
+
+	if opcode, payload, err := readServerFrame(wsReader); err != nil || opcode != wsOpPong || string(payload) != "p" {
+		t.Error(opcode, string(payload), err)
+	}
+
+	if err := writeClientFrame(conn, wsOpClose, []byte{1000 >> 8, 1000 & 0xff}); err != nil {
+		t.Fatal(err)
+	}
+
+	if opcode, _, err := readServerFrame(wsReader); err != nil || opcode != wsOpClose {
+		t.Error(opcode, err)
+	}
+
+	row = <-rows
+	for _, field := range []string{"status=101", "eventsSent=3", `streamClosed="client gone"`} {
+		if !strings.Contains(row, field) {
+			t.Error(field, row)
+		}
+	}
+}
+
+func TestSSEWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	s := &sseWriter{w: w, flusher: w}
+	err := s.writeEvent(&Event{ID: "1\ndata: forged", Type: "a\r\nretry: 1", Data: []byte("{\"n\":\r1}")})
+	if err != nil || w.Body.String() != "id: 1data: forged\nevent: aretry: 1\ndata: {\"n\":\ndata: 1}\n\n" {
+		t.Errorf("%q %v", w.Body.String(), err)
+	}
+
+	function := func(ctx *ServiceMethodContext, arg *struct{}) (EventFilter, error) {
+		return nil, nil
+	}
+	for _, rt := range []*Route{
+		{Canary: &CanaryRoute{Function: function}},
+		{Compatibility: &CompatibilityRules{}},
+		{Destructive: &DestructiveGuard{Secret: []byte("secret")}},
+		{Deduplication: &Deduplication{}},
+	} {
+		rt.Method, rt.Path, rt.Function = "GET", "/events", function
+		rt.EventStream = &EventStream{Subscriber: feedSubscriber{}}
+		if err := ValidateRoutes([]*Route{rt}); err == nil {
+			t.Error("the event stream route is accepted", rt.Canary, rt.Compatibility, rt.Destructive, rt.Deduplication)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DefaultExperimentsHeader is like "X-Experiments: checkout=b, search=control".
+const DefaultExperimentsHeader = "X-Experiments"
+
+// ExperimentResolver assigns the variants of the request, like {"checkout": "b"}.
+type ExperimentResolver interface {
+	Resolve(r *http.Request) map[string]string
+}
+
+// ExperimentOptions are of RouterOptions.Experiments, see ExperimentVariant.
+type ExperimentOptions struct {
+	Resolver ExperimentResolver
+	// EchoHeader makes the responses have the variants for the clients to align with, like DefaultExperimentsHeader.
+	EchoHeader string
+}
+
+// Experiment splits the requests into the variants by the weights, which are equal if nil.
+type Experiment struct {
+	Name     string
+	Variants []string
+	Weights  []int
+}
+
+// HashExperimentResolver assigns the variants by hashing the caller id with the experiment name.
+type HashExperimentResolver struct {
+	Experiments []*Experiment
+	// IDFunc returns the stable id, the subject of the principal by default. the requests without ids get no variants.
+	IDFunc func(r *http.Request) string
+	// OverrideHeader selects the variants explicitly, no overrides if empty.
+	OverrideHeader string
+}
+
+type experimentsContextKey struct{}
+
+func (e *Experiment) variant(id string) string {
+	total := 0
+	for i := range e.Variants {
+		total += e.weight(i)
+	}
+
+	if total <= 0 {
+		return ""
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(e.Name + ":" + id))
+	n := int(h.Sum32() % uint32(total))
+	for i, variant := range e.Variants {
+		if n -= e.weight(i); n < 0 {
+			return variant
+		}
+	}
+
+	return ""
+}
+
+func (e *Experiment) weight(i int) int {
+	if e.Weights == nil {
+		return 1
+	}
+
+	if i < len(e.Weights) {
+		return e.Weights[i]
+	}
+
+	return 0
+}
+
+func (e *Experiment) hasVariant(variant string) bool {
+	for _, v := range e.Variants {
+		if v == variant {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseExperiments parses the header values like "checkout=b, search=control".
+func parseExperiments(values []string) map[string]string {
+	experiments := make(map[string]string)
+	for _, value := range values {
+		for _, pair := range strings.Split(value, ",") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && kv[0] != "" && kv[1] != "" {
+				experiments[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+			}
+		}
+	}
+
+	return experiments
+}
+
+func formatExperiments(experiments map[string]string) string {
+	pairs := make([]string, 0, len(experiments))
+	for name, variant := range experiments {
+		pairs = append(pairs, name+"="+variant)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+func (resolver *HashExperimentResolver) Resolve(r *http.Request) map[string]string {
+	var overrides map[string]string
+	if resolver.OverrideHeader != "" {
+		overrides = parseExperiments(r.Header[http.CanonicalHeaderKey(resolver.OverrideHeader)])
+	}
+
+	id := principalSubject(r)
+	if resolver.IDFunc != nil {
+		id = resolver.IDFunc(r)
+	}
+
+	experiments := make(map[string]string)
+	for _, experiment := range resolver.Experiments {
+		if variant, ok := overrides[experiment.Name]; ok && experiment.hasVariant(variant) {
+			experiments[experiment.Name] = variant
+		} else if id != "" {
+			if variant := experiment.variant(id); variant != "" {
+				experiments[experiment.Name] = variant
+			}
+		}
+	}
+
+	return experiments
+}
+
+// NewExperimentsContext is for the requests not served by the routes, like the ones of the queue consumers.
+func NewExperimentsContext(ctx context.Context, experiments map[string]string) context.Context {
+	return context.WithValue(ctx, experimentsContextKey{}, experiments)
+}
+
+// ExperimentsFromContext returns nil if the experiments are not resolved, the map shouldn't be modified.
+func ExperimentsFromContext(ctx context.Context) map[string]string {
+	experiments, _ := ctx.Value(experimentsContextKey{}).(map[string]string)
+	return experiments
+}
+
+// ExperimentVariant returns the variant of the experiment, or empty if it doesn't apply to the request.
+func ExperimentVariant(ctx context.Context, name string) string {
+	return ExperimentsFromContext(ctx)[name]
+}
+
+func newExperimentHandle(handle httprouter.Handle, options *ExperimentOptions,
+	loggerContextKey interface{}) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		experiments := options.Resolver.Resolve(r)
+		if len(experiments) > 0 {
+			formatted := formatExperiments(experiments)
+			recordLogField(r, loggerContextKey, "experiments", formatted)
+			if options.EchoHeader != "" {
+				w.Header().Set(options.EchoHeader, formatted)
+			}
+		}
+
+		handle(w, r.WithContext(NewExperimentsContext(r.Context(), experiments)), params)
+	}
+}
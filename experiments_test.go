@@ -0,0 +1,77 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExperiments(t *testing.T) {
+	resolver := &HashExperimentResolver{
+		Experiments: []*Experiment{
+			{Name: "checkout", Variants: []string{"control", "b"}},
+			{Name: "search", Variants: []string{"control", "new"}, Weights: []int{0, 1}},
+		},
+		IDFunc:         RateLimitByHeader("X-User"),
+		OverrideHeader: DefaultExperimentsHeader,
+	}
+
+	var variants []string
+	logs := &bytes.Buffer{}
+	handler, err := NewLoggingHTTPRouterWithOptions([]*Route{{Method: "GET", Path: "/",
+		Function: func(ctx *ServiceMethodContext, arg *struct{}) error {
+			variants = append(variants, ExperimentVariant(ctx.Context, "checkout")+"/"+
+				ExperimentVariant(ctx.Context, "search"))
+			return nil
+		}}}, nil, logs, &RouterOptions{Experiments: &ExperimentOptions{Resolver: resolver,
+		EchoHeader: DefaultExperimentsHeader}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(user string, override string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		if user != "" {
+			r.Header.Set("X-User", user)
+		}
+		if override != "" {
+			r.Header.Set(DefaultExperimentsHeader, override)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	first := serve("u1", "")
+	if serve("u1", "").Header().Get(DefaultExperimentsHeader) != first.Header().Get(DefaultExperimentsHeader) ||
+		!strings.HasSuffix(variants[0], "/new") || variants[0] != variants[1] {
+		t.Error("the variants of the same caller differ", variants)
+	}
+
+	seen := map[string]bool{}
+	for _, user := range []string{"u1", "u2", "u3", "u4", "u5", "u6", "u7", "u8"} {
+		w := serve(user, "")
+		seen[w.Header().Get(DefaultExperimentsHeader)] = true
+	}
+	if len(seen) != 2 {
+		t.Error("the callers are not split", seen)
+	}
+
+	w := serve("", "checkout=b, search=unknown, other=x")
+	if w.Header().Get(DefaultExperimentsHeader) != "checkout=b" || variants[len(variants)-1] != "b/" {
+		t.Error(w.Header(), variants)
+	}
+
+	if w := serve("", ""); w.Header().Get(DefaultExperimentsHeader) != "" {
+		t.Error("the caller without id is assigned", w.Header())
+	}
+
+	if !strings.Contains(logs.String(), "experiments=\"checkout=b\"") {
+		t.Error(logs.String())
+	}
+
+	if ExperimentsFromContext(httptest.NewRequest("GET", "/", nil).Context()) != nil {
+		t.Error("the experiments are resolved without resolver")
+	}
+}
@@ -0,0 +1,163 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FakeResponse is Route.Fake, the data is generated for the response type instead of calling the method.
+type FakeResponse struct {
+	Seed int64
+	// Length is of the generated slices and maps, 2 if not positive.
+	Length int
+}
+
+const (
+	defaultFakeLength = 2
+	// maxFakeDepth stops generating the recursive types, the deeper pointers, slices and maps are nil.
+	maxFakeDepth = 8
+)
+
+var fakeTimeBase = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// function returns the function of the same type as the method, which returns the generated data.
+func (f *FakeResponse) function(method interface{}) interface{} {
+	methodType := reflect.TypeOf(method)
+	return reflect.MakeFunc(methodType, func(in []reflect.Value) []reflect.Value {
+		var methodCtx *ServiceMethodContext
+		if isTypeServiceMethodContext(methodType.In(0)) {
+			methodCtx = in[0].Interface().(*ServiceMethodContext)
+		} else if ctx, ok := in[0].Interface().(context.Context); ok {
+			methodCtx = ServiceMethodContextFromContext(ctx)
+		}
+
+		if methodCtx != nil {
+			methodCtx.Logger().Record("fake", "true")
+		}
+
+		out := []reflect.Value{reflect.Zero(errorType)}
+		if methodType.NumOut() == 1 {
+			return out
+		}
+
+		h := fnv.New64a()
+		if arg, err := json.Marshal(in[1].Interface()); err == nil {
+			h.Write(arg)
+		}
+
+		g := &fakeGenerator{rand: rand.New(rand.NewSource(f.Seed ^ int64(h.Sum64()))), length: f.Length}
+		if g.length <= 0 {
+			g.length = defaultFakeLength
+		}
+
+		v := reflect.New(methodType.Out(0)).Elem()
+		g.fill(v, "", 0)
+		return append([]reflect.Value{v}, out...)
+	}).Interface()
+}
+
+type fakeGenerator struct {
+	rand   *rand.Rand
+	length int
+}
+
+// fill sets v by its type, name is of the field for the strings.
+func (g *fakeGenerator) fill(v reflect.Value, name string, depth int) {
+	switch {
+	case v.Type() == timeType:
+		v.Set(reflect.ValueOf(fakeTimeBase.Add(time.Duration(g.rand.Intn(365*24*3600)) * time.Second)))
+		return
+	case v.Type() == bulkResultType:
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(g.rand.Intn(2) == 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(g.rand.Intn(100)))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(uint64(g.rand.Intn(100)))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(g.rand.Intn(100000)) / 100)
+	case reflect.String:
+		if name == "" {
+			name = "string"
+		}
+		v.SetString(strings.ToLower(name) + "-" + strconv.Itoa(g.rand.Intn(10000)))
+	case reflect.Ptr:
+		if depth < maxFakeDepth {
+			v.Set(reflect.New(v.Type().Elem()))
+			g.fill(v.Elem(), name, depth+1)
+		}
+	case reflect.Slice:
+		if depth >= maxFakeDepth {
+			return
+		}
+
+		v.Set(reflect.MakeSlice(v.Type(), g.length, g.length))
+		for i := 0; i < g.length; i++ {
+			g.fill(v.Index(i), name, depth+1)
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			g.fill(v.Index(i), name, depth+1)
+		}
+	case reflect.Map:
+		if depth >= maxFakeDepth || v.Type().Key().Kind() != reflect.String {
+			return
+		}
+
+		v.Set(reflect.MakeMap(v.Type()))
+		for i := 1; i <= g.length; i++ {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			g.fill(elem, name, depth+1)
+			v.SetMapIndex(reflect.ValueOf("key"+strconv.Itoa(i)).Convert(v.Type().Key()), elem)
+		}
+	case reflect.Struct:
+		g.fillStruct(v, depth)
+	}
+}
+
+func (g *fakeGenerator) fillStruct(v reflect.Value, depth int) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || field.Tag.Get("json") == "-" {
+			continue
+		}
+
+		if example, ok := field.Tag.Lookup("example"); ok {
+			if err := setExample(v.Field(i), example); err == nil {
+				continue
+			}
+		}
+
+		g.fill(v.Field(i), field.Name, depth+1)
+	}
+}
+
+func setExample(v reflect.Value, example string) error {
+	if v.Kind() == reflect.String {
+		v.SetString(example)
+		return nil
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(example))
+	}
+
+	if err := json.Unmarshal([]byte(example), v.Addr().Interface()); err != nil {
+		return fmt.Errorf("invalid example %q: %s", example, err)
+	}
+
+	return nil
+}
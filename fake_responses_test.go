@@ -0,0 +1,83 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFakeResponse(t *testing.T) {
+	type fakeItem struct {
+		Name    string
+		Count   int       `example:"7"`
+		Status  string    `example:"active"`
+		Created time.Time `example:"2021-02-03T04:05:06Z"`
+	}
+
+	type fakeList struct {
+		Items []*fakeItem
+		Total int
+	}
+
+	type listArguments struct {
+		Query string `schema:"q"`
+	}
+
+	rows := make(logRowWriter, 4)
+	router, err := NewLoggingHTTPRouter([]*Route{{Method: "GET", Path: "/items", Fake: &FakeResponse{Seed: 1},
+		Function: func(ctx *ServiceMethodContext, arg *listArguments) (*fakeList, error) {
+			t.Error("the method is called")
+			return nil, nil
+		}}}, nil, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(query string) string {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/items?q="+query, nil))
+		if w.Code != 200 {
+			t.Error(w.Code, w.Body.String())
+		}
+
+		if row := <-rows; !strings.Contains(row, "fake=true") {
+			t.Error("the fake response is not logged", row)
+		}
+
+		return w.Body.String()
+	}
+
+	first := get("a")
+	if again := get("a"); again != first {
+		t.Error("the fake response is not deterministic", first, again)
+	}
+
+	if other := get("b"); other == first {
+		t.Error("the fake response doesn't depend on the arguments", other)
+	}
+
+	resp := &fakeList{}
+	if err := json.Unmarshal([]byte(first), resp); err != nil {
+		t.Fatal(first, err)
+	}
+
+	if len(resp.Items) != 2 {
+		t.Fatal("the slice length is not the default", first)
+	}
+
+	for _, item := range resp.Items {
+		if item.Count != 7 || item.Status != "active" || !strings.HasPrefix(item.Name, "name-") ||
+			!item.Created.Equal(time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC)) {
+			t.Error("the item is not generated by the examples", item)
+		}
+	}
+
+	if err := ValidateRoutes([]*Route{{Method: "GET", Path: "/", Fake: &FakeResponse{}, WebSocket: &WebSocket{},
+		Function: func(*ServiceMethodContext, *struct{}, *WebSocketConn) error {
+			return nil
+		}}}); err == nil {
+		t.Error("the fake websocket route is accepted")
+	}
+}
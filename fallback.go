@@ -0,0 +1,129 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// DegradedWarningCode is of the warning added to the responses of the fallbacks.
+const DegradedWarningCode = "degraded"
+
+// SetFallback sets the function of the same prototype called when the method fails, see Route.Fallback.
+func (h *ServiceHandler) SetFallback(fallback interface{}) error {
+	if fallback == nil {
+		h.fallback = nil
+		return nil
+	}
+
+	methodType := h.method.value.Type()
+	if reflect.TypeOf(fallback) != methodType {
+		return fmt.Errorf("the fallback should have the same prototype as the method: %s", methodType)
+	}
+
+	if methodType.NumOut() != 2 || isEventStreamFunction(methodType) {
+		return fmt.Errorf("the fallback is only of the methods returning the data")
+	}
+
+	h.fallback = newServiceMethod(fallback)
+	return nil
+}
+
+// fallbackCause returns the failure of the method the fallback is called for, "" if the method is fine.
+func fallbackCause(out []reflect.Value, methodPanic *panicStack, timedOut bool) string {
+	if methodPanic != nil {
+		return "panic: " + methodPanic.Panic
+	}
+
+	if timedOut {
+		return "timeout"
+	}
+
+	methodError, _ := out[1].Interface().(error)
+	if httpError, ok := methodError.(HTTPError); ok && httpError.StatusCode() >= 400 && httpError.StatusCode() < 500 {
+		return ""
+	} else if methodError != nil {
+		return methodError.Error()
+	}
+
+	return ""
+}
+
+// copyArgument copies the exported fields deeply, the method may still be running.
+func copyArgument(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.New(v.Type()).Elem()
+		if v.Kind() == reflect.Ptr {
+			c.Set(reflect.New(v.Type().Elem()))
+			c.Elem().Set(copyArgument(v.Elem()))
+		} else {
+			c.Set(copyArgument(v.Elem()))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < c.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(copyArgument(v.Field(i)))
+			}
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyArgument(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyArgument(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, k := range v.MapKeys() {
+			c.SetMapIndex(k, copyArgument(v.MapIndex(k)))
+		}
+		return c
+	}
+
+	return v
+}
+
+// callFallback returns nil if the fallback fails too.
+func (h *ServiceHandler) callFallback(w http.ResponseWriter, r *http.Request, methodCtx *ServiceMethodContext,
+	ctx context.Context, in reflect.Value, setStatus func(int)) ([]reflect.Value, *warningList) {
+	fallbackCtx := *methodCtx
+	fallbackCtx.Context = ctx
+	fallbackCtx.ResponseStatusSetter = setStatus
+	fallbackCtx.ResponseHeader = w.Header()
+	fallbackCtx.ResponseBodyWriter = w
+	fallbackCtx.warnings = &warningList{}
+	fallbackCtx.logger = h.methodLogger(r)
+	out, fallbackPanic := doServiceMethodCall(h.fallback, []reflect.Value{reflect.ValueOf(&fallbackCtx), in})
+	if fallbackPanic != nil {
+		h.notifyPanic(r, "fallbackIncidentId", fallbackPanic)
+		return nil, nil
+	} else if !out[1].IsNil() {
+		return nil, nil
+	}
+
+	fallbackCtx.AddWarning(&Warning{Code: DegradedWarningCode, Message: "the response is served by the fallback"})
+	return out, fallbackCtx.warnings
+}
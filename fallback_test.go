@@ -0,0 +1,100 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFallback(t *testing.T) {
+	type itemArguments struct {
+		Mode string `schema:"mode"`
+	}
+
+	type item struct {
+		Name string
+	}
+
+	rows := make(logRowWriter, 4)
+	router, err := NewLoggingHTTPRouter([]*Route{{Method: "GET", Path: "/item", Timeout: 50 * time.Millisecond,
+		Function: func(ctx *ServiceMethodContext, arg *itemArguments) (*item, error) {
+			switch arg.Mode {
+			case "error":
+				return nil, fmt.Errorf("database is down")
+			case "invalid":
+				return nil, &StatusError{Status: 400, Message: "invalid mode"}
+			case "slow":
+				<-ctx.Context.Done()
+				return nil, ctx.Context.Err()
+			case "late":
+				// the method keeps running after the timeout, while the fallback runs.
+				<-ctx.Context.Done()
+				arg.Mode = "changed"
+				ctx.ResponseStatusSetter(500)
+				return nil, ctx.Context.Err()
+			}
+			return &item{Name: "fresh"}, nil
+		},
+		Fallback: func(ctx *ServiceMethodContext, arg *itemArguments) (*item, error) {
+			if ctx.Context.Err() != nil {
+				t.Error("the fallback has the context of the method timeout")
+			}
+			if arg.Mode == "changed" {
+				t.Error("the fallback shares the argument with the timed out method")
+			}
+			return &item{Name: "cached"}, nil
+		}}}, nil, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		mode  string
+		code  int
+		name  string
+		cause string
+	}{
+		{"", 200, "fresh", ""},
+		{"error", 200, "cached", "fallbackCause=\"database is down\""},
+		{"invalid", 400, "", ""},
+		{"slow", 200, "cached", "fallbackCause=timeout"},
+		{"late", 200, "cached", "fallbackCause=timeout"},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/item?mode="+c.mode, nil)
+		r.Header.Set(EnvelopeVersionHeader, "2")
+		router.ServeHTTP(w, r)
+
+		resp := &struct {
+			Data *item
+			Meta *EnvelopeMeta
+		}{}
+		if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil || w.Code != c.code {
+			t.Error(c.mode, w.Code, w.Body.String())
+			continue
+		}
+
+		if c.name != "" && (resp.Data == nil || resp.Data.Name != c.name || resp.Meta.Degraded != (c.cause != "")) {
+			t.Error(c.mode, w.Body.String())
+		}
+
+		row := <-rows
+		if degraded := strings.Contains(row, "degraded=true"); degraded != (c.cause != "") ||
+			!strings.Contains(row, c.cause) {
+			t.Error(c.mode, row)
+		}
+	}
+
+	if err := ValidateRoutes([]*Route{{Method: "GET", Path: "/", Fallback: func() {},
+		EventStream: &EventStream{Subscriber: closedSubscriber{}},
+		Function: func(*ServiceMethodContext, *struct{}) (EventFilter, error) {
+			return nil, nil
+		}}}); err == nil {
+		t.Error("the fallback of the event stream is accepted")
+	}
+}
@@ -0,0 +1,160 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultGoroutineGracePeriod is the wait of the goroutines canceled at the request end.
+const DefaultGoroutineGracePeriod = 100 * time.Millisecond
+
+type goroutineGroup struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running int64
+}
+
+var goroutineGroups = struct {
+	mu      sync.Mutex
+	groups  map[*goroutineGroup]struct{}
+	running int64
+	leaked  int64
+}{groups: make(map[*goroutineGroup]struct{})}
+
+func newGoroutineGroup() *goroutineGroup {
+	g := &goroutineGroup{}
+	g.ctx, g.cancel = context.WithCancel(context.Background())
+
+	goroutineGroups.mu.Lock()
+	goroutineGroups.groups[g] = struct{}{}
+	goroutineGroups.mu.Unlock()
+	return g
+}
+
+// Go runs fn in a goroutine canceled at the request end, the ones not returning are reported as leaked.
+func (ctx *ServiceMethodContext) Go(parent context.Context, fn func(ctx context.Context)) {
+	g := ctx.goroutines
+	if g == nil {
+		// the context is not made by the framework, like in the unit tests of the service methods.
+		go fn(parent)
+		return
+	}
+
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	fnCtx, cancel := context.WithCancel(parent)
+	g.wg.Add(1)
+	atomic.AddInt64(&g.running, 1)
+	atomic.AddInt64(&goroutineGroups.running, 1)
+	go func() {
+		defer func() {
+			cancel()
+			atomic.AddInt64(&goroutineGroups.running, -1)
+			atomic.AddInt64(&g.running, -1)
+			g.wg.Done()
+		}()
+
+		go func() {
+			select {
+			case <-g.ctx.Done():
+				cancel()
+			case <-fnCtx.Done():
+			}
+		}()
+
+		fn(fnCtx)
+	}()
+}
+
+// close returns the count of the goroutines still running after the grace period.
+func (g *goroutineGroup) close(grace time.Duration) int64 {
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		goroutineGroups.mu.Lock()
+		delete(goroutineGroups.groups, g)
+		goroutineGroups.mu.Unlock()
+		close(done)
+	}()
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return 0
+	case <-timer.C:
+	}
+
+	leaked := atomic.LoadInt64(&g.running)
+	atomic.AddInt64(&goroutineGroups.leaked, leaked)
+	return leaked
+}
+
+// ShutdownGoroutines cancels all the goroutines spawned by ServiceMethodContext.Go and waits them until ctx is done.
+func ShutdownGoroutines(ctx context.Context) error {
+	goroutineGroups.mu.Lock()
+	groups := make([]*goroutineGroup, 0, len(goroutineGroups.groups))
+	for g := range goroutineGroups.groups {
+		groups = append(groups, g)
+	}
+	goroutineGroups.mu.Unlock()
+
+	for _, g := range groups {
+		g.cancel()
+	}
+
+	for _, g := range groups {
+		done := make(chan struct{})
+		go func(g *goroutineGroup) {
+			g.wg.Wait()
+			close(done)
+		}(g)
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// GoroutineStats returns the count of the goroutines running and ever leaked.
+func GoroutineStats() (running int64, leaked int64) {
+	return atomic.LoadInt64(&goroutineGroups.running), atomic.LoadInt64(&goroutineGroups.leaked)
+}
+
+// SetGoroutineGracePeriod sets the wait of the goroutines at the request end.
+func (h *ServiceHandler) SetGoroutineGracePeriod(grace time.Duration) {
+	h.goroutineGracePeriod = grace
+}
+
+// closeGoroutines returns the count of the goroutines still running after the grace period.
+func (h *ServiceHandler) closeGoroutines(w http.ResponseWriter, g *goroutineGroup, logger MethodLogger) int64 {
+	grace := h.goroutineGracePeriod
+	if grace <= 0 {
+		grace = DefaultGoroutineGracePeriod
+	}
+
+	// the client shouldn't wait for the goroutines.
+	if flusher, ok := w.(http.Flusher); ok && atomic.LoadInt64(&g.running) > 0 {
+		flusher.Flush()
+	}
+
+	leaked := g.close(grace)
+	if leaked > 0 && logger != nil {
+		logger.Record("leakedGoroutines", strconv.FormatInt(leaked, 10))
+	}
+
+	return leaked
+}
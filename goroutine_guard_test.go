@@ -0,0 +1,60 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGoroutineGuard(t *testing.T) {
+	logs := &bytes.Buffer{}
+	release := make(chan struct{})
+	canceled := make(chan struct{})
+	router, err := NewLoggingHTTPRouter([]*Route{{
+		Method: "GET",
+		Path:   "/",
+		Function: func(ctx *ServiceMethodContext, _ *struct{}) error {
+			// canceled at the request end.
+			ctx.Go(ctx.Context, func(ctx context.Context) {
+				<-ctx.Done()
+				close(canceled)
+			})
+
+			// ignores the cancellation, so it leaks.
+			ctx.Go(nil, func(context.Context) {
+				<-release
+			})
+			return nil
+		},
+	}}, nil, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, leakedBefore := GoroutineStats()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("the goroutine is not canceled")
+	}
+
+	if !strings.Contains(logs.String(), "leakedGoroutines=1") {
+		t.Error(logs.String())
+	}
+
+	if running, leaked := GoroutineStats(); running < 1 || leaked != leakedBefore+1 {
+		t.Error(running, leaked)
+	}
+
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := ShutdownGoroutines(ctx); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,564 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// GraphQLFacade serves the service methods as the fields of Query and Mutation, the arguments are the json fields.
+type GraphQLFacade struct {
+	queries   map[string]*graphQLField
+	mutations map[string]*graphQLField
+	panicHook PanicHook
+}
+
+type graphQLField struct {
+	name    string
+	handler *ServiceHandler
+}
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type GraphQLError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   interface{}     `json:"data"`
+	Errors []*GraphQLError `json:"errors,omitempty"`
+}
+
+// graphQLObject keeps the order of the selections when it is encoded.
+type graphQLObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *graphQLObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *graphQLObject) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(k)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// graphQLFieldName derives the field name from the function name, "pkg.(*T).GetUser-fm" becomes "getUser".
+func graphQLFieldName(function interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(function).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+
+	if name == "" {
+		return name
+	}
+
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// NewGraphQLFacade makes the GET routes queries and the others mutations, named by the functions.
+func NewGraphQLFacade(routes []*Route) (*GraphQLFacade, error) {
+	f := &GraphQLFacade{
+		queries:   make(map[string]*graphQLField),
+		mutations: make(map[string]*graphQLField),
+	}
+
+	for _, rt := range routes {
+		if setting := graphQLUnsupportedSetting(rt); setting != "" {
+			return nil, fmt.Errorf("route %s %s: %s is not supported by the graphql facade", rt.Method, rt.Path,
+				setting)
+		}
+
+		var err error
+		if strings.ToUpper(rt.Method) == "GET" {
+			err = f.AddQuery(graphQLFieldName(rt.Function), rt.Function)
+		} else {
+			err = f.AddMutation(graphQLFieldName(rt.Function), rt.Function)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("route %s %s: %s", rt.Method, rt.Path, err)
+		}
+	}
+
+	return f, nil
+}
+
+// graphQLUnsupportedSetting returns the route setting bypassed by calling the method directly.
+func graphQLUnsupportedSetting(rt *Route) string {
+	switch {
+	case len(rt.Middlewares) > 0:
+		return "Middlewares"
+	case rt.RateLimit != nil:
+		return "RateLimit"
+	case rt.ConcurrencyLimit != nil:
+		return "ConcurrencyLimit"
+	case rt.Timeout > 0:
+		return "Timeout"
+	case rt.Destructive != nil:
+		return "Destructive"
+	case rt.Deduplication != nil:
+		return "Deduplication"
+	}
+
+	return ""
+}
+
+func (f *GraphQLFacade) add(fields map[string]*graphQLField, name string, function interface{}) error {
+	if name == "" || !isGQLNameStart(name[0]) {
+		return fmt.Errorf("invalid graphql field name %q", name)
+	}
+
+	if _, ok := fields[name]; ok {
+		return fmt.Errorf("duplicated graphql field %q", name)
+	}
+
+	handler, err := NewServiceHandler(function, nil, true)
+	if err != nil {
+		return err
+	}
+
+	methodType := reflect.TypeOf(function)
+	if isEventStreamFunction(methodType) {
+		return fmt.Errorf("the event stream methods can't be graphql fields")
+	}
+
+	if hasCryptFields(methodType.In(1)) || (methodType.NumOut() == 2 && hasCryptFields(methodType.Out(0))) {
+		return fmt.Errorf("the methods having crypt fields can't be graphql fields")
+	}
+
+	fields[name] = &graphQLField{name: name, handler: handler}
+	return nil
+}
+
+// SetPanicHook sets the callback of the panics of the fields, the errors of the fields have the incident ids.
+func (f *GraphQLFacade) SetPanicHook(hook PanicHook) {
+	f.panicHook = hook
+}
+
+func (f *GraphQLFacade) AddQuery(name string, function interface{}) error {
+	return f.add(f.queries, name, function)
+}
+
+func (f *GraphQLFacade) AddMutation(name string, function interface{}) error {
+	return f.add(f.mutations, name, function)
+}
+
+func (f *GraphQLFacade) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := &graphQLRequest{}
+	if r.Method == "GET" {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				f.writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		f.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	op, err := parseGraphQL(req.Query, req.OperationName)
+	if err != nil {
+		f.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if op.kind == "mutation" && r.Method == "GET" {
+		f.writeError(w, http.StatusMethodNotAllowed, "mutations are not allowed in GET requests")
+		return
+	}
+
+	variables := make(map[string]interface{})
+	for _, definition := range op.variables {
+		if v, ok := req.Variables[definition.name]; ok {
+			variables[definition.name] = v
+		} else if definition.defaultValue != nil {
+			variables[definition.name] = resolveGQLValue(definition.defaultValue, nil)
+		}
+	}
+
+	fields := f.queries
+	rootType := "Query"
+	if op.kind == "mutation" {
+		fields = f.mutations
+		rootType = "Mutation"
+	}
+
+	data := &graphQLObject{values: make(map[string]interface{})}
+	resp := &graphQLResponse{Data: data}
+	for _, selection := range op.selections {
+		if selection.name == "__typename" {
+			data.set(selection.alias, rootType)
+			continue
+		}
+
+		field, ok := fields[selection.name]
+		if !ok {
+			resp.Errors = append(resp.Errors, &GraphQLError{
+				Message: fmt.Sprintf("cannot query field %q on type %q", selection.name, rootType),
+				Path:    []interface{}{selection.alias},
+			})
+			data.set(selection.alias, nil)
+			continue
+		}
+
+		value, err := f.resolve(w, r, field, selection, variables)
+		if err != nil {
+			err.Path = append([]interface{}{selection.alias}, err.Path...)
+			resp.Errors = append(resp.Errors, err)
+		}
+		data.set(selection.alias, value)
+	}
+
+	f.writeResponse(w, http.StatusOK, resp)
+}
+
+func (f *GraphQLFacade) writeResponse(w http.ResponseWriter, status int, resp *graphQLResponse) {
+	setResponseHeader(w)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (f *GraphQLFacade) writeError(w http.ResponseWriter, status int, message string) {
+	f.writeResponse(w, status, &graphQLResponse{Errors: []*GraphQLError{{Message: message}}})
+}
+
+func (f *GraphQLFacade) resolve(w http.ResponseWriter, r *http.Request, field *graphQLField, selection *gqlField,
+	variables map[string]interface{}) (interface{}, *GraphQLError) {
+	arguments := make(map[string]interface{}, len(selection.arguments))
+	for _, argument := range selection.arguments {
+		arguments[argument.name] = resolveGQLValue(argument.value, variables)
+	}
+
+	// the arguments are bound as the json body, so the field names and conversions are the same as the HTTP API.
+	marshaled, err := json.Marshal(arguments)
+	if err != nil {
+		return nil, &GraphQLError{Message: err.Error()}
+	}
+
+	arg, in := field.handler.method.newArgument()
+	if err := json.Unmarshal(marshaled, arg.Interface()); err != nil {
+		return nil, &GraphQLError{Message: "parse argument failed: " + err.Error()}
+	}
+
+	if err := field.handler.validateArgument(arg); err != nil {
+		return nil, &GraphQLError{Message: "validate argument failed: " + err.Error()}
+	}
+
+	ctx, cancel, md, err := requestContext(r)
+	if err != nil {
+		return nil, &GraphQLError{Message: err.Error()}
+	}
+	defer cancel()
+
+	status := http.StatusOK
+	out, methodPanic := doServiceMethodCall(field.handler.method, []reflect.Value{
+		reflect.ValueOf(&ServiceMethodContext{
+			Context:              ctx,
+			RemoteAddr:           r.RemoteAddr,
+			RequestHeader:        r.Header,
+			RequestBodyReader:    http.NoBody,
+			ResponseStatusSetter: func(s int) { status = s },
+			ResponseHeader:       w.Header(),
+			ResponseBodyWriter:   &bytes.Buffer{},
+			Metadata:             md,
+			Principal:            PrincipalFromContext(r.Context()),
+		}),
+		in,
+	})
+
+	if methodPanic != nil {
+		// the panic values may have the internal details, the same as the HTTP responses.
+		id := newIncidentID()
+		if f.panicHook != nil {
+			f.panicHook(r, id, methodPanic.Panic, methodPanic.Stack)
+		}
+		return nil, &GraphQLError{Message: "service method panicked, incident " + id}
+	}
+
+	if errValue := out[len(out)-1].Interface(); errValue != nil {
+		message := errValue.(error).Error()
+		if status != http.StatusOK {
+			message = fmt.Sprintf("%s (status %d)", message, status)
+		}
+		return nil, &GraphQLError{Message: message}
+	}
+
+	if len(out) == 1 {
+		if len(selection.selections) > 0 {
+			return nil, &GraphQLError{Message: fmt.Sprintf("field %q has no subfields", selection.name)}
+		}
+		return nil, nil
+	}
+
+	return selectGraphQLValue(out[0], selection.selections)
+}
+
+func isGraphQLLeaf(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.Slice {
+		return isGraphQLLeaf(t.Elem())
+	}
+
+	return t.Kind() != reflect.Struct || t == timeType
+}
+
+func graphQLTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	if t.Name() != "" {
+		return t.Name()
+	}
+
+	return "Object"
+}
+
+func selectGraphQLValue(v reflect.Value, selections []*gqlField) (interface{}, *GraphQLError) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	if isGraphQLLeaf(v.Type()) {
+		if len(selections) > 0 {
+			return nil, &GraphQLError{Message: fmt.Sprintf("type %q has no subfields", graphQLTypeName(v.Type()))}
+		}
+		return v.Interface(), nil
+	}
+
+	if len(selections) == 0 {
+		return nil, &GraphQLError{Message: fmt.Sprintf("type %q must have a selection of subfields",
+			graphQLTypeName(v.Type()))}
+	}
+
+	if v.Kind() == reflect.Slice {
+		if v.IsNil() {
+			return nil, nil
+		}
+
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			item, err := selectGraphQLValue(v.Index(i), selections)
+			if err != nil {
+				err.Path = append([]interface{}{i}, err.Path...)
+				return nil, err
+			}
+			list[i] = item
+		}
+
+		return list, nil
+	}
+
+	columns := make(map[string]*tabularColumn)
+	for _, c := range structColumns(v.Type(), nil, "json") {
+		columns[c.name] = c
+	}
+
+	object := &graphQLObject{values: make(map[string]interface{})}
+	for _, selection := range selections {
+		if selection.name == "__typename" {
+			object.set(selection.alias, graphQLTypeName(v.Type()))
+			continue
+		}
+
+		c, ok := columns[selection.name]
+		if !ok {
+			return nil, &GraphQLError{Message: fmt.Sprintf("cannot query field %q on type %q", selection.name,
+				graphQLTypeName(v.Type()))}
+		}
+
+		fieldValue, ok := fieldByIndexNoAlloc(v, c.index)
+		if !ok {
+			object.set(selection.alias, nil)
+			continue
+		}
+
+		value, gqlErr := selectGraphQLValue(fieldValue, selection.selections)
+		if gqlErr != nil {
+			gqlErr.Path = append([]interface{}{selection.alias}, gqlErr.Path...)
+			return nil, gqlErr
+		}
+		object.set(selection.alias, value)
+	}
+
+	return object, nil
+}
+
+// fieldByIndexNoAlloc is like reflect.Value.FieldByIndex, but reports false for the nil embedded struct pointers.
+func fieldByIndexNoAlloc(v reflect.Value, index []int) (reflect.Value, bool) {
+	for _, x := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+type graphQLSchemaWriter struct {
+	types   map[string]string
+	pending []string
+}
+
+func (s *graphQLSchemaWriter) typeRef(t reflect.Type, input bool, anonymousName string) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return "String"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "String"
+	case reflect.Bool:
+		return "Boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "Int"
+	case reflect.Float32, reflect.Float64:
+		return "Float"
+	case reflect.Slice, reflect.Array:
+		return "[" + s.typeRef(t.Elem(), input, anonymousName) + "]"
+	case reflect.Struct:
+		return s.objectType(t, input, anonymousName)
+	}
+
+	return "JSON"
+}
+
+func (s *graphQLSchemaWriter) objectType(t reflect.Type, input bool, anonymousName string) string {
+	name := t.Name()
+	if name == "" {
+		name = anonymousName
+	}
+
+	keyword := "type"
+	if input {
+		keyword = "input"
+		name += "Input"
+	}
+
+	if _, ok := s.types[name]; ok {
+		return name
+	}
+
+	s.types[name] = ""
+	sb := strings.Builder{}
+	sb.WriteString(keyword + " " + name + " {\n")
+	for _, c := range structColumns(t, nil, "json") {
+		field := t.FieldByIndex(c.index)
+		sb.WriteString("  " + c.name + ": " + s.typeRef(field.Type, input, name+field.Name) + "\n")
+	}
+	sb.WriteString("}\n")
+	s.types[name] = sb.String()
+	s.pending = append(s.pending, name)
+	return name
+}
+
+func (s *graphQLSchemaWriter) rootType(name string, fields map[string]*graphQLField) string {
+	names := make([]string, 0, len(fields))
+	for n := range fields {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	sb := strings.Builder{}
+	sb.WriteString("type " + name + " {\n")
+	for _, n := range names {
+		methodType := fields[n].handler.method.value.Type()
+		argType := methodType.In(1)
+		for argType.Kind() == reflect.Ptr {
+			argType = argType.Elem()
+		}
+
+		prefix := strings.ToUpper(n[:1]) + n[1:]
+		sb.WriteString("  " + n)
+		if argType.Kind() == reflect.Struct {
+			var arguments []string
+			for _, c := range structColumns(argType, nil, "json") {
+				field := argType.FieldByIndex(c.index)
+				arguments = append(arguments, c.name+": "+s.typeRef(field.Type, true, prefix+field.Name))
+			}
+
+			if len(arguments) > 0 {
+				sb.WriteString("(" + strings.Join(arguments, ", ") + ")")
+			}
+		}
+
+		result := "Boolean"
+		if methodType.NumOut() == 2 {
+			result = s.typeRef(methodType.Out(0), false, prefix+"Result")
+		}
+		sb.WriteString(": " + result + "\n")
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// Schema returns the schema in SDL, functions only returning error are typed as Boolean and always resolve null.
+func (f *GraphQLFacade) Schema() string {
+	s := &graphQLSchemaWriter{types: make(map[string]string)}
+	parts := []string{"scalar JSON\n"}
+	if len(f.queries) > 0 {
+		parts = append(parts, s.rootType("Query", f.queries))
+	}
+
+	if len(f.mutations) > 0 {
+		parts = append(parts, s.rootType("Mutation", f.mutations))
+	}
+
+	sort.Strings(s.pending)
+	for _, name := range s.pending {
+		parts = append(parts, s.types[name])
+	}
+
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,104 @@
+package apihttpwrapper
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type graphQLTestUser struct {
+	Name    string `json:"name"`
+	Age     int    `json:"age"`
+	Friends []*graphQLTestUser
+}
+
+func getGraphQLTestUser(_ *ServiceMethodContext, args *struct {
+	Name string `json:"name"`
+}) (*graphQLTestUser, error) {
+	if args.Name == "" {
+		return nil, errors.New("name is required")
+	}
+
+	return &graphQLTestUser{Name: args.Name, Age: 18, Friends: []*graphQLTestUser{{Name: "alice"}}}, nil
+}
+
+func TestGraphQLFacade(t *testing.T) {
+	facade, err := NewGraphQLFacade([]*Route{{Method: "GET", Path: "/user", Function: getGraphQLTestUser}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := func(body string) string {
+		recorder := httptest.NewRecorder()
+		facade.ServeHTTP(recorder, httptest.NewRequest("POST", "/graphql", strings.NewReader(body)))
+		return strings.TrimSpace(recorder.Body.String())
+	}
+
+	t.Run("query with variables and aliases", func(t *testing.T) {
+		resp := query(`{"query": "query Q($n: String = \"x\") { u: getGraphQLTestUser(name: $n) ` +
+			`{ age name Friends { name } } }", "variables": {"n": "bob"}}`)
+		expected := `{"data":{"u":{"age":18,"name":"bob","Friends":[{"name":"alice"}]}}}`
+		if resp != expected {
+			t.Error(resp)
+		}
+	})
+
+	t.Run("method error", func(t *testing.T) {
+		resp := query(`{"query": "{ getGraphQLTestUser { name } }"}`)
+		expected := `{"data":{"getGraphQLTestUser":null},` +
+			`"errors":[{"message":"name is required","path":["getGraphQLTestUser"]}]}`
+		if resp != expected {
+			t.Error(resp)
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		resp := query(`{"query": "{ getGraphQLTestUser(name: \"bob\") { email } }"}`)
+		if !strings.Contains(resp, `cannot query field \"email\"`) {
+			t.Error(resp)
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		if err := facade.AddQuery("count", func(_ *ServiceMethodContext, args *struct {
+			N int `json:"n" validate:"gt=0"`
+		}) (*struct{ N int }, error) {
+			return &struct{ N int }{args.N}, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		resp := query(`{"query": "{ count(n: 0) { N } }"}`)
+		if !strings.Contains(resp, `"count":null`) || !strings.Contains(resp, "validate argument failed") {
+			t.Error(resp)
+		}
+	})
+
+	t.Run("unsupported routes", func(t *testing.T) {
+		middleware := func(next http.Handler) http.Handler { return next }
+		for _, rt := range []*Route{
+			{Method: "POST", Path: "/user", Function: getGraphQLTestUser,
+				Middlewares: []func(http.Handler) http.Handler{middleware}},
+			{Method: "POST", Path: "/user", Function: getGraphQLTestUser, RateLimit: &RateLimit{Rate: 1, Burst: 1}},
+			{Method: "POST", Path: "/secret", Function: func(_ *ServiceMethodContext, args *struct {
+				Secret string `json:"secret" crypt:"k1"`
+			}) error {
+				return nil
+			}},
+		} {
+			if _, err := NewGraphQLFacade([]*Route{rt}); err == nil {
+				t.Error("the route is accepted", rt.Path)
+			}
+		}
+	})
+
+	t.Run("schema", func(t *testing.T) {
+		schema := facade.Schema()
+		if !strings.Contains(schema, "getGraphQLTestUser(name: String): graphQLTestUser") ||
+			!strings.Contains(schema, "Friends: [graphQLTestUser]") {
+			t.Error(schema)
+		}
+	})
+}
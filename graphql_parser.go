@@ -0,0 +1,606 @@
+package apihttpwrapper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// a parser of the executable subset of GraphQL, fragments and directives are rejected.
+
+type gqlTokenKind int
+
+const (
+	gqlEOF gqlTokenKind = iota
+	gqlPunct
+	gqlName
+	gqlInt
+	gqlFloat
+	gqlString
+)
+
+type gqlToken struct {
+	kind  gqlTokenKind
+	value string
+	pos   int
+}
+
+type gqlLexer struct {
+	src string
+	pos int
+}
+
+type gqlOperation struct {
+	kind       string
+	name       string
+	variables  []*gqlVariableDefinition
+	selections []*gqlField
+}
+
+type gqlVariableDefinition struct {
+	name         string
+	defaultValue gqlValue
+}
+
+type gqlField struct {
+	alias      string
+	name       string
+	arguments  []*gqlArgument
+	selections []*gqlField
+}
+
+type gqlArgument struct {
+	name  string
+	value gqlValue
+}
+
+type gqlValue interface{}
+
+type gqlVariable string
+type gqlEnum string
+type gqlObjectValue []*gqlArgument
+
+type gqlParser struct {
+	lexer *gqlLexer
+	token gqlToken
+}
+
+func (l *gqlLexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\ufeff"):
+			l.pos += len("\ufeff")
+		default:
+			return
+		}
+	}
+}
+
+func isGQLNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isGQLDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *gqlLexer) next() (gqlToken, error) {
+	l.skipIgnored()
+	begin := l.pos
+	if l.pos >= len(l.src) {
+		return gqlToken{kind: gqlEOF, pos: begin}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return gqlToken{kind: gqlPunct, value: "...", pos: begin}, nil
+	case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+		l.pos++
+		return gqlToken{kind: gqlPunct, value: string(c), pos: begin}, nil
+	case isGQLNameStart(c):
+		for l.pos < len(l.src) && (isGQLNameStart(l.src[l.pos]) || isGQLDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return gqlToken{kind: gqlName, value: l.src[begin:l.pos], pos: begin}, nil
+	case c == '-' || isGQLDigit(c):
+		return l.number()
+	case c == '"':
+		return l.string()
+	}
+
+	return gqlToken{}, fmt.Errorf("unexpected character %q at %d", c, begin)
+}
+
+func (l *gqlLexer) number() (gqlToken, error) {
+	begin := l.pos
+	kind := gqlInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isGQLDigit(l.src[l.pos]) {
+			l.pos++
+			n++
+		}
+		return n
+	}
+
+	if digits() == 0 {
+		return gqlToken{}, fmt.Errorf("invalid number at %d", begin)
+	}
+
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = gqlFloat
+		l.pos++
+		if digits() == 0 {
+			return gqlToken{}, fmt.Errorf("invalid number at %d", begin)
+		}
+	}
+
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = gqlFloat
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if digits() == 0 {
+			return gqlToken{}, fmt.Errorf("invalid number at %d", begin)
+		}
+	}
+
+	return gqlToken{kind: kind, value: l.src[begin:l.pos], pos: begin}, nil
+}
+
+func (l *gqlLexer) string() (gqlToken, error) {
+	begin := l.pos
+	if strings.HasPrefix(l.src[l.pos:], `"""`) {
+		end := strings.Index(l.src[l.pos+3:], `"""`)
+		if end < 0 {
+			return gqlToken{}, fmt.Errorf("unterminated string at %d", begin)
+		}
+
+		l.pos += end + 6
+		return gqlToken{kind: gqlString, value: l.src[begin+3 : begin+3+end], pos: begin}, nil
+	}
+
+	sb := strings.Builder{}
+	l.pos++
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			return gqlToken{kind: gqlString, value: sb.String(), pos: begin}, nil
+		case '\n', '\r':
+			return gqlToken{}, fmt.Errorf("unterminated string at %d", begin)
+		case '\\':
+			if l.pos+1 >= len(l.src) {
+				return gqlToken{}, fmt.Errorf("unterminated string at %d", begin)
+			}
+
+			escaped := l.src[l.pos+1]
+			l.pos += 2
+			switch escaped {
+			case '"', '\\', '/':
+				sb.WriteByte(escaped)
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					return gqlToken{}, fmt.Errorf("invalid unicode escape at %d", l.pos)
+				}
+
+				r, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					return gqlToken{}, fmt.Errorf("invalid unicode escape at %d", l.pos)
+				}
+
+				sb.WriteRune(rune(r))
+				l.pos += 4
+			default:
+				return gqlToken{}, fmt.Errorf("invalid escape at %d", l.pos-1)
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			sb.WriteRune(r)
+			l.pos += size
+		}
+	}
+
+	return gqlToken{}, fmt.Errorf("unterminated string at %d", begin)
+}
+
+func (p *gqlParser) advance() error {
+	token, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+
+	p.token = token
+	return nil
+}
+
+func (p *gqlParser) peek(kind gqlTokenKind, value string) bool {
+	return p.token.kind == kind && (value == "" || p.token.value == value)
+}
+
+func (p *gqlParser) expect(kind gqlTokenKind, value string) (string, error) {
+	if !p.peek(kind, value) {
+		if value == "" {
+			value = "name"
+		}
+		return "", fmt.Errorf("expected %q at %d, found %q", value, p.token.pos, p.token.value)
+	}
+
+	v := p.token.value
+	return v, p.advance()
+}
+
+func parseGraphQL(query string, operationName string) (*gqlOperation, error) {
+	p := &gqlParser{lexer: &gqlLexer{src: query}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	var operations []*gqlOperation
+	for !p.peek(gqlEOF, "") {
+		op, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+
+		operations = append(operations, op)
+	}
+
+	if len(operations) == 0 {
+		return nil, fmt.Errorf("no operation found")
+	}
+
+	if operationName == "" {
+		if len(operations) > 1 {
+			return nil, fmt.Errorf("operationName is required for documents with multiple operations")
+		}
+		return operations[0], nil
+	}
+
+	for _, op := range operations {
+		if op.name == operationName {
+			return op, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown operation %q", operationName)
+}
+
+func (p *gqlParser) parseOperation() (*gqlOperation, error) {
+	op := &gqlOperation{kind: "query"}
+	if p.peek(gqlName, "fragment") {
+		return nil, fmt.Errorf("fragments are not supported")
+	}
+
+	if p.peek(gqlName, "") {
+		op.kind = p.token.value
+		if op.kind != "query" && op.kind != "mutation" {
+			return nil, fmt.Errorf("unsupported operation type %q", op.kind)
+		}
+
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		if p.peek(gqlName, "") {
+			op.name = p.token.value
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.peek(gqlPunct, "(") {
+			variables, err := p.parseVariableDefinitions()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = variables
+		}
+	}
+
+	if p.peek(gqlPunct, "@") {
+		return nil, fmt.Errorf("directives are not supported")
+	}
+
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+
+	op.selections = selections
+	return op, nil
+}
+
+func (p *gqlParser) parseVariableDefinitions() ([]*gqlVariableDefinition, error) {
+	if _, err := p.expect(gqlPunct, "("); err != nil {
+		return nil, err
+	}
+
+	var definitions []*gqlVariableDefinition
+	for !p.peek(gqlPunct, ")") {
+		if _, err := p.expect(gqlPunct, "$"); err != nil {
+			return nil, err
+		}
+
+		name, err := p.expect(gqlName, "")
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(gqlPunct, ":"); err != nil {
+			return nil, err
+		}
+
+		if err := p.skipType(); err != nil {
+			return nil, err
+		}
+
+		definition := &gqlVariableDefinition{name: name}
+		if p.peek(gqlPunct, "=") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+
+			definition.defaultValue, err = p.parseValue(true)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		definitions = append(definitions, definition)
+	}
+
+	return definitions, p.advance()
+}
+
+// skipType skips a type reference, the variables are coerced by the binding.
+func (p *gqlParser) skipType() error {
+	if p.peek(gqlPunct, "[") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+
+		if err := p.skipType(); err != nil {
+			return err
+		}
+
+		if _, err := p.expect(gqlPunct, "]"); err != nil {
+			return err
+		}
+	} else if _, err := p.expect(gqlName, ""); err != nil {
+		return err
+	}
+
+	if p.peek(gqlPunct, "!") {
+		return p.advance()
+	}
+
+	return nil
+}
+
+func (p *gqlParser) parseSelectionSet() ([]*gqlField, error) {
+	if _, err := p.expect(gqlPunct, "{"); err != nil {
+		return nil, err
+	}
+
+	var fields []*gqlField
+	for !p.peek(gqlPunct, "}") {
+		if p.peek(gqlPunct, "...") {
+			return nil, fmt.Errorf("fragments are not supported")
+		}
+
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, p.advance()
+}
+
+func (p *gqlParser) parseField() (*gqlField, error) {
+	name, err := p.expect(gqlName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	field := &gqlField{alias: name, name: name}
+	if p.peek(gqlPunct, ":") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		field.name, err = p.expect(gqlName, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek(gqlPunct, "(") {
+		field.arguments, err = p.parseArguments(false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek(gqlPunct, "@") {
+		return nil, fmt.Errorf("directives are not supported")
+	}
+
+	if p.peek(gqlPunct, "{") {
+		field.selections, err = p.parseSelectionSet()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return field, nil
+}
+
+func (p *gqlParser) parseArguments(constant bool) ([]*gqlArgument, error) {
+	if _, err := p.expect(gqlPunct, "("); err != nil {
+		return nil, err
+	}
+
+	var arguments []*gqlArgument
+	for !p.peek(gqlPunct, ")") {
+		name, err := p.expect(gqlName, "")
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(gqlPunct, ":"); err != nil {
+			return nil, err
+		}
+
+		value, err := p.parseValue(constant)
+		if err != nil {
+			return nil, err
+		}
+
+		arguments = append(arguments, &gqlArgument{name: name, value: value})
+	}
+
+	return arguments, p.advance()
+}
+
+func (p *gqlParser) parseValue(constant bool) (gqlValue, error) {
+	token := p.token
+	switch token.kind {
+	case gqlPunct:
+		switch token.value {
+		case "$":
+			if constant {
+				return nil, fmt.Errorf("unexpected variable at %d", token.pos)
+			}
+
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+
+			name, err := p.expect(gqlName, "")
+			return gqlVariable(name), err
+		case "[":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+
+			list := make([]gqlValue, 0)
+			for !p.peek(gqlPunct, "]") {
+				v, err := p.parseValue(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+
+			return list, p.advance()
+		case "{":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+
+			object := gqlObjectValue{}
+			for !p.peek(gqlPunct, "}") {
+				name, err := p.expect(gqlName, "")
+				if err != nil {
+					return nil, err
+				}
+
+				if _, err := p.expect(gqlPunct, ":"); err != nil {
+					return nil, err
+				}
+
+				v, err := p.parseValue(constant)
+				if err != nil {
+					return nil, err
+				}
+
+				object = append(object, &gqlArgument{name: name, value: v})
+			}
+
+			return object, p.advance()
+		}
+	case gqlInt:
+		i, err := strconv.ParseInt(token.value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return i, p.advance()
+	case gqlFloat:
+		f, err := strconv.ParseFloat(token.value, 64)
+		if err != nil {
+			return nil, err
+		}
+		return f, p.advance()
+	case gqlString:
+		return token.value, p.advance()
+	case gqlName:
+		var v gqlValue
+		switch token.value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = gqlEnum(token.value)
+		}
+		return v, p.advance()
+	}
+
+	return nil, fmt.Errorf("unexpected %q at %d", token.value, token.pos)
+}
+
+// resolveGQLValue converts the literal into a json compatible value, with the variables substituted.
+func resolveGQLValue(v gqlValue, variables map[string]interface{}) interface{} {
+	switch value := v.(type) {
+	case gqlVariable:
+		return variables[string(value)]
+	case gqlEnum:
+		return string(value)
+	case []gqlValue:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			list[i] = resolveGQLValue(item, variables)
+		}
+		return list
+	case gqlObjectValue:
+		object := make(map[string]interface{}, len(value))
+		for _, field := range value {
+			object[field.name] = resolveGQLValue(field.value, variables)
+		}
+		return object
+	default:
+		return value
+	}
+}
@@ -0,0 +1,98 @@
+package apihttpwrapper
+
+import (
+	"github.com/gorilla/schema"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// the fields tagged like `header:"X-Api-Version"` and `cookie:"session_id"`.
+var (
+	headerDecoder = schema.NewDecoder()
+	cookieDecoder = schema.NewDecoder()
+	boundNames    sync.Map
+)
+
+type headerCookieNames struct {
+	headers []string
+	cookies []string
+}
+
+func init() {
+	headerDecoder.SetAliasTag("header")
+	headerDecoder.IgnoreUnknownKeys(true)
+	cookieDecoder.SetAliasTag("cookie")
+	cookieDecoder.IgnoreUnknownKeys(true)
+}
+
+// headerCookieFields lists the tagged fields of the struct and its embedded structs by the tag names.
+func headerCookieFields(t reflect.Type, tag string, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			headerCookieFields(field.Type, tag, fields)
+			continue
+		}
+
+		if name := strings.Split(field.Tag.Get(tag), ",")[0]; name != "" && name != "-" && field.PkgPath == "" {
+			fields[name] = field.Type
+		}
+	}
+}
+
+func boundHeaderCookieNames(t reflect.Type) *headerCookieNames {
+	if names, ok := boundNames.Load(t); ok {
+		return names.(*headerCookieNames)
+	}
+
+	names := &headerCookieNames{}
+	headers := map[string]reflect.Type{}
+	headerCookieFields(t, "header", headers)
+	for name := range headers {
+		names.headers = append(names.headers, name)
+	}
+
+	cookies := map[string]reflect.Type{}
+	headerCookieFields(t, "cookie", cookies)
+	for name := range cookies {
+		names.cookies = append(names.cookies, name)
+	}
+
+	boundNames.Store(t, names)
+	return names
+}
+
+func bindHeaders(r *http.Request, arg interface{}) error {
+	names := boundHeaderCookieNames(reflect.TypeOf(arg).Elem())
+	if len(names.headers) == 0 {
+		return nil
+	}
+
+	values := url.Values{}
+	for _, name := range names.headers {
+		if value, ok := r.Header[http.CanonicalHeaderKey(name)]; ok {
+			values[name] = value
+		}
+	}
+
+	return headerDecoder.Decode(arg, values)
+}
+
+func bindCookies(r *http.Request, arg interface{}) error {
+	names := boundHeaderCookieNames(reflect.TypeOf(arg).Elem())
+	if len(names.cookies) == 0 {
+		return nil
+	}
+
+	values := url.Values{}
+	for _, name := range names.cookies {
+		if cookie, err := r.Cookie(name); err == nil {
+			values.Set(name, cookie.Value)
+		}
+	}
+
+	return cookieDecoder.Decode(arg, values)
+}
@@ -0,0 +1,65 @@
+package apihttpwrapper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHeaderCookieBinding(t *testing.T) {
+	type versioned struct {
+		Version int `header:"x-api-version"`
+	}
+	type arguments struct {
+		versioned
+		Session string   `cookie:"session_id"`
+		Tags    []string `header:"X-Tag"`
+		Page    int      `json:"page"`
+	}
+
+	var got *arguments
+	method := func(ctx *ServiceMethodContext, arg *arguments) error {
+		got = arg
+		return nil
+	}
+	handler, err := NewServiceHandler(method, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"page":2}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Api-Version", "3")
+	r.Header.Add("X-Tag", "a")
+	r.Header.Add("X-Tag", "b")
+	r.AddCookie(&http.Cookie{Name: "session_id", Value: "s1"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != 200 || got == nil || got.Version != 3 || got.Session != "s1" || len(got.Tags) != 2 ||
+		got.Page != 2 {
+		t.Fatal(w.Code, w.Body.String(), got)
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Api-Version", "v3")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != 400 {
+		t.Error("the malformed header is bound", w.Code)
+	}
+
+	doc, err := GenerateOpenAPI("t", "1", []*Route{{Method: "GET", Path: "/", Function: method}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := map[string]string{}
+	for _, param := range doc.Paths["/"]["get"].Parameters {
+		in[param.Name] = param.In
+	}
+	if in["x-api-version"] != "header" || in["X-Tag"] != "header" || in["session_id"] != "cookie" ||
+		in["Session"] != "" || in["Page"] != "query" {
+		t.Error(in)
+	}
+}
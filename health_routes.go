@@ -0,0 +1,111 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultLivenessPath       = "/healthz"
+	DefaultReadinessPath      = "/readyz"
+	DefaultHealthCheckTimeout = 5 * time.Second
+)
+
+// HealthCheck is a named checker, ctx is done after the timeout.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthOptions are of RegisterHealthRoutes, the zero value serves the paths without any checks.
+type HealthOptions struct {
+	// LivenessPath and ReadinessPath are DefaultLivenessPath and DefaultReadinessPath if empty.
+	LivenessPath  string
+	ReadinessPath string
+	// Liveness should only fail when the process should be restarted.
+	Liveness  []*HealthCheck
+	Readiness []*HealthCheck
+	// Timeout limits each check, DefaultHealthCheckTimeout if not positive.
+	Timeout time.Duration
+}
+
+// HealthCheckResult is an item of the data of the health responses.
+type HealthCheckResult struct {
+	Name     string  `json:"name"`
+	OK       bool    `json:"ok"`
+	Error    string  `json:"error,omitempty"`
+	Duration float64 `json:"duration"`
+}
+
+// RegisterHealthRoutes mounts the liveness and readiness paths, which are not logged.
+func RegisterHealthRoutes(router *httprouter.Router, options *HealthOptions) error {
+	if options == nil {
+		options = &HealthOptions{}
+	}
+
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+
+	livenessPath, readinessPath := options.LivenessPath, options.ReadinessPath
+	if livenessPath == "" {
+		livenessPath = DefaultLivenessPath
+	}
+
+	if readinessPath == "" {
+		readinessPath = DefaultReadinessPath
+	}
+
+	for _, checks := range [][]*HealthCheck{options.Liveness, options.Readiness} {
+		for _, check := range checks {
+			if check == nil || check.Name == "" || check.Check == nil {
+				return fmt.Errorf("the health check should have a name and a checker")
+			}
+		}
+	}
+
+	if err := handleRoute(router, http.MethodGet, livenessPath, newHealthHandle(options.Liveness, timeout)); err != nil {
+		return err
+	}
+
+	return handleRoute(router, http.MethodGet, readinessPath, newHealthHandle(options.Readiness, timeout))
+}
+
+func newHealthHandle(checks []*HealthCheck, timeout time.Duration) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		SkipAccessLogRow(r.Context())
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		results := make([]*HealthCheckResult, len(checks))
+		wg := sync.WaitGroup{}
+		for i, check := range checks {
+			wg.Add(1)
+			go func(i int, check *HealthCheck) {
+				defer wg.Done()
+				beginTime := time.Now()
+				err := check.Check(ctx)
+				results[i] = &HealthCheckResult{Name: check.Name, OK: err == nil,
+					Duration: time.Now().Sub(beginTime).Seconds()}
+				if err != nil {
+					results[i].Error = err.Error()
+				}
+			}(i, check)
+		}
+		wg.Wait()
+
+		for _, result := range results {
+			if !result.OK {
+				writeFormattedError(w, http.StatusServiceUnavailable, "unhealthy", results)
+				return
+			}
+		}
+
+		writeFormattedError(w, http.StatusOK, "ok", results)
+	}
+}
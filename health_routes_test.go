@@ -0,0 +1,60 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthRoutes(t *testing.T) {
+	var databaseErr error
+	router := httprouter.New()
+	err := RegisterHealthRoutes(router, &HealthOptions{Readiness: []*HealthCheck{
+		{Name: "cache", Check: func(ctx context.Context) error { return nil }},
+		{Name: "database", Check: func(ctx context.Context) error { return databaseErr }},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := &bytes.Buffer{}
+	handler := NewAccessLogHandler(router, nil, logs)
+	serve := func(path string) (int, *FormattedResponse) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		var results []*HealthCheckResult
+		resp := &FormattedResponse{Data: &results}
+		if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, resp
+	}
+
+	if code, resp := serve("/healthz"); code != 200 || resp.Msg != "ok" {
+		t.Error(code, resp)
+	}
+
+	if code, resp := serve("/readyz"); code != 200 || len(*resp.Data.(*[]*HealthCheckResult)) != 2 {
+		t.Error(code, resp)
+	}
+
+	databaseErr = fmt.Errorf("connection refused")
+	code, resp := serve("/readyz")
+	results := *resp.Data.(*[]*HealthCheckResult)
+	if code != 503 || results[0].Name != "cache" || !results[0].OK || results[1].OK ||
+		results[1].Error != "connection refused" {
+		t.Error(code, resp)
+	}
+
+	if logs.Len() != 0 {
+		t.Error("the health checks are logged", logs.String())
+	}
+
+	if err := RegisterHealthRoutes(httprouter.New(), &HealthOptions{Liveness: []*HealthCheck{{Name: "x"}}}); err == nil {
+		t.Error("the check without checker is accepted")
+	}
+}
@@ -0,0 +1,77 @@
+package apihttpwrapper
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// HotSwap replaces the function of Route.HotSwap at runtime.
+type HotSwap struct {
+	mu       sync.Mutex
+	build    func(function interface{}) (httprouter.Handle, error)
+	funcType reflect.Type
+	current  atomic.Value
+}
+
+type hotSwapState struct {
+	function interface{}
+	handle   httprouter.Handle
+}
+
+func (s *HotSwap) bind(function interface{},
+	build func(function interface{}) (httprouter.Handle, error)) (httprouter.Handle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.build != nil {
+		return nil, fmt.Errorf("the HotSwap is already bound to another route")
+	}
+
+	handle, err := build(function)
+	if err != nil {
+		return nil, err
+	}
+
+	s.build = build
+	s.funcType = reflect.TypeOf(function)
+	s.current.Store(&hotSwapState{function, handle})
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		s.current.Load().(*hotSwapState).handle(w, r, params)
+	}, nil
+}
+
+// Swap replaces the function of the same type and returns the previous one.
+func (s *HotSwap) Swap(function interface{}) (previous interface{}, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.build == nil {
+		return nil, fmt.Errorf("the HotSwap is not bound to any route")
+	}
+
+	if t := reflect.TypeOf(function); t != s.funcType {
+		return nil, fmt.Errorf("the function type %v differs from the registered %v", t, s.funcType)
+	}
+
+	handle, err := s.build(function)
+	if err != nil {
+		return nil, err
+	}
+
+	previous = s.current.Load().(*hotSwapState).function
+	s.current.Store(&hotSwapState{function, handle})
+	return previous, nil
+}
+
+// Current returns the function serving the route now, nil if not bound.
+func (s *HotSwap) Current() interface{} {
+	if state, ok := s.current.Load().(*hotSwapState); ok {
+		return state.function
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package apihttpwrapper
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHotSwap(t *testing.T) {
+	type result struct{ Version int }
+
+	v1 := func(*ServiceMethodContext, *struct{}) (*result, error) { return &result{1}, nil }
+	v2 := func(*ServiceMethodContext, *struct{}) (*result, error) { return &result{2}, nil }
+
+	swap := &HotSwap{}
+	router, err := NewHTTPRouter([]*Route{{Method: "GET", Path: "/", Function: v1, HotSwap: swap}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func() string {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+		return recorder.Body.String()
+	}
+
+	if body := do(); body != "{\"Version\":1}\n" {
+		t.Error(body)
+	}
+
+	if _, err := swap.Swap(func(*ServiceMethodContext, *struct{ A int }) error { return nil }); err == nil {
+		t.Error("function of another type swapped in")
+	}
+
+	if _, err := swap.Swap(v2); err != nil {
+		t.Fatal(err)
+	}
+
+	if body := do(); body != "{\"Version\":2}\n" {
+		t.Error(body)
+	}
+
+	if _, err := NewHTTPRouter([]*Route{{Method: "GET", Path: "/", Function: v1, HotSwap: swap}}); err == nil {
+		t.Error("HotSwap bound twice")
+	}
+}
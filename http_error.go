@@ -0,0 +1,57 @@
+package apihttpwrapper
+
+import (
+	"golang.org/x/net/trace"
+	"net/http"
+)
+
+// HTTPError controls the response of the service method error, instead of 500.
+type HTTPError interface {
+	error
+	StatusCode() int
+	// ErrorCode is the code in the response body, 0 means the same as the status.
+	ErrorCode() int
+	PublicMessage() string
+}
+
+// StatusError is the simple HTTPError, like &StatusError{Status: 404, Message: "user not found"}.
+type StatusError struct {
+	Status  int
+	Code    int
+	Message string
+	Err     error
+}
+
+func (e *StatusError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+
+	return e.Message
+}
+
+func (e *StatusError) StatusCode() int {
+	return e.Status
+}
+
+func (e *StatusError) ErrorCode() int {
+	return e.Code
+}
+
+func (e *StatusError) PublicMessage() string {
+	return e.Message
+}
+
+func (h *ServiceHandler) httpErrorResponse(w http.ResponseWriter, r *http.Request, tr trace.Trace,
+	err HTTPError) *FormattedResponse {
+	resp := h.reportIncident(w, r, tr, &FormattedResponse{err.StatusCode(), err.PublicMessage(), err.Error()})
+	if _, ok := resp.Data.(*Incident); !ok {
+		resp.Data = nil
+	}
+
+	if code := err.ErrorCode(); code != 0 {
+		resp.Code = code
+	}
+
+	return resp
+}
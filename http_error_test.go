@@ -0,0 +1,52 @@
+package apihttpwrapper
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPError(t *testing.T) {
+	var methodError error
+	router, err := NewHTTPRouter([]*Route{{
+		Method: "GET",
+		Path:   "/",
+		Function: func(*ServiceMethodContext, *struct{}) error {
+			return methodError
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		err    error
+		header string
+		status int
+		expect string
+	}{
+		{&StatusError{Status: 404, Code: 40401, Message: "user not found"}, "", 404,
+			"{\"code\":40401,\"msg\":\"user not found\",\"data\":null}\n"},
+		{&StatusError{Status: 409, Message: "version conflict", Err: errors.New("row 3 locked")}, "2", 409,
+			"{\"error\":{\"code\":409,\"message\":\"version conflict\"},\"meta\":{\"version\":2,\"status\":409}}\n"},
+		{&StatusError{Status: 503, Message: "try later", Err: errors.New("db down")}, "", 503,
+			"{\"code\":503,\"msg\":\"try later\",\"data\":{\"incidentId\":"},
+	}
+
+	for _, c := range cases {
+		methodError = c.err
+		r := httptest.NewRequest("GET", "/", nil)
+		if c.header != "" {
+			r.Header.Set(EnvelopeVersionHeader, c.header)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		body := recorder.Body.String()
+		if recorder.Code != c.status || !strings.HasPrefix(body, c.expect) || strings.Contains(body, "locked") ||
+			strings.Contains(body, "db down") {
+			t.Error(c.err, recorder.Code, body)
+		}
+	}
+}
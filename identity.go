@@ -0,0 +1,132 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strings"
+)
+
+// DefaultIdentityLogClaims are recorded into the access log by default, see RouterOptions.IdentityLogClaims.
+var DefaultIdentityLogClaims = []string{"sub", "org", "scope"}
+
+// Identity is the typed view of the JWT and OIDC claims of the Principal.
+type Identity struct {
+	Subject      string
+	Issuer       string
+	Audience     []string
+	Organization string
+	Email        string
+	// Scopes are of the space separated "scope" claim, or the "scp" claim of a string or an array.
+	Scopes []string
+	Claims map[string]interface{}
+}
+
+// IdentityFromPrincipal returns nil if the principal is nil.
+func IdentityFromPrincipal(principal *Principal) *Identity {
+	if principal == nil {
+		return nil
+	}
+
+	claims := principal.Claims
+	identity := &Identity{
+		Subject:  principal.Subject,
+		Issuer:   claimString(claims["iss"]),
+		Audience: claimStrings(claims["aud"]),
+		Email:    claimString(claims["email"]),
+		Claims:   claims,
+	}
+
+	identity.Organization = claimString(claims["org"])
+	if identity.Organization == "" {
+		identity.Organization = claimString(claims["org_id"])
+	}
+
+	identity.Scopes = claimStrings(claims["scope"])
+	if len(identity.Scopes) == 0 {
+		identity.Scopes = claimStrings(claims["scp"])
+	}
+
+	return identity
+}
+
+// Identity returns nil if the request is not authenticated.
+func (ctx *ServiceMethodContext) Identity() *Identity {
+	return IdentityFromPrincipal(ctx.Principal)
+}
+
+func (identity *Identity) HasScope(scope string) bool {
+	for _, s := range identity.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+func claimString(claim interface{}) string {
+	s, _ := claim.(string)
+	return s
+}
+
+// claimStrings takes the space separated strings and the arrays of strings.
+func claimStrings(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+// formatClaim records the strings as is, the arrays of strings space separated and the others as JSON.
+func formatClaim(claim interface{}) string {
+	switch v := claim.(type) {
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, " ")
+	case []interface{}:
+		if values := claimStrings(v); len(values) == len(v) {
+			return strings.Join(values, " ")
+		}
+	}
+
+	if b, err := json.Marshal(claim); err == nil {
+		return string(b)
+	}
+
+	return fmt.Sprint(claim)
+}
+
+// newIdentityLogHandle records the claims, "sub" falls back to the subject of the principal.
+func newIdentityLogHandle(handle httprouter.Handle, claims []string, loggerContextKey interface{}) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		if principal := PrincipalFromContext(r.Context()); principal != nil {
+			for _, name := range claims {
+				claim, ok := principal.Claims[name]
+				if !ok && name == "sub" && principal.Subject != "" {
+					claim, ok = principal.Subject, true
+				}
+
+				if ok {
+					recordLogField(r, loggerContextKey, name, formatClaim(claim))
+				}
+			}
+		}
+
+		handle(w, r, params)
+	}
+}
@@ -0,0 +1,59 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIdentity(t *testing.T) {
+	principal := &Principal{Subject: "u1", Claims: map[string]interface{}{
+		"sub": "u1", "iss": "https://issuer", "aud": []interface{}{"api", "web"}, "org_id": "o1",
+		"scope": "read write", "email": "u1@example.com", "level": 3.0,
+	}}
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(NewPrincipalContext(r.Context(), principal)))
+		})
+	}
+
+	var identity *Identity
+	logs := &bytes.Buffer{}
+	handler, err := NewLoggingHTTPRouterWithOptions([]*Route{{Method: "GET", Path: "/",
+		Function: func(ctx *ServiceMethodContext, arg *struct{}) error {
+			identity = ctx.Identity()
+			return nil
+		}}}, nil, logs, &RouterOptions{Middlewares: []func(http.Handler) http.Handler{authenticate},
+		IdentityLogClaims: []string{"sub", "org", "scope", "aud", "level"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if identity == nil || identity.Subject != "u1" || identity.Issuer != "https://issuer" || len(identity.Audience) != 2 ||
+		identity.Organization != "o1" || !identity.HasScope("write") || identity.HasScope("admin") ||
+		identity.Email != "u1@example.com" {
+		t.Errorf("%+v", identity)
+	}
+
+	for _, field := range []string{"sub=u1", `scope="read write"`, `aud="api web"`, "level=3"} {
+		if !strings.Contains(logs.String(), field) {
+			t.Error(field, logs.String())
+		}
+	}
+
+	if strings.Contains(logs.String(), "org=") {
+		t.Error("the missing claim is recorded", logs.String())
+	}
+
+	if IdentityFromPrincipal(nil) != nil {
+		t.Error("the nil principal has an identity")
+	}
+
+	scp := IdentityFromPrincipal(&Principal{Claims: map[string]interface{}{"scp": []interface{}{"a", "b"}}})
+	if len(scp.Scopes) != 2 {
+		t.Error(scp.Scopes)
+	}
+}
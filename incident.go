@@ -0,0 +1,53 @@
+package apihttpwrapper
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/net/trace"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// IncidentHeader carries the incident id of the internal errors, the same id is in the response body and the logs.
+const IncidentHeader = "X-Incident-Id"
+
+// Incident is the data of the 5xx responses in place of the error details, which are only recorded in the logs.
+type Incident struct {
+	ID string `json:"incidentId"`
+}
+
+func newIncidentID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// reportIncident replaces the details of the 5xx response with an incident id.
+func (h *ServiceHandler) reportIncident(w http.ResponseWriter, r *http.Request, tr trace.Trace,
+	resp *FormattedResponse) *FormattedResponse {
+	if resp.Code < http.StatusInternalServerError {
+		return resp
+	}
+
+	id := newIncidentID()
+	w.Header().Set(IncidentHeader, id)
+	tr.LazyPrintf("incident %s: %s: %+v", id, resp.Msg, resp.Data)
+
+	if logger := h.methodLogger(r); logger != nil {
+		detail, err := json.Marshal(resp.Data)
+		if err != nil {
+			detail = []byte(fmt.Sprintf("%+v", resp.Data))
+		}
+
+		logger.Record("incidentId", id)
+		logger.Record("incidentDetail", string(detail))
+	}
+
+	return &FormattedResponse{resp.Code, resp.Msg, &Incident{id}}
+}
@@ -0,0 +1,54 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIncident(t *testing.T) {
+	logs := &bytes.Buffer{}
+	router, err := NewLoggingHTTPRouter([]*Route{{
+		Method: "GET",
+		Path:   "/",
+		Function: func(*ServiceMethodContext, *struct{}) (*struct{}, error) {
+			panic("secret detail")
+		},
+	}}, nil, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, version := range []string{"1", "2"} {
+		logs.Reset()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(EnvelopeVersionHeader, version)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+
+		id := recorder.Header().Get(IncidentHeader)
+		if recorder.Code != 500 || id == "" || strings.Contains(recorder.Body.String(), "secret") {
+			t.Error(recorder.Code, recorder.Header(), recorder.Body.String())
+		}
+
+		var body struct {
+			Data  *Incident
+			Error *EnvelopeError
+		}
+
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+
+		if (version == "1" && (body.Data == nil || body.Data.ID != id)) ||
+			(version == "2" && (body.Error == nil || body.Error.Incident != id)) {
+			t.Error(recorder.Body.String())
+		}
+
+		if !strings.Contains(logs.String(), "incidentId="+id) || !strings.Contains(logs.String(), "secret detail") {
+			t.Error(logs.String())
+		}
+	}
+}
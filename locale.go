@@ -0,0 +1,78 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type localesContextKey struct{}
+
+type weightedValue struct {
+	value string
+	q     float64
+}
+
+// parseQualityList returns the values of the Accept like header ordered by the q values.
+func parseQualityList(header string) []string {
+	var weighted []weightedValue
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		value := strings.TrimSpace(fields[0])
+		if value == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if len(param) > 2 && (param[:2] == "q=" || param[:2] == "Q=") {
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil || v < 0 || v > 1 {
+					v = 0
+				}
+				q = v
+			}
+		}
+
+		if q > 0 {
+			weighted = append(weighted, weightedValue{value, q})
+		}
+	}
+
+	sort.SliceStable(weighted, func(i, j int) bool { return weighted[i].q > weighted[j].q })
+
+	values := make([]string, 0, len(weighted))
+	for _, w := range weighted {
+		values = append(values, w.value)
+	}
+
+	return values
+}
+
+// ParseAcceptLanguage returns the locales of the Accept-Language header in the order of preference, "*" is dropped.
+func ParseAcceptLanguage(header string) []string {
+	locales := []string{}
+	for _, locale := range parseQualityList(header) {
+		if locale != "*" {
+			locales = append(locales, locale)
+		}
+	}
+
+	return locales
+}
+
+func NewLocalesContext(ctx context.Context, locales []string) context.Context {
+	return context.WithValue(ctx, localesContextKey{}, locales)
+}
+
+func LocalesFromContext(ctx context.Context) ([]string, bool) {
+	locales, ok := ctx.Value(localesContextKey{}).([]string)
+	return locales, ok
+}
+
+func requestLocales(r *http.Request) []string {
+	return ParseAcceptLanguage(strings.Join(r.Header["Accept-Language"], ","))
+}
@@ -0,0 +1,103 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// logMarshalCache keeps the marshaled args and resp of the hot routes for a short window.
+type logMarshalCache struct {
+	window    time.Duration
+	mu        sync.Mutex
+	entries   map[interface{}]*logMarshalEntry
+	lastSweep time.Time
+}
+
+type logMarshalEntry struct {
+	value     interface{}
+	marshaled string
+	expiry    time.Time
+}
+
+type argsLogKey struct {
+	uri string
+}
+
+type respLogKey struct {
+	typ reflect.Type
+	ptr uintptr
+}
+
+// SetLogCoalescingWindow marshals the identical args and resp in the window once, 0 disables it.
+func (h *ServiceHandler) SetLogCoalescingWindow(window time.Duration) {
+	if window <= 0 {
+		h.logCoalescing = nil
+		return
+	}
+
+	h.logCoalescing = &logMarshalCache{
+		window:  window,
+		entries: make(map[interface{}]*logMarshalEntry),
+	}
+}
+
+func marshalLogged(v interface{}) string {
+	buffer := getJSONBuffer()
+	defer putJSONBuffer(buffer)
+	if err := buffer.encoder.Encode(v); err != nil {
+		panic(err)
+	}
+
+	// the encoder ends the value with a newline.
+	return string(bytes.TrimSuffix(buffer.Bytes(), []byte("\n")))
+}
+
+// marshal keeps v alive in the window, and marshals logged, which is the redacted copy of v.
+func (c *logMarshalCache) marshal(key interface{}, v interface{}, logged interface{}) string {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if now.Sub(c.lastSweep) > c.window {
+		for k, e := range c.entries {
+			if now.After(e.expiry) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	c.mu.Unlock()
+
+	if ok && now.Before(entry.expiry) {
+		return entry.marshaled
+	}
+
+	marshaled := marshalLogged(logged)
+	c.mu.Lock()
+	c.entries[key] = &logMarshalEntry{value: v, marshaled: marshaled, expiry: now.Add(c.window)}
+	c.mu.Unlock()
+	return marshaled
+}
+
+// marshalLoggedArgs coalesces the args only if the request body isn't bound, see parseArgument.
+func (h *ServiceHandler) marshalLoggedArgs(r *http.Request, arg interface{}) string {
+	arg = h.logRedaction.redact(arg)
+	if h.logCoalescing == nil || h.bindsBody(r.Method) {
+		return marshalLogged(arg)
+	}
+
+	return h.logCoalescing.marshal(argsLogKey{r.URL.RequestURI()}, arg, arg)
+}
+
+// marshalLoggedResp coalesces the results returned as pointers, the error responses are always marshaled.
+func (h *ServiceHandler) marshalLoggedResp(resp interface{}, methodReturn interface{}) string {
+	v := reflect.ValueOf(methodReturn)
+	// the kind is checked first, since comparing the uncomparable values like maps panics.
+	if h.logCoalescing == nil || v.Kind() != reflect.Ptr || v.IsNil() || resp != methodReturn {
+		return marshalLogged(h.logRedaction.redact(resp))
+	}
+
+	return h.logCoalescing.marshal(respLogKey{v.Type(), v.Pointer()}, resp, h.logRedaction.redact(resp))
+}
@@ -0,0 +1,59 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type coalescedResult struct {
+	Count int
+}
+
+func (r *coalescedResult) MarshalJSON() ([]byte, error) {
+	marshalCount++
+	return []byte("{\"Count\":" + strconv.Itoa(r.Count) + "}"), nil
+}
+
+var marshalCount int
+
+func TestLogCoalescing(t *testing.T) {
+	cached := &coalescedResult{1}
+	var logs bytes.Buffer
+	router, err := NewLoggingHTTPRouter([]*Route{{
+		Method: "GET",
+		Path:   "/poll",
+		Function: func(*ServiceMethodContext, *struct{ Since int }) (*coalescedResult, error) {
+			return cached, nil
+		},
+		LogCoalescingWindow: time.Minute,
+	}, {
+		Method: "GET",
+		Path:   "/slice",
+		Function: func(*ServiceMethodContext, *struct{}) ([]int, error) {
+			return []int{1}, nil
+		},
+		LogCoalescingWindow: time.Minute,
+	}}, nil, &logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/poll?Since=1", nil))
+	}
+
+	// one for the response body, one for the log.
+	if marshalCount != 4 || strings.Count(logs.String(), "resp=\"{\\\"Count\\\":1}\"") != 3 {
+		t.Error(marshalCount, logs.String())
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/slice", nil))
+	if recorder.Code != 200 || !strings.Contains(logs.String(), "resp=\"[1]\"") {
+		t.Error(recorder.Code, logs.String())
+	}
+}
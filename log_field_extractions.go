@@ -0,0 +1,133 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is the subset of JSONPath selecting a single value, like "$.order.id" or "$.items[0].id".
+type jsonPath []interface{}
+
+type logFieldExtraction struct {
+	field string
+	path  jsonPath
+}
+
+func parseJSONPath(path string) (jsonPath, error) {
+	s := strings.TrimPrefix(path, "$")
+	var result jsonPath
+	for s != "" {
+		switch {
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("json path %q has unclosed bracket", path)
+			}
+
+			index, err := strconv.Atoi(s[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("json path %q has invalid index %q", path, s[1:end])
+			}
+
+			result = append(result, index)
+			s = s[end+1:]
+		case s[0] == '.':
+			s = s[1:]
+			if s == "" || s[0] == '.' || s[0] == '[' {
+				return nil, fmt.Errorf("json path %q has empty key", path)
+			}
+		default:
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+
+			if end == 0 {
+				return nil, fmt.Errorf("json path %q has empty key", path)
+			}
+
+			result = append(result, s[:end])
+			s = s[end:]
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("json path %q selects nothing", path)
+	}
+
+	return result, nil
+}
+
+func (p jsonPath) lookup(doc interface{}) (interface{}, bool) {
+	for _, step := range p {
+		switch key := step.(type) {
+		case string:
+			object, ok := doc.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+
+			if doc, ok = object[key]; !ok {
+				return nil, false
+			}
+		case int:
+			array, ok := doc.([]interface{})
+			if !ok || key >= len(array) {
+				return nil, false
+			}
+
+			doc = array[key]
+		}
+	}
+
+	return doc, true
+}
+
+// SetLogFields records the values of the paths like "$.order.id" of the result into the access log.
+func (h *ServiceHandler) SetLogFields(fields map[string]string) error {
+	extractions := make([]*logFieldExtraction, 0, len(fields))
+	for field, path := range fields {
+		p, err := parseJSONPath(path)
+		if err != nil {
+			return err
+		}
+
+		extractions = append(extractions, &logFieldExtraction{field, p})
+	}
+
+	h.logFields = extractions
+	return nil
+}
+
+func (h *ServiceHandler) recordLogFields(logger MethodLogger, marshaledResult string) {
+	if len(h.logFields) == 0 {
+		return
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(marshaledResult))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return
+	}
+
+	for _, extraction := range h.logFields {
+		value, ok := extraction.path.lookup(doc)
+		if !ok || value == nil {
+			continue
+		}
+
+		if s, ok := value.(string); ok {
+			logger.Record(extraction.field, s)
+			continue
+		}
+
+		buffer := &bytes.Buffer{}
+		if err := json.NewEncoder(buffer).Encode(value); err == nil {
+			logger.Record(extraction.field, strings.TrimSpace(buffer.String()))
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	p, err := parseJSONPath("$.items[1].id")
+	if err != nil || len(p) != 3 || p[0] != "items" || p[1] != 1 || p[2] != "id" {
+		t.Error(p, err)
+	}
+
+	for _, path := range []string{"$", "$.items[", "$.items[-1]", "$..id"} {
+		if _, err := parseJSONPath(path); err == nil {
+			t.Error(path)
+		}
+	}
+}
+
+func TestLogFields(t *testing.T) {
+	type item struct {
+		ID int `json:"id"`
+	}
+
+	type order struct {
+		OrderID string  `json:"order_id"`
+		Items   []*item `json:"items"`
+	}
+
+	var logs bytes.Buffer
+	router, err := NewLoggingHTTPRouter([]*Route{{
+		Method: "GET",
+		Path:   "/order",
+		Function: func(*ServiceMethodContext, *struct{}) (*order, error) {
+			return &order{OrderID: "o-1", Items: []*item{{7}}}, nil
+		},
+		LogFields: map[string]string{"orderId": "$.order_id", "firstItem": "$.items[0]", "missing": "$.x"},
+	}}, nil, &logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/order", nil))
+	if !strings.Contains(logs.String(), "orderId=o-1") ||
+		!strings.Contains(logs.String(), "firstItem=\"{\\\"id\\\":7}\"") || strings.Contains(logs.String(), "missing") {
+		t.Error(logs.String())
+	}
+
+	_, err = NewHTTPRouter([]*Route{{
+		Method: "GET",
+		Path:   "/order",
+		Function: func(*ServiceMethodContext, *struct{}) (*order, error) {
+			return nil, nil
+		},
+		LogFields: map[string]string{"orderId": "$.items[x]"},
+	}})
+	if err == nil {
+		t.Error("invalid path accepted")
+	}
+}
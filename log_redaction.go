@@ -0,0 +1,174 @@
+package apihttpwrapper
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// redactedLogValue replaces the masked strings in the logged args and resp.
+const redactedLogValue = "******"
+
+// logRedaction masks the copies of the logged args and resp.
+type logRedaction struct {
+	// fields are the lowercased names of the denied fields and map keys.
+	fields map[string]bool
+	// cache tells whether the values of the types have the redacted fields.
+	cache sync.Map
+}
+
+// SetLogRedactedFields masks the fields of the names in the logged args and resp, like "password".
+func (h *ServiceHandler) SetLogRedactedFields(fields ...string) {
+	h.logRedaction = newLogRedaction(fields)
+}
+
+func newLogRedaction(fields []string) *logRedaction {
+	l := &logRedaction{fields: make(map[string]bool, len(fields))}
+	for _, field := range fields {
+		l.fields[strings.ToLower(field)] = true
+	}
+
+	return l
+}
+
+// fieldRedaction returns "-", "mask" or "" for the fields logged as is.
+func (l *logRedaction) fieldRedaction(field reflect.StructField) string {
+	if tag := field.Tag.Get("log"); tag == "-" || tag == "mask" {
+		return tag
+	}
+
+	jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+	if l.fields[strings.ToLower(field.Name)] || (jsonName != "" && l.fields[strings.ToLower(jsonName)]) {
+		return "mask"
+	}
+
+	return ""
+}
+
+func (l *logRedaction) hasRedactedFields(t reflect.Type) bool {
+	if cached, ok := l.cache.Load(t); ok {
+		return cached.(bool)
+	}
+
+	// recursive types are considered having no redacted fields until proved otherwise, the same as hasCryptFields.
+	l.cache.Store(t, false)
+	has := false
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		has = t != bytesType && l.hasRedactedFields(t.Elem())
+	case reflect.Map:
+		has = (len(l.fields) > 0 && t.Key().Kind() == reflect.String) || l.hasRedactedFields(t.Elem())
+	case reflect.Interface:
+		has = true
+	case reflect.Struct:
+		for i := 0; i < t.NumField() && !has; i++ {
+			field := t.Field(i)
+			if field.PkgPath == "" {
+				has = l.fieldRedaction(field) != "" || l.hasRedactedFields(field.Type)
+			}
+		}
+	}
+
+	l.cache.Store(t, has)
+	return has
+}
+
+// redact returns the copy of v to be logged.
+func (l *logRedaction) redact(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	return l.redactValue(reflect.ValueOf(v)).Interface()
+}
+
+func maskedValue(t reflect.Type) reflect.Value {
+	switch {
+	case t.Kind() == reflect.String:
+		return reflect.ValueOf(redactedLogValue).Convert(t)
+	case t == bytesType:
+		return reflect.ValueOf([]byte(redactedLogValue))
+	}
+
+	return reflect.Zero(t)
+}
+
+func (l *logRedaction) redactValue(v reflect.Value) reflect.Value {
+	if !l.hasRedactedFields(v.Type()) {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+
+		elem := l.redactValue(v.Elem())
+		if v.Kind() == reflect.Interface {
+			result := reflect.New(v.Type()).Elem()
+			result.Set(elem)
+			return result
+		}
+
+		ptr := reflect.New(v.Type().Elem())
+		ptr.Elem().Set(elem)
+		return ptr
+	case reflect.Slice, reflect.Array:
+		var result reflect.Value
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return v
+			}
+			result = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		} else {
+			result = reflect.New(v.Type()).Elem()
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			result.Index(i).Set(l.redactValue(v.Index(i)))
+		}
+
+		return result
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+
+		result := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, value := iter.Key(), l.redactValue(iter.Value())
+			if key.Kind() == reflect.String && l.fields[strings.ToLower(key.String())] {
+				value = maskedValue(v.Type().Elem())
+			}
+			result.SetMapIndex(key, value)
+		}
+
+		return result
+	case reflect.Struct:
+		result := reflect.New(v.Type()).Elem()
+		result.Set(v)
+
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			switch l.fieldRedaction(field) {
+			case "-":
+				result.Field(i).Set(reflect.Zero(field.Type))
+			case "mask":
+				result.Field(i).Set(maskedValue(field.Type))
+			default:
+				result.Field(i).Set(l.redactValue(v.Field(i)))
+			}
+		}
+
+		return result
+	}
+
+	return v
+}
@@ -0,0 +1,61 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogRedaction(t *testing.T) {
+	type loginArguments struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+		Code     string `json:"code" log:"mask"`
+		Captcha  []byte `json:"captcha" log:"-"`
+	}
+
+	type session struct {
+		Token   string            `json:"accessToken"`
+		Profile map[string]string `json:"profile"`
+	}
+
+	arg := &loginArguments{}
+	logs := &bytes.Buffer{}
+	router, err := NewLoggingHTTPRouterWithOptions([]*Route{{Method: "POST", Path: "/login",
+		LogRedactedFields: []string{"accessToken"},
+		Function: func(ctx *ServiceMethodContext, login *loginArguments) (*session, error) {
+			arg = login
+			return &session{Token: "t0k3n", Profile: map[string]string{"Name": "alice", "secret": "s3cr3t"}}, nil
+		}}}, nil, logs, &RouterOptions{LogRedactedFields: []string{"PASSWORD", "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/login",
+		strings.NewReader(`{"user":"alice","password":"p4ss","code":"9876","captcha":"YWJj"}`))
+	r.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, r)
+	if w.Code != 200 || !strings.Contains(w.Body.String(), "t0k3n") || !strings.Contains(w.Body.String(), "s3cr3t") {
+		t.Error("the response is redacted", w.Body.String())
+	}
+
+	if arg.Password != "p4ss" || arg.Code != "9876" || string(arg.Captcha) != "abc" {
+		t.Error("the argument is redacted", arg)
+	}
+
+	row := logs.String()
+	for _, secret := range []string{"p4ss", "9876", "YWJj", "t0k3n", "s3cr3t"} {
+		if strings.Contains(row, secret) {
+			t.Error(secret, "is logged", row)
+		}
+	}
+
+	for _, field := range []string{`\"user\":\"alice\"`, `\"password\":\"******\"`, `\"captcha\":null`,
+		`\"accessToken\":\"******\"`, `\"Name\":\"alice\"`} {
+		if !strings.Contains(row, field) {
+			t.Error(field, row)
+		}
+	}
+}
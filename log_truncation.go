@@ -0,0 +1,33 @@
+package apihttpwrapper
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// DefaultMaxLoggedBytes limits the marshaled args and resp in the access log, see SetMaxLoggedBytes.
+const DefaultMaxLoggedBytes = 4 << 10
+
+// SetMaxLoggedBytes truncates the logged args and resp, 0 means DefaultMaxLoggedBytes and negative unlimited.
+func (h *ServiceHandler) SetMaxLoggedBytes(n int) {
+	h.maxLoggedBytes = n
+}
+
+func (h *ServiceHandler) truncateLogged(marshaled string) string {
+	max := h.maxLoggedBytes
+	if max == 0 {
+		max = DefaultMaxLoggedBytes
+	}
+
+	if max < 0 || len(marshaled) <= max {
+		return marshaled
+	}
+
+	// the cut doesn't split a multi-byte character.
+	end := max
+	for end > 0 && !utf8.RuneStart(marshaled[end]) {
+		end--
+	}
+
+	return fmt.Sprintf("%s...(truncated, %d bytes)", marshaled[:end], len(marshaled))
+}
@@ -0,0 +1,40 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogTruncation(t *testing.T) {
+	logs := &bytes.Buffer{}
+	router, err := NewLoggingHTTPRouterWithOptions([]*Route{{Method: "GET", Path: "/large",
+		Function: func(ctx *ServiceMethodContext, arg *struct{}) (*struct{ Text string }, error) {
+			return &struct{ Text string }{strings.Repeat("x", 10000)}, nil
+		}}, {Method: "GET", Path: "/unlimited", MaxLoggedBytes: -1,
+		Function: func(ctx *ServiceMethodContext, arg *struct{}) (*struct{ Text string }, error) {
+			return &struct{ Text string }{strings.Repeat("y", 10000)}, nil
+		}}}, nil, logs, &RouterOptions{MaxLoggedBytes: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/large", nil))
+	if w.Body.Len() < 10000 || !strings.Contains(logs.String(), `resp="{\"Text\":\"`+strings.Repeat("x", 91)+
+		"...(truncated, 10011 bytes)\"") {
+		t.Error(w.Body.Len(), logs.String())
+	}
+
+	logs.Reset()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/unlimited", nil))
+	if !strings.Contains(logs.String(), strings.Repeat("y", 10000)) || strings.Contains(logs.String(), "truncated") {
+		t.Error("the unlimited resp is truncated")
+	}
+
+	h := &ServiceHandler{maxLoggedBytes: 4}
+	if truncated := h.truncateLogged("ab世界"); truncated != "ab...(truncated, 8 bytes)" {
+		t.Error("the multi-byte character is split", truncated)
+	}
+}
@@ -0,0 +1,96 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata is carried by the "X-Md-" prefixed request headers, the keys are lower case.
+type Metadata map[string][]string
+
+type metadataContextKey struct{}
+
+const (
+	MetadataHeaderPrefix = "X-Md-"
+	// TimeoutHeader has the same format as grpc-timeout, like "100m" for 100 milliseconds.
+	TimeoutHeader = "Grpc-Timeout"
+)
+
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+func (md Metadata) Get(key string) string {
+	values := md[strings.ToLower(key)]
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func NewMetadataContext(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, metadataContextKey{}, md)
+}
+
+func MetadataFromContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(metadataContextKey{}).(Metadata)
+	return md, ok
+}
+
+func requestMetadata(header http.Header) Metadata {
+	md := Metadata{}
+	for k, values := range header {
+		if len(k) > len(MetadataHeaderPrefix) && strings.EqualFold(k[:len(MetadataHeaderPrefix)], MetadataHeaderPrefix) {
+			key := strings.ToLower(k[len(MetadataHeaderPrefix):])
+			md[key] = append(md[key], values...)
+		}
+	}
+
+	return md
+}
+
+// parseGRPCTimeout parses at most 8 digits followed by a unit, as the gRPC over HTTP2 protocol defines.
+func parseGRPCTimeout(value string) (time.Duration, error) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, fmt.Errorf("invalid timeout %q", value)
+	}
+
+	unit, ok := grpcTimeoutUnits[value[len(value)-1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid timeout unit in %q", value)
+	}
+
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid timeout %q", value)
+	}
+
+	return time.Duration(n) * unit, nil
+}
+
+// requestContext derives the context of the service method.
+func requestContext(r *http.Request) (context.Context, context.CancelFunc, Metadata, error) {
+	md := requestMetadata(r.Header)
+	ctx := NewLocalesContext(NewMetadataContext(r.Context(), md), requestLocales(r))
+	if value := r.Header.Get(TimeoutHeader); value != "" {
+		timeout, err := parseGRPCTimeout(value)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		return ctx, cancel, md, nil
+	}
+
+	return ctx, func() {}, md, nil
+}
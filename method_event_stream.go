@@ -0,0 +1,192 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventSender sends an SSE event, the data is JSON unless it's a string, []byte or json.RawMessage.
+type EventSender func(event string, data interface{}) error
+
+// SetEventHeartbeat sets the interval of the heartbeat comments, 30 seconds if not positive.
+func (h *ServiceHandler) SetEventHeartbeat(interval time.Duration) {
+	h.eventHeartbeat = interval
+}
+
+var eventChannelType = reflect.TypeOf((<-chan *Event)(nil))
+
+// isEventStreamFunction is of the methods returning (<-chan *Event, error).
+func isEventStreamFunction(methodType reflect.Type) bool {
+	return methodType.NumOut() == 2 && methodType.Out(0) == eventChannelType &&
+		methodType.Out(1).Kind() == reflect.Interface && methodType.Out(1).Name() == "error"
+}
+
+// EventStream turns the response into an SSE stream, the result of the method is ignored except the error.
+func (ctx *ServiceMethodContext) EventStream() EventSender {
+	stream := ctx.events
+	return func(event string, data interface{}) error {
+		if stream == nil {
+			return fmt.Errorf("the context is not made by the framework")
+		}
+
+		raw, err := eventData(data)
+		if err != nil {
+			return err
+		}
+
+		if err := stream.start(); err != nil {
+			return err
+		}
+
+		if err := ctx.Context.Err(); err != nil {
+			return err
+		}
+
+		return stream.send(&Event{Type: event, Data: raw})
+	}
+}
+
+func eventData(data interface{}) (json.RawMessage, error) {
+	switch d := data.(type) {
+	case json.RawMessage:
+		return d, nil
+	case []byte:
+		return d, nil
+	case string:
+		return json.RawMessage(d), nil
+	default:
+		return json.Marshal(data)
+	}
+}
+
+// methodEventStream serializes the writes with the heartbeats.
+type methodEventStream struct {
+	w         http.ResponseWriter
+	heartbeat time.Duration
+	once      sync.Once
+	mutex     sync.Mutex
+	sse       *sseWriter
+	err       error
+	closed    bool
+	sent      int
+	stop      chan struct{}
+	stopped   chan struct{}
+}
+
+func (s *methodEventStream) start() error {
+	s.once.Do(func() {
+		flusher, ok := s.w.(http.Flusher)
+		if !ok {
+			s.err = fmt.Errorf("streaming unsupported")
+			return
+		}
+
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.Header().Set("X-Accel-Buffering", "no")
+		s.w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		s.mutex.Lock()
+		s.sse = &sseWriter{w: s.w, flusher: flusher}
+		s.stop, s.stopped = make(chan struct{}), make(chan struct{})
+		s.mutex.Unlock()
+		go s.beat()
+	})
+	return s.err
+}
+
+func (s *methodEventStream) isStarted() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sse != nil
+}
+
+func (s *methodEventStream) beat() {
+	defer close(s.stopped)
+	interval := s.heartbeat
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mutex.Lock()
+			if !s.closed {
+				_ = s.sse.writeHeartbeat()
+			}
+			s.mutex.Unlock()
+		}
+	}
+}
+
+func (s *methodEventStream) send(event *Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return fmt.Errorf("the event stream is closed")
+	}
+
+	if err := s.sse.writeEvent(event); err != nil {
+		return err
+	}
+
+	s.sent++
+	return nil
+}
+
+// pump sends the events of the channel returned by the method until it's closed or ctx is done.
+func (s *methodEventStream) pump(ctx context.Context, events <-chan *Event) error {
+	if err := s.start(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			if err := s.send(event); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// close stops the heartbeat, and sends the failure of the method as the error event if it's not nil.
+func (s *methodEventStream) close(failure *FormattedResponse) {
+	close(s.stop)
+	<-s.stopped
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	if failure == nil {
+		return
+	}
+
+	if data, err := json.Marshal(failure); err == nil {
+		_ = s.sse.writeEvent(&Event{Type: "error", Data: data})
+	}
+}
+
+func (s *methodEventStream) record(logger MethodLogger) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	logger.Record("eventsSent", strconv.Itoa(s.sent))
+}
@@ -0,0 +1,64 @@
+package apihttpwrapper
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMethodEventStream(t *testing.T) {
+	type progress struct {
+		Done int `json:"done"`
+	}
+
+	routes := []*Route{
+		{Method: "GET", Path: "/progress", EventHeartbeat: 10 * time.Millisecond,
+			Function: func(ctx *ServiceMethodContext, arg *struct{}) error {
+				send := ctx.EventStream()
+				if err := send("progress", &progress{1}); err != nil {
+					return err
+				}
+
+				time.Sleep(50 * time.Millisecond)
+				if err := send("", "raw\nlines"); err != nil {
+					return err
+				}
+				return &StatusError{Status: 409, Message: "conflict"}
+			}},
+		{Method: "GET", Path: "/events",
+			Function: func(ctx *ServiceMethodContext, arg *struct{}) (<-chan *Event, error) {
+				events := make(chan *Event, 2)
+				events <- &Event{ID: "1", Type: "created", Data: []byte(`{"id":1}`)}
+				events <- &Event{ID: "2", Type: "deleted", Data: []byte(`{"id":1}`)}
+				close(events)
+				return events, nil
+			}},
+	}
+
+	router, err := NewHTTPRouter(routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/progress", nil))
+	body := w.Body.String()
+	if w.Code != 200 || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatal(w.Code, body)
+	}
+
+	for _, part := range []string{"event: progress\ndata: {\"done\":1}\n\n", ": heartbeat\n\n",
+		"data: raw\ndata: lines\n\n", "event: error\ndata: {\"code\":409"} {
+		if !strings.Contains(body, part) {
+			t.Errorf("%q has no %q", body, part)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+	expected := "id: 1\nevent: created\ndata: {\"id\":1}\n\nid: 2\nevent: deleted\ndata: {\"id\":1}\n\n"
+	if w.Code != 200 || w.Body.String() != expected {
+		t.Errorf("%d %q", w.Code, w.Body.String())
+	}
+}
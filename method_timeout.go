@@ -0,0 +1,159 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// SetTimeout responds 504 if the method doesn't return in time, 0 means unlimited.
+func (h *ServiceHandler) SetTimeout(timeout time.Duration) {
+	h.timeout = timeout
+}
+
+// timeoutWriter merges the headers on the writes, the timeout response may be written meanwhile.
+type timeoutWriter struct {
+	ctx      context.Context
+	w        http.ResponseWriter
+	header   http.Header
+	mutex    sync.Mutex
+	timedOut bool
+	wrote    bool
+}
+
+func newTimeoutWriter(ctx context.Context, w http.ResponseWriter) *timeoutWriter {
+	header := make(http.Header, len(w.Header()))
+	for k, v := range w.Header() {
+		header[k] = v
+	}
+
+	return &timeoutWriter{ctx: ctx, w: w, header: header}
+}
+
+// expired is called with the mutex locked.
+func (tw *timeoutWriter) expired() bool {
+	return tw.timedOut || tw.ctx.Err() != nil
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+// syncHeader is called with the mutex locked.
+func (tw *timeoutWriter) syncHeader() {
+	dst := tw.w.Header()
+	for k := range dst {
+		if _, ok := tw.header[k]; !ok {
+			delete(dst, k)
+		}
+	}
+
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.setStatus(status, nil)
+}
+
+// setStatus records the status unless expired.
+func (tw *timeoutWriter) setStatus(status int, record func()) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.expired() {
+		return
+	}
+
+	if record != nil {
+		record()
+	}
+	tw.syncHeader()
+	tw.wrote = true
+	tw.w.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if !tw.wrote {
+		tw.syncHeader()
+		tw.wrote = true
+	}
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if flusher, ok := tw.w.(http.Flusher); ok && !tw.expired() {
+		flusher.Flush()
+	}
+}
+
+// call returns timedOut if tw.ctx is done before the method returns.
+func (tw *timeoutWriter) call(method *serviceMethod, in []reflect.Value) (out []reflect.Value, ps *panicStack,
+	timedOut bool, written bool) {
+	type result struct {
+		out []reflect.Value
+		ps  *panicStack
+	}
+
+	results := make(chan *result, 1)
+	go func() {
+		out, ps := doServiceMethodCall(method, in)
+		results <- &result{out, ps}
+	}()
+
+	var res *result
+	select {
+	case res = <-results:
+	case <-tw.ctx.Done():
+	}
+
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if res == nil || tw.ctx.Err() != nil {
+		tw.timedOut = true
+		return nil, nil, true, tw.wrote
+	}
+
+	if !tw.wrote {
+		tw.syncHeader()
+	}
+	return res.out, res.ps, false, false
+}
+
+// logger drops the records after the timeout, since the access log row may be being written.
+func (tw *timeoutWriter) logger(logger MethodLogger) MethodLogger {
+	if logger == nil {
+		return nil
+	}
+
+	return &timeoutLogger{logger, tw}
+}
+
+type timeoutLogger struct {
+	logger MethodLogger
+	tw     *timeoutWriter
+}
+
+func (l *timeoutLogger) Record(field string, value string) {
+	l.tw.mutex.Lock()
+	defer l.tw.mutex.Unlock()
+	if !l.tw.expired() {
+		l.logger.Record(field, value)
+	}
+}
+
+func methodTimeoutResponse(timeout time.Duration) *FormattedResponse {
+	return &FormattedResponse{504, "service method timeout",
+		fmt.Sprintf("the service method doesn't return in %s", timeout)}
+}
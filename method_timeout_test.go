@@ -0,0 +1,65 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMethodTimeout(t *testing.T) {
+	cancelled := make(chan struct{}, 1)
+	logs := &bytes.Buffer{}
+	router, err := NewLoggingHTTPRouter([]*Route{{
+		Method:  "GET",
+		Path:    "/slow",
+		Timeout: 10 * time.Millisecond,
+		Function: func(ctx *ServiceMethodContext, arg *struct{}) (*struct{ A int }, error) {
+			ctx.ResponseHeader.Set("X-Dropped", "1")
+			<-ctx.Context.Done()
+			ctx.Logger().Record("late", "1")
+			ctx.ResponseBodyWriter.Write([]byte("late"))
+			cancelled <- struct{}{}
+			return &struct{ A int }{1}, nil
+		},
+	}, {
+		Method:  "GET",
+		Path:    "/fast",
+		Timeout: time.Second,
+		Function: func(ctx *ServiceMethodContext, arg *struct{}) (*struct{ A int }, error) {
+			ctx.ResponseHeader.Set("X-Kept", "1")
+			return &struct{ A int }{2}, nil
+		},
+	}}, nil, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+	if w.Code != 504 || !strings.Contains(w.Body.String(), "service method timeout") || w.Header().Get("X-Dropped") != "" {
+		t.Error(w.Code, w.Body.String(), w.Header())
+	}
+
+	<-cancelled
+	if !strings.Contains(logs.String(), "timedOut=true") || !strings.Contains(logs.String(), "status=504") ||
+		strings.Contains(logs.String(), "late") {
+		t.Error(logs.String())
+	}
+
+	if strings.Contains(w.Body.String(), "late") {
+		t.Error(w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/fast", nil))
+	if w.Code != 200 || strings.TrimSpace(w.Body.String()) != `{"A":2}` || w.Header().Get("X-Kept") != "1" {
+		t.Error(w.Code, w.Body.String(), w.Header())
+	}
+
+	if err := ValidateRoutes([]*Route{{Method: "GET", Path: "/", Timeout: -time.Second,
+		Function: func(ctx *ServiceMethodContext, arg *struct{}) error { return nil }}}); err == nil {
+		t.Error("negative timeout is accepted")
+	}
+}
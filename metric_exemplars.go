@@ -0,0 +1,40 @@
+package apihttpwrapper
+
+import (
+	"context"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// spanContextRecorder records the span of the request for RequestMetrics.TraceID.
+type spanContextRecorder struct {
+	spanContext oteltrace.SpanContext
+}
+
+type spanContextRecorderKey struct{}
+
+func withSpanContextRecorder(r *http.Request) (*http.Request, *spanContextRecorder) {
+	recorder := &spanContextRecorder{}
+	return r.WithContext(context.WithValue(r.Context(), spanContextRecorderKey{}, recorder)), recorder
+}
+
+// recordSpanContext is called by the ServiceHandler starting the span of the request.
+func recordSpanContext(ctx context.Context, spanContext oteltrace.SpanContext) {
+	if recorder, ok := ctx.Value(spanContextRecorderKey{}).(*spanContextRecorder); ok {
+		recorder.spanContext = spanContext
+	}
+}
+
+// ids returns the empty strings if no span is valid.
+func (recorder *spanContextRecorder) ids(r *http.Request) (string, string) {
+	spanContext := oteltrace.SpanContextFromContext(r.Context())
+	if recorder != nil && recorder.spanContext.IsValid() {
+		spanContext = recorder.spanContext
+	}
+
+	if !spanContext.IsValid() || !spanContext.IsSampled() {
+		return "", ""
+	}
+
+	return spanContext.TraceID().String(), spanContext.SpanID().String()
+}
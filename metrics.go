@@ -0,0 +1,202 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type RequestMetrics struct {
+	// Route is the path pattern which the request matched, like "/user/:Name".
+	Route         string
+	Method        string
+	Status        int
+	Duration      time.Duration
+	RequestBytes  int64
+	ResponseBytes int64
+	// TraceID and SpanID are of the sampled span, empty if not traced.
+	TraceID string
+	SpanID  string
+	// Traffic is the class of the request, "" if RouterOptions.Classifier isn't set.
+	Traffic TrafficClass
+}
+
+type MetricsCollector interface {
+	Observe(m *RequestMetrics)
+}
+
+type Histogram struct {
+	// Buckets are the inclusive upper bounds, Counts has one more element for the values beyond the last bound.
+	Buckets []int64  `json:"buckets"`
+	Counts  []uint64 `json:"counts"`
+	Count   uint64   `json:"count"`
+	Sum     int64    `json:"sum"`
+}
+
+type RouteSizeMetrics struct {
+	Method        string     `json:"method"`
+	Route         string     `json:"route"`
+	RequestBytes  *Histogram `json:"requestBytes"`
+	ResponseBytes *Histogram `json:"responseBytes"`
+}
+
+// SizeMetricsCollector keeps request and response body size histograms per route in memory.
+type SizeMetricsCollector struct {
+	buckets []int64
+	mu      sync.Mutex
+	routes  map[[2]string]*RouteSizeMetrics
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	count int64
+}
+
+// DefaultSizeBuckets grows by 4 times from 64B to 64MB.
+var DefaultSizeBuckets = []int64{64, 256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20,
+	16 << 20, 64 << 20}
+
+func NewHistogram(buckets []int64) *Histogram {
+	sorted := append([]int64(nil), buckets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return &Histogram{
+		Buckets: sorted,
+		Counts:  make([]uint64, len(sorted)+1),
+	}
+}
+
+func (h *Histogram) Observe(v int64) {
+	i := sort.Search(len(h.Buckets), func(i int) bool { return v <= h.Buckets[i] })
+	h.Counts[i]++
+	h.Count++
+	h.Sum += v
+}
+
+func (h *Histogram) clone() *Histogram {
+	return &Histogram{
+		Buckets: h.Buckets,
+		Counts:  append([]uint64(nil), h.Counts...),
+		Count:   h.Count,
+		Sum:     h.Sum,
+	}
+}
+
+func NewSizeMetricsCollector(buckets []int64) *SizeMetricsCollector {
+	if len(buckets) == 0 {
+		buckets = DefaultSizeBuckets
+	}
+
+	return &SizeMetricsCollector{
+		buckets: buckets,
+		routes:  make(map[[2]string]*RouteSizeMetrics),
+	}
+}
+
+func (c *SizeMetricsCollector) Observe(m *RequestMetrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := [2]string{m.Method, m.Route}
+	rm, ok := c.routes[key]
+	if !ok {
+		rm = &RouteSizeMetrics{
+			Method:        m.Method,
+			Route:         m.Route,
+			RequestBytes:  NewHistogram(c.buckets),
+			ResponseBytes: NewHistogram(c.buckets),
+		}
+		c.routes[key] = rm
+	}
+
+	rm.RequestBytes.Observe(m.RequestBytes)
+	rm.ResponseBytes.Observe(m.ResponseBytes)
+}
+
+func (c *SizeMetricsCollector) Snapshot() []*RouteSizeMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot := make([]*RouteSizeMetrics, 0, len(c.routes))
+	for _, rm := range c.routes {
+		snapshot = append(snapshot, &RouteSizeMetrics{
+			Method:        rm.Method,
+			Route:         rm.Route,
+			RequestBytes:  rm.RequestBytes.clone(),
+			ResponseBytes: rm.ResponseBytes.clone(),
+		})
+	}
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].Route != snapshot[j].Route {
+			return snapshot[i].Route < snapshot[j].Route
+		}
+		return snapshot[i].Method < snapshot[j].Method
+	})
+
+	return snapshot
+}
+
+// ServeHTTP writes the snapshot as json, so that the collector can be mounted as a metrics endpoint.
+func (c *SizeMetricsCollector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	setResponseHeader(w)
+	_ = json.NewEncoder(w).Encode(c.Snapshot())
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(&r.count, int64(n))
+	return n, err
+}
+
+// newMetricsHandle records the span started inside it if traced, which is set if the routes have the tracer provider.
+func newMetricsHandle(handle httprouter.Handle, method string, path string, collector MetricsCollector,
+	traced bool) httprouter.Handle {
+	inFlight, _ := collector.(InFlightCollector)
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		if inFlight != nil {
+			inFlight.Started(method, path)
+			defer inFlight.Finished(method, path)
+		}
+
+		beginTime := time.Now()
+		body := &countingReadCloser{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+
+		sw := &statusResponseWriter{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+		}
+
+		var spans *spanContextRecorder
+		if traced {
+			r, spans = withSpanContextRecorder(r)
+		}
+
+		handle(sw, r, params)
+
+		requestBytes := atomic.LoadInt64(&body.count)
+		if r.ContentLength > requestBytes {
+			requestBytes = r.ContentLength
+		}
+
+		traceID, spanID := spans.ids(r)
+		collector.Observe(&RequestMetrics{
+			Route:         path,
+			Method:        method,
+			Status:        sw.status,
+			Duration:      time.Now().Sub(beginTime),
+			RequestBytes:  requestBytes,
+			ResponseBytes: sw.written,
+			TraceID:       traceID,
+			SpanID:        spanID,
+			Traffic:       TrafficClassFromContext(r.Context()),
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package apihttpwrapper
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	buckets := []int64{100, 10, 1000}
+	h := NewHistogram(buckets)
+	for _, v := range []int64{0, 10, 11, 100, 1000, 1001} {
+		h.Observe(v)
+	}
+
+	if !reflect.DeepEqual(h.Buckets, []int64{10, 100, 1000}) || !reflect.DeepEqual(buckets, []int64{100, 10, 1000}) {
+		t.Error("the buckets are not sorted into a copy", h.Buckets, buckets)
+	}
+
+	// the bounds are inclusive, and the last count is of the values beyond the last bound.
+	if !reflect.DeepEqual(h.Counts, []uint64{2, 2, 1, 1}) || h.Count != 6 || h.Sum != 2122 {
+		t.Error(h.Counts, h.Count, h.Sum)
+	}
+}
+
+func TestSizeMetricsCollector(t *testing.T) {
+	type item struct {
+		Name string `json:"name"`
+	}
+
+	collector := NewSizeMetricsCollector([]int64{8, 64})
+	router, err := NewHTTPRouterWithOptions([]*Route{
+		{Method: "POST", Path: "/items", Function: func(ctx *ServiceMethodContext, arg *item) (*item, error) {
+			return arg, nil
+		}},
+		{Method: "GET", Path: "/items", Function: func(ctx *ServiceMethodContext, arg *struct{}) error {
+			return nil
+		}},
+		{Method: "POST", Path: "/archives", BypassRequestBody: true,
+			Function: func(ctx *ServiceMethodContext, arg *struct{}) error {
+				return nil
+			}},
+	}, &RouterOptions{Metrics: collector})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"name":"` + strings.Repeat("n", 20) + `"}`
+	// the declared length counts for the bodies not read, and the bytes read count for the chunked bodies.
+	for _, c := range []struct {
+		method        string
+		path          string
+		contentLength int64
+	}{{"POST", "/items", -1}, {"POST", "/archives", int64(len(body))}, {"GET", "/items", 0}} {
+		r := httptest.NewRequest(c.method, c.path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.ContentLength = c.contentLength
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != 200 {
+			t.Fatal(c.path, w.Code, w.Body.String())
+		}
+	}
+
+	snapshot := collector.Snapshot()
+	if len(snapshot) != 3 {
+		t.Fatal(snapshot)
+	}
+
+	expected := []struct {
+		method   string
+		route    string
+		request  []uint64
+		response []uint64
+	}{
+		{"POST", "/archives", []uint64{0, 1, 0}, []uint64{1, 0, 0}},
+		{"GET", "/items", []uint64{1, 0, 0}, []uint64{1, 0, 0}},
+		{"POST", "/items", []uint64{0, 1, 0}, []uint64{0, 1, 0}},
+	}
+
+	for i, e := range expected {
+		m := snapshot[i]
+		if m.Method != e.method || m.Route != e.route || !reflect.DeepEqual(m.RequestBytes.Counts, e.request) ||
+			!reflect.DeepEqual(m.ResponseBytes.Counts, e.response) {
+			t.Error(i, m.Method, m.Route, m.RequestBytes, m.ResponseBytes)
+		}
+	}
+
+	if snapshot[0].RequestBytes.Sum != int64(len(body)) || snapshot[2].RequestBytes.Sum != int64(len(body)) {
+		t.Error(snapshot[0].RequestBytes.Sum, snapshot[2].RequestBytes.Sum)
+	}
+
+	// the snapshot is a copy.
+	snapshot[0].RequestBytes.Observe(1)
+	if collector.Snapshot()[0].RequestBytes.Count != 1 {
+		t.Error("the snapshot shares the histograms of the collector")
+	}
+}
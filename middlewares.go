@@ -0,0 +1,29 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// newMiddlewareHandle applies the middlewares in order, the first one is the outermost.
+func newMiddlewareHandle(handle httprouter.Handle, middlewares []func(http.Handler) http.Handler) httprouter.Handle {
+	if len(middlewares) == 0 {
+		return handle
+	}
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r, httprouter.ParamsFromContext(r.Context()))
+	})
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		if params != nil {
+			r = r.WithContext(context.WithValue(r.Context(), httprouter.ParamsKey, params))
+		}
+
+		handler.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,59 @@
+package apihttpwrapper
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewares(t *testing.T) {
+	var calls []string
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name+":"+httprouter.ParamsFromContext(r.Context()).ByName("Name"))
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	router, err := NewHTTPRouterWithOptions([]*Route{{
+		Method: "GET",
+		Path:   "/user/:Name",
+		Function: func(_ *ServiceMethodContext, arg *struct{ Name string }) error {
+			calls = append(calls, "method:"+arg.Name)
+			return nil
+		},
+		Middlewares: []func(http.Handler) http.Handler{tag("route"), auth},
+	}}, &RouterOptions{Middlewares: []func(http.Handler) http.Handler{tag("global")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/user/bob", nil)
+	r.Header.Set("Authorization", "token")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, r)
+	if recorder.Code != 200 || strings.Join(calls, ",") != "global:bob,route:bob,method:bob" {
+		t.Error(recorder.Code, calls)
+	}
+
+	calls = nil
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/user/bob", nil))
+	if recorder.Code != 401 || strings.Join(calls, ",") != "global:bob,route:bob" {
+		t.Error(recorder.Code, calls)
+	}
+}
@@ -0,0 +1,63 @@
+package apihttpwrapper
+
+import (
+	"mime/multipart"
+	"reflect"
+	"strings"
+)
+
+// DefaultMultipartMemory is the bytes of the multipart bodies kept in memory.
+const DefaultMultipartMemory = 1024
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// SetMultipartMemory sets the memory of the multipart bodies, DefaultMultipartMemory if not positive.
+func (h *ServiceHandler) SetMultipartMemory(bytes int64) {
+	if bytes <= 0 {
+		bytes = DefaultMultipartMemory
+	}
+
+	h.multipartMemory = bytes
+}
+
+// bindFileHeaders sets the file header fields by the form field names.
+func bindFileHeaders(v reflect.Value, form *multipart.Form) {
+	if form == nil || len(form.File) == 0 {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			bindFileHeaders(v.Field(i), form)
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("schema"), ",")[0]
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+
+		files := form.File[name]
+		if len(files) == 0 {
+			continue
+		}
+
+		switch field.Type {
+		case fileHeaderType:
+			v.Field(i).Set(reflect.ValueOf(files[0]))
+		case fileHeadersType:
+			v.Field(i).Set(reflect.ValueOf(files))
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMultipartFileBinding(t *testing.T) {
+	type uploadArguments struct {
+		Title       string                  `schema:"title"`
+		Avatar      *multipart.FileHeader   `schema:"avatar"`
+		Attachments []*multipart.FileHeader `schema:"attachment"`
+	}
+
+	var contents []string
+	handler, err := NewServiceHandler(func(ctx *ServiceMethodContext, arg *uploadArguments) error {
+		if arg.Title != "t" || arg.Avatar == nil || arg.Avatar.Filename != "a.png" || len(arg.Attachments) != 2 {
+			t.Errorf("%+v", arg)
+			return nil
+		}
+
+		for _, header := range append([]*multipart.FileHeader{arg.Avatar}, arg.Attachments...) {
+			f, err := header.Open()
+			if err != nil {
+				return err
+			}
+			b, _ := ioutil.ReadAll(f)
+			_ = f.Close()
+			contents = append(contents, string(b))
+		}
+		return nil
+	}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.SetMultipartMemory(4)
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	_ = mw.WriteField("title", "t")
+	for _, file := range [][2]string{{"avatar", "a.png"}, {"attachment", "b.txt"}, {"attachment", "c.txt"}} {
+		w, _ := mw.CreateFormFile(file[0], file[1])
+		_, _ = w.Write([]byte("content of " + file[1]))
+	}
+	_ = mw.Close()
+
+	r := httptest.NewRequest("POST", "/", body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != 200 || len(contents) != 3 || contents[2] != "content of c.txt" {
+		t.Error(w.Code, w.Body.String(), contents)
+	}
+}
@@ -0,0 +1,419 @@
+package apihttpwrapper
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"html"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// OpenAPIOptions serves the OpenAPI document of the routes, see GenerateOpenAPI.
+type OpenAPIOptions struct {
+	Title   string
+	Version string
+	// Path serves the document as JSON, like "/openapi.json".
+	Path string
+	// SwaggerUIPath serves the Swagger UI of the document if not empty, the assets are loaded from SwaggerUIAssets.
+	SwaggerUIPath string
+}
+
+// SwaggerUIAssets is where the Swagger UI page loads the scripts and the styles from.
+var SwaggerUIAssets = "https://unpkg.com/swagger-ui-dist@3"
+
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       *OpenAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components *OpenAPIComponents                      `json:"components,omitempty"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*OpenAPISchema `json:"schemas,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+}
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	bulkResultType    = reflect.TypeOf(BulkResult{})
+)
+
+const formattedErrorName = "FormattedResponse"
+
+type openAPIGenerator struct {
+	schemas map[string]*OpenAPISchema
+	names   map[reflect.Type]string
+}
+
+// GenerateOpenAPI describes the routes by reflecting on their functions.
+func GenerateOpenAPI(title string, version string, routes []*Route) (*OpenAPIDocument, error) {
+	g := &openAPIGenerator{
+		schemas: make(map[string]*OpenAPISchema),
+		names:   make(map[reflect.Type]string),
+	}
+	g.schemas[formattedErrorName] = &OpenAPISchema{
+		Type: "object",
+		Properties: map[string]*OpenAPISchema{
+			"code": {Type: "integer"},
+			"msg":  {Type: "string"},
+			"data": {},
+		},
+		Required: []string{"code", "msg", "data"},
+	}
+
+	doc := &OpenAPIDocument{
+		OpenAPI:    "3.0.3",
+		Info:       &OpenAPIInfo{Title: title, Version: version},
+		Paths:      make(map[string]map[string]*OpenAPIOperation),
+		Components: &OpenAPIComponents{Schemas: g.schemas},
+	}
+
+	for _, rt := range routes {
+		methodType := reflect.TypeOf(rt.Function)
+		if err := checkRoutePrototype(rt); err != nil {
+			return nil, fmt.Errorf("route %s %s: %s", rt.Method, rt.Path, err)
+		}
+
+		path, pathParams := openAPIPath(rt.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*OpenAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(rt.Method)] = g.operation(rt, methodType, pathParams)
+	}
+
+	return doc, nil
+}
+
+func isDefaultBodyMethod(method string) bool {
+	for _, m := range DefaultBodyMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// openAPIPath converts the httprouter path pattern, like "/user/:Name" to "/user/{Name}".
+func openAPIPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+func (g *openAPIGenerator) operation(rt *Route, methodType reflect.Type, pathParams []string) *OpenAPIOperation {
+	op := &OpenAPIOperation{Responses: map[string]*OpenAPIResponse{
+		"default": {Description: "error", Content: map[string]*OpenAPIMediaType{
+			"application/json": {Schema: &OpenAPISchema{Ref: "#/components/schemas/" + formattedErrorName}},
+		}},
+	}}
+
+	argType := methodType.In(1)
+	formFields := map[string]reflect.Type{}
+	if isStructPointer(argType) {
+		formFields = openAPIFormFields(argType.Elem())
+	}
+
+	isPathParam := map[string]bool{}
+	for _, name := range pathParams {
+		isPathParam[name] = true
+		schema := &OpenAPISchema{Type: "string"}
+		if t, ok := formFields[name]; ok {
+			schema = g.schema(t)
+		}
+
+		op.Parameters = append(op.Parameters, &OpenAPIParameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+
+	if isStructPointer(argType) {
+		for _, in := range []string{"header", "cookie"} {
+			fields := map[string]reflect.Type{}
+			headerCookieFields(argType.Elem(), in, fields)
+			names := make([]string, 0, len(fields))
+			for name := range fields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				op.Parameters = append(op.Parameters, &OpenAPIParameter{Name: name, In: in,
+					Schema: g.schema(fields[name])})
+			}
+		}
+	}
+
+	if isDefaultBodyMethod(rt.Method) && !rt.BypassRequestBody {
+		op.RequestBody = &OpenAPIRequestBody{Required: true, Content: map[string]*OpenAPIMediaType{
+			"application/json": {Schema: g.schema(argType)},
+		}}
+	} else {
+		names := make([]string, 0, len(formFields))
+		for name := range formFields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if !isPathParam[name] {
+				op.Parameters = append(op.Parameters, &OpenAPIParameter{Name: name, In: "query",
+					Schema: g.schema(formFields[name])})
+			}
+		}
+	}
+
+	switch {
+	case rt.WebSocket != nil:
+		op.Responses["101"] = &OpenAPIResponse{Description: "websocket upgrade"}
+	case rt.EventStream != nil || isEventStreamFunction(methodType):
+		op.Responses["200"] = &OpenAPIResponse{Description: "event stream", Content: map[string]*OpenAPIMediaType{
+			"text/event-stream": {Schema: &OpenAPISchema{Type: "string"}},
+		}}
+	case isDelegatedResponseBodyFunction(methodType):
+		op.Responses["200"] = &OpenAPIResponse{Description: "ok", Content: map[string]*OpenAPIMediaType{
+			"application/json": {Schema: g.schema(methodType.Out(0))},
+		}}
+	default:
+		op.Responses["200"] = &OpenAPIResponse{Description: "written by the service method"}
+	}
+
+	return op
+}
+
+// openAPIFormFields lists the fields gorilla/schema binds.
+func openAPIFormFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("schema"), ",")[0]
+		if name == "-" || field.Tag.Get("header") != "" || field.Tag.Get("cookie") != "" {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if ft.Kind() == reflect.Struct && !reflect.PtrTo(ft).Implements(textMarshalerType) && ft != timeType {
+			continue
+		}
+
+		fields[name] = field.Type
+	}
+
+	return fields
+}
+
+func (g *openAPIGenerator) schema(t reflect.Type) *OpenAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == bulkResultType:
+		return &OpenAPISchema{Type: "array", Items: g.schema(reflect.TypeOf(BulkItemResult{}))}
+	case t == timeType:
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	case reflect.PtrTo(t).Implements(textMarshalerType):
+		return &OpenAPISchema{Type: "string"}
+	case reflect.PtrTo(t).Implements(jsonMarshalerType):
+		// the shape is up to the marshaler.
+		return &OpenAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &OpenAPISchema{Type: "string", Format: "byte"}
+		}
+		return &OpenAPISchema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.structSchema(t)
+	}
+
+	return &OpenAPISchema{}
+}
+
+// structSchema puts the named structs into the components, so that the recursive types can be described.
+func (g *openAPIGenerator) structSchema(t reflect.Type) *OpenAPISchema {
+	if t.Name() == "" {
+		return g.objectSchema(t)
+	}
+
+	name, ok := g.names[t]
+	if !ok {
+		name = t.Name()
+		for i := 2; g.schemas[name] != nil; i++ {
+			name = fmt.Sprintf("%s%d", t.Name(), i)
+		}
+
+		g.names[t] = name
+		g.schemas[name] = &OpenAPISchema{}
+		*g.schemas[name] = *g.objectSchema(t)
+	}
+
+	return &OpenAPISchema{Ref: "#/components/schemas/" + name}
+}
+
+// objectSchema follows the encoding/json names, and requires the fields of validate "required".
+func (g *openAPIGenerator) objectSchema(t reflect.Type) *OpenAPISchema {
+	schema := &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		if tag[0] == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if field.Anonymous && tag[0] == "" && ft.Kind() == reflect.Struct {
+			embedded := g.objectSchema(ft)
+			for name, property := range embedded.Properties {
+				schema.Properties[name] = property
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := tag[0]
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = g.schema(field.Type)
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			if rule == "required" {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	}
+
+	return schema
+}
+
+// ServeHTTP writes the document as JSON.
+func (doc *OpenAPIDocument) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	setResponseHeader(w)
+	_ = json.NewEncoder(w).Encode(doc)
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%[1]s</title>
+<link rel="stylesheet" href="%[2]s/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="%[2]s/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({url: %[3]q, dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+func newSwaggerUIHandle(title string, specPath string) httprouter.Handle {
+	page := fmt.Sprintf(swaggerUIPage, html.EscapeString(title), SwaggerUIAssets, specPath)
+	return func(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(page))
+	}
+}
+
+func registerOpenAPI(r *httprouter.Router, routes []*Route, options *OpenAPIOptions) error {
+	doc, err := GenerateOpenAPI(options.Title, options.Version, routes)
+	if err != nil {
+		return err
+	}
+
+	err = handleRoute(r, "GET", options.Path, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		doc.ServeHTTP(w, r)
+	})
+	if err != nil || options.SwaggerUIPath == "" {
+		return err
+	}
+
+	return handleRoute(r, "GET", options.SwaggerUIPath, newSwaggerUIHandle(options.Title, options.Path))
+}
@@ -0,0 +1,101 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type openAPIUser struct {
+	Name    string    `json:"name" validate:"required"`
+	Created time.Time `json:"created"`
+	Friends []*openAPIUser
+	secret  string
+}
+
+func TestGenerateOpenAPI(t *testing.T) {
+	routes := []*Route{{
+		Method: "GET",
+		Path:   "/users/:id",
+		Function: func(*ServiceMethodContext, *struct {
+			ID     int64     `schema:"id"`
+			Fields []string  `schema:"fields"`
+			Range  DateRange `schema:"range"`
+		}) (*openAPIUser, error) {
+			return nil, nil
+		},
+	}, {
+		Method: "POST",
+		Path:   "/users",
+		Function: func(*ServiceMethodContext, *openAPIUser) (*BulkResult, error) {
+			return nil, nil
+		},
+	}}
+
+	doc, err := GenerateOpenAPI("users", "1.0", append(routes, &Route{
+		Method: "GET",
+		Path:   "/users/:id/events",
+		Function: func(*ServiceMethodContext, *struct{}) (EventFilter, error) {
+			return nil, nil
+		},
+		EventStream: &EventStream{Topic: "users"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := doc.Paths["/users/{id}"]["get"]
+	if len(get.Parameters) != 3 || get.Parameters[0].In != "path" || get.Parameters[0].Schema.Format != "int64" ||
+		get.Parameters[1].Name != "fields" || get.Parameters[1].Schema.Type != "array" ||
+		get.Parameters[2].Schema.Type != "string" {
+		t.Error(get.Parameters)
+	}
+
+	if get.Responses["200"].Content["application/json"].Schema.Ref != "#/components/schemas/openAPIUser" {
+		t.Error(get.Responses)
+	}
+
+	user := doc.Components.Schemas["openAPIUser"]
+	if len(user.Properties) != 3 || user.Properties["created"].Format != "date-time" ||
+		user.Properties["Friends"].Items.Ref != "#/components/schemas/openAPIUser" ||
+		len(user.Required) != 1 || user.Required[0] != "name" {
+		t.Error(user)
+	}
+
+	events := doc.Paths["/users/{id}/events"]["get"]
+	if events.Responses["200"].Content["text/event-stream"] == nil {
+		t.Error(events.Responses)
+	}
+
+	post := doc.Paths["/users"]["post"]
+	if post.RequestBody.Content["application/json"].Schema.Ref != "#/components/schemas/openAPIUser" ||
+		post.Responses["200"].Content["application/json"].Schema.Type != "array" ||
+		post.Responses["default"].Content["application/json"].Schema.Ref != "#/components/schemas/FormattedResponse" {
+		t.Error(post)
+	}
+
+	router, err := NewHTTPRouterWithOptions(routes, &RouterOptions{OpenAPI: &OpenAPIOptions{
+		Title:         "users",
+		Version:       "1.0",
+		Path:          "/openapi.json",
+		SwaggerUIPath: "/docs",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/openapi.json", nil))
+	var served map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &served); err != nil || served["openapi"] != "3.0.3" {
+		t.Error(err, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/docs", nil))
+	if !strings.Contains(recorder.Body.String(), "url: \"/openapi.json\"") {
+		t.Error(recorder.Body.String())
+	}
+}
@@ -0,0 +1,59 @@
+package apihttpwrapper
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+const tracerName = "github.com/abadcafe/apihttpwrapper"
+
+// SetTracerProvider starts an OpenTelemetry span per request, named by route or the request path.
+func (h *ServiceHandler) SetTracerProvider(provider oteltrace.TracerProvider, route string) {
+	if provider == nil {
+		h.tracer = nil
+		return
+	}
+
+	h.tracer = provider.Tracer(tracerName)
+	h.route = route
+}
+
+// startSpan returns the function ending the span.
+func (h *ServiceHandler) startSpan(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request,
+	func()) {
+	if h.tracer == nil {
+		return w, r, func() {}
+	}
+
+	name := h.route
+	attributes := []attribute.KeyValue{
+		semconv.HTTPMethodKey.String(r.Method),
+		semconv.HTTPTargetKey.String(r.URL.RequestURI()),
+	}
+	if name == "" {
+		name = r.URL.Path
+	} else {
+		attributes = append(attributes, semconv.HTTPRouteKey.String(name))
+	}
+
+	ctx, span := h.tracer.Start(r.Context(), name, oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+		oteltrace.WithAttributes(attributes...))
+	recordSpanContext(ctx, span.SpanContext())
+	sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+	return sw, r.WithContext(ctx), func() {
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(sw.status))
+		if sw.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.status))
+		}
+		span.End()
+	}
+}
+
+func recordSpanPanic(span oteltrace.Span, ps *panicStack) {
+	span.AddEvent("panic", oteltrace.WithAttributes(attribute.String("panic", ps.Panic),
+		attribute.String("stack", ps.Stack)))
+	span.SetStatus(codes.Error, ps.Panic)
+}
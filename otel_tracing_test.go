@@ -0,0 +1,62 @@
+package apihttpwrapper
+
+import (
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenTelemetryTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	router, err := NewHTTPRouterWithOptions([]*Route{{
+		Method: "GET",
+		Path:   "/users/:id",
+		Function: func(ctx *ServiceMethodContext, _ *struct{}) error {
+			_, span := oteltrace.SpanFromContext(ctx.Context).TracerProvider().Tracer("test").Start(ctx.Context, "query")
+			span.End()
+			return nil
+		},
+	}, {
+		Method: "GET",
+		Path:   "/panic",
+		Function: func(*ServiceMethodContext, *struct{}) error {
+			panic("expected panic")
+		},
+	}}, &RouterOptions{TracerProvider: provider})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatal(spans)
+	}
+
+	child, server, panicked := spans[0], spans[1], spans[2]
+	if child.Name() != "query" || child.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error(child.Name(), child.Parent())
+	}
+
+	attributes := map[string]string{}
+	for _, kv := range server.Attributes() {
+		attributes[string(kv.Key)] = kv.Value.Emit()
+	}
+
+	if server.Name() != "/users/:id" || attributes[string(semconv.HTTPRouteKey)] != "/users/:id" ||
+		attributes[string(semconv.HTTPStatusCodeKey)] != "200" || server.SpanKind() != oteltrace.SpanKindServer {
+		t.Error(server.Name(), attributes)
+	}
+
+	events := panicked.Events()
+	if panicked.Status().Code != codes.Error || len(events) != 1 || events[0].Name != "panic" {
+		t.Error(panicked.Status(), events)
+	}
+}
@@ -0,0 +1,54 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"golang.org/x/net/trace"
+	"net/http"
+	"strings"
+)
+
+// PanicHook receives the panics with the stacks, the request is nil for the ScheduledJobs.
+type PanicHook func(r *http.Request, incident string, panicked string, stack string)
+
+// SetPanicHook sets the callback of the panics, including the ones not responded.
+func (h *ServiceHandler) SetPanicHook(hook PanicHook) {
+	h.panicHook = hook
+}
+
+// notifyPanic reports the panic not responded, into the field of the method logger.
+func (h *ServiceHandler) notifyPanic(r *http.Request, field string, ps *panicStack) {
+	reportPanic(h.methodLogger(r), h.panicHook, r, field, ps)
+}
+
+// reportPanic is notifyPanic of the method logger and the hook which may be nil, like those of the ScheduledJobs.
+func reportPanic(logger MethodLogger, hook PanicHook, r *http.Request, field string, ps *panicStack) {
+	id := newIncidentID()
+	if logger != nil {
+		detail, _ := json.Marshal(ps)
+		logger.Record(field, id)
+		logger.Record(strings.TrimSuffix(field, "Id")+"Detail", string(detail))
+	}
+
+	if hook != nil {
+		hook(r, id, ps.Panic, ps.Stack)
+	}
+}
+
+// SetExposePanicDetails responds the panics with the stacks, only for the development.
+func (h *ServiceHandler) SetExposePanicDetails(expose bool) {
+	h.exposePanicDetails = expose
+}
+
+func (h *ServiceHandler) panicResponse(w http.ResponseWriter, r *http.Request, tr trace.Trace,
+	ps *panicStack) *FormattedResponse {
+	resp := h.reportIncident(w, r, tr, &FormattedResponse{500, "service method panicked", ps})
+	if h.panicHook != nil {
+		h.panicHook(r, resp.Data.(*Incident).ID, ps.Panic, ps.Stack)
+	}
+
+	if h.exposePanicDetails {
+		return &FormattedResponse{resp.Code, resp.Msg, ps}
+	}
+
+	return resp
+}
@@ -0,0 +1,101 @@
+package apihttpwrapper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPanicReports(t *testing.T) {
+	do := func(options *RouterOptions) *httptest.ResponseRecorder {
+		router, err := NewHTTPRouterWithOptions([]*Route{{
+			Method: "GET",
+			Path:   "/",
+			Function: func(*ServiceMethodContext, *struct{}) error {
+				panic("secret panic")
+			},
+		}}, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+		return recorder
+	}
+
+	var hooked []string
+	recorder := do(&RouterOptions{PanicHook: func(r *http.Request, incident string, panicked string, stack string) {
+		hooked = append(hooked, incident, panicked, stack)
+	}})
+	body := recorder.Body.String()
+	if recorder.Code != 500 || strings.Contains(body, "secret panic") || strings.Contains(body, "goroutine") {
+		t.Error(recorder.Code, body)
+	}
+
+	if len(hooked) != 3 || hooked[0] != recorder.Header().Get(IncidentHeader) || hooked[1] != "secret panic" ||
+		!strings.Contains(hooked[2], "goroutine") {
+		t.Error(hooked)
+	}
+
+	recorder = do(&RouterOptions{ExposePanicDetails: true})
+	if body := recorder.Body.String(); !strings.Contains(body, "secret panic") || !strings.Contains(body, "stack") {
+		t.Error(body)
+	}
+}
+
+func TestUnrespondedPanicReports(t *testing.T) {
+	var hooked []string
+	hook := func(r *http.Request, incident string, panicked string, stack string) {
+		hooked = append(hooked, panicked)
+	}
+
+	rows := make(logRowWriter, 1)
+	router, err := NewLoggingHTTPRouterWithOptions([]*Route{{
+		Method: "GET",
+		Path:   "/",
+		Function: func(*ServiceMethodContext, *struct{}) (*graphQLTestUser, error) {
+			panic("method panic")
+		},
+		Fallback: func(*ServiceMethodContext, *struct{}) (*graphQLTestUser, error) {
+			return &graphQLTestUser{Name: "cached"}, nil
+		},
+	}}, nil, rows, &RouterOptions{PanicHook: hook})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	if recorder.Code != 200 || len(hooked) != 1 || hooked[0] != "method panic" {
+		t.Error(recorder.Code, hooked)
+	}
+
+	// the stack is in the access log, the same as the responded panics.
+	if row := <-rows; !strings.Contains(row, "incidentId=") || !strings.Contains(row, "incidentDetail=") ||
+		!strings.Contains(row, "method panic") || !strings.Contains(row, "goroutine") {
+		t.Error(row)
+	}
+
+	hooked = nil
+	facade, err := NewGraphQLFacade(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facade.SetPanicHook(hook)
+	if err := facade.AddQuery("user", func(*ServiceMethodContext, *struct{}) (*graphQLTestUser, error) {
+		panic("resolver panic")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder = httptest.NewRecorder()
+	facade.ServeHTTP(recorder, httptest.NewRequest("POST", "/graphql",
+		strings.NewReader(`{"query": "{ user { name } }"}`)))
+	if body := recorder.Body.String(); len(hooked) != 1 || hooked[0] != "resolver panic" ||
+		strings.Contains(body, "resolver panic") || !strings.Contains(body, "incident") {
+		t.Error(hooked, body)
+	}
+}
@@ -0,0 +1,160 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PipelineStage is a service method of the pipeline, named by the function if empty.
+type PipelineStage struct {
+	Name     string
+	Function interface{}
+}
+
+type pipelineStage struct {
+	name   string
+	method *serviceMethod
+	in     reflect.Type
+	out    reflect.Type
+}
+
+// NewPipeline composes the service methods sequentially, the result of each stage is the argument of the next.
+func NewPipeline(stages ...PipelineStage) (interface{}, error) {
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("the pipeline has no stage")
+	}
+
+	compiled := make([]*pipelineStage, 0, len(stages))
+	for i, stage := range stages {
+		methodType := reflect.TypeOf(stage.Function)
+		if err := checkServiceMethodPrototype(methodType); err != nil {
+			return nil, fmt.Errorf("stage #%d: %s", i, err)
+		}
+
+		if isEventStreamFunction(methodType) {
+			return nil, fmt.Errorf("stage #%d: the event stream methods can't be pipeline stages", i)
+		}
+
+		name := stage.Name
+		if name == "" {
+			name = graphQLFieldName(stage.Function)
+		}
+
+		s := &pipelineStage{name: name, method: newServiceMethod(stage.Function), in: methodType.In(1)}
+		if methodType.NumOut() == 2 {
+			s.out = methodType.Out(0)
+		}
+
+		if i < len(stages)-1 && (s.out == nil || !isStructPointer(s.out)) {
+			return nil, fmt.Errorf("stage #%d %s: only the last stage can return other than (*struct, error)", i, name)
+		}
+
+		if i > 0 && !isStructPointer(s.in) {
+			return nil, fmt.Errorf("stage #%d %s: the argument should be a struct pointer", i, name)
+		}
+
+		compiled = append(compiled, s)
+	}
+
+	first, last := compiled[0], compiled[len(compiled)-1]
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	outTypes := []reflect.Type{errorType}
+	if last.out != nil {
+		outTypes = []reflect.Type{last.out, errorType}
+	}
+
+	pipelineType := reflect.FuncOf([]reflect.Type{reflect.TypeOf(&ServiceMethodContext{}), first.in}, outTypes, false)
+	return reflect.MakeFunc(pipelineType, func(args []reflect.Value) []reflect.Value {
+		return runPipeline(compiled, args[0].Interface().(*ServiceMethodContext), args[1], outTypes)
+	}).Interface(), nil
+}
+
+func runPipeline(stages []*pipelineStage, ctx *ServiceMethodContext, arg reflect.Value,
+	outTypes []reflect.Type) []reflect.Value {
+	fail := func(err error) []reflect.Value {
+		out := make([]reflect.Value, len(outTypes))
+		for i, t := range outTypes {
+			out[i] = reflect.Zero(t)
+		}
+		out[len(out)-1] = reflect.ValueOf(&err).Elem()
+		return out
+	}
+
+	var durations []string
+	defer func() {
+		ctx.Logger().Record("pipelineStages", strings.Join(durations, ","))
+	}()
+
+	tracer := oteltrace.SpanFromContext(ctx.Context).TracerProvider().Tracer(tracerName)
+	var out []reflect.Value
+	for i, stage := range stages {
+		if i > 0 {
+			if out[0].IsNil() {
+				return fail(fmt.Errorf("stage %s returned nil", stages[i-1].name))
+			}
+
+			next, err := pipelineArgument(out[0], stage.in)
+			if err != nil {
+				return fail(fmt.Errorf("stage %s: %s", stage.name, err))
+			}
+			arg = next
+		}
+
+		begin := time.Now()
+		out = stage.call(tracer, ctx, arg)
+		durations = append(durations, stage.name+"="+strconv.FormatFloat(time.Now().Sub(begin).Seconds(), 'f', -1, 64))
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return fail(err)
+		}
+	}
+
+	return out
+}
+
+// call runs the stage in its span, the panics are recorded by the span and passed on.
+func (s *pipelineStage) call(tracer oteltrace.Tracer, ctx *ServiceMethodContext, arg reflect.Value) []reflect.Value {
+	spanCtx, span := tracer.Start(ctx.Context, s.name)
+	defer span.End()
+
+	defer func() {
+		if p := recover(); p != nil {
+			span.SetStatus(codes.Error, fmt.Sprintf("%v", p))
+			panic(p)
+		}
+	}()
+
+	stageCtx := *ctx
+	stageCtx.Context = spanCtx
+	out := s.method.call([]reflect.Value{reflect.ValueOf(&stageCtx), arg})
+	if err, _ := out[len(out)-1].Interface().(error); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return out
+}
+
+// pipelineArgument converts the result of the previous stage into the argument of the next one.
+func pipelineArgument(result reflect.Value, argType reflect.Type) (reflect.Value, error) {
+	if result.Type() == argType {
+		return result, nil
+	}
+
+	marshaled, err := json.Marshal(result.Interface())
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	arg := reflect.New(argType.Elem())
+	if err := json.Unmarshal(marshaled, arg.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+
+	return arg, nil
+}
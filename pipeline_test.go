@@ -0,0 +1,79 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"errors"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type pipelineOrder struct {
+	ID    int `json:"id"`
+	Count int `json:"count"`
+}
+
+type pipelinePricing struct {
+	ID    int `json:"id"`
+	Count int `json:"count"`
+	Price int `json:"price"`
+}
+
+func TestPipeline(t *testing.T) {
+	pipeline, err := NewPipeline(
+		PipelineStage{Name: "load", Function: func(ctx *ServiceMethodContext, arg *struct{ ID int }) (*pipelineOrder, error) {
+			if arg.ID == 0 {
+				return nil, errors.New("no order")
+			}
+			return &pipelineOrder{ID: arg.ID, Count: 2}, nil
+		}},
+		PipelineStage{Name: "price", Function: func(ctx *ServiceMethodContext,
+			arg *pipelinePricing) (*pipelinePricing, error) {
+			arg.Price = arg.Count * 10
+			return arg, nil
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	buffer := &bytes.Buffer{}
+	router, err := NewLoggingHTTPRouterWithOptions([]*Route{{Method: "GET", Path: "/", Function: pipeline}}, nil, buffer,
+		&RouterOptions{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest("GET", "/?ID=7", nil))
+	if response.Code != 200 || response.Body.String() != "{\"id\":7,\"count\":2,\"price\":20}\n" {
+		t.Error(response.Code, response.Body.String())
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 || spans[0].Name() != "load" || spans[1].Name() != "price" ||
+		spans[0].Parent().SpanID() != spans[2].SpanContext().SpanID() {
+		t.Error(spans)
+	}
+
+	if logs := buffer.String(); !strings.Contains(logs, "pipelineStages=\"load=") || !strings.Contains(logs, ",price=") {
+		t.Error(logs)
+	}
+
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	if response.Code != 500 || len(recorder.Ended()) != 5 {
+		t.Error(response.Code, recorder.Ended())
+	}
+
+	_, err = NewPipeline(
+		PipelineStage{Function: func(*ServiceMethodContext, *struct{}) error { return nil }},
+		PipelineStage{Function: func(*ServiceMethodContext, *struct{}) error { return nil }},
+	)
+	if err == nil {
+		t.Error("the first stage returns nothing")
+	}
+}
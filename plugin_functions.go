@@ -0,0 +1,202 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"plugin"
+	"reflect"
+	"sync"
+)
+
+// SidecarRequest is sent to the sidecar for each call, Args is the JSON of the bound argument.
+type SidecarRequest struct {
+	Method   string
+	Metadata Metadata
+	Args     json.RawMessage
+}
+
+// SidecarResponse is the reply of the sidecar, a non-empty Error fails the call with Status, 500 by default.
+type SidecarResponse struct {
+	Status int
+	Result json.RawMessage
+	Error  string
+}
+
+// SidecarClient calls the methods of a sidecar by JSON-RPC, see SidecarService.
+type SidecarClient struct {
+	mu      sync.Mutex
+	network string
+	address string
+	client  *rpc.Client
+}
+
+// SidecarService serves the "Sidecar.Call" method for the sidecars written in Go.
+type SidecarService struct {
+	Methods map[string]func(req *SidecarRequest, resp *SidecarResponse) error
+}
+
+const sidecarCallMethod = "Sidecar.Call"
+
+var (
+	serviceMethodContextType = reflect.TypeOf((*ServiceMethodContext)(nil))
+	errorType                = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// PluginFunction looks up the function or the variable of function exported by the Go plugin.
+func PluginFunction(path string, symbol string) (interface{}, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sym, err := p.Lookup(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	v := reflect.ValueOf(sym)
+	if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Func {
+		v = v.Elem()
+	}
+
+	if err := checkServiceMethodPrototype(v.Type()); err != nil {
+		return nil, fmt.Errorf("plugin symbol %s: %s", symbol, err)
+	}
+
+	return v.Interface(), nil
+}
+
+func DialSidecar(network string, address string) (*SidecarClient, error) {
+	c := &SidecarClient{network: network, address: address}
+	if _, err := c.rpcClient(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// rpcClient redials after the connection is broken, so that a restarted sidecar is picked up.
+func (c *SidecarClient) rpcClient() (*rpc.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client != nil {
+		return c.client, nil
+	}
+
+	conn, err := net.Dial(c.network, c.address)
+	if err != nil {
+		return nil, err
+	}
+
+	c.client = jsonrpc.NewClient(conn)
+	return c.client, nil
+}
+
+func (c *SidecarClient) reset(client *rpc.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client == client {
+		_ = c.client.Close()
+		c.client = nil
+	}
+}
+
+func (c *SidecarClient) call(ctx *ServiceMethodContext, req *SidecarRequest) (*SidecarResponse, error) {
+	client, err := c.rpcClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &SidecarResponse{}
+	call := client.Go(sidecarCallMethod, req, resp, make(chan *rpc.Call, 1))
+	select {
+	case <-ctx.Context.Done():
+		return nil, ctx.Context.Err()
+	case <-call.Done:
+	}
+
+	if call.Error == rpc.ErrShutdown {
+		c.reset(client)
+	}
+
+	return resp, call.Error
+}
+
+// Function makes a service method calling the sidecar, the prototypes are like (*SomeArgs)(nil).
+func (c *SidecarClient) Function(method string, argPrototype interface{},
+	resultPrototype interface{}) (interface{}, error) {
+	argType, resultType := reflect.TypeOf(argPrototype), reflect.TypeOf(resultPrototype)
+	if argType == nil || resultType == nil {
+		return nil, fmt.Errorf("the prototypes of the argument and result should be typed")
+	}
+
+	funcType := reflect.FuncOf([]reflect.Type{serviceMethodContextType, argType},
+		[]reflect.Type{resultType, errorType}, false)
+	if err := checkServiceMethodPrototype(funcType); err != nil {
+		return nil, err
+	}
+
+	fail := func(err error) []reflect.Value {
+		return []reflect.Value{reflect.Zero(resultType), reflect.ValueOf(&err).Elem()}
+	}
+
+	return reflect.MakeFunc(funcType, func(in []reflect.Value) []reflect.Value {
+		ctx := in[0].Interface().(*ServiceMethodContext)
+		args, err := json.Marshal(in[1].Interface())
+		if err != nil {
+			return fail(err)
+		}
+
+		resp, err := c.call(ctx, &SidecarRequest{Method: method, Metadata: ctx.Metadata, Args: args})
+		if err != nil {
+			ctx.ResponseStatusSetter(502)
+			return fail(fmt.Errorf("sidecar call failed: %s", err))
+		}
+
+		if resp.Error != "" {
+			if resp.Status != 0 {
+				ctx.ResponseStatusSetter(resp.Status)
+			}
+			return fail(fmt.Errorf("%s", resp.Error))
+		}
+
+		result := reflect.New(resultType)
+		if len(resp.Result) > 0 {
+			if err := json.Unmarshal(resp.Result, result.Interface()); err != nil {
+				ctx.ResponseStatusSetter(502)
+				return fail(fmt.Errorf("decode sidecar result failed: %s", err))
+			}
+		}
+
+		return []reflect.Value{result.Elem(), reflect.Zero(errorType)}
+	}).Interface(), nil
+}
+
+func (c *SidecarClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client == nil {
+		return nil
+	}
+
+	err := c.client.Close()
+	c.client = nil
+	return err
+}
+
+func (s *SidecarService) Call(req *SidecarRequest, resp *SidecarResponse) error {
+	method, ok := s.Methods[req.Method]
+	if !ok {
+		resp.Status = 404
+		resp.Error = fmt.Sprintf("unknown method %q", req.Method)
+		return nil
+	}
+
+	return method(req, resp)
+}
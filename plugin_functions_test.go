@@ -0,0 +1,92 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strings"
+	"testing"
+)
+
+func TestSidecarFunction(t *testing.T) {
+	type argument struct {
+		Name string `json:"name" schema:"name"`
+	}
+
+	type result struct {
+		Greeting string `json:"greeting"`
+	}
+
+	service := &SidecarService{Methods: map[string]func(*SidecarRequest, *SidecarResponse) error{
+		"greet": func(req *SidecarRequest, resp *SidecarResponse) error {
+			arg := &argument{}
+			if err := json.Unmarshal(req.Args, arg); err != nil {
+				return err
+			}
+
+			if arg.Name == "" {
+				resp.Status = 400
+				resp.Error = "name required"
+				return nil
+			}
+
+			resp.Result, _ = json.Marshal(&result{"hello " + arg.Name + " of " + req.Metadata.Get("tenant")})
+			return nil
+		},
+	}}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("Sidecar", service); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+		}
+	}()
+
+	sidecar, err := DialSidecar("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sidecar.Close()
+
+	function, err := sidecar.Function("greet", (*argument)(nil), (*result)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router, err := NewHTTPRouter([]*Route{{Method: "GET", Path: "/", Function: function}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(uri string) (int, string) {
+		r := httptest.NewRequest("GET", uri, nil)
+		r.Header.Set("X-Md-Tenant", "acme")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		return recorder.Code, recorder.Body.String()
+	}
+
+	if code, body := do("/?name=bob"); code != 200 || body != "{\"greeting\":\"hello bob of acme\"}\n" {
+		t.Error(code, body)
+	}
+
+	if code, body := do("/"); code != 400 || !strings.Contains(body, "name required") {
+		t.Error(code, body)
+	}
+}
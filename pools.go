@@ -0,0 +1,59 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// ArgumentResetter makes the argument pooled, the method must not keep it after returning.
+type ArgumentResetter interface {
+	Reset()
+}
+
+var argumentResetterType = reflect.TypeOf((*ArgumentResetter)(nil)).Elem()
+
+// maxPooledBufferSize keeps the buffers of the large responses from being pinned by the pool.
+const maxPooledBufferSize = 64 << 10
+
+// jsonBuffer is the pooled encoder state of the responses and the logged values.
+type jsonBuffer struct {
+	bytes.Buffer
+	encoder *json.Encoder
+}
+
+var jsonBufferPool = sync.Pool{New: func() interface{} {
+	b := &jsonBuffer{}
+	b.encoder = json.NewEncoder(&b.Buffer)
+	return b
+}}
+
+func getJSONBuffer() *jsonBuffer {
+	b := jsonBufferPool.Get().(*jsonBuffer)
+	b.Reset()
+	return b
+}
+
+func putJSONBuffer(b *jsonBuffer) {
+	if b.Cap() <= maxPooledBufferSize {
+		jsonBufferPool.Put(b)
+	}
+}
+
+// newArgumentPool returns nil if the argument type doesn't implement ArgumentResetter.
+func newArgumentPool(argType reflect.Type) *sync.Pool {
+	if argType.Kind() != reflect.Ptr || !argType.Implements(argumentResetterType) {
+		return nil
+	}
+
+	return &sync.Pool{New: func() interface{} {
+		return reflect.New(argType.Elem()).Interface()
+	}}
+}
+
+func (m *serviceMethod) releaseArgument(ptr reflect.Value) {
+	if m.pool != nil {
+		m.pool.Put(ptr.Interface())
+	}
+}
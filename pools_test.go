@@ -0,0 +1,52 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type pooledArguments struct {
+	Name  string   `json:"name"`
+	Tags  []string `json:"tags"`
+	reset int
+}
+
+func (a *pooledArguments) Reset() {
+	*a = pooledArguments{Tags: a.Tags[:0], reset: a.reset + 1}
+}
+
+func TestArgumentPool(t *testing.T) {
+	var names []string
+	handler, err := NewServiceHandler(func(ctx *ServiceMethodContext, arg *pooledArguments) error {
+		if arg.reset == 0 || (arg.Name == "" && len(arg.Tags) > 0) {
+			t.Errorf("the argument is not reset: %+v", arg)
+		}
+		names = append(names, arg.Name+strings.Join(arg.Tags, ","))
+		return nil
+	}, dummyLogger, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, body := range []string{`{"name":"a","tags":["x","y"]}`, `{}`, `{"name":"c"}`} {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != 200 {
+			t.Fatal(w.Code, w.Body.String())
+		}
+	}
+
+	// the pool may drop the arguments, so only the resets are checked.
+	if strings.Join(names, "|") != "ax,y||c" {
+		t.Error(names)
+	}
+
+	v := map[string]interface{}{"a": "<b>", "c": []int{1}}
+	if marshaled, _ := json.Marshal(v); marshalLogged(v) != string(marshaled) {
+		t.Error(marshalLogged(v))
+	}
+}
@@ -0,0 +1,156 @@
+package apihttpwrapper
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/trace"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PreflightContentLengthHeader declares the body size of the preflights of Route.Preflight.
+const PreflightContentLengthHeader = "X-Preflight-Content-Length"
+
+// preflightContentLength returns -1 if the header is absent.
+func preflightContentLength(r *http.Request) (int64, error) {
+	value := r.Header.Get(PreflightContentLengthHeader)
+	if value == "" {
+		return -1, nil
+	}
+
+	length, err := strconv.ParseInt(value, 10, 64)
+	if err == nil && length < 0 {
+		err = fmt.Errorf("negative length %d", length)
+	}
+
+	return length, err
+}
+
+// servePreflight checks the declared body size, after the middlewares of the route.
+func (h *ServiceHandler) servePreflight(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	tracer := trace.New(traceFamily, r.URL.Path)
+	defer tracer.Finish()
+
+	format := h.negotiateResponseFormat(w, r)
+	length, err := preflightContentLength(r)
+	if err != nil {
+		writeEnvelopedError(w, tracer, format, &FormattedResponse{400, "parse preflight content length failed",
+			err.Error()})
+		return
+	}
+
+	if h.maxBodyBytes > 0 && length > h.maxBodyBytes {
+		writeEnvelopedError(w, tracer, format, bodyTooLargeResponse(h.maxBodyBytes))
+		return
+	}
+
+	if h.bodyBufferLimit > 0 && !h.bypassRequestBody && length > h.bodyBufferLimit {
+		writeEnvelopedError(w, tracer, format, &FormattedResponse{413, "buffer request body failed",
+			fmt.Sprintf("the request body exceeds %d bytes", h.bodyBufferLimit)})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newPreflightHandle(rt *Route, loggerContextKey interface{}, options *RouterOptions) (httprouter.Handle, error) {
+	if rt.EventStream != nil {
+		return nil, fmt.Errorf("the event stream routes can't be preflighted")
+	}
+
+	handler, err := newRouteServiceHandler(rt, rt.Function, loggerContextKey, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMiddlewareHandle(handler.servePreflight, routeMiddlewares(rt, options, handler)), nil
+}
+
+// preflightDispatcher selects the method by Access-Control-Request-Method.
+type preflightDispatcher struct {
+	handles map[string]httprouter.Handle
+	allow   string
+}
+
+func newPreflightDispatcher(handles map[string]httprouter.Handle) httprouter.Handle {
+	methods := []string{http.MethodOptions}
+	for method := range handles {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	d := &preflightDispatcher{handles: handles, allow: strings.Join(methods, ", ")}
+	return d.serve
+}
+
+func (d *preflightDispatcher) serve(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	w.Header().Set("Allow", d.allow)
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if method == "" && len(d.handles) == 1 {
+		for m := range d.handles {
+			method = m
+		}
+	}
+
+	if method == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	handle, ok := d.handles[method]
+	if !ok {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	handle(w, r, params)
+}
+
+// registerPreflights registers one OPTIONS handle for each path of the routes having Route.Preflight or CORSPolicy.
+func registerPreflights(r *httprouter.Router, loggerContextKeys []interface{}, routes []*Route,
+	options *RouterOptions) error {
+	// the paths having their own OPTIONS routes are left to them, unless Route.Preflight conflicts.
+	optionsRoutes := make(map[string]bool)
+	for _, rt := range routes {
+		if strings.ToUpper(rt.Method) == http.MethodOptions {
+			optionsRoutes[rt.Path] = true
+		}
+	}
+
+	var paths []string
+	preflights := make(map[string]map[string]httprouter.Handle)
+	for i, rt := range routes {
+		policy := routeCORSPolicy(rt, options)
+		if !rt.Preflight && (policy == nil || optionsRoutes[rt.Path]) {
+			continue
+		}
+
+		handle := httprouter.Handle(serveNoContent)
+		if rt.Preflight {
+			var err error
+			if handle, err = newPreflightHandle(rt, loggerContextKeys[i], options); err != nil {
+				return &RouteError{Index: i, Route: rt, Err: err}
+			}
+		}
+
+		if policy != nil {
+			handle = newCORSPreflightHandle(handle, policy, strings.ToUpper(rt.Method))
+		}
+
+		if preflights[rt.Path] == nil {
+			preflights[rt.Path] = make(map[string]httprouter.Handle)
+			paths = append(paths, rt.Path)
+		}
+		preflights[rt.Path][strings.ToUpper(rt.Method)] = handle
+	}
+
+	for _, path := range paths {
+		if err := handleRoute(r, http.MethodOptions, path, newPreflightDispatcher(preflights[path])); err != nil {
+			return fmt.Errorf("preflight %s: %s", path, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package apihttpwrapper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPreflight(t *testing.T) {
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(401)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	upload := func(ctx *ServiceMethodContext, arg *struct{ A int }) error {
+		return nil
+	}
+	router, err := NewHTTPRouter([]*Route{
+		{Method: "POST", Path: "/upload", Function: upload, Preflight: true, MaxBodyBytes: 100,
+			Middlewares: []func(http.Handler) http.Handler{auth}},
+		{Method: "PUT", Path: "/upload", Function: upload, Preflight: true, MaxBodyBytes: 1000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quota := NewQuotaDecorator(router, NewMemoryQuotaStore(), QuotaPolicy{
+		Period: time.Hour,
+		Limit:  func(string) QuotaLimit { return QuotaLimit{Bytes: 500} },
+	})
+
+	cases := []struct {
+		method string
+		auth   string
+		length string
+		status int
+	}{
+		{"POST", "token", "50", 204},
+		{"POST", "", "50", 401},
+		{"POST", "token", "200", 413},
+		{"POST", "token", "x", 400},
+		{"PUT", "", "200", 204},
+		{"PUT", "", "600", 429},
+		{"DELETE", "", "", 405},
+		{"", "", "", 204},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("OPTIONS", "/upload", nil)
+		r.Header.Set(DefaultAPIKeyHeader, "client")
+		if c.method != "" {
+			r.Header.Set("Access-Control-Request-Method", c.method)
+		}
+		if c.auth != "" {
+			r.Header.Set("Authorization", c.auth)
+		}
+		if c.length != "" {
+			r.Header.Set(PreflightContentLengthHeader, c.length)
+		}
+
+		recorder := httptest.NewRecorder()
+		quota.ServeHTTP(recorder, r)
+		if recorder.Code != c.status || recorder.Header().Get("Allow") == "" && c.status != 429 {
+			t.Error(c, recorder.Code, recorder.Header())
+		}
+	}
+
+	_, err = NewHTTPRouter([]*Route{
+		{Method: "POST", Path: "/upload", Function: upload, Preflight: true},
+		{Method: "OPTIONS", Path: "/upload", Function: upload},
+	})
+	if errs, ok := err.(RouteErrors); !ok || len(errs) != 1 || errs[0].Index != 1 {
+		t.Error(err)
+	}
+}
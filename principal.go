@@ -0,0 +1,42 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"golang.org/x/net/trace"
+	"net/http"
+)
+
+// Principal is the authenticated caller attached by the middlewares, see ServiceMethodContext.Principal.
+type Principal struct {
+	Subject string
+	Claims  map[string]interface{}
+}
+
+type principalContextKey struct{}
+
+func NewPrincipalContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns nil if the request is not authenticated.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalContextKey{}).(*Principal)
+	return principal
+}
+
+// requirePrincipal makes the middleware rejecting the requests not authenticated, in the format of the handler.
+func requirePrincipal(handler *ServiceHandler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if PrincipalFromContext(r.Context()) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			tracer := trace.New(traceFamily, r.URL.Path)
+			writeEnvelopedError(w, tracer, handler.negotiateResponseFormat(w, r), &FormattedResponse{
+				http.StatusUnauthorized, "unauthorized", "the request is not authenticated"})
+			tracer.Finish()
+		})
+	}
+}
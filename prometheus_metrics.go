@@ -0,0 +1,117 @@
+package apihttpwrapper
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+)
+
+// InFlightCollector is optionally implemented by the MetricsCollector for counting the requests being served.
+type InFlightCollector interface {
+	Started(method string, route string)
+	Finished(method string, route string)
+}
+
+// PrometheusCollector records the request metrics per route, method and status.
+type PrometheusCollector struct {
+	registry      *prometheus.Registry
+	requests      *prometheus.CounterVec
+	inFlight      *prometheus.GaugeVec
+	duration      *prometheus.HistogramVec
+	responseBytes *prometheus.HistogramVec
+	limits        *prometheus.GaugeVec
+	rejections    *prometheus.CounterVec
+}
+
+// NewPrometheusCollector registers the metrics into the registry, a new one is created if nil. see Handler.
+func NewPrometheusCollector(registry *prometheus.Registry, namespace string) (*PrometheusCollector, error) {
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+	}
+
+	labels := []string{"method", "route", "status"}
+	responseBuckets := make([]float64, 0, len(DefaultSizeBuckets))
+	for _, bucket := range DefaultSizeBuckets {
+		responseBuckets = append(responseBuckets, float64(bucket))
+	}
+
+	c := &PrometheusCollector{
+		registry: registry,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "The count of the requests.",
+		}, labels),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "The requests being served.",
+		}, []string{"method", "route"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "The latencies of the requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		responseBytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_response_size_bytes",
+			Help:      "The sizes of the response bodies.",
+			Buckets:   responseBuckets,
+		}, labels),
+		limits: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_concurrency_limit",
+			Help:      "The current concurrency limits of the routes having Route.ConcurrencyLimit.",
+		}, []string{"method", "route"}),
+		rejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_concurrency_limit_rejections_total",
+			Help:      "The count of the requests rejected by the concurrency limits.",
+		}, []string{"method", "route"}),
+	}
+
+	for _, collector := range []prometheus.Collector{c.requests, c.inFlight, c.duration, c.responseBytes, c.limits,
+		c.rejections} {
+		if err := registry.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *PrometheusCollector) Observe(m *RequestMetrics) {
+	status := strconv.Itoa(m.Status)
+	c.requests.WithLabelValues(m.Method, m.Route, status).Inc()
+	duration := c.duration.WithLabelValues(m.Method, m.Route, status)
+	if exemplars, ok := duration.(prometheus.ExemplarObserver); ok && m.TraceID != "" {
+		exemplars.ObserveWithExemplar(m.Duration.Seconds(), prometheus.Labels{"trace_id": m.TraceID,
+			"span_id": m.SpanID})
+	} else {
+		duration.Observe(m.Duration.Seconds())
+	}
+	c.responseBytes.WithLabelValues(m.Method, m.Route, status).Observe(float64(m.ResponseBytes))
+}
+
+func (c *PrometheusCollector) Started(method string, route string) {
+	c.inFlight.WithLabelValues(method, route).Inc()
+}
+
+func (c *PrometheusCollector) Finished(method string, route string) {
+	c.inFlight.WithLabelValues(method, route).Dec()
+}
+
+func (c *PrometheusCollector) ConcurrencyLimitChanged(method string, route string, limit int) {
+	c.limits.WithLabelValues(method, route).Set(float64(limit))
+}
+
+func (c *PrometheusCollector) ConcurrencyLimitRejected(method string, route string) {
+	c.rejections.WithLabelValues(method, route).Inc()
+}
+
+// Handler exposes the metrics, as OpenMetrics with the exemplars if the scraper accepts it.
+func (c *PrometheusCollector) Handler() http.Handler {
+	return promhttp.HandlerFor(c.registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
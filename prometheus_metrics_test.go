@@ -0,0 +1,83 @@
+package apihttpwrapper
+
+import (
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusCollector(t *testing.T) {
+	collector, err := NewPrometheusCollector(nil, "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var inFlight string
+	router, err := NewHTTPRouterWithOptions([]*Route{{
+		Method: "GET",
+		Path:   "/user/:Name",
+		Function: func(*ServiceMethodContext, *struct{ Name string }) (*struct{ A int }, error) {
+			recorder := httptest.NewRecorder()
+			collector.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+			inFlight = recorder.Body.String()
+			return &struct{ A int }{1}, nil
+		},
+	}}, &RouterOptions{Metrics: collector})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/user/bob", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/user/alice", nil))
+
+	recorder := httptest.NewRecorder()
+	collector.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	metrics := recorder.Body.String()
+	for _, expect := range []string{
+		"api_http_requests_total{method=\"GET\",route=\"/user/:Name\",status=\"200\"} 2",
+		"api_http_request_duration_seconds_count{method=\"GET\",route=\"/user/:Name\",status=\"200\"} 2",
+		"api_http_response_size_bytes_sum{method=\"GET\",route=\"/user/:Name\",status=\"200\"} 16",
+		"api_http_requests_in_flight{method=\"GET\",route=\"/user/:Name\"} 0",
+	} {
+		if !strings.Contains(metrics, expect) {
+			t.Error(expect, metrics)
+		}
+	}
+
+	if !strings.Contains(inFlight, "api_http_requests_in_flight{method=\"GET\",route=\"/user/:Name\"} 1") {
+		t.Error(inFlight)
+	}
+}
+
+func TestPrometheusExemplars(t *testing.T) {
+	collector, err := NewPrometheusCollector(nil, "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := tracetest.NewSpanRecorder()
+	router, err := NewHTTPRouterWithOptions([]*Route{{Method: "GET", Path: "/users",
+		Function: func(*ServiceMethodContext, *struct{}) error {
+			return nil
+		}}}, &RouterOptions{Metrics: collector,
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+	if len(spans.Ended()) != 1 {
+		t.Fatal(spans.Ended())
+	}
+
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	r.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+	recorder := httptest.NewRecorder()
+	collector.Handler().ServeHTTP(recorder, r)
+	exemplar := "trace_id=\"" + spans.Ended()[0].SpanContext().TraceID().String() + "\""
+	if !strings.Contains(recorder.Body.String(), exemplar) {
+		t.Error(exemplar, recorder.Body.String())
+	}
+}
@@ -0,0 +1,59 @@
+// Package queue provides the apihttpwrapper.MessageQueue implementations.
+package queue
+
+import (
+	"context"
+	"fmt"
+	"github.com/abadcafe/apihttpwrapper"
+	"github.com/segmentio/kafka-go"
+)
+
+// ErrKafkaRequeue stops the consumer, the message is redelivered after restarting.
+var ErrKafkaRequeue = fmt.Errorf("kafka can't requeue the message")
+
+// KafkaReader is implemented by *kafka.Reader, the reader should be of a consumer group to commit the offsets.
+type KafkaReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+type Kafka struct {
+	reader KafkaReader
+}
+
+func NewKafka(reader KafkaReader) *Kafka {
+	return &Kafka{reader: reader}
+}
+
+// Receive takes the headers as the attributes, the id is like "topic/partition/offset".
+func (k *Kafka) Receive(ctx context.Context) (*apihttpwrapper.QueueMessage, error) {
+	m, err := k.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := make(map[string]string, len(m.Headers))
+	for _, header := range m.Headers {
+		attributes[header.Key] = string(header.Value)
+	}
+
+	return &apihttpwrapper.QueueMessage{
+		ID:         fmt.Sprintf("%s/%d/%d", m.Topic, m.Partition, m.Offset),
+		Body:       m.Value,
+		Attributes: attributes,
+		Raw:        m,
+	}, nil
+}
+
+func (k *Kafka) Ack(ctx context.Context, msg *apihttpwrapper.QueueMessage) error {
+	return k.reader.CommitMessages(ctx, msg.Raw.(kafka.Message))
+}
+
+// Nack commits the dropped messages the same as Ack.
+func (k *Kafka) Nack(ctx context.Context, msg *apihttpwrapper.QueueMessage, requeue bool) error {
+	if requeue {
+		return ErrKafkaRequeue
+	}
+
+	return k.Ack(ctx, msg)
+}
@@ -0,0 +1,118 @@
+package queue
+
+import (
+	"context"
+	"github.com/abadcafe/apihttpwrapper"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/segmentio/kafka-go"
+	"reflect"
+	"testing"
+)
+
+type testKafkaReader struct {
+	committed []int64
+}
+
+func (r *testKafkaReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	return kafka.Message{Topic: "orders", Partition: 1, Offset: 7, Value: []byte(`{}`),
+		Headers: []kafka.Header{{Key: "Tenant", Value: []byte("acme")}}}, nil
+}
+
+func (r *testKafkaReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	for _, m := range msgs {
+		r.committed = append(r.committed, m.Offset)
+	}
+	return nil
+}
+
+func TestKafka(t *testing.T) {
+	reader := &testKafkaReader{}
+	var q apihttpwrapper.MessageQueue = NewKafka(reader)
+	msg, err := q.Receive(context.Background())
+	if err != nil || msg.ID != "orders/1/7" || msg.Attributes["Tenant"] != "acme" {
+		t.Fatal(msg, err)
+	}
+
+	if err := q.Ack(context.Background(), msg); err != nil {
+		t.Error(err)
+	}
+
+	if err := q.Nack(context.Background(), msg, false); err != nil {
+		t.Error(err)
+	}
+
+	if err := q.Nack(context.Background(), msg, true); err != ErrKafkaRequeue {
+		t.Error(err)
+	}
+
+	if !reflect.DeepEqual(reader.committed, []int64{7, 7}) {
+		t.Error(reader.committed)
+	}
+}
+
+type testSQSClient struct {
+	sqsiface.SQSAPI
+	receives int
+	calls    []string
+}
+
+func (c *testSQSClient) ReceiveMessageWithContext(ctx aws.Context, input *sqs.ReceiveMessageInput,
+	opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	c.receives++
+	if c.receives == 1 {
+		return &sqs.ReceiveMessageOutput{}, nil
+	}
+
+	return &sqs.ReceiveMessageOutput{Messages: []*sqs.Message{
+		{MessageId: aws.String("a"), ReceiptHandle: aws.String("ra"), Body: aws.String(`{}`),
+			MessageAttributes: map[string]*sqs.MessageAttributeValue{
+				"Tenant": {DataType: aws.String("String"), StringValue: aws.String("acme")},
+			}},
+		{MessageId: aws.String("b"), ReceiptHandle: aws.String("rb"), Body: aws.String(`{}`)},
+	}}, nil
+}
+
+func (c *testSQSClient) DeleteMessageWithContext(ctx aws.Context, input *sqs.DeleteMessageInput,
+	opts ...request.Option) (*sqs.DeleteMessageOutput, error) {
+	c.calls = append(c.calls, "delete:"+*input.ReceiptHandle)
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (c *testSQSClient) ChangeMessageVisibilityWithContext(ctx aws.Context, input *sqs.ChangeMessageVisibilityInput,
+	opts ...request.Option) (*sqs.ChangeMessageVisibilityOutput, error) {
+	c.calls = append(c.calls, "visible:"+*input.ReceiptHandle)
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func TestSQS(t *testing.T) {
+	client := &testSQSClient{}
+	q := NewSQS(client, "https://sqs.example.com/orders")
+	a, err := q.Receive(context.Background())
+	if err != nil || a.ID != "a" || a.Attributes["Tenant"] != "acme" || string(a.Body) != "{}" {
+		t.Fatal(a, err)
+	}
+
+	b, err := q.Receive(context.Background())
+	if err != nil || b.ID != "b" || client.receives != 2 {
+		t.Fatal(b, err, client.receives)
+	}
+
+	if err := q.Ack(context.Background(), a); err != nil {
+		t.Error(err)
+	}
+
+	if err := q.Nack(context.Background(), b, true); err != nil {
+		t.Error(err)
+	}
+
+	if err := q.Nack(context.Background(), b, false); err != nil {
+		t.Error(err)
+	}
+
+	if !reflect.DeepEqual(client.calls, []string{"delete:ra", "visible:rb", "delete:rb"}) {
+		t.Error(client.calls)
+	}
+}
@@ -0,0 +1,81 @@
+package queue
+
+import (
+	"context"
+	"github.com/abadcafe/apihttpwrapper"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"sync"
+)
+
+// SQS receives the messages by the long polling, in batches of up to 10.
+type SQS struct {
+	client   sqsiface.SQSAPI
+	queueURL string
+
+	mutex   sync.Mutex
+	pending []*sqs.Message
+}
+
+func NewSQS(client sqsiface.SQSAPI, queueURL string) *SQS {
+	return &SQS{client: client, queueURL: queueURL}
+}
+
+// Receive takes the string message attributes as the attributes.
+func (q *SQS) Receive(ctx context.Context) (*apihttpwrapper.QueueMessage, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for len(q.pending) == 0 {
+		out, err := q.client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(q.queueURL),
+			MaxNumberOfMessages:   aws.Int64(10),
+			WaitTimeSeconds:       aws.Int64(20),
+			MessageAttributeNames: aws.StringSlice([]string{"All"}),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		q.pending = out.Messages
+	}
+
+	m := q.pending[0]
+	q.pending = q.pending[1:]
+
+	attributes := make(map[string]string, len(m.MessageAttributes))
+	for k, v := range m.MessageAttributes {
+		if v.StringValue != nil {
+			attributes[k] = *v.StringValue
+		}
+	}
+
+	return &apihttpwrapper.QueueMessage{
+		ID:         aws.StringValue(m.MessageId),
+		Body:       []byte(aws.StringValue(m.Body)),
+		Attributes: attributes,
+		Raw:        m,
+	}, nil
+}
+
+func (q *SQS) Ack(ctx context.Context, msg *apihttpwrapper.QueueMessage) error {
+	_, err := q.client.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.queueURL),
+		ReceiptHandle: msg.Raw.(*sqs.Message).ReceiptHandle,
+	})
+	return err
+}
+
+// Nack makes the requeued messages visible at once, and deletes the dropped ones.
+func (q *SQS) Nack(ctx context.Context, msg *apihttpwrapper.QueueMessage, requeue bool) error {
+	if !requeue {
+		return q.Ack(ctx, msg)
+	}
+
+	_, err := q.client.ChangeMessageVisibilityWithContext(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(q.queueURL),
+		ReceiptHandle:     msg.Raw.(*sqs.Message).ReceiptHandle,
+		VisibilityTimeout: aws.Int64(0),
+	})
+	return err
+}
@@ -0,0 +1,151 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// QueueMessageIDHeader is the request header of QueueMessage.ID, it is in the access log rows of the messages.
+const QueueMessageIDHeader = "X-Queue-Message-Id"
+
+type QueueMessage struct {
+	ID   string
+	Body []byte
+	// Attributes are passed as the request headers, like the Kafka headers.
+	Attributes map[string]string
+	// Raw is the message of the queue client, for the MessageQueue implementations.
+	Raw interface{}
+}
+
+// MessageQueue is implemented by the queue clients, see the queue package for Kafka and SQS.
+type MessageQueue interface {
+	// Receive blocks until a message arrives or ctx is done.
+	Receive(ctx context.Context) (*QueueMessage, error)
+	Ack(ctx context.Context, msg *QueueMessage) error
+	// Nack gives the message back to be redelivered if requeue, or drops it otherwise, like the malformed ones.
+	Nack(ctx context.Context, msg *QueueMessage, requeue bool) error
+}
+
+// QueueConsumer serves the messages by a service method, acking on 2xx, dropping on 4xx and requeueing on 5xx.
+type QueueConsumer struct {
+	queue       MessageQueue
+	handler     http.Handler
+	path        string
+	concurrency int
+}
+
+// NewQueueConsumer logs the messages with the uri "/queues/<name>".
+func NewQueueConsumer(name string, queue MessageQueue, function interface{}, logWriter io.Writer,
+	options *RouterOptions) (*QueueConsumer, error) {
+	if options == nil {
+		options = &RouterOptions{}
+	}
+
+	if err := checkServiceMethodPrototype(reflect.TypeOf(function)); err != nil {
+		return nil, err
+	}
+
+	rt := &Route{Method: http.MethodPost, Path: "/queues/" + name, Function: function}
+	handler, err := newRouteServiceHandler(rt, function, ServiceHandlerAccessLogRowFillerContextKey, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueueConsumer{
+		queue:       queue,
+		handler:     newAccessLogDecorator(handler, []string{QueueMessageIDHeader}, logWriter, options),
+		path:        rt.Path,
+		concurrency: 1,
+	}, nil
+}
+
+// SetConcurrency sets the number of the messages served at the same time, 1 by default.
+func (c *QueueConsumer) SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+
+	c.concurrency = n
+}
+
+// Run serves the messages until ctx is done, which returns nil, or the queue fails.
+func (c *QueueConsumer) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var once sync.Once
+	var result error
+	var wg sync.WaitGroup
+	for i := 0; i < c.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.consume(ctx); err != nil {
+				once.Do(func() {
+					result = err
+					cancel()
+				})
+			}
+		}()
+	}
+
+	wg.Wait()
+	return result
+}
+
+func (c *QueueConsumer) consume(ctx context.Context) error {
+	for {
+		msg, err := c.queue.Receive(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("receive message failed: %s", err)
+		}
+
+		if err := c.serve(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// serve doesn't use the context of Run, so the received messages are served and settled even if it is done.
+func (c *QueueConsumer) serve(msg *QueueMessage) error {
+	r, err := http.NewRequest(http.MethodPost, c.path, bytes.NewReader(msg.Body))
+	if err != nil {
+		return err
+	}
+
+	r.RemoteAddr = "queue"
+	r.Header.Set("Content-Type", "application/json")
+	for k, v := range msg.Attributes {
+		r.Header.Set(k, v)
+	}
+	r.Header.Set(QueueMessageIDHeader, msg.ID)
+
+	ctx := context.Background()
+	w := &localResponseWriter{header: http.Header{}, status: http.StatusOK, out: ioutil.Discard}
+	c.handler.ServeHTTP(w, r)
+
+	switch {
+	case w.status < http.StatusBadRequest:
+		err = c.queue.Ack(ctx, msg)
+	case w.status < http.StatusInternalServerError && w.status != http.StatusTooManyRequests:
+		err = c.queue.Nack(ctx, msg, false)
+	default:
+		err = c.queue.Nack(ctx, msg, true)
+	}
+
+	if err != nil {
+		return fmt.Errorf("settle message %s failed: %s", msg.ID, err)
+	}
+
+	return nil
+}
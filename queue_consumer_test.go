@@ -0,0 +1,102 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testQueue struct {
+	messages chan *QueueMessage
+	mutex    sync.Mutex
+	settled  []string
+}
+
+func (q *testQueue) Receive(ctx context.Context) (*QueueMessage, error) {
+	select {
+	case msg := <-q.messages:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (q *testQueue) settle(result string) {
+	q.mutex.Lock()
+	q.settled = append(q.settled, result)
+	q.mutex.Unlock()
+}
+
+func (q *testQueue) Ack(ctx context.Context, msg *QueueMessage) error {
+	q.settle(msg.ID + ":ack")
+	return nil
+}
+
+func (q *testQueue) Nack(ctx context.Context, msg *QueueMessage, requeue bool) error {
+	if msg.ID == "fatal" {
+		return fmt.Errorf("queue closed")
+	}
+
+	q.settle(fmt.Sprintf("%s:nack:%v", msg.ID, requeue))
+	return nil
+}
+
+func TestQueueConsumer(t *testing.T) {
+	type orderArg struct {
+		OrderID int    `json:"orderId" validate:"min=1"`
+		Tenant  string `json:"-"`
+	}
+
+	logs := &bytes.Buffer{}
+	queue := &testQueue{messages: make(chan *QueueMessage, 10)}
+	consumer, err := NewQueueConsumer("orders", queue, func(ctx *ServiceMethodContext, arg *orderArg) error {
+		switch arg.OrderID {
+		case 2:
+			return fmt.Errorf("database unavailable")
+		case 3:
+			panic("boom")
+		}
+
+		if ctx.RequestHeader.Get("Tenant") != "acme" {
+			return fmt.Errorf("no tenant")
+		}
+		return nil
+	}, logs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queue.messages <- &QueueMessage{ID: "1", Body: []byte(`{"orderId":1}`), Attributes: map[string]string{
+		"Tenant": "acme"}}
+	queue.messages <- &QueueMessage{ID: "2", Body: []byte(`{"orderId":2}`)}
+	queue.messages <- &QueueMessage{ID: "3", Body: []byte(`{"orderId":3}`)}
+	queue.messages <- &QueueMessage{ID: "4", Body: []byte(`{"orderId":0}`)}
+	queue.messages <- &QueueMessage{ID: "5", Body: []byte(`not json`)}
+	queue.messages <- &QueueMessage{ID: "fatal", Body: []byte(`{"orderId":2}`)}
+
+	if err := consumer.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "queue closed") {
+		t.Error(err)
+	}
+
+	expected := []string{"1:ack", "2:nack:true", "3:nack:true", "4:nack:false", "5:nack:false"}
+	if !reflect.DeepEqual(queue.settled, expected) {
+		t.Error(queue.settled)
+	}
+
+	rows := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(rows) != 6 || !strings.Contains(rows[0], "uri=/queues/orders") || !strings.Contains(rows[0], "remote=queue") ||
+		!strings.Contains(rows[2], "incidentId=") || !strings.Contains(rows[1], `X-Queue-Message-Id\":[\"2\"]`) {
+		t.Error(rows)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	consumer.SetConcurrency(4)
+	if err := consumer.Run(ctx); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,213 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type QuotaUsage struct {
+	Requests int64 `json:"requests"`
+	Bytes    int64 `json:"bytes"`
+}
+
+// QuotaLimit is the usage allowed in one period, zero means unlimited.
+type QuotaLimit struct {
+	Requests int64 `json:"requests"`
+	Bytes    int64 `json:"bytes"`
+}
+
+// QuotaStore accumulates the usages, it should be shared by all replicas of the service to get global quotas.
+type QuotaStore interface {
+	// Add adds the delta to the usage of the key in the period beginning at periodStart, and returns the totals.
+	Add(key string, periodStart time.Time, delta QuotaUsage) (QuotaUsage, error)
+}
+
+type QuotaPolicy struct {
+	Period time.Duration
+	// Limit returns the limit of the key, so that plans can differ between clients.
+	Limit func(key string) QuotaLimit
+	// KeyFunc extracts the client key, the requests with empty key are not accounted. API key header by default.
+	KeyFunc func(r *http.Request) string
+	// OnExhausted is called once the usage exceeds the limit in a period.
+	OnExhausted func(key string, usage QuotaUsage, limit QuotaLimit)
+}
+
+type QuotaDecorator struct {
+	http.Handler
+	store  QuotaStore
+	policy QuotaPolicy
+}
+
+// MemoryQuotaStore is a QuotaStore for single replica deployments, only the current period of each key is kept.
+type MemoryQuotaStore struct {
+	mu     sync.Mutex
+	usages map[string]*memoryQuotaUsage
+}
+
+type memoryQuotaUsage struct {
+	periodStart time.Time
+	usage       QuotaUsage
+}
+
+const DefaultAPIKeyHeader = "X-Api-Key"
+
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{usages: make(map[string]*memoryQuotaUsage)}
+}
+
+func (s *MemoryQuotaStore) Add(key string, periodStart time.Time, delta QuotaUsage) (QuotaUsage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.usages[key]
+	if !ok || u.periodStart.Before(periodStart) {
+		u = &memoryQuotaUsage{periodStart: periodStart}
+		s.usages[key] = u
+	} else if periodStart.Before(u.periodStart) {
+		// a request of the previous period finished late, it is not counted in the new period.
+		return QuotaUsage{}, nil
+	}
+
+	u.usage.Requests += delta.Requests
+	u.usage.Bytes += delta.Bytes
+	return u.usage, nil
+}
+
+func defaultQuotaKey(r *http.Request) string {
+	return r.Header.Get(DefaultAPIKeyHeader)
+}
+
+func NewQuotaDecorator(handler http.Handler, store QuotaStore, policy QuotaPolicy) *QuotaDecorator {
+	if policy.Period <= 0 {
+		policy.Period = time.Hour
+	}
+
+	if policy.KeyFunc == nil {
+		policy.KeyFunc = defaultQuotaKey
+	}
+
+	if policy.Limit == nil {
+		policy.Limit = func(string) QuotaLimit { return QuotaLimit{} }
+	}
+
+	return &QuotaDecorator{
+		Handler: handler,
+		store:   store,
+		policy:  policy,
+	}
+}
+
+func quotaExceeded(value int64, limit int64) bool {
+	return limit > 0 && value > limit
+}
+
+func quotaRemaining(value int64, limit int64) int64 {
+	if value > limit {
+		return 0
+	}
+
+	return limit - value
+}
+
+func (d *QuotaDecorator) exhausted(key string, before QuotaUsage, after QuotaUsage, limit QuotaLimit) {
+	if d.policy.OnExhausted == nil {
+		return
+	}
+
+	crossed := (quotaExceeded(after.Requests, limit.Requests) && !quotaExceeded(before.Requests, limit.Requests)) ||
+		(quotaExceeded(after.Bytes, limit.Bytes) && !quotaExceeded(before.Bytes, limit.Bytes))
+	if crossed {
+		d.policy.OnExhausted(key, after, limit)
+	}
+}
+
+func setQuotaHeaders(h http.Header, usage QuotaUsage, limit QuotaLimit, reset time.Duration) {
+	resetSeconds := strconv.FormatInt(ceilSeconds(reset), 10)
+	if limit.Requests > 0 {
+		h.Set("X-RateLimit-Limit", strconv.FormatInt(limit.Requests, 10))
+		h.Set("X-RateLimit-Remaining", strconv.FormatInt(quotaRemaining(usage.Requests, limit.Requests), 10))
+		h.Set("X-RateLimit-Reset", resetSeconds)
+	}
+
+	if limit.Bytes > 0 {
+		h.Set("X-Quota-Bytes-Limit", strconv.FormatInt(limit.Bytes, 10))
+		h.Set("X-Quota-Bytes-Remaining", strconv.FormatInt(quotaRemaining(usage.Bytes, limit.Bytes), 10))
+		h.Set("X-Quota-Reset", resetSeconds)
+	}
+}
+
+func (d *QuotaDecorator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := d.policy.KeyFunc(r)
+	if key == "" {
+		d.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	now := time.Now()
+	periodStart := now.Truncate(d.policy.Period)
+	reset := periodStart.Add(d.policy.Period).Sub(now)
+	limit := d.policy.Limit(key)
+
+	// the preflight requests are not counted, they check the quota is enough for the request and its body.
+	preflightLength, preflightErr := preflightContentLength(r)
+	preflight := r.Method == http.MethodOptions && preflightLength >= 0 && preflightErr == nil
+	delta := QuotaUsage{Requests: 1}
+	if preflight {
+		delta = QuotaUsage{}
+	}
+
+	// the request is counted before it is served, so that concurrent requests can't overdraw the quota.
+	usage, err := d.store.Add(key, periodStart, delta)
+	if err != nil {
+		setResponseHeader(w)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(&FormattedResponse{503, "quota store unavailable", err.Error()})
+		return
+	}
+
+	exceeded := quotaExceeded(usage.Requests, limit.Requests) || (limit.Bytes > 0 && usage.Bytes >= limit.Bytes)
+	if preflight {
+		exceeded = quotaExceeded(usage.Requests+1, limit.Requests) ||
+			quotaExceeded(usage.Bytes+preflightLength, limit.Bytes)
+	} else {
+		d.exhausted(key, QuotaUsage{Requests: usage.Requests - 1, Bytes: usage.Bytes}, usage, limit)
+	}
+
+	setQuotaHeaders(w.Header(), usage, limit, reset)
+	if exceeded {
+		setRetryHeaders(w.Header(), reset)
+		setResponseHeader(w)
+		w.WriteHeader(http.StatusTooManyRequests)
+		_ = json.NewEncoder(w).Encode(&FormattedResponse{429, "quota exhausted", &struct {
+			Usage QuotaUsage `json:"usage"`
+			Limit QuotaLimit `json:"limit"`
+		}{usage, limit}})
+		return
+	}
+
+	body := &countingReadCloser{ReadCloser: r.Body}
+	if r.Body != nil {
+		r.Body = body
+	}
+
+	sw := &statusResponseWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+	}
+
+	d.Handler.ServeHTTP(sw, r)
+
+	transferred := atomic.LoadInt64(&body.count) + sw.written
+	if transferred == 0 {
+		return
+	}
+
+	after, err := d.store.Add(key, periodStart, QuotaUsage{Bytes: transferred})
+	if err == nil {
+		d.exhausted(key, QuotaUsage{Requests: after.Requests, Bytes: after.Bytes - transferred}, after, limit)
+	}
+}
@@ -0,0 +1,74 @@
+package apihttpwrapper
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuotaDecorator(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	})
+
+	t.Run("requests quota", func(t *testing.T) {
+		exhausted := 0
+		d := NewQuotaDecorator(echo, NewMemoryQuotaStore(), QuotaPolicy{
+			Period:      time.Hour,
+			Limit:       func(string) QuotaLimit { return QuotaLimit{Requests: 2} },
+			OnExhausted: func(string, QuotaUsage, QuotaLimit) { exhausted++ },
+		})
+
+		statuses := []int{200, 200, 429, 429}
+		remainings := []string{"1", "0", "0", "0"}
+		for i, status := range statuses {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set(DefaultAPIKeyHeader, "key1")
+			w := httptest.NewRecorder()
+			d.ServeHTTP(w, r)
+			if w.Code != status {
+				t.Error("request", i, "status", w.Code)
+			}
+
+			if w.Header().Get("X-RateLimit-Remaining") != remainings[i] {
+				t.Error("request", i, "remaining", w.Header().Get("X-RateLimit-Remaining"))
+			}
+		}
+
+		if exhausted != 1 {
+			t.Error("exhausted callback called", exhausted, "times")
+		}
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(DefaultAPIKeyHeader, "key2")
+		w := httptest.NewRecorder()
+		d.ServeHTTP(w, r)
+		if w.Code != 200 {
+			t.Error("other key status", w.Code)
+		}
+	})
+
+	t.Run("bytes quota", func(t *testing.T) {
+		d := NewQuotaDecorator(echo, NewMemoryQuotaStore(), QuotaPolicy{
+			Limit: func(string) QuotaLimit { return QuotaLimit{Bytes: 10} },
+		})
+
+		for i, status := range []int{200, 429} {
+			r := httptest.NewRequest("POST", "/", strings.NewReader("abcdef"))
+			r.Header.Set(DefaultAPIKeyHeader, "key")
+			w := httptest.NewRecorder()
+			d.ServeHTTP(w, r)
+			if w.Code != status {
+				t.Error("request", i, "status", w.Code)
+			}
+
+			if status == 429 && (w.Header().Get("Retry-After") == "" ||
+				w.Header().Get("X-Quota-Bytes-Remaining") != "0") {
+				t.Error("unexpected headers", w.Header())
+			}
+		}
+	})
+}
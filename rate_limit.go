@@ -0,0 +1,138 @@
+package apihttpwrapper
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/trace"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimit is the token bucket limiter of Route.RateLimit, it may be shared by the routes.
+type RateLimit struct {
+	// Rate is the requests per second refilled into each bucket.
+	Rate float64
+	// Burst is the capacity of each bucket, 1 if less.
+	Burst int
+	// KeyFunc extracts the client key, RateLimitByIP by default and for the empty keys.
+	KeyFunc func(r *http.Request) string
+	// Exempt are the classes of RouterOptions.Classifier not limited, like TrafficProbe.
+	Exempt []TrafficClass
+
+	once      sync.Once
+	mutex     sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimitSweepInterval is how often the full buckets are removed, they are the same as the absent ones.
+const rateLimitSweepInterval = time.Minute
+
+// RateLimitByIP keys the requests by the host of the remote address.
+func RateLimitByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// RateLimitByHeader keys the requests by the header, like an API key.
+func RateLimitByHeader(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+func (l *RateLimit) init() {
+	if l.KeyFunc == nil {
+		l.KeyFunc = RateLimitByIP
+	}
+
+	if l.Burst < 1 {
+		l.Burst = 1
+	}
+
+	l.buckets = make(map[string]*tokenBucket)
+}
+
+// take returns 0 if the request is allowed, or the wait until the bucket has a token.
+func (l *RateLimit) take(key string, now time.Time) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	burst := float64(l.Burst)
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		for k, b := range l.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*l.Rate >= burst {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.Rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+	}
+
+	b.tokens--
+	return 0
+}
+
+func (l *RateLimit) exempt(class TrafficClass) bool {
+	for _, c := range l.Exempt {
+		if c == class {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newRateLimitHandle answers the rejected requests in the format of the handler, with the retry hint headers.
+func newRateLimitHandle(handle httprouter.Handle, limit *RateLimit, handler *ServiceHandler) httprouter.Handle {
+	limit.once.Do(limit.init)
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		if limit.exempt(TrafficClassFromContext(r.Context())) {
+			handle(w, r, params)
+			return
+		}
+
+		// the keys of RateLimitByIP are prefixed, so they can't collide with those of KeyFunc.
+		key := limit.KeyFunc(r)
+		if key == "" {
+			key = "ip:" + RateLimitByIP(r)
+		}
+
+		wait := limit.take(key, time.Now())
+		if wait == 0 {
+			handle(w, r, params)
+			return
+		}
+
+		tracer := trace.New(traceFamily, r.URL.Path)
+		setRetryHeaders(w.Header(), wait)
+		writeEnvelopedError(w, tracer, handler.negotiateResponseFormat(w, r), &FormattedResponse{
+			http.StatusTooManyRequests, "rate limit exceeded", (&RetryAfterError{After: wait}).Error()})
+		tracer.Finish()
+	}
+}
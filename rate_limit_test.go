@@ -0,0 +1,94 @@
+package apihttpwrapper
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	limit := &RateLimit{Rate: 1, Burst: 2}
+	method := func(ctx *ServiceMethodContext, arg *struct{}) error {
+		return nil
+	}
+	router, err := NewHTTPRouter([]*Route{
+		{Method: "GET", Path: "/a", Function: method, RateLimit: limit},
+		{Method: "GET", Path: "/b", Function: method, RateLimit: limit},
+		{Method: "GET", Path: "/keyed", Function: method, RateLimit: &RateLimit{Rate: 1,
+			KeyFunc: RateLimitByHeader(DefaultAPIKeyHeader)}},
+		{Method: "GET", Path: "/enveloped", Function: method, RateLimit: &RateLimit{Rate: 1},
+			Enveloper: errnoEnveloper{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(path string, remote string, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r.RemoteAddr = remote
+		if key != "" {
+			r.Header.Set(DefaultAPIKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	cases := []struct {
+		path   string
+		remote string
+		key    string
+		status int
+	}{
+		{"/a", "10.0.0.1:1000", "", 200},
+		{"/b", "10.0.0.1:1001", "", 200},
+		{"/a", "10.0.0.1:1002", "", 429},
+		{"/a", "10.0.0.2:1000", "", 200},
+		{"/keyed", "10.0.0.1:1000", "k1", 200},
+		{"/keyed", "10.0.0.2:1000", "k1", 429},
+		{"/keyed", "10.0.0.1:1000", "k2", 200},
+		{"/keyed", "10.0.0.1:1000", "", 200},
+		{"/keyed", "10.0.0.1:1000", "", 429},
+		{"/keyed", "10.0.0.2:1000", "", 200},
+		{"/enveloped", "10.0.0.1:1000", "", 200},
+		{"/enveloped", "10.0.0.1:1000", "", 429},
+	}
+
+	for i, c := range cases {
+		w := serve(c.path, c.remote, c.key)
+		if w.Code != c.status {
+			t.Error(i, w.Code, w.Body.String())
+		}
+
+		if c.status == 429 {
+			retryAfter, _ := strconv.Atoi(w.Header().Get("Retry-After"))
+			if retryAfter < 1 || !strings.Contains(w.Body.String(), "rate limit exceeded") ||
+				(c.path == "/enveloped") != strings.Contains(w.Body.String(), `"errno":429`) {
+				t.Error(i, w.Header(), w.Body.String())
+			}
+		}
+	}
+
+	now := time.Now()
+	l := &RateLimit{Rate: 10}
+	l.init()
+	if l.take("k", now) != 0 {
+		t.Error("the first request is limited")
+	}
+
+	if wait := l.take("k", now.Add(50*time.Millisecond)); wait < 40*time.Millisecond || wait > 60*time.Millisecond {
+		t.Error(wait)
+	}
+
+	if l.take("k", now.Add(100*time.Millisecond)) != 0 {
+		t.Error("the refilled request is limited")
+	}
+
+	l.take("idle", now)
+	l.take("k", now.Add(2*rateLimitSweepInterval))
+	if _, ok := l.buckets["idle"]; ok || len(l.buckets) != 1 {
+		t.Error(l.buckets)
+	}
+}
@@ -0,0 +1,126 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// TimestampHeader carries the unix seconds when the request is signed.
+	TimestampHeader = "X-Timestamp"
+	NonceHeader     = "X-Nonce"
+)
+
+const (
+	DefaultReplayWindow = 5 * time.Minute
+	maxNonceLength      = 128
+)
+
+// NonceStore remembers the used nonces, it should be shared by all replicas to reject the replays across them.
+type NonceStore interface {
+	// Remember records the nonce until expiry, and returns false if the nonce is already recorded.
+	Remember(nonce string, expiry time.Time) (bool, error)
+}
+
+// ReplayProtectionDecorator rejects the requests out of the window or having the used nonces.
+type ReplayProtectionDecorator struct {
+	http.Handler
+	store  NonceStore
+	window time.Duration
+}
+
+// MemoryNonceStore is a NonceStore for single replica deployments.
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	nextSweep time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+func (s *MemoryNonceStore) Remember(nonce string, expiry time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.After(s.nextSweep) {
+		for k, e := range s.nonces {
+			if now.After(e) {
+				delete(s.nonces, k)
+			}
+		}
+		s.nextSweep = now.Add(time.Minute)
+	}
+
+	if e, ok := s.nonces[nonce]; ok && !now.After(e) {
+		return false, nil
+	}
+
+	s.nonces[nonce] = expiry
+	return true, nil
+}
+
+// NewReplayProtectionDecorator uses DefaultReplayWindow if window is not positive.
+func NewReplayProtectionDecorator(handler http.Handler, store NonceStore,
+	window time.Duration) *ReplayProtectionDecorator {
+	if window <= 0 {
+		window = DefaultReplayWindow
+	}
+
+	return &ReplayProtectionDecorator{
+		Handler: handler,
+		store:   store,
+		window:  window,
+	}
+}
+
+func writeReplayRejected(w http.ResponseWriter, code int, msg string, reason string) {
+	setResponseHeader(w)
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(&FormattedResponse{code, msg, reason})
+}
+
+func (d *ReplayProtectionDecorator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	value := r.Header.Get(TimestampHeader)
+	if value == "" {
+		writeReplayRejected(w, http.StatusUnauthorized, "replay check failed", "missing timestamp")
+		return
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		writeReplayRejected(w, http.StatusUnauthorized, "replay check failed", "invalid timestamp")
+		return
+	}
+
+	timestamp := time.Unix(seconds, 0)
+	if skew := time.Since(timestamp); skew > d.window || skew < -d.window {
+		writeReplayRejected(w, http.StatusUnauthorized, "replay check failed", "stale timestamp")
+		return
+	}
+
+	nonce := r.Header.Get(NonceHeader)
+	if nonce == "" || len(nonce) > maxNonceLength {
+		writeReplayRejected(w, http.StatusUnauthorized, "replay check failed", "missing or invalid nonce")
+		return
+	}
+
+	// the requests with the nonce are rejected by the timestamp check after the expiry anyway.
+	fresh, err := d.store.Remember(nonce, timestamp.Add(d.window))
+	if err != nil {
+		writeReplayRejected(w, http.StatusServiceUnavailable, "nonce store unavailable", err.Error())
+		return
+	}
+
+	if !fresh {
+		writeReplayRejected(w, http.StatusUnauthorized, "replay check failed", "nonce reused")
+		return
+	}
+
+	d.Handler.ServeHTTP(w, r)
+}
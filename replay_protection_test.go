@@ -0,0 +1,51 @@
+package apihttpwrapper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReplayProtectionDecorator(t *testing.T) {
+	d := NewReplayProtectionDecorator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		NewMemoryNonceStore(), time.Minute)
+
+	do := func(timestamp time.Time, nonce string) int {
+		r := httptest.NewRequest("GET", "/", nil)
+		if !timestamp.IsZero() {
+			r.Header.Set(TimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+		}
+
+		if nonce != "" {
+			r.Header.Set(NonceHeader, nonce)
+		}
+
+		recorder := httptest.NewRecorder()
+		d.ServeHTTP(recorder, r)
+		return recorder.Code
+	}
+
+	now := time.Now()
+	cases := []struct {
+		name      string
+		timestamp time.Time
+		nonce     string
+		expect    int
+	}{
+		{"fresh", now, "n1", 200},
+		{"replayed", now, "n1", 401},
+		{"another nonce", now.Add(-30 * time.Second), "n2", 200},
+		{"stale", now.Add(-2 * time.Minute), "n3", 401},
+		{"future", now.Add(2 * time.Minute), "n4", 401},
+		{"missing timestamp", time.Time{}, "n5", 401},
+		{"missing nonce", now, "", 401},
+	}
+
+	for _, c := range cases {
+		if code := do(c.timestamp, c.nonce); code != c.expect {
+			t.Error(c.name, code)
+		}
+	}
+}
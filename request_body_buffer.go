@@ -0,0 +1,82 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// SetRequestBodyBuffer buffers the request body up to limit bytes for RequestBodyReader, 0 disables it.
+func (h *ServiceHandler) SetRequestBodyBuffer(limit int64) {
+	h.bodyBufferLimit = limit
+}
+
+// bufferLimitError is of the bodies exceeding the buffer limit.
+type bufferLimitError struct {
+	limit int64
+}
+
+func (e *bufferLimitError) Error() string {
+	return fmt.Sprintf("the request body exceeds %d bytes", e.limit)
+}
+
+// bufferRequestBody replaces the body of r with the buffered one, and returns another reader of the same bytes.
+func (h *ServiceHandler) bufferRequestBody(r *http.Request) (io.ReadCloser, error) {
+	if h.bodyBufferLimit <= 0 || h.bypassRequestBody || r.Body == nil || r.Body == http.NoBody {
+		return r.Body, nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, h.bodyBufferLimit+1))
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > h.bodyBufferLimit {
+		return nil, &bufferLimitError{h.bodyBufferLimit}
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return ioutil.NopCloser(bytes.NewReader(body)), nil
+}
+
+// SetMaxBodyBytes rejects the request bodies larger than n bytes with 413, 0 means unlimited.
+func (h *ServiceHandler) SetMaxBodyBytes(n int64) {
+	h.maxBodyBytes = n
+}
+
+// limitRequestBody returns false if the body of r is declared larger than the limit by the Content-Length header.
+func (h *ServiceHandler) limitRequestBody(w http.ResponseWriter, r *http.Request) bool {
+	if h.maxBodyBytes <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return true
+	}
+
+	if r.ContentLength > h.maxBodyBytes {
+		return false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBodyBytes)
+	return true
+}
+
+// isBodyTooLarge checks the message too, the error has no exported type in the older go versions.
+func isBodyTooLarge(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "http: request body too large") ||
+		strings.Contains(err.Error(), decompressedTooLargeMessage))
+}
+
+// tooLargeResponse is of the errors of isBodyTooLarge.
+func (h *ServiceHandler) tooLargeResponse(err error) *FormattedResponse {
+	if strings.Contains(err.Error(), decompressedTooLargeMessage) {
+		return &FormattedResponse{413, "request body too large", err.Error()}
+	}
+
+	return bodyTooLargeResponse(h.maxBodyBytes)
+}
+
+func bodyTooLargeResponse(limit int64) *FormattedResponse {
+	return &FormattedResponse{413, "request body too large", fmt.Sprintf("the request body exceeds %d bytes", limit)}
+}
@@ -0,0 +1,97 @@
+package apihttpwrapper
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestBodyBuffer(t *testing.T) {
+	var raw string
+	router, err := NewHTTPRouter([]*Route{{
+		Method: "POST",
+		Path:   "/",
+		Function: func(ctx *ServiceMethodContext, arg *struct{ A int }) error {
+			body, _ := ioutil.ReadAll(ctx.RequestBodyReader)
+			raw = string(body)
+			if arg.A != 1 {
+				t.Error(arg)
+			}
+			return nil
+		},
+		BufferRequestBody: 16,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		body   string
+		status int
+		raw    string
+	}{
+		{"{\"A\":1}", 200, "{\"A\":1}"},
+		{"{\"A\":1,\"B\":\"too long\"}", 413, ""},
+	}
+
+	for _, c := range cases {
+		raw = ""
+		r := httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+		r.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		if recorder.Code != c.status || raw != c.raw {
+			t.Error(c.body, recorder.Code, raw)
+		}
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	router, err := NewHTTPRouter([]*Route{{
+		Method: "POST",
+		Path:   "/",
+		Function: func(ctx *ServiceMethodContext, arg *struct{ A int }) error {
+			return nil
+		},
+		MaxBodyBytes: 16,
+	}, {
+		Method: "POST",
+		Path:   "/buffered",
+		Function: func(ctx *ServiceMethodContext, arg *struct{ A int }) error {
+			return nil
+		},
+		MaxBodyBytes:      16,
+		BufferRequestBody: 64,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path          string
+		body          string
+		unknownLength bool
+		status        int
+	}{
+		{"/", "{\"A\":1}", false, 200},
+		{"/", "{\"A\":1,\"B\":\"too long\"}", false, 413},
+		{"/", "{\"A\":1,\"B\":\"too long\"}", true, 413},
+		{"/buffered", "{\"A\":1}", true, 200},
+		{"/buffered", "{\"A\":1,\"B\":\"too long\"}", true, 413},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", c.path, strings.NewReader(c.body))
+		r.Header.Set("Content-Type", "application/json")
+		if c.unknownLength {
+			r.ContentLength = -1
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		if recorder.Code != c.status {
+			t.Error(c, recorder.Code, recorder.Body.String())
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package apihttpwrapper
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxDecompressedBytes limits the decompressed request bodies, see SetMaxDecompressedBytes.
+const DefaultMaxDecompressedBytes = 32 << 20
+
+// decompressedTooLargeMessage is matched by isBodyTooLarge, since the decoders may wrap the errors of the readers.
+const decompressedTooLargeMessage = "the decompressed request body exceeds the limit"
+
+// SetMaxDecompressedBytes limits the gzip or deflate bodies, 0 means the default and negative disables it.
+func (h *ServiceHandler) SetMaxDecompressedBytes(n int64) {
+	h.maxDecompressedBytes = n
+}
+
+type decompressedBody struct {
+	io.Reader
+	body   io.ReadCloser
+	remain int64
+	limit  int64
+}
+
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	if b.remain < 0 {
+		return 0, fmt.Errorf("%s of %d bytes", decompressedTooLargeMessage, b.limit)
+	}
+
+	if int64(len(p)) > b.remain+1 {
+		p = p[:b.remain+1]
+	}
+
+	n, err := b.Reader.Read(p)
+	if b.remain -= int64(n); b.remain < 0 {
+		return n, fmt.Errorf("%s of %d bytes", decompressedTooLargeMessage, b.limit)
+	}
+
+	return n, err
+}
+
+func (b *decompressedBody) Close() error {
+	return b.body.Close()
+}
+
+// deflateReader takes both the zlib format of the RFC and the raw deflate sent by some clients.
+func deflateReader(body io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(body)
+	header, err := buffered.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+
+	return flate.NewReader(buffered), nil
+}
+
+// decompressRequestBody returns the status of the failure, or 0 if the body of r is decompressed or not compressed.
+func (h *ServiceHandler) decompressRequestBody(r *http.Request) (int, error) {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if h.maxDecompressedBytes < 0 || h.bypassRequestBody || encoding == "" || encoding == "identity" ||
+		r.Body == nil || r.Body == http.NoBody {
+		return 0, nil
+	}
+
+	var reader io.Reader
+	var err error
+	switch encoding {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(r.Body)
+	case "deflate":
+		reader, err = deflateReader(r.Body)
+	default:
+		return 0, nil
+	}
+
+	if isBodyTooLarge(err) {
+		return http.StatusRequestEntityTooLarge, err
+	} else if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	limit := h.maxDecompressedBytes
+	if limit == 0 {
+		limit = DefaultMaxDecompressedBytes
+	}
+
+	r.Body = &decompressedBody{Reader: reader, body: r.Body, remain: limit, limit: limit}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return 0, nil
+}
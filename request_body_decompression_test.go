@@ -0,0 +1,91 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestBodyDecompression(t *testing.T) {
+	var raw string
+	router, err := NewHTTPRouter([]*Route{{
+		Method: "POST",
+		Path:   "/",
+		Function: func(ctx *ServiceMethodContext, arg *struct{ A int }) error {
+			body, _ := ioutil.ReadAll(ctx.RequestBodyReader)
+			raw = string(body)
+			if arg.A != 1 {
+				t.Error(arg)
+			}
+			return nil
+		},
+		BufferRequestBody:    1 << 10,
+		MaxDecompressedBytes: 1 << 10,
+	}, {
+		Method:            "POST",
+		Path:              "/raw",
+		BypassRequestBody: true,
+		Function: func(ctx *ServiceMethodContext, arg *struct{}) error {
+			body, _ := ioutil.ReadAll(ctx.RequestBodyReader)
+			raw = string(body)
+			return nil
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compress := func(newWriter func(io.Writer) io.WriteCloser, body string) string {
+		buffer := &bytes.Buffer{}
+		w := newWriter(buffer)
+		_, _ = w.Write([]byte(body))
+		_ = w.Close()
+		return buffer.String()
+	}
+	gzipWriter := func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+	zlibWriter := func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }
+	flateWriter := func(w io.Writer) io.WriteCloser {
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	}
+
+	body := `{"A":1}`
+	bomb := `{"A":1,"B":"` + strings.Repeat("0", 1<<20) + `"}`
+	cases := []struct {
+		path     string
+		encoding string
+		body     string
+		status   int
+		raw      string
+	}{
+		{"/", "", body, 200, body},
+		{"/", "gzip", compress(gzipWriter, body), 200, body},
+		{"/", "deflate", compress(zlibWriter, body), 200, body},
+		{"/", "deflate", compress(flateWriter, body), 200, body},
+		{"/", "gzip", compress(gzipWriter, bomb), 413, ""},
+		{"/", "gzip", body, 400, ""},
+		// the truncated streams fail while buffering.
+		{"/", "gzip", compress(gzipWriter, body)[:16], 400, ""},
+		// the other encodings and the bypassed bodies are left for the methods.
+		{"/", "br", body, 200, body},
+		{"/raw", "gzip", compress(gzipWriter, body), 200, compress(gzipWriter, body)},
+	}
+
+	for _, c := range cases {
+		raw = ""
+		r := httptest.NewRequest("POST", c.path, strings.NewReader(c.body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Content-Encoding", c.encoding)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		if recorder.Code != c.status || raw != c.raw {
+			t.Error(c.path, c.encoding, c.status, recorder.Code, recorder.Body.String(), raw)
+		}
+	}
+}
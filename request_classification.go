@@ -0,0 +1,114 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strings"
+)
+
+// TrafficClass tells the synthetic requests from the ones of the real users, see RequestClassifier.
+type TrafficClass string
+
+const (
+	TrafficHuman TrafficClass = "human"
+	// TrafficBot is of the crawlers and the other automated clients.
+	TrafficBot TrafficClass = "bot"
+	// TrafficProbe is of the health checks of the load balancers and the orchestrators.
+	TrafficProbe TrafficClass = "probe"
+)
+
+// RequestClassifier is RouterOptions.Classifier, see TrafficClassFromContext.
+type RequestClassifier func(r *http.Request) TrafficClass
+
+// TrafficRule matches the requests by all of the conditions set, see NewRequestClassifier.
+type TrafficRule struct {
+	Class TrafficClass
+	// UserAgent matches the substring of User-Agent case-insensitively.
+	UserAgent string
+	// PathPrefix matches the prefix of the path.
+	PathPrefix string
+	// Header matches the requests having the header, and the value if HeaderValue is set.
+	Header      string
+	HeaderValue string
+}
+
+var (
+	defaultProbeAgents = []string{"kube-probe/", "elb-healthchecker", "googlehc", "consul health check",
+		"prometheus/", "blackbox-exporter"}
+	defaultBotAgents = []string{"bot", "crawler", "spider", "slurp"}
+)
+
+// DefaultRequestClassifier classifies the requests by the User-Agents of the common probes and crawlers.
+func DefaultRequestClassifier(r *http.Request) TrafficClass {
+	agent := strings.ToLower(r.UserAgent())
+	for _, probe := range defaultProbeAgents {
+		if strings.Contains(agent, probe) {
+			return TrafficProbe
+		}
+	}
+
+	for _, bot := range defaultBotAgents {
+		if strings.Contains(agent, bot) {
+			return TrafficBot
+		}
+	}
+
+	return TrafficHuman
+}
+
+func (rule *TrafficRule) match(r *http.Request) bool {
+	if rule.UserAgent != "" && !strings.Contains(strings.ToLower(r.UserAgent()), strings.ToLower(rule.UserAgent)) {
+		return false
+	}
+
+	if rule.PathPrefix != "" && !strings.HasPrefix(r.URL.Path, rule.PathPrefix) {
+		return false
+	}
+
+	if rule.Header != "" {
+		values, ok := r.Header[http.CanonicalHeaderKey(rule.Header)]
+		if !ok || (rule.HeaderValue != "" && (len(values) == 0 || values[0] != rule.HeaderValue)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewRequestClassifier returns the class of the first rule matched, and falls back to DefaultRequestClassifier.
+func NewRequestClassifier(rules ...*TrafficRule) RequestClassifier {
+	return func(r *http.Request) TrafficClass {
+		for _, rule := range rules {
+			if rule.match(r) {
+				return rule.Class
+			}
+		}
+
+		return DefaultRequestClassifier(r)
+	}
+}
+
+type trafficClassContextKey struct{}
+
+// TrafficClassFromContext returns the class of the request, "" if RouterOptions.Classifier isn't set.
+func TrafficClassFromContext(ctx context.Context) TrafficClass {
+	class, _ := ctx.Value(trafficClassContextKey{}).(TrafficClass)
+	return class
+}
+
+func newClassifierHandle(handle httprouter.Handle, classifier RequestClassifier, unlogged []TrafficClass,
+	loggerContextKey interface{}) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		class := classifier(r)
+		r = r.WithContext(context.WithValue(r.Context(), trafficClassContextKey{}, class))
+		recordLogField(r, loggerContextKey, "traffic", string(class))
+		for _, c := range unlogged {
+			if c == class {
+				SkipAccessLogRow(r.Context())
+			}
+		}
+
+		handle(w, r, params)
+	}
+}
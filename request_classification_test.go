@@ -0,0 +1,78 @@
+package apihttpwrapper
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type trafficCollector []TrafficClass
+
+func (c *trafficCollector) Observe(m *RequestMetrics) {
+	*c = append(*c, m.Traffic)
+}
+
+func TestRequestClassifier(t *testing.T) {
+	classes := make(chan TrafficClass, 1)
+	collector := &trafficCollector{}
+	rows := make(logRowWriter, 4)
+	router, err := NewLoggingHTTPRouterWithOptions([]*Route{{Method: "GET", Path: "/api/items",
+		RateLimit: &RateLimit{Rate: 0.001, Burst: 1, Exempt: []TrafficClass{TrafficProbe}},
+		Function: func(ctx *ServiceMethodContext, arg *struct{}) error {
+			classes <- TrafficClassFromContext(ctx.Context)
+			return nil
+		}}}, nil, rows, &RouterOptions{
+		Metrics:         collector,
+		UnloggedTraffic: []TrafficClass{TrafficProbe},
+		Classifier:      NewRequestClassifier(&TrafficRule{Class: TrafficBot, Header: "X-Synthetic", HeaderValue: "1"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		agent  string
+		header string
+		class  TrafficClass
+	}{
+		{"Mozilla/5.0", "", TrafficHuman},
+		{"kube-probe/1.27", "", TrafficProbe},
+		{"kube-probe/1.27", "", TrafficProbe},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1)", "", TrafficBot},
+		{"Mozilla/5.0", "1", TrafficBot},
+	}
+
+	for i, c := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/items", nil)
+		r.Header.Set("User-Agent", c.agent)
+		// the probes share the address, which are not limited.
+		if c.class != TrafficProbe {
+			r.RemoteAddr = "192.0.2." + string(rune('1'+i)) + ":1234"
+		}
+		if c.header != "" {
+			r.Header.Set("X-Synthetic", c.header)
+		}
+		router.ServeHTTP(w, r)
+		if w.Code != 200 {
+			t.Error(c.agent, w.Code)
+			continue
+		}
+
+		if class := <-classes; class != c.class {
+			t.Error(c.agent, class)
+		}
+
+		if c.class == TrafficProbe {
+			continue
+		}
+
+		if row := <-rows; !strings.Contains(row, "traffic="+string(c.class)) {
+			t.Error(c.agent, row)
+		}
+	}
+
+	if len(rows) != 0 || len(*collector) != len(cases) || (*collector)[1] != TrafficProbe {
+		t.Error("the probes are logged or not observed", *collector)
+	}
+}
@@ -0,0 +1,153 @@
+package apihttpwrapper
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/trace"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DuplicateOfHeader is the id of the original request in the 409 responses.
+const DuplicateOfHeader = "X-Duplicate-Of"
+
+const DefaultDeduplicationWindow = 10 * time.Second
+
+// Deduplication rejects the same requests of the same client within the window with 409.
+type Deduplication struct {
+	// Window is DefaultDeduplicationWindow if not positive.
+	Window time.Duration
+	// KeyFunc extracts the client key, the subject of the principal or else RateLimitByIP by default.
+	KeyFunc func(r *http.Request) string
+
+	once      sync.Once
+	mutex     sync.Mutex
+	requests  map[string]*dedupRequest
+	lastSweep time.Time
+}
+
+type dedupRequest struct {
+	id       string
+	received time.Time
+	expiry   time.Time
+	done     bool
+	status   int
+	location string
+}
+
+// DuplicateRequest is the data of the 409 responses, Status is 0 if the original request is in progress.
+type DuplicateRequest struct {
+	ID       string    `json:"id"`
+	Received time.Time `json:"received"`
+	Status   int       `json:"status,omitempty"`
+	Location string    `json:"location,omitempty"`
+}
+
+func dedupClientKey(r *http.Request) string {
+	if subject := principalSubject(r); subject != "" {
+		return subject
+	}
+
+	return RateLimitByIP(r)
+}
+
+func (d *Deduplication) init() {
+	if d.Window <= 0 {
+		d.Window = DefaultDeduplicationWindow
+	}
+
+	if d.KeyFunc == nil {
+		d.KeyFunc = dedupClientKey
+	}
+
+	d.requests = make(map[string]*dedupRequest)
+}
+
+// begin returns the original request if the request is a duplicate, or else the new one.
+func (d *Deduplication) begin(hash string, now time.Time) (*dedupRequest, bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if now.Sub(d.lastSweep) >= d.Window {
+		for k, req := range d.requests {
+			if req.done && now.After(req.expiry) {
+				delete(d.requests, k)
+			}
+		}
+		d.lastSweep = now
+	}
+
+	if req, ok := d.requests[hash]; ok && (!req.done || !now.After(req.expiry)) {
+		return req, true, nil
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, false, err
+	}
+
+	req := &dedupRequest{id: hex.EncodeToString(id), received: now}
+	d.requests[hash] = req
+	return req, false, nil
+}
+
+func (d *Deduplication) finish(hash string, req *dedupRequest, status int, location string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if status < http.StatusOK || status >= http.StatusMultipleChoices {
+		delete(d.requests, hash)
+		return
+	}
+
+	req.done, req.status, req.location = true, status, location
+	req.expiry = time.Now().Add(d.Window)
+}
+
+func newDeduplicationHandle(handle httprouter.Handle, dedup *Deduplication, handler *ServiceHandler,
+	loggerContextKey interface{}) httprouter.Handle {
+	dedup.once.Do(dedup.init)
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		body, ok := readHashedBody(w, r)
+		if !ok {
+			return
+		}
+
+		h := sha256.New()
+		_, _ = io.WriteString(h, dedup.KeyFunc(r)+"\n")
+		_, _ = io.WriteString(h, requestDigest(r, body))
+		hash := hex.EncodeToString(h.Sum(nil))
+
+		req, duplicate, err := dedup.begin(hash, time.Now())
+		if err != nil {
+			tracer := trace.New(traceFamily, r.URL.Path)
+			writeEnvelopedError(w, tracer, handler.negotiateResponseFormat(w, r), &FormattedResponse{
+				http.StatusInternalServerError, "deduplication failed", err.Error()})
+			tracer.Finish()
+			return
+		}
+		recordLogField(r, loggerContextKey, "dedupId", req.id)
+
+		if duplicate {
+			dedup.mutex.Lock()
+			original := &DuplicateRequest{req.id, req.received, req.status, req.location}
+			dedup.mutex.Unlock()
+			w.Header().Set(DuplicateOfHeader, req.id)
+			tracer := trace.New(traceFamily, r.URL.Path)
+			writeEnvelopedError(w, tracer, handler.negotiateResponseFormat(w, r), &FormattedResponse{
+				http.StatusConflict, "duplicate request", original})
+			tracer.Finish()
+			return
+		}
+
+		sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			dedup.finish(hash, req, sw.status, sw.Header().Get("Location"))
+		}()
+		handle(sw, r, params)
+	}
+}
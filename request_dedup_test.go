@@ -0,0 +1,89 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeduplication(t *testing.T) {
+	type orderArguments struct {
+		Item string `json:"item"`
+	}
+	calls := 0
+	router, err := NewHTTPRouter([]*Route{
+		{Method: "POST", Path: "/orders", Deduplication: &Deduplication{Window: 50 * time.Millisecond},
+			Function: func(ctx *ServiceMethodContext, arg *orderArguments) (*orderArguments, error) {
+				calls++
+				if arg.Item == "bad" {
+					return nil, &StatusError{Status: 400, Message: "bad item"}
+				}
+				ctx.ResponseHeader.Set("Location", "/orders/"+arg.Item)
+				return arg, nil
+			}},
+		{Method: "POST", Path: "/callbacks", Deduplication: &Deduplication{Window: time.Minute},
+			Enveloper: partnerEnveloper{}, Function: func(ctx *ServiceMethodContext, arg *orderArguments) error {
+				return nil
+			}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	servePath := func(path string, remote string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+	serve := func(remote string, body string) *httptest.ResponseRecorder {
+		return servePath("/orders", remote, body)
+	}
+
+	if w := serve("10.0.0.1:1000", `{"item":"a"}`); w.Code != 200 || calls != 1 {
+		t.Fatal(w.Code, w.Body.String())
+	}
+
+	w := serve("10.0.0.1:1001", `{"item":"a"}`)
+	resp := &struct {
+		Data *DuplicateRequest `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil || w.Code != 409 || calls != 1 {
+		t.Fatal(w.Code, w.Body.String())
+	}
+
+	if resp.Data.ID == "" || resp.Data.ID != w.Header().Get(DuplicateOfHeader) || resp.Data.Status != 200 ||
+		resp.Data.Location != "/orders/a" {
+		t.Error(w.Body.String())
+	}
+
+	if w := serve("10.0.0.2:1000", `{"item":"a"}`); w.Code != 200 || calls != 2 {
+		t.Error("the request of another client is rejected", w.Code)
+	}
+
+	if w := serve("10.0.0.1:1000", `{"item":"b"}`); w.Code != 200 || calls != 3 {
+		t.Error("the request of another body is rejected", w.Code)
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := serve("10.0.0.1:1000", `{"item":"bad"}`); w.Code != 400 || calls != 4+i {
+			t.Error("the retry of the failed request is rejected", w.Code)
+		}
+	}
+
+	// the rejections are shaped by the enveloper of the route.
+	servePath("/callbacks", "10.0.0.1:1000", `{"item":"a"}`)
+	if w := servePath("/callbacks", "10.0.0.1:1000", `{"item":"a"}`); w.Code != 200 ||
+		w.Body.String() != "{\"errcode\":409,\"errmsg\":\"duplicate request\"}\n" {
+		t.Error(w.Code, w.Body.String())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if w := serve("10.0.0.1:1000", `{"item":"a"}`); w.Code != 200 || calls != 6 {
+		t.Error("the request after the window is rejected", w.Code)
+	}
+}
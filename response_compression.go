@@ -0,0 +1,366 @@
+package apihttpwrapper
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// the content codings of CompressionDecorator.SetEncodings.
+const (
+	GzipEncoding   = "gzip"
+	BrotliEncoding = "br"
+	ZstdEncoding   = "zstd"
+)
+
+// DefaultCompressibleContentTypes are the text types worth compressing, see CompressionDecorator.SetContentTypes.
+var DefaultCompressibleContentTypes = []string{"text/*", "application/json", "application/xml",
+	"application/javascript", "application/x-ndjson", "application/problem+json", "image/svg+xml"}
+
+// CompressionDecorator compresses the responses for the clients accepting it.
+type CompressionDecorator struct {
+	http.Handler
+	loggerContextKey interface{}
+	level            int
+	encodings        []string
+	writers          map[string]*sync.Pool
+	minSize          int
+	contentTypes     []string
+}
+
+// compressor is the common methods of gzip.Writer, brotli.Writer and zstd.Encoder.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+type compressionResponseWriter struct {
+	http.ResponseWriter
+	decorator *CompressionDecorator
+	// encoding is the one negotiated, "" if the client accepts none.
+	encoding   string
+	compressor compressor
+	status     int
+	started    bool
+	hijacked   bool
+	// buffer keeps the body until the min size is reached, so the small responses are sent as is.
+	buffer  []byte
+	written int64
+}
+
+// NewCompressionDecorator compresses by gzip at level, 0 means gzip.DefaultCompression.
+func NewCompressionDecorator(handler http.Handler, loggerContextKey interface{}, level int) *CompressionDecorator {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	d := &CompressionDecorator{
+		Handler:          handler,
+		loggerContextKey: loggerContextKey,
+		level:            level,
+	}
+	_ = d.SetEncodings(GzipEncoding)
+	return d
+}
+
+func (d *CompressionDecorator) newCompressor(encoding string) compressor {
+	switch encoding {
+	case BrotliEncoding:
+		return brotli.NewWriterLevel(ioutil.Discard, brotli.DefaultCompression)
+	case ZstdEncoding:
+		// the encoders in the pool shouldn't have the goroutines of the concurrent encoding.
+		w, _ := zstd.NewWriter(ioutil.Discard, zstd.WithEncoderConcurrency(1))
+		return w
+	}
+
+	w, err := gzip.NewWriterLevel(ioutil.Discard, d.level)
+	if err != nil {
+		w = gzip.NewWriter(ioutil.Discard)
+	}
+	return w
+}
+
+// SetEncodings sets the encodings in the order of preference.
+func (d *CompressionDecorator) SetEncodings(encodings ...string) error {
+	writers := make(map[string]*sync.Pool, len(encodings))
+	for _, encoding := range encodings {
+		switch encoding {
+		case GzipEncoding, BrotliEncoding, ZstdEncoding:
+		default:
+			return fmt.Errorf("unsupported content encoding %q", encoding)
+		}
+
+		encoding := encoding
+		writers[encoding] = &sync.Pool{New: func() interface{} {
+			return d.newCompressor(encoding)
+		}}
+	}
+
+	d.encodings, d.writers = encodings, writers
+	return nil
+}
+
+// SetMinSize sends the responses smaller than size uncompressed.
+func (d *CompressionDecorator) SetMinSize(size int) {
+	d.minSize = size
+}
+
+// SetContentTypes sets the media types compressed, like "text/*", all types if nil.
+func (d *CompressionDecorator) SetContentTypes(types ...string) {
+	d.contentTypes = types
+}
+
+// acceptedQuality returns the q value of the encoding, "*" is of the ones not listed.
+func acceptedQuality(r *http.Request, encoding string) float64 {
+	quality, wildcard := -1.0, -1.0
+	for _, value := range r.Header["Accept-Encoding"] {
+		for _, coding := range strings.Split(value, ",") {
+			parts := strings.Split(coding, ";")
+			name := strings.ToLower(strings.TrimSpace(parts[0]))
+			if name != encoding && name != "*" {
+				continue
+			}
+
+			q := 1.0
+			for _, param := range parts[1:] {
+				if param = strings.TrimSpace(param); strings.HasPrefix(param, "q=") {
+					q, _ = strconv.ParseFloat(param[2:], 64)
+				}
+			}
+
+			if name == encoding {
+				quality = q
+			} else {
+				wildcard = q
+			}
+		}
+	}
+
+	if quality < 0 {
+		return wildcard
+	}
+
+	return quality
+}
+
+// negotiate returns the encoding of the highest q value, "" if none is accepted.
+func (d *CompressionDecorator) negotiate(r *http.Request) string {
+	best, bestQuality := "", 0.0
+	for _, encoding := range d.encodings {
+		if q := acceptedQuality(r, encoding); q > bestQuality {
+			best, bestQuality = encoding, q
+		}
+	}
+
+	return best
+}
+
+func (d *CompressionDecorator) allowsContentType(contentType string) bool {
+	if d.contentTypes == nil {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range d.contentTypes {
+		allowed = strings.ToLower(allowed)
+		if allowed == mediaType || (strings.HasSuffix(allowed, "/*") &&
+			strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func addVary(header http.Header, value string) {
+	for _, vary := range header["Vary"] {
+		for _, field := range strings.Split(vary, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), value) {
+				return
+			}
+		}
+	}
+
+	header.Add("Vary", value)
+}
+
+func bodylessStatus(status int) bool {
+	return status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified
+}
+
+// compressible tells whether to compress the response, final means the buffer is the whole body.
+func (w *compressionResponseWriter) compressible(final bool) bool {
+	header := w.Header()
+	if w.encoding == "" || bodylessStatus(w.status) || header.Get("Content-Encoding") != "" ||
+		header.Get("Content-Range") != "" || strings.Contains(header.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+
+	if final && (len(w.buffer) == 0 || len(w.buffer) < w.decorator.minSize) {
+		return false
+	}
+
+	if header.Get("Content-Type") == "" && len(w.buffer) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buffer))
+	}
+
+	return w.decorator.allowsContentType(header.Get("Content-Type"))
+}
+
+// start writes the header and the buffered body, the response is compressed from then on if compressible.
+func (w *compressionResponseWriter) start(final bool) error {
+	w.started = true
+	header := w.Header()
+	addVary(header, "Accept-Encoding")
+	if w.compressible(final) {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.compressor = w.decorator.writers[w.encoding].Get().(compressor)
+		w.compressor.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	buffer := w.buffer
+	w.buffer = nil
+	if len(buffer) == 0 {
+		return nil
+	}
+
+	_, err := w.write(buffer)
+	return err
+}
+
+func (w *compressionResponseWriter) write(b []byte) (int, error) {
+	if w.compressor == nil {
+		return w.ResponseWriter.Write(b)
+	}
+
+	return w.compressor.Write(b)
+}
+
+func (w *compressionResponseWriter) WriteHeader(status int) {
+	if w.started || w.status != 0 {
+		return
+	}
+
+	// the informational responses are sent at once, the same as net/http.
+	if status < http.StatusOK && status != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	w.status = status
+	if bodylessStatus(status) {
+		_ = w.start(false)
+	}
+}
+
+func (w *compressionResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	w.written += int64(len(b))
+	if w.started {
+		return w.write(b)
+	}
+
+	if w.encoding == "" {
+		if err := w.start(false); err != nil {
+			return 0, err
+		}
+		return w.write(b)
+	}
+
+	w.buffer = append(w.buffer, b...)
+	if len(w.buffer) >= w.decorator.minSize {
+		if err := w.start(false); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// Flush sends the buffered body, the streaming responses are compressed regardless of the min size.
+func (w *compressionResponseWriter) Flush() {
+	if !w.started {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		_ = w.start(false)
+	}
+
+	if w.compressor != nil {
+		_ = w.compressor.Flush()
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *compressionResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressionResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the response writer doesn't support hijacking")
+	}
+
+	w.hijacked, w.started = true, true
+	return hijacker.Hijack()
+}
+
+func (w *compressionResponseWriter) close() {
+	if !w.started {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		_ = w.start(true)
+	}
+
+	if w.compressor == nil {
+		return
+	}
+
+	if !w.hijacked {
+		_ = w.compressor.Close()
+	}
+	w.compressor.Reset(ioutil.Discard)
+	w.decorator.writers[w.encoding].Put(w.compressor)
+}
+
+func (d *CompressionDecorator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cw := &compressionResponseWriter{
+		ResponseWriter: w,
+		decorator:      d,
+		encoding:       d.negotiate(r),
+	}
+
+	d.Handler.ServeHTTP(cw, r)
+	cw.close()
+
+	if cw.compressor != nil && d.loggerContextKey != nil {
+		if logger, ok := r.Context().Value(d.loggerContextKey).(MethodLogger); ok {
+			logger.Record("uncompressedBytes", strconv.FormatInt(cw.written, 10))
+		}
+	}
+}
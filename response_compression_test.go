@@ -0,0 +1,134 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestResponseCompression(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	router, err := NewLoggingHTTPRouterWithOptions([]*Route{{
+		Method: "GET",
+		Path:   "/",
+		Function: func(ctx *ServiceMethodContext, arg *struct{}) (*struct{ A string }, error) {
+			return &struct{ A string }{strings.Repeat("a", 1000)}, nil
+		},
+	}}, nil, buffer, &RouterOptions{Compression: &CompressionOptions{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "deflate, gzip;q=0.5")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, r)
+	wire := recorder.Body.Len()
+	if recorder.Header().Get("Content-Encoding") != "gzip" || wire >= 1000 {
+		t.Fatal(recorder.Header(), wire)
+	}
+
+	reader, err := gzip.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := ioutil.ReadAll(reader)
+	logs := buffer.String()
+	if !strings.Contains(logs, "bytes="+strconv.Itoa(wire)+" ") ||
+		!strings.Contains(logs, "uncompressedBytes="+strconv.Itoa(len(body))+" ") {
+		t.Error(wire, len(body), logs)
+	}
+
+	buffer.Reset()
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip;q=0")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, r)
+	if recorder.Header().Get("Content-Encoding") != "" || strings.Contains(buffer.String(), "uncompressedBytes") {
+		t.Error(recorder.Header(), buffer.String())
+	}
+}
+
+func TestCompressionNegotiation(t *testing.T) {
+	body := strings.Repeat("compressible ", 100)
+	d := NewCompressionDecorator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = w.Write([]byte(body[:len(body)/2]))
+		_, _ = w.Write([]byte(body[len(body)/2:]))
+	}), nil, 0)
+	if err := d.SetEncodings("deflate"); err == nil {
+		t.Error("deflate is not supported")
+	}
+
+	if err := d.SetEncodings(ZstdEncoding, BrotliEncoding, GzipEncoding); err != nil {
+		t.Fatal(err)
+	}
+	d.SetMinSize(200)
+	d.SetContentTypes(DefaultCompressibleContentTypes...)
+
+	cases := []struct {
+		accept      string
+		contentType string
+		encoding    string
+	}{
+		{"gzip, br", "application/json", BrotliEncoding},
+		{"gzip, br;q=0.5", "application/json", GzipEncoding},
+		{"*", "text/plain; charset=utf-8", ZstdEncoding},
+		{"*, zstd;q=0", "text/html", BrotliEncoding},
+		{"identity", "application/json", ""},
+		{"br", "image/png", ""},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/?type="+url.QueryEscape(c.contentType), nil)
+		r.Header.Set("Accept-Encoding", c.accept)
+		recorder := httptest.NewRecorder()
+		d.ServeHTTP(recorder, r)
+
+		header := recorder.Header()
+		if header.Get("Content-Encoding") != c.encoding || header.Get("Vary") != "Accept-Encoding" ||
+			(c.encoding != "") == (header.Get("Content-Length") != "") {
+			t.Error(c.accept, c.contentType, header)
+			continue
+		}
+
+		var reader io.Reader = recorder.Body
+		switch c.encoding {
+		case GzipEncoding:
+			reader, _ = gzip.NewReader(recorder.Body)
+		case BrotliEncoding:
+			reader = brotli.NewReader(recorder.Body)
+		case ZstdEncoding:
+			decoder, _ := zstd.NewReader(recorder.Body)
+			defer decoder.Close()
+			reader = decoder
+		}
+
+		if decoded, err := ioutil.ReadAll(reader); err != nil || string(decoded) != body {
+			t.Error(c.accept, err, len(decoded))
+		}
+	}
+
+	d = NewCompressionDecorator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("small"))
+	}), nil, 0)
+	d.SetMinSize(200)
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	recorder := httptest.NewRecorder()
+	d.ServeHTTP(recorder, r)
+	if recorder.Header().Get("Content-Encoding") != "" || recorder.Body.String() != "small" {
+		t.Error(recorder.Header(), recorder.Body.String())
+	}
+}
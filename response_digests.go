@@ -0,0 +1,136 @@
+package apihttpwrapper
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ContentDigestHeader carries the sha-256 digest of the response body, see RFC 9530.
+const ContentDigestHeader = "Content-Digest"
+
+// ResponseDigestDecorator sets the digests of the bodies, except the streaming ones.
+type ResponseDigestDecorator struct {
+	http.Handler
+	loggerContextKey interface{}
+	etag             bool
+}
+
+type digestResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	streaming   bool
+}
+
+func NewResponseDigestDecorator(handler http.Handler, loggerContextKey interface{}) *ResponseDigestDecorator {
+	return &ResponseDigestDecorator{
+		Handler:          handler,
+		loggerContextKey: loggerContextKey,
+	}
+}
+
+// SetETag makes the digests the ETags, answering If-None-Match with 304.
+func (d *ResponseDigestDecorator) SetETag(etag bool) {
+	d.etag = etag
+}
+
+func (w *digestResponseWriter) WriteHeader(status int) {
+	if w.streaming {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+}
+
+func (w *digestResponseWriter) Write(b []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.wroteHeader = true
+	return w.body.Write(b)
+}
+
+// Flush turns the writer into the passthrough mode, the buffered body is written first.
+func (w *digestResponseWriter) Flush() {
+	if !w.streaming {
+		w.streaming = true
+		w.ResponseWriter.WriteHeader(w.status)
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *digestResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *digestResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the response writer doesn't support hijacking")
+	}
+
+	w.streaming = true
+	return hijacker.Hijack()
+}
+
+func (d *ResponseDigestDecorator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	dw := &digestResponseWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+	}
+
+	d.Handler.ServeHTTP(dw, r)
+	if dw.streaming {
+		return
+	}
+
+	sum := sha256.Sum256(dw.body.Bytes())
+	digest := "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+	w.Header().Set(ContentDigestHeader, digest)
+	if d.loggerContextKey != nil {
+		if logger, ok := r.Context().Value(d.loggerContextKey).(MethodLogger); ok {
+			logger.Record("digest", digest)
+		}
+	}
+
+	if d.etag && w.Header().Get("ETag") == "" && dw.status == http.StatusOK {
+		etag := "\"" + base64.RawURLEncoding.EncodeToString(sum[:]) + "\""
+		w.Header().Set("ETag", etag)
+		conditional := r.Method == http.MethodGet || r.Method == http.MethodHead
+		if conditional && matchesETag(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Del(ContentDigestHeader)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.WriteHeader(dw.status)
+	_, _ = w.Write(dw.body.Bytes())
+}
+
+func matchesETag(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,45 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseDigests(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	router, err := NewLoggingHTTPRouterWithOptions([]*Route{{
+		Method: "GET",
+		Path:   "/",
+		Function: func(ctx *ServiceMethodContext, arg *struct{}) (*struct{ A int }, error) {
+			return &struct{ A int }{1}, nil
+		},
+	}}, nil, buffer, &RouterOptions{ResponseDigests: &ResponseDigestOptions{ETag: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	sum := sha256.Sum256(recorder.Body.Bytes())
+	digest := "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+	if recorder.Code != 200 || recorder.Header().Get(ContentDigestHeader) != digest {
+		t.Error(recorder.Code, recorder.Header())
+	}
+
+	if !strings.Contains(buffer.String(), "digest=\""+digest+"\"") {
+		t.Error(buffer.String())
+	}
+
+	etag := recorder.Header().Get("ETag")
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("If-None-Match", "\"other\", "+etag)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, r)
+	if recorder.Code != 304 || recorder.Body.Len() != 0 || recorder.Header().Get("ETag") != etag {
+		t.Error(recorder.Code, recorder.Body.String(), recorder.Header())
+	}
+}
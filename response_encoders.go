@@ -0,0 +1,156 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ResponseEncoder encodes the response bodies of its media type.
+type ResponseEncoder interface {
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+}
+
+// EncoderRegistry selects the encoder by the Accept header of the request, JSON is used when nothing matches.
+type EncoderRegistry struct {
+	encoders map[string]ResponseEncoder
+}
+
+type JSONEncoder struct{}
+
+// XMLEncoder can't encode maps, the responses containing them fall back to JSON.
+type XMLEncoder struct{}
+
+// responseFormat is negotiated once per request, before anything of the response is written.
+type responseFormat struct {
+	envelope    int
+	encoder     ResponseEncoder
+	enveloper   ResponseEnveloper
+	wrapSuccess bool
+}
+
+var defaultResponseEncoder ResponseEncoder = JSONEncoder{}
+
+func (JSONEncoder) ContentType() string {
+	return "application/json"
+}
+
+func (JSONEncoder) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (XMLEncoder) ContentType() string {
+	return "application/xml"
+}
+
+func (XMLEncoder) Encode(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func NewEncoderRegistry() *EncoderRegistry {
+	return &EncoderRegistry{
+		encoders: map[string]ResponseEncoder{"application/json": defaultResponseEncoder},
+	}
+}
+
+// Register adds or replaces the encoder of the media type, like "application/msgpack".
+func (registry *EncoderRegistry) Register(mediaType string, encoder ResponseEncoder) {
+	registry.encoders[strings.ToLower(mediaType)] = encoder
+}
+
+// Negotiate returns the encoder preferred by the Accept header, JSON for a nil registry.
+func (registry *EncoderRegistry) Negotiate(accept string) ResponseEncoder {
+	if registry == nil || accept == "" {
+		return defaultResponseEncoder
+	}
+
+	for _, mediaType := range parseQualityList(strings.ToLower(accept)) {
+		if encoder, ok := registry.encoders[mediaType]; ok {
+			return encoder
+		}
+
+		// the wildcards are satisfied by JSON.
+		if mediaType == "*/*" || mediaType == "application/*" {
+			return defaultResponseEncoder
+		}
+	}
+
+	return defaultResponseEncoder
+}
+
+// SetEncoders sets the registry of the response encoders, only JSON is used if it is nil.
+func (h *ServiceHandler) SetEncoders(registry *EncoderRegistry) {
+	h.encoders = registry
+}
+
+func (h *ServiceHandler) negotiateResponseFormat(w http.ResponseWriter, r *http.Request) *responseFormat {
+	if h.encoders != nil {
+		w.Header().Add("Vary", "Accept")
+	}
+
+	format := &responseFormat{
+		envelope:    h.responseEnvelopeVersion(w, r),
+		encoder:     h.encoders.Negotiate(r.Header.Get("Accept")),
+		enveloper:   h.enveloper,
+		wrapSuccess: h.wrapSuccess,
+	}
+
+	if negotiated, ok := r.Context().Value(negotiatedFormatKey{}).(*negotiatedFormat); ok {
+		negotiated.format = format
+	}
+	return format
+}
+
+// negotiatedFormat shapes the errors of the decorators the same as those of the route.
+type negotiatedFormat struct {
+	format *responseFormat
+}
+
+type negotiatedFormatKey struct{}
+
+// withNegotiatedFormat returns the request recording the format of the route.
+func withNegotiatedFormat(r *http.Request) (*http.Request, *negotiatedFormat) {
+	negotiated := &negotiatedFormat{format: &responseFormat{envelope: EnvelopeV1, encoder: defaultResponseEncoder}}
+	return r.WithContext(context.WithValue(r.Context(), negotiatedFormatKey{}, negotiated)), negotiated
+}
+
+// writeEncodedResponse writes the status only if it is not zero, since the method may have written it.
+func writeEncodedResponse(w http.ResponseWriter, encoder ResponseEncoder, status int, data interface{}) {
+	if encoder == nil || encoder == defaultResponseEncoder {
+		setResponseHeader(w)
+		if status != 0 {
+			w.WriteHeader(status)
+		}
+
+		buffer := getJSONBuffer()
+		defer putJSONBuffer(buffer)
+		if buffer.encoder.Encode(data) == nil {
+			_, _ = w.Write(buffer.Bytes())
+		}
+		return
+	}
+
+	// the body is buffered so that it can still fall back to JSON if the encoding fails.
+	buffer := getJSONBuffer()
+	defer putJSONBuffer(buffer)
+	if err := encoder.Encode(buffer, data); err != nil {
+		writeEncodedResponse(w, defaultResponseEncoder, status, data)
+		return
+	}
+
+	setResponseHeader(w)
+	w.Header().Set("Content-Type", encoder.ContentType())
+	if status != 0 {
+		w.WriteHeader(status)
+	}
+
+	_, _ = w.Write(buffer.Bytes())
+}
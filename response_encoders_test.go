@@ -0,0 +1,83 @@
+package apihttpwrapper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+)
+
+type testingTextEncoder struct{}
+
+func (testingTextEncoder) ContentType() string {
+	return "text/plain"
+}
+
+func (testingTextEncoder) Encode(w io.Writer, v interface{}) error {
+	_, err := fmt.Fprintf(w, "%+v", v)
+	return err
+}
+
+func TestResponseEncoders(t *testing.T) {
+	type result struct {
+		A int `json:"a" xml:"a"`
+	}
+
+	encoders := NewEncoderRegistry()
+	encoders.Register("application/xml", XMLEncoder{})
+	encoders.Register("text/plain", testingTextEncoder{})
+	router, err := NewHTTPRouterWithOptions([]*Route{{
+		Method: "GET",
+		Path:   "/ok",
+		Function: func(*ServiceMethodContext, *struct{}) (*result, error) {
+			return &result{1}, nil
+		},
+	}, {
+		Method: "GET",
+		Path:   "/error",
+		Function: func(ctx *ServiceMethodContext, _ *struct{}) (*result, error) {
+			ctx.ResponseStatusSetter(400)
+			return nil, errors.New("bad")
+		},
+	}, {
+		Method: "GET",
+		Path:   "/map",
+		Function: func(*ServiceMethodContext, *struct{}) ([]map[string]int, error) {
+			return []map[string]int{{"a": 1}}, nil
+		},
+	}}, &RouterOptions{Encoders: encoders})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"/ok", "", "application/json", "{\"a\":1}\n"},
+		{"/ok", "text/html, application/xml;q=0.9, */*;q=0.1", "application/xml",
+			xmlHeader("<result><a>1</a></result>")},
+		{"/ok", "text/plain", "text/plain", "&{A:1}"},
+		{"/error", "application/xml", "application/xml",
+			xmlHeader("<FormattedResponse><code>400</code><msg>service method error</msg><data>bad</data>" +
+				"</FormattedResponse>")},
+		{"/map", "application/xml", "application/json", "[{\"a\":1}]\n"},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", c.path, nil)
+		r.Header.Set("Accept", c.accept)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		if recorder.Header().Get("Content-Type") != c.contentType || recorder.Body.String() != c.body {
+			t.Error(c.path, c.accept, recorder.Header().Get("Content-Type"), recorder.Body.String())
+		}
+	}
+}
+
+func xmlHeader(body string) string {
+	return "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" + body
+}
@@ -0,0 +1,49 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ResponseHeaderMapping copies the value of Metadata or ContextKey into the response header.
+type ResponseHeaderMapping struct {
+	Header string
+	// Metadata is the key of the request metadata, see Metadata.
+	Metadata string
+	// ContextKey is of a string or fmt.Stringer value.
+	ContextKey interface{}
+}
+
+// SetResponseHeaderMappings sets the values copied into the response headers before the service method is called.
+func (h *ServiceHandler) SetResponseHeaderMappings(mappings ...*ResponseHeaderMapping) {
+	h.headerMappings = mappings
+}
+
+func (m *ResponseHeaderMapping) value(ctx context.Context) string {
+	if m.Metadata != "" {
+		md, _ := MetadataFromContext(ctx)
+		return md.Get(m.Metadata)
+	}
+
+	if m.ContextKey == nil {
+		return ""
+	}
+
+	switch value := ctx.Value(m.ContextKey).(type) {
+	case string:
+		return value
+	case fmt.Stringer:
+		return value.String()
+	}
+
+	return ""
+}
+
+func (h *ServiceHandler) setMappedResponseHeaders(w http.ResponseWriter, ctx context.Context) {
+	for _, mapping := range h.headerMappings {
+		if value := mapping.value(ctx); value != "" {
+			w.Header().Set(mapping.Header, value)
+		}
+	}
+}
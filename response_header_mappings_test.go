@@ -0,0 +1,46 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type cacheStatusContextKey struct{}
+
+func TestResponseHeaderMappings(t *testing.T) {
+	router, err := NewHTTPRouterWithOptions([]*Route{{
+		Method: "GET",
+		Path:   "/",
+		Function: func(*ServiceMethodContext, *struct{}) error {
+			return nil
+		},
+	}}, &RouterOptions{ResponseHeaders: []*ResponseHeaderMapping{
+		{Header: "X-Tenant", Metadata: "tenant"},
+		{Header: "X-Region", Metadata: "region"},
+		{Header: "X-Cache-Status", ContextKey: cacheStatusContextKey{}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the cache status is set by a middleware in front of the router.
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), cacheStatusContextKey{}, "MISS")))
+	})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Md-Tenant", "acme")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, r)
+
+	header := recorder.Header()
+	if header.Get("X-Tenant") != "acme" || header.Get("X-Cache-Status") != "MISS" {
+		t.Error(header)
+	}
+
+	if _, ok := header["X-Region"]; ok {
+		t.Error(header)
+	}
+}
@@ -0,0 +1,203 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/net/trace"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+type ResponseSigner interface {
+	KeyID() string
+	Algorithm() string
+	Sign(body []byte) ([]byte, error)
+}
+
+type HMACSigner struct {
+	ID     string
+	Secret []byte
+}
+
+type AsymmetricSigner struct {
+	ID  string
+	Key crypto.Signer
+}
+
+// SigningKeyRing keeps the retired signers for the lookups until they are removed.
+type SigningKeyRing struct {
+	mu      sync.RWMutex
+	active  ResponseSigner
+	signers map[string]ResponseSigner
+}
+
+// ResponseSigningDecorator signs the buffered response bodies, so it can't stream.
+type ResponseSigningDecorator struct {
+	http.Handler
+	keyRing *SigningKeyRing
+	header  string
+}
+
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+const DefaultSignatureHeader = "X-Signature"
+
+func (s *HMACSigner) KeyID() string {
+	return s.ID
+}
+
+func (s *HMACSigner) Algorithm() string {
+	return "hmac-sha256"
+}
+
+func (s *HMACSigner) Sign(body []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.Secret)
+	_, _ = mac.Write(body)
+	return mac.Sum(nil), nil
+}
+
+// Verify checks the signature in constant time.
+func (s *HMACSigner) Verify(body []byte, signature []byte) bool {
+	expected, _ := s.Sign(body)
+	return hmac.Equal(expected, signature)
+}
+
+func (s *AsymmetricSigner) KeyID() string {
+	return s.ID
+}
+
+func (s *AsymmetricSigner) Algorithm() string {
+	switch s.Key.Public().(type) {
+	case ed25519.PublicKey:
+		return "ed25519"
+	case *rsa.PublicKey:
+		return "rsa-sha256"
+	default:
+		return "ecdsa-sha256"
+	}
+}
+
+func (s *AsymmetricSigner) Sign(body []byte) ([]byte, error) {
+	if _, ok := s.Key.Public().(ed25519.PublicKey); ok {
+		return s.Key.Sign(rand.Reader, body, crypto.Hash(0))
+	}
+
+	digest := sha256.Sum256(body)
+	return s.Key.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+func NewSigningKeyRing(active ResponseSigner) *SigningKeyRing {
+	return &SigningKeyRing{
+		active:  active,
+		signers: map[string]ResponseSigner{active.KeyID(): active},
+	}
+}
+
+// Rotate makes the signer active, the previous one is still retrievable by Signer() until Retire() is called.
+func (k *SigningKeyRing) Rotate(signer ResponseSigner) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.active = signer
+	k.signers[signer.KeyID()] = signer
+}
+
+func (k *SigningKeyRing) Retire(keyID string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.active.KeyID() == keyID {
+		return fmt.Errorf("can not retire the active key %q", keyID)
+	}
+
+	delete(k.signers, keyID)
+	return nil
+}
+
+func (k *SigningKeyRing) Active() ResponseSigner {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+func (k *SigningKeyRing) Signer(keyID string) (ResponseSigner, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	s, ok := k.signers[keyID]
+	return s, ok
+}
+
+// SignatureHeaderValue is like 'keyid="k1",algorithm="hmac-sha256",signature="..."'.
+func (k *SigningKeyRing) SignatureHeaderValue(body []byte) (string, error) {
+	signer := k.Active()
+	signature, err := signer.Sign(body)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("keyid=%s,algorithm=%s,signature=%s", strconv.Quote(signer.KeyID()),
+		strconv.Quote(signer.Algorithm()), strconv.Quote(base64.StdEncoding.EncodeToString(signature))), nil
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *bufferedResponseWriter) flush() error {
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.ResponseWriter.Write(w.body.Bytes())
+	return err
+}
+
+func NewResponseSigningDecorator(handler http.Handler, keyRing *SigningKeyRing,
+	header string) *ResponseSigningDecorator {
+	if header == "" {
+		header = DefaultSignatureHeader
+	}
+
+	return &ResponseSigningDecorator{
+		Handler: handler,
+		keyRing: keyRing,
+		header:  header,
+	}
+}
+
+func (d *ResponseSigningDecorator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bw := &bufferedResponseWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+	}
+
+	r, negotiated := withNegotiatedFormat(r)
+	d.Handler.ServeHTTP(bw, r)
+
+	value, err := d.keyRing.SignatureHeaderValue(bw.body.Bytes())
+	if err != nil {
+		// the error is shaped by the enveloper of the route too.
+		tracer := trace.New(traceFamily, r.URL.Path)
+		writeEnvelopedError(w, tracer, negotiated.format, &FormattedResponse{500, "sign response failed", err.Error()})
+		tracer.Finish()
+		return
+	}
+
+	w.Header().Set(d.header, value)
+	_ = bw.flush()
+}
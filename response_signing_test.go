@@ -0,0 +1,173 @@
+package apihttpwrapper
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type failingSigner struct{}
+
+func (failingSigner) KeyID() string {
+	return "broken"
+}
+
+func (failingSigner) Algorithm() string {
+	return "hmac-sha256"
+}
+
+func (failingSigner) Sign(body []byte) ([]byte, error) {
+	return nil, errors.New("hsm unavailable")
+}
+
+// parseSignatureHeader parses 'keyid="k1",algorithm="hmac-sha256",signature="..."'.
+func parseSignatureHeader(t *testing.T, value string) map[string]string {
+	fields := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			t.Fatal(value)
+		}
+
+		unquoted, err := strconv.Unquote(kv[1])
+		if err != nil {
+			t.Fatal(value, err)
+		}
+		fields[kv[0]] = unquoted
+	}
+
+	return fields
+}
+
+func TestResponseSigners(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	hmacSigner := &HMACSigner{ID: "h1", Secret: []byte("secret")}
+	signature, err := hmacSigner.Sign(body)
+	if err != nil || !hmacSigner.Verify(body, signature) || hmacSigner.Verify([]byte(`{"id":2}`), signature) ||
+		(&HMACSigner{Secret: []byte("other")}).Verify(body, signature) {
+		t.Error("the hmac signature is not verified", err)
+	}
+
+	_, ed25519Key, _ := ed25519.GenerateKey(rand.Reader)
+	signer := &AsymmetricSigner{ID: "e1", Key: ed25519Key}
+	signature, err = signer.Sign(body)
+	if err != nil || signer.Algorithm() != "ed25519" ||
+		!ed25519.Verify(ed25519Key.Public().(ed25519.PublicKey), body, signature) {
+		t.Error("the ed25519 signature is not verified", err)
+	}
+
+	digest := sha256.Sum256(body)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer = &AsymmetricSigner{ID: "r1", Key: rsaKey}
+	signature, err = signer.Sign(body)
+	if err != nil || signer.Algorithm() != "rsa-sha256" ||
+		rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature) != nil {
+		t.Error("the rsa signature is not verified", err)
+	}
+
+	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	signer = &AsymmetricSigner{ID: "c1", Key: ecdsaKey}
+	signature, err = signer.Sign(body)
+	var rs struct{ R, S *big.Int }
+	if _, asn1Err := asn1.Unmarshal(signature, &rs); err != nil || asn1Err != nil ||
+		signer.Algorithm() != "ecdsa-sha256" || !ecdsa.Verify(&ecdsaKey.PublicKey, digest[:], rs.R, rs.S) {
+		t.Error("the ecdsa signature is not verified", err)
+	}
+}
+
+func TestSigningKeyRing(t *testing.T) {
+	first, second := &HMACSigner{ID: "k1", Secret: []byte("1")}, &HMACSigner{ID: "k2", Secret: []byte("2")}
+	keyRing := NewSigningKeyRing(first)
+	keyRing.Rotate(second)
+	if keyRing.Active() != second {
+		t.Error("the rotated key is not active", keyRing.Active().KeyID())
+	}
+
+	if s, ok := keyRing.Signer("k1"); !ok || s != first {
+		t.Error("the previous key is not kept while rotating")
+	}
+
+	if err := keyRing.Retire("k2"); err == nil {
+		t.Error("the active key is retired")
+	}
+
+	if err := keyRing.Retire("k1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := keyRing.Signer("k1"); ok {
+		t.Error("the retired key is still retrievable")
+	}
+
+	value, err := keyRing.SignatureHeaderValue([]byte("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := parseSignatureHeader(t, value)
+	signature, _ := base64.StdEncoding.DecodeString(fields["signature"])
+	if fields["keyid"] != "k2" || fields["algorithm"] != "hmac-sha256" || !second.Verify([]byte("body"), signature) {
+		t.Error(value)
+	}
+}
+
+func TestResponseSigningDecorator(t *testing.T) {
+	function := func(ctx *ServiceMethodContext, arg *struct{}) (*struct{ A int }, error) {
+		ctx.ResponseStatusSetter(201)
+		return &struct{ A int }{1}, nil
+	}
+
+	router, err := NewHTTPRouter([]*Route{{Method: "GET", Path: "/items", Function: function},
+		{Method: "GET", Path: "/callback", Function: function, Enveloper: partnerEnveloper{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := &HMACSigner{ID: "k1", Secret: []byte("secret")}
+	keyRing := NewSigningKeyRing(signer)
+	decorator := NewResponseSigningDecorator(router, keyRing, "")
+	w := httptest.NewRecorder()
+	decorator.ServeHTTP(w, httptest.NewRequest("GET", "/items", nil))
+	fields := parseSignatureHeader(t, w.Header().Get(DefaultSignatureHeader))
+	signature, _ := base64.StdEncoding.DecodeString(fields["signature"])
+	if w.Code != 201 || w.Body.String() != "{\"A\":1}\n" || fields["keyid"] != "k1" ||
+		!signer.Verify(w.Body.Bytes(), signature) {
+		t.Error(w.Code, w.Body.String(), w.Header())
+	}
+
+	// the signing failures are shaped by the enveloper of the route.
+	keyRing.Rotate(failingSigner{})
+	cases := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/items", 500, "{\"code\":500,\"msg\":\"sign response failed\",\"data\":\"hsm unavailable\"}\n"},
+		{"/callback", 200, "{\"errcode\":500,\"errmsg\":\"sign response failed\"}\n"},
+		{"/missing", 500, "{\"code\":500,\"msg\":\"sign response failed\",\"data\":\"hsm unavailable\"}\n"},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		decorator.ServeHTTP(w, httptest.NewRequest("GET", c.path, nil))
+		if w.Code != c.status || w.Body.String() != c.body || w.Header().Get(DefaultSignatureHeader) != "" {
+			t.Error(c.path, w.Code, w.Body.String(), w.Header())
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package apihttpwrapper
+
+import (
+	"fmt"
+	mrand "math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryAfterError makes the handler respond with the retry hint headers.
+type RetryAfterError struct {
+	// Status is 429 by default, 503 fits the load shedding better.
+	Status int
+	// After is when the limiter state resets, like the refill time of a token bucket.
+	After time.Duration
+	Err   error
+}
+
+// retryJitterFraction spreads the retries of the clients rejected together.
+const retryJitterFraction = 0.1
+
+func (e *RetryAfterError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+
+	return fmt.Sprintf("retry after %s", e.After)
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
+
+// setRetryHeaders sets X-RateLimit-Reset and the jittered Retry-After.
+func setRetryHeaders(h http.Header, reset time.Duration) {
+	if reset < 0 {
+		reset = 0
+	}
+
+	seconds := ceilSeconds(reset)
+	jitter := int64(float64(seconds) * retryJitterFraction)
+	if jitter < 1 {
+		jitter = 1
+	}
+
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(seconds, 10))
+	h.Set("Retry-After", strconv.FormatInt(seconds+mrand.Int63n(jitter+1), 10))
+}
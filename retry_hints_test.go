@@ -0,0 +1,31 @@
+package apihttpwrapper
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRetryAfterError(t *testing.T) {
+	router, err := NewHTTPRouter([]*Route{{
+		Method: "GET",
+		Path:   "/",
+		Function: func(*ServiceMethodContext, *struct{}) error {
+			return &RetryAfterError{After: 20 * time.Second}
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+		retryAfter, _ := strconv.Atoi(recorder.Header().Get("Retry-After"))
+		if recorder.Code != 429 || recorder.Header().Get("X-RateLimit-Reset") != "20" ||
+			retryAfter < 20 || retryAfter > 22 {
+			t.Error(recorder.Code, recorder.Header())
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	RouteAdded   = "added"
+	RouteRemoved = "removed"
+	RouteChanged = "changed"
+)
+
+// RouteChange is a route added, removed or changed between the route tables, see DiffRoutes.
+type RouteChange struct {
+	Kind   string `json:"kind"`
+	Method string `json:"method"`
+	// Path is in the OpenAPI form, like "/user/{Name}".
+	Path     string          `json:"path"`
+	Changes  []*SchemaChange `json:"changes,omitempty"`
+	Breaking bool            `json:"breaking"`
+}
+
+// SchemaChange is a change of a field, like "request.body.items[].name".
+type SchemaChange struct {
+	Field    string `json:"field"`
+	Message  string `json:"message"`
+	Breaking bool   `json:"breaking"`
+}
+
+type RouteDiff []*RouteChange
+
+// Breaking tells whether any change may break the existing clients, which the deployment pipelines should stop on.
+func (d RouteDiff) Breaking() bool {
+	for _, change := range d {
+		if change.Breaking {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d RouteDiff) String() string {
+	var lines []string
+	for _, change := range d {
+		mark := ""
+		if change.Breaking {
+			mark = " (breaking)"
+		}
+
+		lines = append(lines, fmt.Sprintf("%s %s %s%s", change.Kind, strings.ToUpper(change.Method), change.Path, mark))
+		for _, c := range change.Changes {
+			mark = ""
+			if c.Breaking {
+				mark = " (breaking)"
+			}
+			lines = append(lines, fmt.Sprintf("  %s: %s%s", c.Field, c.Message, mark))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// DiffRoutes compares the route tables by their OpenAPI documents.
+func DiffRoutes(before []*Route, after []*Route) (RouteDiff, error) {
+	beforeDoc, err := GenerateOpenAPI("", "", before)
+	if err != nil {
+		return nil, err
+	}
+
+	afterDoc, err := GenerateOpenAPI("", "", after)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffOpenAPI(beforeDoc, afterDoc), nil
+}
+
+// ReadOpenAPI reads the document written by OpenAPIDocument.ServeHTTP or json.Marshal, like a stored manifest.
+func ReadOpenAPI(r io.Reader) (*OpenAPIDocument, error) {
+	doc := &OpenAPIDocument{}
+	if err := json.NewDecoder(r).Decode(doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// DiffOpenAPI reports the changes in the order of the paths and the methods.
+func DiffOpenAPI(before *OpenAPIDocument, after *OpenAPIDocument) RouteDiff {
+	keys := map[[2]string]bool{}
+	for path, ops := range before.Paths {
+		for method := range ops {
+			keys[[2]string{path, method}] = true
+		}
+	}
+	for path, ops := range after.Paths {
+		for method := range ops {
+			keys[[2]string{path, method}] = true
+		}
+	}
+
+	sorted := make([][2]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i][0] != sorted[j][0] {
+			return sorted[i][0] < sorted[j][0]
+		}
+		return sorted[i][1] < sorted[j][1]
+	})
+
+	var diff RouteDiff
+	for _, key := range sorted {
+		beforeOp, afterOp := before.Paths[key[0]][key[1]], after.Paths[key[0]][key[1]]
+		change := &RouteChange{Method: key[1], Path: key[0]}
+		switch {
+		case beforeOp == nil:
+			change.Kind = RouteAdded
+		case afterOp == nil:
+			change.Kind, change.Breaking = RouteRemoved, true
+		default:
+			d := &schemaDiff{before: before, after: after, visited: map[[2]*OpenAPISchema]bool{}}
+			d.operation(beforeOp, afterOp)
+			if len(d.changes) == 0 {
+				continue
+			}
+
+			change.Kind, change.Changes = RouteChanged, d.changes
+			for _, c := range d.changes {
+				change.Breaking = change.Breaking || c.Breaking
+			}
+		}
+
+		diff = append(diff, change)
+	}
+
+	return diff
+}
+
+type schemaDiff struct {
+	before  *OpenAPIDocument
+	after   *OpenAPIDocument
+	visited map[[2]*OpenAPISchema]bool
+	changes []*SchemaChange
+}
+
+func (d *schemaDiff) add(field string, breaking bool, format string, args ...interface{}) {
+	d.changes = append(d.changes, &SchemaChange{Field: field, Message: fmt.Sprintf(format, args...),
+		Breaking: breaking})
+}
+
+func (d *schemaDiff) operation(before *OpenAPIOperation, after *OpenAPIOperation) {
+	beforeParams := map[string]*OpenAPIParameter{}
+	for _, p := range before.Parameters {
+		beforeParams[p.In+"."+p.Name] = p
+	}
+
+	for _, p := range after.Parameters {
+		field := "request." + p.In + "." + p.Name
+		o, ok := beforeParams[p.In+"."+p.Name]
+		delete(beforeParams, p.In+"."+p.Name)
+		if !ok {
+			d.add(field, p.Required, "added")
+			continue
+		}
+
+		if p.Required && !o.Required {
+			d.add(field, true, "becomes required")
+		}
+		d.schema(field, o.Schema, p.Schema, true)
+	}
+
+	// the unknown parameters are ignored by the binding.
+	removed := make([]string, 0, len(beforeParams))
+	for key := range beforeParams {
+		removed = append(removed, key)
+	}
+	sort.Strings(removed)
+	for _, key := range removed {
+		d.add("request."+key, false, "removed")
+	}
+
+	switch {
+	case before.RequestBody == nil && after.RequestBody != nil:
+		d.add("request.body", true, "added")
+	case before.RequestBody != nil && after.RequestBody == nil:
+		d.add("request.body", true, "removed")
+	case before.RequestBody != nil:
+		d.content("request.body", before.RequestBody.Content, after.RequestBody.Content, true)
+	}
+
+	beforeResp, afterResp := before.Responses["200"], after.Responses["200"]
+	if beforeResp != nil && afterResp != nil {
+		d.content("response", beforeResp.Content, afterResp.Content, false)
+	}
+}
+
+func (d *schemaDiff) content(field string, before map[string]*OpenAPIMediaType, after map[string]*OpenAPIMediaType,
+	request bool) {
+	types := make([]string, 0, len(before))
+	for contentType := range before {
+		types = append(types, contentType)
+	}
+	sort.Strings(types)
+
+	for _, contentType := range types {
+		n, ok := after[contentType]
+		if !ok {
+			d.add(field, true, "content type %s removed", contentType)
+			continue
+		}
+		d.schema(field, before[contentType].Schema, n.Schema, request)
+	}
+
+	for contentType := range after {
+		if _, ok := before[contentType]; !ok {
+			d.add(field, false, "content type %s added", contentType)
+		}
+	}
+}
+
+func resolveSchema(doc *OpenAPIDocument, schema *OpenAPISchema) *OpenAPISchema {
+	for schema != nil && schema.Ref != "" && doc.Components != nil {
+		schema = doc.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+
+	return schema
+}
+
+func schemaType(schema *OpenAPISchema) string {
+	if schema.Format != "" {
+		return schema.Type + "(" + schema.Format + ")"
+	}
+	if schema.Type == "" {
+		return "any"
+	}
+	return schema.Type
+}
+
+// schema compares the shapes of the field.
+func (d *schemaDiff) schema(field string, before *OpenAPISchema, after *OpenAPISchema, request bool) {
+	before, after = resolveSchema(d.before, before), resolveSchema(d.after, after)
+	if before == nil || after == nil || d.visited[[2]*OpenAPISchema{before, after}] {
+		return
+	}
+	d.visited[[2]*OpenAPISchema{before, after}] = true
+
+	if before.Type != "" && schemaType(before) != schemaType(after) {
+		d.add(field, true, "type changed from %s to %s", schemaType(before), schemaType(after))
+		return
+	}
+
+	if before.Items != nil && after.Items != nil {
+		d.schema(field+"[]", before.Items, after.Items, request)
+	}
+
+	if before.AdditionalProperties != nil && after.AdditionalProperties != nil {
+		d.schema(field+"{}", before.AdditionalProperties, after.AdditionalProperties, request)
+	}
+
+	beforeRequired, afterRequired := map[string]bool{}, map[string]bool{}
+	for _, name := range before.Required {
+		beforeRequired[name] = true
+	}
+	for _, name := range after.Required {
+		afterRequired[name] = true
+	}
+
+	names := make([]string, 0, len(before.Properties)+len(after.Properties))
+	for name := range before.Properties {
+		names = append(names, name)
+	}
+	for name := range after.Properties {
+		if _, ok := before.Properties[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		o, n := before.Properties[name], after.Properties[name]
+		path := field + "." + name
+		switch {
+		case o == nil:
+			d.add(path, request && afterRequired[name], "added")
+		case n == nil:
+			d.add(path, !request, "removed")
+		default:
+			if request && afterRequired[name] && !beforeRequired[name] {
+				d.add(path, true, "becomes required")
+			}
+			d.schema(path, o, n, request)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type diffItem struct {
+	Name  string    `json:"name"`
+	Price int       `json:"price"`
+	Next  *diffItem `json:"next"`
+}
+
+type diffItemV2 struct {
+	Name  string      `json:"name"`
+	Price string      `json:"price"`
+	Next  *diffItemV2 `json:"next"`
+}
+
+func TestDiffRoutes(t *testing.T) {
+	before := []*Route{
+		{Method: "GET", Path: "/items", Function: func(ctx *ServiceMethodContext, arg *struct {
+			Page int    `schema:"page"`
+			Sort string `schema:"sort"`
+		}) ([]*diffItem, error) {
+			return nil, nil
+		}},
+		{Method: "POST", Path: "/items", Function: func(ctx *ServiceMethodContext, arg *struct {
+			Name string `json:"name"`
+			Tags string `json:"tags"`
+		}) (*struct {
+			ID    int `json:"id"`
+			Extra int `json:"extra"`
+		}, error) {
+			return nil, nil
+		}},
+		{Method: "DELETE", Path: "/items/:id", Function: func(ctx *ServiceMethodContext, arg *struct{}) error {
+			return nil
+		}},
+	}
+
+	after := []*Route{
+		{Method: "GET", Path: "/items", Function: func(ctx *ServiceMethodContext, arg *struct {
+			Page int `schema:"page"`
+		}) ([]*diffItemV2, error) {
+			return nil, nil
+		}},
+		{Method: "POST", Path: "/items", Function: func(ctx *ServiceMethodContext, arg *struct {
+			Name  string `json:"name"`
+			Owner string `json:"owner" validate:"required"`
+			Note  string `json:"note"`
+		}) (*struct {
+			ID      int `json:"id"`
+			Created int `json:"created"`
+		}, error) {
+			return nil, nil
+		}},
+		{Method: "GET", Path: "/items/:id", Function: func(ctx *ServiceMethodContext, arg *struct{}) error {
+			return nil
+		}},
+	}
+
+	diff, err := DiffRoutes(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type change struct {
+		kind     string
+		method   string
+		path     string
+		breaking bool
+		fields   []string
+	}
+	var changes []change
+	for _, c := range diff {
+		var fields []string
+		for _, sc := range c.Changes {
+			fields = append(fields, sc.Field+":"+sc.Message)
+		}
+		changes = append(changes, change{c.Kind, c.Method, c.Path, c.Breaking, fields})
+	}
+
+	expected := []change{
+		{"changed", "get", "/items", true, []string{
+			"request.query.sort:removed",
+			"response[].price:type changed from integer(int32) to string",
+		}},
+		{"changed", "post", "/items", true, []string{
+			"request.body.note:added",
+			"request.body.owner:added",
+			"request.body.tags:removed",
+			"response.created:added",
+			"response.extra:removed",
+		}},
+		{"removed", "delete", "/items/{id}", true, nil},
+		{"added", "get", "/items/{id}", false, nil},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("%+v\n%s", changes, diff)
+	}
+
+	breaking := map[string]bool{}
+	for _, sc := range diff[1].Changes {
+		breaking[sc.Field] = sc.Breaking
+	}
+	if !reflect.DeepEqual(breaking, map[string]bool{"request.body.note": false, "request.body.owner": true,
+		"request.body.tags": false, "response.created": false, "response.extra": true}) {
+		t.Error(breaking)
+	}
+
+	doc, err := GenerateOpenAPI("", "", before)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, _ := json.Marshal(doc)
+	stored, err := ReadOpenAPI(bytes.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current, _ := GenerateOpenAPI("", "", before)
+	if diff := DiffOpenAPI(stored, current); len(diff) != 0 || diff.Breaking() {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,59 @@
+package apihttpwrapper
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strings"
+)
+
+// RouteGroup mounts the routes under the prefix with the shared settings.
+type RouteGroup struct {
+	// Prefix is prepended to the paths of the routes, the trailing '/' is ignored.
+	Prefix string
+	// Middlewares are inside the RouterOptions.Middlewares and outside the Route.Middlewares.
+	Middlewares []func(http.Handler) http.Handler
+	Routes      []*Route
+	// BypassRequestBody is the default of the routes, see Route.BindRequestBody.
+	BypassRequestBody bool
+	// LoggerContextKey overrides the key passed to RegisterRouteGroups if not nil.
+	LoggerContextKey interface{}
+}
+
+// groupRoute returns the copy of rt having the settings of the group.
+func (g *RouteGroup) groupRoute(rt *Route) *Route {
+	if rt == nil {
+		return nil
+	}
+
+	grouped := *rt
+	grouped.Path = strings.TrimSuffix(g.Prefix, "/") + rt.Path
+	grouped.BypassRequestBody = rt.BypassRequestBody || (g.BypassRequestBody && !rt.BindRequestBody)
+	grouped.Middlewares = make([]func(http.Handler) http.Handler, 0, len(g.Middlewares)+len(rt.Middlewares))
+	grouped.Middlewares = append(append(grouped.Middlewares, g.Middlewares...), rt.Middlewares...)
+	return &grouped
+}
+
+// RegisterRouteGroups validates the routes of all groups together.
+func RegisterRouteGroups(r *httprouter.Router, loggerContextKey interface{}, groups []*RouteGroup,
+	options *RouterOptions) error {
+	var routes []*Route
+	var keys []interface{}
+	for i, g := range groups {
+		if g == nil {
+			return fmt.Errorf("route group #%d is nil", i)
+		}
+
+		key := loggerContextKey
+		if g.LoggerContextKey != nil {
+			key = g.LoggerContextKey
+		}
+
+		for _, rt := range g.Routes {
+			routes = append(routes, g.groupRoute(rt))
+			keys = append(keys, key)
+		}
+	}
+
+	return registerRoutes(r, keys, routes, options)
+}
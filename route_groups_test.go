@@ -0,0 +1,84 @@
+package apihttpwrapper
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouteGroups(t *testing.T) {
+	var bypassed string
+	method := func(ctx *ServiceMethodContext, arg *struct{ A int }) (*struct{ A int }, error) {
+		body, _ := ioutil.ReadAll(ctx.RequestBodyReader)
+		bypassed = string(body)
+		return arg, nil
+	}
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(401)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	router := httprouter.New()
+	err := RegisterRouteGroups(router, ServiceHandlerAccessLogRowFillerContextKey, []*RouteGroup{{
+		Prefix: "/v1/",
+		Routes: []*Route{{Method: "POST", Path: "/items", Function: method}},
+	}, {
+		Prefix:            "/v2",
+		Middlewares:       []func(http.Handler) http.Handler{auth},
+		BypassRequestBody: true,
+		Routes: []*Route{{Method: "POST", Path: "/items", Function: method},
+			{Method: "POST", Path: "/bound", Function: method, BindRequestBody: true}},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path     string
+		auth     string
+		status   int
+		bypassed string
+	}{
+		{"/v1/items", "", 200, ""},
+		{"/v2/items", "", 401, ""},
+		{"/v2/items", "token", 200, "{\"A\":1}"},
+		{"/v2/bound", "token", 200, ""},
+		{"/items", "token", 404, ""},
+	}
+
+	for _, c := range cases {
+		bypassed = ""
+		r := httptest.NewRequest("POST", c.path, strings.NewReader("{\"A\":1}"))
+		r.Header.Set("Content-Type", "application/json")
+		if c.auth != "" {
+			r.Header.Set("Authorization", c.auth)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		if recorder.Code != c.status || bypassed != c.bypassed {
+			t.Error(c, recorder.Code, bypassed)
+		}
+	}
+
+	err = RegisterRouteGroups(httprouter.New(), nil, []*RouteGroup{
+		{Prefix: "/v1", Routes: []*Route{{Method: "POST", Path: "/items", Function: method}}},
+		{Prefix: "/v1/", Routes: []*Route{{Method: "POST", Path: "/items", Function: method}}},
+	}, nil)
+	if errs, ok := err.(RouteErrors); !ok || len(errs) != 1 || errs[0].Index != 1 {
+		t.Error(err)
+	}
+
+	if err := ValidateRoutes([]*Route{{Method: "POST", Path: "/items", Function: method, BypassRequestBody: true,
+		BindRequestBody: true}}); err == nil {
+		t.Error("the route both bypassing and binding the body is accepted")
+	}
+}
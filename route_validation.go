@@ -0,0 +1,197 @@
+package apihttpwrapper
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// RouteError names the route which can't be registered.
+type RouteError struct {
+	// Index is the position of the route in the slice.
+	Index int
+	Route *Route
+	Err   error
+}
+
+func (e *RouteError) Error() string {
+	if e.Route == nil {
+		return fmt.Sprintf("route #%d: %s", e.Index, e.Err)
+	}
+
+	return fmt.Sprintf("route #%d %s %s: %s", e.Index, e.Route.Method, e.Route.Path, e.Err)
+}
+
+// RouteErrors are all the problems of the routes found by ValidateRoutes.
+type RouteErrors []*RouteError
+
+func (e RouteErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, re := range e {
+		messages = append(messages, re.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+var noopHandle httprouter.Handle = func(http.ResponseWriter, *http.Request, httprouter.Params) {}
+
+// handleRoute registers the handle, the panics of httprouter on the conflicting patterns are returned as errors.
+func handleRoute(r *httprouter.Router, method string, path string, handle httprouter.Handle) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+
+	r.Handle(method, path, handle)
+	return nil
+}
+
+// ValidateRoutes checks all the routes before registering any, the error is RouteErrors.
+func ValidateRoutes(routes []*Route) error {
+	var errs RouteErrors
+	fail := func(i int, rt *Route, format string, args ...interface{}) {
+		errs = append(errs, &RouteError{Index: i, Route: rt, Err: fmt.Errorf(format, args...)})
+	}
+
+	scratch := httprouter.New()
+	registered := make(map[[2]string]int)
+	preflighted := make(map[string]bool)
+	for i, rt := range routes {
+		if rt == nil {
+			fail(i, rt, "the route is nil")
+			continue
+		}
+
+		if rt.Method == "" {
+			fail(i, rt, "the method is empty")
+		}
+
+		if !strings.HasPrefix(rt.Path, "/") {
+			fail(i, rt, "the path should begin with '/'")
+			continue
+		}
+
+		if err := checkRoutePrototype(rt); err != nil {
+			fail(i, rt, "%s", err)
+		}
+
+		if rt.BypassRequestBody && rt.BindRequestBody {
+			fail(i, rt, "the request body can't be both bypassed and bound")
+		}
+
+		if rt.Canary != nil {
+			if err := checkServiceMethodPrototype(reflect.TypeOf(rt.Canary.Function)); err != nil {
+				fail(i, rt, "canary: %s", err)
+			}
+		}
+
+		if rt.Timeout < 0 || (rt.Timeout > 0 && rt.EventStream != nil) {
+			fail(i, rt, "the timeout should be positive and not of the event stream routes")
+		}
+
+		if rt.WriteTimeout < 0 || (rt.WriteTimeout > 0 && rt.EventStream != nil) {
+			fail(i, rt, "the write timeout should be positive and not of the event stream routes")
+		}
+
+		if rt.RateLimit != nil && rt.RateLimit.Rate <= 0 {
+			fail(i, rt, "the rate of the rate limit should be positive")
+		}
+
+		if rt.ConcurrencyLimit != nil && (rt.ConcurrencyLimit.MaxConcurrent < 1 || rt.EventStream != nil) {
+			fail(i, rt, "the concurrency limit should be positive and not of the event stream routes")
+		}
+
+		if rt.Destructive != nil && (len(rt.Destructive.Secret) == 0 || rt.EventStream != nil) {
+			fail(i, rt, "the destructive guard should have a secret and not be of the event stream routes")
+		}
+
+		if rt.Undo != nil && (rt.Undo.Guard == nil || len(rt.Undo.Guard.Secret) == 0 || rt.Undo.Method == "" ||
+			rt.Undo.Path == "" || rt.EventStream != nil) {
+			fail(i, rt, "the undo route should have a guard with a secret and the destructive method and path")
+		}
+
+		if rt.Deduplication != nil && rt.EventStream != nil {
+			fail(i, rt, "the deduplication is not of the event stream routes")
+		}
+
+		if rt.EventStream != nil && (rt.Canary != nil || rt.Compatibility != nil) {
+			fail(i, rt, "the canary and the compatibility rules are not of the event stream routes")
+		}
+
+		if rt.Fallback != nil && (rt.EventStream != nil || rt.WebSocket != nil) {
+			fail(i, rt, "the fallback is not of the event stream and websocket routes")
+		}
+
+		if rt.Fake != nil && (rt.EventStream != nil || rt.WebSocket != nil) {
+			fail(i, rt, "the fake response is not of the event stream and websocket routes")
+		}
+
+		if rt.CORS != nil {
+			if err := rt.CORS.Check(); err != nil {
+				fail(i, rt, "%s", err)
+			}
+		}
+
+		if rt.WebSocket != nil && (strings.ToUpper(rt.Method) != "GET" || rt.EventStream != nil || rt.Canary != nil ||
+			rt.Timeout != 0 || rt.WriteTimeout != 0 || rt.Destructive != nil || rt.Undo != nil ||
+			rt.Deduplication != nil || rt.Preflight || rt.ConcurrencyLimit != nil || rt.Compatibility != nil) {
+			fail(i, rt, "the websocket route should be GET, and has not the timeouts, the canary, the preflight, the "+
+				"concurrency limit and the compatibility rules, and is not an event stream, destructive, undo or "+
+				"deduplicated route")
+		}
+
+		key := [2]string{strings.ToUpper(rt.Method), rt.Path}
+		if first, ok := registered[key]; ok {
+			fail(i, rt, "duplicates route #%d", first)
+			continue
+		}
+		registered[key] = i
+
+		if rt.Method == "" {
+			continue
+		}
+
+		if err := handleRoute(scratch, rt.Method, rt.Path, noopHandle); err != nil {
+			fail(i, rt, "%s", err)
+		}
+
+		if rt.Preflight {
+			validatePreflight(i, rt, registered, preflighted, scratch, fail)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// validatePreflight checks the OPTIONS handle shared by the preflight routes of the path.
+func validatePreflight(i int, rt *Route, registered map[[2]string]int, preflighted map[string]bool,
+	scratch *httprouter.Router, fail func(i int, rt *Route, format string, args ...interface{})) {
+	if rt.EventStream != nil {
+		fail(i, rt, "the event stream routes can't be preflighted")
+		return
+	}
+
+	if preflighted[rt.Path] {
+		return
+	}
+	preflighted[rt.Path] = true
+
+	key := [2]string{http.MethodOptions, rt.Path}
+	if first, ok := registered[key]; ok {
+		fail(i, rt, "the preflight duplicates route #%d", first)
+		return
+	}
+	registered[key] = i
+
+	if err := handleRoute(scratch, http.MethodOptions, rt.Path, noopHandle); err != nil {
+		fail(i, rt, "preflight: %s", err)
+	}
+}
@@ -0,0 +1,53 @@
+package apihttpwrapper
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"strings"
+	"testing"
+)
+
+func TestValidateRoutes(t *testing.T) {
+	method := func(*ServiceMethodContext, *struct{}) error { return nil }
+	routes := []*Route{
+		{Method: "GET", Path: "/user/:id", Function: method},
+		{Method: "GET", Path: "/user/:id", Function: method},
+		{Method: "GET", Path: "/user/:name/posts", Function: method},
+		{Method: "GET", Path: "/post", Function: func() {}},
+		{Method: "get", Path: "post", Function: method},
+		{Method: "POST", Path: "/user/:name", Function: method},
+	}
+
+	err := ValidateRoutes(routes)
+	errs, ok := err.(RouteErrors)
+	if !ok || len(errs) != 4 {
+		t.Fatal(err)
+	}
+
+	expects := []struct {
+		index   int
+		message string
+	}{
+		{1, "duplicates route #0"},
+		{2, "conflicts with existing wildcard"},
+		{3, "two arguments"},
+		{4, "should begin with '/'"},
+	}
+	for i, expect := range expects {
+		if errs[i].Index != expect.index || errs[i].Route != routes[expect.index] ||
+			!strings.Contains(errs[i].Error(), expect.message) {
+			t.Error(errs[i])
+		}
+	}
+
+	router := httprouter.New()
+	err = RegisterRoutes(router, nil, routes)
+	if handle, _, _ := router.Lookup("GET", "/user/1"); err == nil || handle != nil {
+		t.Error("the routes are partly registered", err)
+	}
+
+	router.GET("/existing/:id", noopHandle)
+	err = RegisterRoutes(router, nil, []*Route{{Method: "GET", Path: "/existing/new", Function: method}})
+	if _, ok := err.(*RouteError); !ok {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,220 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ScheduledJob invokes a service method on the cron schedule.
+type ScheduledJob struct {
+	Name string
+	// Schedule is a standard cron spec of 5 fields, or a descriptor like "@hourly" and "@every 1m".
+	Schedule string
+	Function interface{}
+	// Argument is copied into the argument of each run through json, the zero value if nil.
+	Argument interface{}
+	// Timeout cancels the ServiceMethodContext.Context of each run, unlimited if 0.
+	Timeout time.Duration
+	// LogRedactedFields and MaxLoggedBytes are of the logged resp, like those of Route.
+	LogRedactedFields []string
+	MaxLoggedBytes    int
+}
+
+// Scheduler runs the ScheduledJobs and logs each run as a row, the overlapping runs are skipped.
+type Scheduler struct {
+	cron      *cron.Cron
+	logger    *logrus.Logger
+	panicHook PanicHook
+
+	mutex sync.Mutex
+	jobs  map[string]*scheduledJob
+}
+
+type scheduledJob struct {
+	name string
+	// handler is only for the method and the logging settings, the jobs are not served by it.
+	handler  *ServiceHandler
+	argument []byte
+	timeout  time.Duration
+	running  int32
+}
+
+func NewScheduler(logWriter io.Writer) *Scheduler {
+	logger := logrus.New()
+	logger.Formatter = &logrus.TextFormatter{DisableTimestamp: true}
+	logger.Out = logWriter
+	return &Scheduler{
+		cron:   cron.New(),
+		logger: logger,
+		jobs:   make(map[string]*scheduledJob),
+	}
+}
+
+// SetPanicHook sets the callback of the panics of the jobs, the request passed to it is nil.
+func (s *Scheduler) SetPanicHook(hook PanicHook) {
+	s.panicHook = hook
+}
+
+// SetEncoder sets the format of the rows, see AccessLogEncoder.
+func (s *Scheduler) SetEncoder(encoder AccessLogEncoder) {
+	s.logger.Formatter = &accessLogFormatter{encoder}
+}
+
+func (s *Scheduler) Add(job *ScheduledJob) error {
+	if job.Name == "" {
+		return fmt.Errorf("the job has no name")
+	}
+
+	methodType := reflect.TypeOf(job.Function)
+	if err := checkServiceMethodPrototype(methodType); err != nil {
+		return fmt.Errorf("job %s: %s", job.Name, err)
+	}
+
+	j := &scheduledJob{
+		name:    job.Name,
+		handler: newServiceHandler(job.Function, nil, true),
+		timeout: job.Timeout,
+	}
+	j.handler.SetLogRedactedFields(job.LogRedactedFields...)
+	j.handler.SetMaxLoggedBytes(job.MaxLoggedBytes)
+
+	if job.Argument != nil {
+		argument, err := json.Marshal(job.Argument)
+		if err != nil {
+			return fmt.Errorf("job %s: %s", job.Name, err)
+		}
+		j.argument = argument
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("duplicated job %s", job.Name)
+	}
+
+	if _, err := s.cron.AddFunc(job.Schedule, func() { s.run(j) }); err != nil {
+		return fmt.Errorf("job %s: %s", job.Name, err)
+	}
+
+	s.jobs[job.Name] = j
+	return nil
+}
+
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop stops scheduling and waits the running jobs until ctx is done.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	select {
+	case <-s.cron.Stop().Done():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RunNow runs the job out of the schedule and waits it, like for the manual triggers.
+func (s *Scheduler) RunNow(name string) error {
+	s.mutex.Lock()
+	j, ok := s.jobs[name]
+	s.mutex.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown job %s", name)
+	}
+
+	s.run(j)
+	return nil
+}
+
+func (s *Scheduler) run(j *scheduledJob) {
+	row := &AccessLogRow{fields: make(logrus.Fields)}
+	row.SetRowField("job", j.name)
+	if !atomic.CompareAndSwapInt32(&j.running, 0, 1) {
+		row.SetRowField("status", "skipped")
+		s.logger.WithFields(row.fields).Info()
+		return
+	}
+	defer atomic.StoreInt32(&j.running, 0)
+
+	beginTime := time.Now()
+	status, failed := s.call(j, row)
+	row.SetRowField("begin", beginTime.Format("2006-01-02 15:04:05.999999999"))
+	row.SetRowField("duration", strconv.FormatFloat(time.Now().Sub(beginTime).Seconds(), 'f', -1, 64))
+	row.SetRowField("status", status)
+	if failed {
+		s.logger.WithFields(row.fields).Error()
+	} else {
+		s.logger.WithFields(row.fields).Info()
+	}
+}
+
+func (s *Scheduler) call(j *scheduledJob, row *AccessLogRow) (status string, failed bool) {
+	logger := &methodLogger{row}
+	arg, in := j.handler.method.newArgument()
+	if j.argument != nil {
+		if err := json.Unmarshal(j.argument, arg.Interface()); err != nil {
+			logger.Record("error", err.Error())
+			return "error", true
+		}
+	}
+
+	md := Metadata{}
+	ctx, cancel := context.WithCancel(NewMetadataContext(context.Background(), md))
+	if j.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+	}
+	defer cancel()
+
+	goroutines := newGoroutineGroup()
+	defer func() {
+		if leaked := goroutines.close(DefaultGoroutineGracePeriod); leaked > 0 {
+			logger.Record("leakedGoroutines", strconv.FormatInt(leaked, 10))
+		}
+	}()
+
+	out, ps := doServiceMethodCall(j.handler.method, []reflect.Value{
+		reflect.ValueOf(&ServiceMethodContext{
+			Context:              ctx,
+			RequestHeader:        http.Header{},
+			RequestBodyReader:    http.NoBody,
+			ResponseStatusSetter: func(int) {},
+			ResponseHeader:       http.Header{},
+			ResponseBodyWriter:   ioutil.Discard,
+			Metadata:             md,
+			goroutines:           goroutines,
+			warnings:             &warningList{},
+			logger:               logger,
+		}),
+		in,
+	})
+
+	if ps != nil {
+		reportPanic(logger, s.panicHook, nil, "incidentId", ps)
+		return "panic", true
+	}
+
+	if err, _ := out[len(out)-1].Interface().(error); err != nil {
+		logger.Record("error", err.Error())
+		return "error", true
+	}
+
+	if len(out) == 2 {
+		// the same as the logged resp of the routes, but the crypt fields are masked since there's no Crypter.
+		resp := j.handler.marshalLoggedResp(redactedArgument(out[0]), nil)
+		logger.Record("resp", j.handler.truncateLogged(resp))
+	}
+
+	return "ok", false
+}
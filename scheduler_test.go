@@ -0,0 +1,126 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestScheduler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	scheduler := NewScheduler(buf)
+	var panicked []string
+	scheduler.SetPanicHook(func(r *http.Request, incident string, p string, stack string) {
+		panicked = append(panicked, p)
+	})
+
+	type cleanupArg struct {
+		Days int `json:"days"`
+	}
+
+	err := scheduler.Add(&ScheduledJob{
+		Name:     "cleanup",
+		Schedule: "@daily",
+		Argument: &cleanupArg{Days: 7},
+		Function: func(ctx *ServiceMethodContext, arg *cleanupArg) (*cleanupArg, error) {
+			ctx.Logger().Record("days", fmt.Sprint(arg.Days))
+			return arg, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = scheduler.Add(&ScheduledJob{
+		Name:     "broken",
+		Schedule: "@every 1h",
+		Function: func(ctx *ServiceMethodContext, arg *cleanupArg) error {
+			panic("boom")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type report struct {
+		Token string `json:"token"`
+		Note  string `json:"note"`
+	}
+
+	err = scheduler.Add(&ScheduledJob{
+		Name:              "report",
+		Schedule:          "@weekly",
+		LogRedactedFields: []string{"token"},
+		MaxLoggedBytes:    32,
+		Function: func(ctx *ServiceMethodContext, arg *struct{}) (*report, error) {
+			return &report{Token: "secret", Note: strings.Repeat("n", 64)}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheduler.Add(&ScheduledJob{Name: "cleanup", Schedule: "@daily", Function: func(ctx *ServiceMethodContext,
+		arg *cleanupArg) error {
+		return nil
+	}}); err == nil {
+		t.Error("duplicated job is added")
+	}
+
+	if err := scheduler.Add(&ScheduledJob{Name: "bad", Schedule: "not a spec", Function: func(ctx *ServiceMethodContext,
+		arg *cleanupArg) error {
+		return nil
+	}}); err == nil {
+		t.Error("bad schedule is added")
+	}
+
+	if err := scheduler.RunNow("cleanup"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheduler.RunNow("broken"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheduler.RunNow("report"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheduler.RunNow("missing"); err == nil {
+		t.Error("unknown job runs")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatal(lines)
+	}
+
+	for _, s := range []string{"job=cleanup", "status=ok", "days=7", `resp="{\"days\":7}"`} {
+		if !strings.Contains(lines[0], s) {
+			t.Error(lines[0], s)
+		}
+	}
+
+	for _, s := range []string{"job=broken", "status=panic", "incidentId="} {
+		if !strings.Contains(lines[1], s) {
+			t.Error(lines[1], s)
+		}
+	}
+
+	// the resp is redacted and truncated the same as the access log.
+	if !strings.Contains(lines[2], `resp="{\"token\":\"******\",\"note\":\"nnnnnn...(truncated, 92 bytes)"`) {
+		t.Error(lines[2])
+	}
+
+	if len(panicked) != 1 || panicked[0] != "boom" {
+		t.Error(panicked)
+	}
+
+	scheduler.Start()
+	if err := scheduler.Stop(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,187 @@
+package apihttpwrapper
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SecureCookie encrypts the cookie values by AES-GCM, see ServiceMethodContext.SetSecureCookie.
+type SecureCookie struct {
+	// Keys are of AES-128, AES-192 or AES-256, the first one encrypts.
+	Keys [][]byte
+	// MaxAge is the Max-Age of the cookies, 0 means the session cookies.
+	MaxAge   time.Duration
+	Path     string
+	Domain   string
+	SameSite http.SameSite
+	Insecure bool
+}
+
+// maxCookieBytes is the limit of the browsers, including the name and the attributes.
+const maxCookieBytes = 4096
+
+func (c *SecureCookie) aeads() ([]cipher.AEAD, error) {
+	if len(c.Keys) == 0 {
+		return nil, fmt.Errorf("no secure cookie key")
+	}
+
+	aeads := make([]cipher.AEAD, 0, len(c.Keys))
+	for i, key := range c.Keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("secure cookie key %d: %s", i, err)
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("secure cookie key %d: %s", i, err)
+		}
+		aeads = append(aeads, aead)
+	}
+
+	return aeads, nil
+}
+
+// Check returns the error of the keys.
+func (c *SecureCookie) Check() error {
+	_, err := c.aeads()
+	return err
+}
+
+// the additional data binds the value to the name and the time it's encoded.
+func secureCookieData(name string, timestamp []byte) []byte {
+	return append(append([]byte(name), 0), timestamp...)
+}
+
+// Encode returns the cookie value of the value, which is encrypted by the first key.
+func (c *SecureCookie) Encode(name string, value interface{}) (string, error) {
+	aeads, err := c.aeads()
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	aead := aeads[0]
+	timestamp := make([]byte, 8)
+	binary.BigEndian.PutUint64(timestamp, uint64(time.Now().Unix()))
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := append(append(timestamp, nonce...), aead.Seal(nil, nonce, plaintext, secureCookieData(name,
+		timestamp))...)
+	encoded := base64.RawURLEncoding.EncodeToString(sealed)
+	if len(name)+len(encoded) > maxCookieBytes {
+		return "", fmt.Errorf("the secure cookie %s exceeds %d bytes", name, maxCookieBytes)
+	}
+
+	return encoded, nil
+}
+
+// Decode fails if the value is tampered, of another name or older than MaxAge.
+func (c *SecureCookie) Decode(name string, encoded string, value interface{}) error {
+	aeads, err := c.aeads()
+	if err != nil {
+		return err
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < 8 {
+		return fmt.Errorf("invalid secure cookie %s", name)
+	}
+
+	timestamp := sealed[:8]
+	encodedAt := time.Unix(int64(binary.BigEndian.Uint64(timestamp)), 0)
+	if c.MaxAge > 0 && time.Now().Sub(encodedAt) > c.MaxAge {
+		return fmt.Errorf("the secure cookie %s is expired", name)
+	}
+
+	for _, aead := range aeads {
+		if len(sealed) < 8+aead.NonceSize() {
+			continue
+		}
+
+		nonce, ciphertext := sealed[8:8+aead.NonceSize()], sealed[8+aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, ciphertext, secureCookieData(name, timestamp))
+		if err == nil {
+			return json.Unmarshal(plaintext, value)
+		}
+	}
+
+	return fmt.Errorf("invalid secure cookie %s", name)
+}
+
+// Cookie returns the cookie having the encoded value and the attributes of c.
+func (c *SecureCookie) Cookie(name string, value interface{}) (*http.Cookie, error) {
+	encoded, err := c.Encode(name, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Cookie{
+		Name:     name,
+		Value:    encoded,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		MaxAge:   int(c.MaxAge / time.Second),
+		Secure:   !c.Insecure,
+		HttpOnly: !c.Insecure,
+		SameSite: c.SameSite,
+	}, nil
+}
+
+// SetSecureCookie sets the cookie of the encrypted value into the response, see SecureCookie.
+func (ctx *ServiceMethodContext) SetSecureCookie(name string, value interface{}) error {
+	if ctx.secureCookie == nil {
+		return fmt.Errorf("no RouterOptions.SecureCookie")
+	}
+
+	cookie, err := ctx.secureCookie.Cookie(name, value)
+	if err != nil {
+		return err
+	}
+
+	ctx.ResponseHeader.Add("Set-Cookie", cookie.String())
+	return nil
+}
+
+// DeleteSecureCookie makes the client remove the cookie.
+func (ctx *ServiceMethodContext) DeleteSecureCookie(name string) {
+	cookie := &http.Cookie{Name: name, Value: "", MaxAge: -1}
+	if ctx.secureCookie != nil {
+		cookie.Path, cookie.Domain = ctx.secureCookie.Path, ctx.secureCookie.Domain
+	}
+
+	ctx.ResponseHeader.Add("Set-Cookie", cookie.String())
+}
+
+// SecureCookie returns http.ErrNoCookie if the request doesn't have the cookie.
+func (ctx *ServiceMethodContext) SecureCookie(name string, value interface{}) error {
+	if ctx.secureCookie == nil {
+		return fmt.Errorf("no RouterOptions.SecureCookie")
+	}
+
+	cookie, err := (&http.Request{Header: ctx.RequestHeader}).Cookie(name)
+	if err != nil {
+		return err
+	}
+
+	return ctx.secureCookie.Decode(name, cookie.Value, value)
+}
+
+// SetSecureCookie makes the service method able to use the secure cookies, see SecureCookie.
+func (h *ServiceHandler) SetSecureCookie(cookie *SecureCookie) {
+	h.secureCookie = cookie
+}
@@ -0,0 +1,85 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSecureCookie(t *testing.T) {
+	type session struct {
+		UserID int
+	}
+
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 16)
+	cookies := &SecureCookie{Keys: [][]byte{oldKey}, Path: "/"}
+	routes := []*Route{
+		{Method: "POST", Path: "/login", Function: func(ctx *ServiceMethodContext, arg *struct{}) error {
+			return ctx.SetSecureCookie("session", &session{UserID: 7})
+		}},
+		{Method: "GET", Path: "/me", Function: func(ctx *ServiceMethodContext, arg *struct{}) (*session, error) {
+			s := &session{}
+			if err := ctx.SecureCookie("session", s); err != nil {
+				return nil, &StatusError{Status: 401, Message: err.Error()}
+			}
+			return s, nil
+		}},
+	}
+
+	router, err := NewHTTPRouterWithOptions(routes, &RouterOptions{SecureCookie: cookies})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/login", nil))
+	cookie := (&http.Response{Header: w.Header()}).Cookies()
+	if len(cookie) != 1 || !cookie[0].Secure || !cookie[0].HttpOnly || cookie[0].Path != "/" {
+		t.Fatal(w.Header())
+	}
+
+	me := func(c *http.Cookie) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/me", nil)
+		r.AddCookie(c)
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	// the keys are rotated, the values of the old key are still accepted.
+	cookies.Keys = [][]byte{newKey, oldKey}
+	if w := me(cookie[0]); w.Code != 200 || w.Body.String() != "{\"UserID\":7}\n" {
+		t.Error(w.Code, w.Body.String())
+	}
+
+	tampered := *cookie[0]
+	tampered.Value = tampered.Value[:len(tampered.Value)-2] + "AA"
+	if w := me(&tampered); w.Code != 401 {
+		t.Error("the tampered cookie is accepted", w.Code)
+	}
+
+	encoded, _ := cookies.Encode("other", &session{UserID: 8})
+	if err := cookies.Decode("session", encoded, &session{}); err == nil {
+		t.Error("the value of another name is accepted")
+	}
+
+	cookies.Keys = [][]byte{newKey}
+	if w := me(cookie[0]); w.Code != 401 {
+		t.Error("the value of the removed key is accepted", w.Code)
+	}
+
+	// the timestamps are of seconds, so the value is older than the nanosecond once encoded.
+	encoded, _ = cookies.Encode("session", &session{UserID: 7})
+	time.Sleep(time.Millisecond)
+	cookies.MaxAge = time.Nanosecond
+	if err := cookies.Decode("session", encoded, &session{}); err == nil {
+		t.Error("the expired value is accepted")
+	}
+
+	if _, err := NewHTTPRouterWithOptions(routes, &RouterOptions{SecureCookie: &SecureCookie{
+		Keys: [][]byte{[]byte("short")}}}); err == nil {
+		t.Error("the invalid key is accepted")
+	}
+}
@@ -0,0 +1,135 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const DefaultDrainTimeout = 30 * time.Second
+
+// Server runs the router until SIGINT or SIGTERM, then drains the requests and flushes the log.
+type Server struct {
+	// HTTPServer can be customized before running, like the timeouts and the ConnState of ConnTimingTracker.
+	HTTPServer *http.Server
+	// DrainTimeout is DefaultDrainTimeout if 0, the connections still active after it are closed.
+	DrainTimeout time.Duration
+	// LogWriter is flushed or synced, the handler of NewLoggingHTTPRouter is closed instead.
+	LogWriter io.Writer
+	// Signals are SIGINT and SIGTERM if nil.
+	Signals []os.Signal
+
+	stopOnce sync.Once
+	makeOnce sync.Once
+	stop     chan struct{}
+}
+
+// NewServer takes the handler from NewHTTPRouter or NewLoggingHTTPRouter, and the log writer of the latter.
+func NewServer(addr string, handler http.Handler, logWriter io.Writer) *Server {
+	return &Server{
+		HTTPServer: &http.Server{Addr: addr, Handler: handler},
+		LogWriter:  logWriter,
+	}
+}
+
+// stopped returns the channel closed by Stop, it's made lazily so that the Server literals work too.
+func (s *Server) stopped() chan struct{} {
+	s.makeOnce.Do(func() {
+		s.stop = make(chan struct{})
+	})
+	return s.stop
+}
+
+// Stop shuts the server down like receiving the signals, Run and Serve return after draining.
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopped())
+	})
+}
+
+func (s *Server) Run() error {
+	addr := s.HTTPServer.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// Serve returns nil if the server is shut down and drained in time.
+func (s *Server) Serve(l net.Listener) error {
+	signals := s.Signals
+	if signals == nil {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	defer signal.Stop(received)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.HTTPServer.Serve(l)
+	}()
+
+	select {
+	case err := <-served:
+		return s.flushLog(err)
+	case <-received:
+	case <-s.stopped():
+	}
+
+	drain := s.DrainTimeout
+	if drain <= 0 {
+		drain = DefaultDrainTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+
+	err := s.HTTPServer.Shutdown(ctx)
+	if err == context.DeadlineExceeded {
+		_ = s.HTTPServer.Close()
+		err = fmt.Errorf("the requests are not drained in %s", drain)
+	}
+
+	<-served
+	return s.flushLog(err)
+}
+
+// flushLog returns err if not nil, or the error of flushing.
+func (s *Server) flushLog(err error) error {
+	var flushErr error
+	if d, ok := s.HTTPServer.Handler.(*AccessLogDecorator); ok {
+		flushErr = d.Close()
+		if err != nil {
+			return err
+		}
+		return flushErr
+	}
+
+	switch w := s.LogWriter.(type) {
+	case interface{ Flush() error }:
+		flushErr = w.Flush()
+	case interface{ Sync() error }:
+		flushErr = w.Sync()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return flushErr
+}
@@ -0,0 +1,98 @@
+package apihttpwrapper
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	started := make(chan struct{})
+	buf := &bytes.Buffer{}
+	logWriter := bufio.NewWriter(buf)
+	handler, err := NewLoggingHTTPRouter([]*Route{{
+		Method: "GET",
+		Path:   "/slow",
+		Function: func(ctx *ServiceMethodContext, arg *struct {
+			Wait time.Duration `schema:"wait"`
+		}) (*struct{ OK bool }, error) {
+			started <- struct{}{}
+			time.Sleep(arg.Wait)
+			return &struct{ OK bool }{true}, nil
+		},
+	}}, nil, logWriter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(drain time.Duration) (*Server, string, chan error) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s := NewServer("", handler, logWriter)
+		s.DrainTimeout = drain
+		served := make(chan error, 1)
+		go func() {
+			served <- s.Serve(l)
+		}()
+		return s, "http://" + l.Addr().String(), served
+	}
+
+	s, url, served := serve(time.Second)
+	responses := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow?wait=100000000")
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		responses <- string(body)
+	}()
+
+	<-started
+	s.Stop()
+	if err := <-served; err != nil {
+		t.Error(err)
+	}
+
+	if body := <-responses; strings.TrimSpace(body) != `{"OK":true}` {
+		t.Error(body)
+	}
+
+	if !strings.Contains(buf.String(), "uri=\"/slow?wait=100000000\"") {
+		t.Error(buf.String())
+	}
+
+	if _, err := http.Get(url + "/slow"); err == nil {
+		t.Error("the stopped server accepts the connections")
+	}
+
+	s, url, served = serve(10 * time.Millisecond)
+	go http.Get(url + "/slow?wait=1000000000")
+	<-started
+	s.Stop()
+	if err := <-served; err == nil || !strings.Contains(err.Error(), "not drained") {
+		t.Error(err)
+	}
+
+	// the Server literals can be stopped too, even before serving.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	literal := &Server{HTTPServer: &http.Server{Handler: handler}}
+	literal.Stop()
+	if err := literal.Serve(l); err != nil {
+		t.Error(err)
+	}
+}
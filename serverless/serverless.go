@@ -0,0 +1,217 @@
+// Package serverless serves the AWS Lambda events of API Gateway and ALB by the routers.
+package serverless
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"github.com/aws/aws-lambda-go/events"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type eventContextKey struct{}
+
+// EventFromContext returns the event of the request, like *events.APIGatewayProxyRequest.
+func EventFromContext(ctx context.Context) interface{} {
+	return ctx.Value(eventContextKey{})
+}
+
+func newRequest(ctx context.Context, event interface{}, method string, path string, query string, header http.Header,
+	body string, isBase64Encoded bool) (*http.Request, error) {
+	data := []byte(body)
+	if isBase64Encoded {
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(body); err != nil {
+			return nil, err
+		}
+	}
+
+	u := &url.URL{Path: path, RawQuery: query}
+	r, err := http.NewRequest(method, u.RequestURI(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	r = r.WithContext(context.WithValue(ctx, eventContextKey{}, event))
+	r.Header = header
+	r.Host = header.Get("Host")
+	r.RemoteAddr = strings.TrimSpace(strings.Split(header.Get("X-Forwarded-For"), ",")[0])
+	return r, nil
+}
+
+func newHeader(single map[string]string, multi map[string][]string) http.Header {
+	header := http.Header{}
+	if len(multi) > 0 {
+		for k, values := range multi {
+			for _, v := range values {
+				header.Add(k, v)
+			}
+		}
+		return header
+	}
+
+	for k, v := range single {
+		header.Set(k, v)
+	}
+	return header
+}
+
+func newQuery(single map[string]string, multi map[string][]string) string {
+	query := url.Values{}
+	if len(multi) > 0 {
+		for k, values := range multi {
+			query[k] = values
+		}
+		return query.Encode()
+	}
+
+	for k, v := range single {
+		query.Set(k, v)
+	}
+	return query.Encode()
+}
+
+// responseBuffer is the response of the event, the bodies of the functions are not streamed.
+type responseBuffer struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func serve(handler http.Handler, r *http.Request) *responseBuffer {
+	w := &responseBuffer{header: http.Header{}, status: http.StatusOK}
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func (w *responseBuffer) Header() http.Header {
+	return w.header
+}
+
+func (w *responseBuffer) WriteHeader(status int) {
+	if !w.wroteHeader && status >= http.StatusOK {
+		w.status, w.wroteHeader = status, true
+	}
+}
+
+func (w *responseBuffer) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.body.Write(b)
+}
+
+func (w *responseBuffer) Flush() {}
+
+// encodedBody returns the body in base64 unless it is uncompressed text.
+func (w *responseBuffer) encodedBody() (string, bool) {
+	contentType := strings.ToLower(w.header.Get("Content-Type"))
+	isText := strings.HasPrefix(contentType, "text/") || strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "xml") || strings.Contains(contentType, "javascript") ||
+		strings.HasPrefix(contentType, "application/x-www-form-urlencoded")
+	if w.body.Len() == 0 || (isText && w.header.Get("Content-Encoding") == "") {
+		return w.body.String(), false
+	}
+
+	return base64.StdEncoding.EncodeToString(w.body.Bytes()), true
+}
+
+// singleValueHeader joins the values of each header, except Set-Cookie which can't be joined.
+func (w *responseBuffer) singleValueHeader() map[string]string {
+	header := make(map[string]string, len(w.header))
+	for k, values := range w.header {
+		if k != "Set-Cookie" {
+			header[k] = strings.Join(values, ",")
+		}
+	}
+	return header
+}
+
+// NewAPIGatewayHandler serves the events of the REST APIs and the HTTP APIs of payload version 1.0.
+func NewAPIGatewayHandler(handler http.Handler) func(context.Context,
+	events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		r, err := newRequest(ctx, &event, event.HTTPMethod, event.Path,
+			newQuery(event.QueryStringParameters, event.MultiValueQueryStringParameters),
+			newHeader(event.Headers, event.MultiValueHeaders), event.Body, event.IsBase64Encoded)
+		if err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+		r.RemoteAddr = event.RequestContext.Identity.SourceIP
+
+		w := serve(handler, r)
+		body, isBase64Encoded := w.encodedBody()
+		return events.APIGatewayProxyResponse{
+			StatusCode:        w.status,
+			MultiValueHeaders: w.header,
+			Body:              body,
+			IsBase64Encoded:   isBase64Encoded,
+		}, nil
+	}
+}
+
+// NewAPIGatewayV2Handler serves the events of the HTTP APIs of payload version 2.0.
+func NewAPIGatewayV2Handler(handler http.Handler) func(context.Context,
+	events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	return func(ctx context.Context, event events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+		header := newHeader(event.Headers, nil)
+		if len(event.Cookies) > 0 {
+			header.Set("Cookie", strings.Join(event.Cookies, "; "))
+		}
+
+		r, err := newRequest(ctx, &event, event.RequestContext.HTTP.Method, event.RawPath, event.RawQueryString, header,
+			event.Body, event.IsBase64Encoded)
+		if err != nil {
+			return events.APIGatewayV2HTTPResponse{}, err
+		}
+		r.RemoteAddr = event.RequestContext.HTTP.SourceIP
+
+		w := serve(handler, r)
+		body, isBase64Encoded := w.encodedBody()
+		return events.APIGatewayV2HTTPResponse{
+			StatusCode:      w.status,
+			Headers:         w.singleValueHeader(),
+			Body:            body,
+			IsBase64Encoded: isBase64Encoded,
+			Cookies:         w.header["Set-Cookie"],
+		}, nil
+	}
+}
+
+// NewALBHandler serves the events of the ALB target groups.
+func NewALBHandler(handler http.Handler) func(context.Context,
+	events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	return func(ctx context.Context, event events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+		r, err := newRequest(ctx, &event, event.HTTPMethod, event.Path,
+			newQuery(event.QueryStringParameters, event.MultiValueQueryStringParameters),
+			newHeader(event.Headers, event.MultiValueHeaders), event.Body, event.IsBase64Encoded)
+		if err != nil {
+			return events.ALBTargetGroupResponse{}, err
+		}
+
+		w := serve(handler, r)
+		body, isBase64Encoded := w.encodedBody()
+		resp := events.ALBTargetGroupResponse{
+			StatusCode:        w.status,
+			StatusDescription: http.StatusText(w.status),
+			Body:              body,
+			IsBase64Encoded:   isBase64Encoded,
+		}
+		if resp.StatusDescription != "" {
+			resp.StatusDescription = strconv.Itoa(w.status) + " " + resp.StatusDescription
+		}
+
+		if len(event.MultiValueHeaders) > 0 {
+			resp.MultiValueHeaders = w.header
+		} else {
+			// only one cookie can be set without the multi-value headers.
+			resp.Headers = w.singleValueHeader()
+			if cookie := w.header.Get("Set-Cookie"); cookie != "" {
+				resp.Headers["Set-Cookie"] = cookie
+			}
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,107 @@
+package serverless
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"github.com/abadcafe/apihttpwrapper"
+	"github.com/aws/aws-lambda-go/events"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type echoArg struct {
+	ID   int    `json:"id" schema:"id"`
+	Name string `json:"name" schema:"name"`
+}
+
+func newTestRouter(t *testing.T, logs *bytes.Buffer) http.Handler {
+	router, err := apihttpwrapper.NewLoggingHTTPRouter([]*apihttpwrapper.Route{{
+		Method: "GET",
+		Path:   "/items/:id",
+		Function: func(ctx *apihttpwrapper.ServiceMethodContext, arg *echoArg) (*echoArg, error) {
+			ctx.ResponseHeader.Add("Set-Cookie", "a=1")
+			ctx.ResponseHeader.Add("Set-Cookie", "b=2")
+			if EventFromContext(ctx.Context) == nil {
+				arg.Name = "no event"
+			}
+			return arg, nil
+		},
+	}, {
+		Method: "POST",
+		Path:   "/items",
+		Function: func(ctx *apihttpwrapper.ServiceMethodContext, arg *echoArg) (*echoArg, error) {
+			return arg, nil
+		},
+	}}, nil, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return router
+}
+
+func TestAPIGatewayHandler(t *testing.T) {
+	logs := &bytes.Buffer{}
+	handle := NewAPIGatewayHandler(newTestRouter(t, logs))
+	event := events.APIGatewayProxyRequest{
+		HTTPMethod:      "POST",
+		Path:            "/items",
+		Headers:         map[string]string{"Content-Type": "application/json"},
+		Body:            base64.StdEncoding.EncodeToString([]byte(`{"id":1,"name":"a"}`)),
+		IsBase64Encoded: true,
+	}
+	event.RequestContext.Identity.SourceIP = "10.0.0.1"
+
+	resp, err := handle(context.Background(), event)
+	if err != nil || resp.StatusCode != 200 || resp.IsBase64Encoded ||
+		strings.TrimSpace(resp.Body) != `{"id":1,"name":"a"}` {
+		t.Error(resp, err)
+	}
+
+	if !strings.Contains(logs.String(), "remote=10.0.0.1") || !strings.Contains(logs.String(), "uri=/items") {
+		t.Error(logs.String())
+	}
+
+	event = events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/missing"}
+	if resp, err := handle(context.Background(), event); err != nil || resp.StatusCode != 404 {
+		t.Error(resp, err)
+	}
+}
+
+func TestAPIGatewayV2Handler(t *testing.T) {
+	handle := NewAPIGatewayV2Handler(newTestRouter(t, &bytes.Buffer{}))
+	event := events.APIGatewayV2HTTPRequest{RawPath: "/items/3", RawQueryString: "name=b%20c"}
+	event.RequestContext.HTTP.Method = "GET"
+
+	resp, err := handle(context.Background(), event)
+	if err != nil || resp.StatusCode != 200 || strings.TrimSpace(resp.Body) != `{"id":3,"name":"b c"}` {
+		t.Error(resp, err)
+	}
+
+	if !reflect.DeepEqual(resp.Cookies, []string{"a=1", "b=2"}) || resp.Headers["Set-Cookie"] != "" ||
+		resp.Headers["Content-Type"] != "application/json" {
+		t.Error(resp.Cookies, resp.Headers)
+	}
+}
+
+func TestALBHandler(t *testing.T) {
+	handle := NewALBHandler(newTestRouter(t, &bytes.Buffer{}))
+	resp, err := handle(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod:                      "GET",
+		Path:                            "/items/4",
+		MultiValueQueryStringParameters: map[string][]string{"name": {"d"}},
+		MultiValueHeaders:               map[string][]string{"X-Forwarded-For": {"10.0.0.2, 10.0.0.3"}},
+	})
+	if err != nil || resp.StatusCode != 200 || resp.StatusDescription != "200 OK" ||
+		strings.TrimSpace(resp.Body) != `{"id":4,"name":"d"}` || len(resp.MultiValueHeaders["Set-Cookie"]) != 2 {
+		t.Error(resp, err)
+	}
+
+	resp, err = handle(context.Background(), events.ALBTargetGroupRequest{HTTPMethod: "GET", Path: "/items/5"})
+	if err != nil || resp.MultiValueHeaders != nil || resp.Headers["Set-Cookie"] != "a=1" {
+		t.Error(resp, err)
+	}
+}
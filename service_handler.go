@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/schema"
 	"github.com/julienschmidt/httprouter"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/trace"
 	"io"
 	"net/http"
@@ -14,6 +16,7 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -25,27 +28,121 @@ type ServiceMethodContext struct {
 	ResponseStatusSetter func(status int)
 	ResponseHeader       http.Header
 	ResponseBodyWriter   io.Writer
+	// Metadata is also attached to Context, see MetadataFromContext.
+	Metadata Metadata
+	// Locales are parsed from Accept-Language in the order of preference, see LocalesFromContext.
+	Locales []string
+	// Principal is the authenticated caller, nil if the request is not authenticated. see PrincipalFromContext.
+	Principal *Principal
+
+	goroutines   *goroutineGroup
+	warnings     *warningList
+	logger       MethodLogger
+	events       *methodEventStream
+	secureCookie *SecureCookie
+}
+
+type serviceMethodContextKey struct{}
+
+// ServiceMethodContextFromContext returns nil if ctx is not of a service method.
+func ServiceMethodContextFromContext(ctx context.Context) *ServiceMethodContext {
+	methodCtx, _ := ctx.Value(serviceMethodContextKey{}).(*ServiceMethodContext)
+	return methodCtx
 }
 
 type MethodLogger interface {
 	Record(field string, value string)
 }
 
+type nopMethodLogger struct{}
+
+func (nopMethodLogger) Record(string, string) {}
+
+// Logger returns the logger of the access log row of the request, the records are dropped if there is no access log.
+func (ctx *ServiceMethodContext) Logger() MethodLogger {
+	if ctx.logger == nil {
+		return nopMethodLogger{}
+	}
+
+	return ctx.logger
+}
+
 type ServiceHandler struct {
-	loggerContextKey   interface{}
-	method             *serviceMethod
-	bypassRequestBody  bool
+	loggerContextKey      interface{}
+	method                *serviceMethod
+	bypassRequestBody     bool
+	argumentExtensions    []ArgumentParserExtension
+	tabularExport         bool
+	maxCSVRows            int
+	envelopeVersion       int
+	crypter               Crypter
+	encoders              *EncoderRegistry
+	goroutineGracePeriod  time.Duration
+	validator             *validator.Validate
+	headerMappings        []*ResponseHeaderMapping
+	tracer                oteltrace.Tracer
+	route                 string
+	logCoalescing         *logMarshalCache
+	logFields             []*logFieldExtraction
+	bodyBufferLimit       int64
+	bodyMethods           map[string]bool
+	maxBodyBytes          int64
+	maxDecompressedBytes  int64
+	bodyLogging           *BodyLogging
+	panicHook             PanicHook
+	exposePanicDetails    bool
+	enveloper             ResponseEnveloper
+	wrapSuccess           bool
+	timeout               time.Duration
+	writeTimeout          time.Duration
+	eventHeartbeat        time.Duration
+	strictJSON            bool
+	multipartMemory       int64
+	argumentSources       []ArgumentSource
+	fallback              *serviceMethod
+	logRedaction          *logRedaction
+	maxLoggedBytes        int
+	secureCookie          *SecureCookie
+	structuredParseErrors bool
 }
 
 type FormattedResponse struct {
-	Code int         `json:"code"`
-	Msg  string      `json:"msg"`
-	Data interface{} `json:"data"`
+	Code int         `json:"code" xml:"code"`
+	Msg  string      `json:"msg" xml:"msg"`
+	Data interface{} `json:"data" xml:"data"`
 }
 
 type serviceMethod struct {
 	value   reflect.Value
 	argType reflect.Type
+	// plainContext is set if the first argument is context.Context, see ServiceMethodContextFromContext.
+	plainContext bool
+	// pool is of the arguments implementing ArgumentResetter.
+	pool *sync.Pool
+}
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// newServiceMethod takes the function checked by checkServiceMethodPrototype.
+func newServiceMethod(method interface{}) *serviceMethod {
+	methodType := reflect.TypeOf(method)
+	return &serviceMethod{
+		value:        reflect.ValueOf(method),
+		argType:      methodType.In(1),
+		plainContext: methodType.In(0) == contextType,
+		pool:         newArgumentPool(methodType.In(1)),
+	}
+}
+
+// call attaches the *ServiceMethodContext to the context for the methods taking context.Context.
+func (m *serviceMethod) call(in []reflect.Value) []reflect.Value {
+	if m.plainContext {
+		methodCtx := in[0].Interface().(*ServiceMethodContext)
+		ctx := context.WithValue(methodCtx.Context, serviceMethodContextKey{}, methodCtx)
+		in = append([]reflect.Value{reflect.ValueOf(&ctx).Elem()}, in[1:]...)
+	}
+
+	return m.value.Call(in)
 }
 
 type panicStack struct {
@@ -85,8 +182,7 @@ func isCustomResponseBodyFunction(methodType reflect.Type) bool {
 }
 
 func isDelegatedResponseBodyFunction(methodType reflect.Type) bool {
-	return methodType.NumOut() == 2 &&
-		methodType.Out(0).Kind() == reflect.Ptr && methodType.Out(0).Elem().Kind() == reflect.Struct &&
+	return methodType.NumOut() == 2 && (isStructPointer(methodType.Out(0)) || isSlice(methodType.Out(0))) &&
 		methodType.Out(1).Kind() == reflect.Interface && methodType.Out(1).Name() == "error"
 }
 
@@ -99,16 +195,18 @@ func checkServiceMethodPrototype(methodType reflect.Type) error {
 		return fmt.Errorf("the service method should have two arguments")
 	}
 
-	if !isTypeServiceMethodContext(methodType.In(0)) {
-		return fmt.Errorf("the first argument should be type *ServiceMethodContext")
+	if !isTypeServiceMethodContext(methodType.In(0)) && methodType.In(0) != contextType {
+		return fmt.Errorf("the first argument should be type *ServiceMethodContext or context.Context")
 	}
 
 	if !isSlice(methodType.In(1)) && !isStringMap(methodType.In(1)) && !isStructPointer(methodType.In(1)) {
 		return fmt.Errorf("the second argument should be a struct pointer, slice or map[string]interface{}")
 	}
 
-	if !isCustomResponseBodyFunction(methodType) && !isDelegatedResponseBodyFunction(methodType) {
-		return fmt.Errorf("the service method only can return error interface or (*struct, error)")
+	if !isCustomResponseBodyFunction(methodType) && !isDelegatedResponseBodyFunction(methodType) &&
+		!isEventStreamFunction(methodType) {
+		return fmt.Errorf("the service method only can return error interface, (*struct, error), (slice, error) " +
+			"or (<-chan *Event, error)")
 	}
 
 	return nil
@@ -116,23 +214,104 @@ func checkServiceMethodPrototype(methodType reflect.Type) error {
 
 func NewServiceHandler(method interface{}, loggerContextKey interface{},
 	bypassRequestBody bool) (h *ServiceHandler, err error) {
-	// the method prototype like this: 'func(*ServiceMethodContext, *struct) (anything)'
+	// the method prototype like this: 'func(*ServiceMethodContext or context.Context, *struct) (anything)'
 	methodType := reflect.TypeOf(method)
 	err = checkServiceMethodPrototype(methodType)
 	if err != nil {
 		return
 	}
 
-	h = &ServiceHandler{
-		loggerContextKey: loggerContextKey,
-		method: &serviceMethod{
-			value:   reflect.ValueOf(method),
-			argType: methodType.In(1),
-		},
+	return newServiceHandler(method, loggerContextKey, bypassRequestBody), nil
+}
+
+// newServiceHandler doesn't check the method, the long-lived routes have their own prototypes.
+func newServiceHandler(method interface{}, loggerContextKey interface{}, bypassRequestBody bool) *ServiceHandler {
+	h := &ServiceHandler{
+		loggerContextKey:  loggerContextKey,
+		method:            newServiceMethod(method),
 		bypassRequestBody: bypassRequestBody,
+		maxCSVRows:        DefaultMaxCSVRows,
+		multipartMemory:   DefaultMultipartMemory,
+		argumentSources:   DefaultArgumentSources,
+		validator:         defaultValidator,
+		logRedaction:      newLogRedaction(nil),
 	}
+	h.SetBodyMethods(DefaultBodyMethods...)
 
-	return
+	return h
+}
+
+// DefaultBodyMethods are the methods whose request bodies are bound into the arguments.
+var DefaultBodyMethods = []string{"POST", "PUT", "PATCH"}
+
+// SetBodyMethods sets the methods whose request bodies are bound, DefaultBodyMethods by default.
+func (h *ServiceHandler) SetBodyMethods(methods ...string) {
+	h.bodyMethods = make(map[string]bool, len(methods))
+	for _, method := range methods {
+		h.bodyMethods[strings.ToUpper(method)] = true
+	}
+}
+
+func (h *ServiceHandler) bindsBody(method string) bool {
+	return !h.bypassRequestBody && h.bodyMethods[strings.ToUpper(method)]
+}
+
+// SetArgumentParserExtensions sets the extensions which provide extra argument values, see ArgumentParserExtension.
+func (h *ServiceHandler) SetArgumentParserExtensions(extensions ...ArgumentParserExtension) {
+	h.argumentExtensions = extensions
+}
+
+// SetTabularExport encodes the results as csv or xlsx if the client asks for it.
+func (h *ServiceHandler) SetTabularExport(enabled bool) {
+	h.tabularExport = enabled
+}
+
+// SetMaxCSVRows limits the rows of the text/csv request body bound into a slice argument, 0 means unlimited.
+func (h *ServiceHandler) SetMaxCSVRows(rows int) {
+	h.maxCSVRows = rows
+}
+
+// SetEnvelopeVersion sets the default envelope, the clients may select it by EnvelopeVersionHeader.
+func (h *ServiceHandler) SetEnvelopeVersion(version int) {
+	h.envelopeVersion = version
+}
+
+// SetWrapSuccessResponses wraps the results of EnvelopeV1 as '{"code":0,"msg":"ok","data":result}'.
+func (h *ServiceHandler) SetWrapSuccessResponses(wrap bool) {
+	h.wrapSuccess = wrap
+}
+
+// SetResponseEnveloper shapes the responses by the enveloper, nil restores the envelope versions.
+func (h *ServiceHandler) SetResponseEnveloper(enveloper ResponseEnveloper) {
+	h.enveloper = enveloper
+}
+
+// SetDisallowUnknownFields rejects the unknown fields of the JSON bodies with 400.
+func (h *ServiceHandler) SetDisallowUnknownFields(disallow bool) {
+	h.strictJSON = disallow
+}
+
+// SetCrypter sets the Crypter of the fields tagged with crypt.
+func (h *ServiceHandler) SetCrypter(crypter Crypter) {
+	h.crypter = crypter
+}
+
+func (m *serviceMethod) newArgument() (ptr reflect.Value, in reflect.Value) {
+	// slice and map arguments are passed by value, but always decoded through a pointer.
+	if m.pool != nil {
+		arg := m.pool.Get()
+		arg.(ArgumentResetter).Reset()
+		ptr = reflect.ValueOf(arg)
+		return ptr, ptr
+	}
+
+	if m.argType.Kind() == reflect.Ptr {
+		ptr = reflect.New(m.argType.Elem())
+		return ptr, ptr
+	}
+
+	ptr = reflect.New(m.argType)
+	return ptr, ptr.Elem()
 }
 
 func setResponseHeader(w http.ResponseWriter) {
@@ -143,8 +322,7 @@ func setResponseHeader(w http.ResponseWriter) {
 
 func writeResponse(w http.ResponseWriter, tr trace.Trace, data interface{}) {
 	tr.LazyPrintf("%+v", data)
-	setResponseHeader(w)
-	_ = json.NewEncoder(w).Encode(data)
+	writeEncodedResponse(w, defaultResponseEncoder, 0, data)
 }
 
 func writeErrorResponse(w http.ResponseWriter, tr trace.Trace, resp *FormattedResponse) {
@@ -153,9 +331,13 @@ func writeErrorResponse(w http.ResponseWriter, tr trace.Trace, resp *FormattedRe
 		tr.SetError()
 	}
 
-	setResponseHeader(w)
-	w.WriteHeader(resp.Code)
-	_ = json.NewEncoder(w).Encode(resp)
+	writeEncodedResponse(w, defaultResponseEncoder, resp.Code, resp)
+}
+
+// redactedArgument is for the logs, the decrypted values shouldn't be leaked into them.
+func redactedArgument(arg reflect.Value) interface{} {
+	redacted, _ := transformCryptFields(arg, redactCryptField)
+	return redacted.Interface()
 }
 
 func doServiceMethodCall(method *serviceMethod, in []reflect.Value) (out []reflect.Value, ps *panicStack) {
@@ -168,18 +350,36 @@ func doServiceMethodCall(method *serviceMethod, in []reflect.Value) (out []refle
 		}
 	}()
 
-	out = method.value.Call(in)
+	out = method.call(in)
 	return
 }
 
+// bindBody binds the JSON body or the csv body into the argument.
+func (h *ServiceHandler) bindBody(r *http.Request, bindsBody bool, contentType string, arg interface{}) error {
+	if bindsBody && strings.HasPrefix(contentType, "application/json") {
+		decoder := json.NewDecoder(r.Body)
+		if h.strictJSON {
+			decoder.DisallowUnknownFields()
+		}
+
+		return decoder.Decode(arg)
+	}
+
+	if bindsBody && strings.HasPrefix(contentType, "text/csv") && isCSVBindable(reflect.TypeOf(arg)) {
+		return decodeCSV(r.Body, arg, h.maxCSVRows)
+	}
+
+	return nil
+}
+
 func (h *ServiceHandler) parseArgument(r *http.Request, params httprouter.Params, arg interface{}) error {
-	method := strings.ToUpper(r.Method)
+	bindsBody := h.bindsBody(r.Method)
 	contentType := strings.ToLower(r.Header.Get("Content-Type"))
 
-	// query string has lowest priority.
 	var err error
-	if method == "POST" && contentType == "multipart/form-data" {
-		err = r.ParseMultipartForm(1024)
+	isMultipart := bindsBody && strings.HasPrefix(contentType, "multipart/form-data")
+	if isMultipart {
+		err = r.ParseMultipartForm(h.multipartMemory)
 	} else {
 		err = r.ParseForm()
 	}
@@ -188,33 +388,74 @@ func (h *ServiceHandler) parseArgument(r *http.Request, params httprouter.Params
 		return err
 	}
 
-	err = formDecoder.Decode(arg, r.Form)
-	if err != nil {
-		return err
-	}
+	// only structs can be bound from the form like values.
+	isStruct := isStructPointer(reflect.TypeOf(arg))
 
-	// json content's priority is higher than query string, but lower than params in url pattern.
-	if method == "POST" && !h.bypassRequestBody && strings.HasPrefix(contentType, "application/json") {
-		err = json.NewDecoder(r.Body).Decode(arg)
+	// the extensions take their parts of the params in the url pattern, whether their values are bound or not.
+	var extensionValues []url.Values
+	for i := 0; isStruct && i < len(h.argumentExtensions); i++ {
+		var values url.Values
+		values, params, err = h.argumentExtensions[i].Parse(r, params)
 		if err != nil {
 			return err
 		}
+		extensionValues = append(extensionValues, values)
 	}
 
-	// params in the url pattern has highest priority.
-	if params != nil {
-		paramValues := url.Values{}
-		for _, param := range params {
-			paramValues.Set(param.Key, param.Value)
+	// the later sources override the earlier ones, see SetArgumentSources.
+	for _, source := range h.argumentSources {
+		if !isStruct && source != ArgumentSourceBody {
+			continue
 		}
 
-		err = formDecoder.Decode(arg, paramValues)
-		if err != nil {
+		switch source {
+		case ArgumentSourceForm:
+			err = formDecoder.Decode(arg, r.Form)
+			if err == nil && isMultipart {
+				bindFileHeaders(reflect.ValueOf(arg).Elem(), r.MultipartForm)
+			}
+		case ArgumentSourceBody:
+			err = h.bindBody(r, bindsBody, contentType, arg)
+		case ArgumentSourceHeader:
+			err = bindHeaders(r, arg)
+		case ArgumentSourceCookie:
+			err = bindCookies(r, arg)
+		case ArgumentSourceExtension:
+			for _, values := range extensionValues {
+				if err = formDecoder.Decode(arg, values); err != nil {
+					break
+				}
+			}
+		case ArgumentSourcePath:
+			paramValues := url.Values{}
+			for _, param := range params {
+				paramValues.Set(param.Key, param.Value)
+			}
+
+			err = formDecoder.Decode(arg, paramValues)
+		}
+
+		if fieldErrors := argumentErrors(source, err); fieldErrors != nil && h.structuredParseErrors {
+			return fieldErrors
+		} else if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	if !isStruct {
+		return nil
+	}
+
+	return validateTimeSpans(reflect.ValueOf(arg))
+}
+
+func (h *ServiceHandler) methodLogger(r *http.Request) MethodLogger {
+	if h.loggerContextKey == nil {
+		return nil
+	}
+
+	logger, _ := r.Context().Value(h.loggerContextKey).(MethodLogger)
+	return logger
 }
 
 func (h *ServiceHandler) ServeHTTP(respWriter http.ResponseWriter, req *http.Request) {
@@ -225,43 +466,218 @@ func (h *ServiceHandler) ServeHTTPWithParams(rw http.ResponseWriter, r *http.Req
 	tracer := trace.New(traceFamily, r.URL.Path)
 	defer tracer.Finish()
 
+	rw, r, endSpan := h.startSpan(rw, r)
+	defer endSpan()
+
+	format := h.negotiateResponseFormat(rw, r)
+	ctx, cancel, md, err := requestContext(r)
+	if err != nil {
+		writeEnvelopedError(rw, tracer, format, &FormattedResponse{400, "parse request metadata failed", err.Error()})
+		return
+	}
+	defer cancel()
+	h.setMappedResponseHeaders(rw, ctx)
+
+	rw, reqCapture, respCapture := h.captureBodies(rw, r)
+	var ww *writeTimeoutWriter
+	if h.writeTimeout > 0 {
+		ww = &writeTimeoutWriter{ResponseWriter: rw, timeout: h.writeTimeout}
+		rw = ww
+	}
+	if !h.limitRequestBody(rw, r) {
+		writeEnvelopedError(rw, tracer, format, bodyTooLargeResponse(h.maxBodyBytes))
+		return
+	}
+
+	if status, err := h.decompressRequestBody(r); status == http.StatusRequestEntityTooLarge {
+		writeEnvelopedError(rw, tracer, format, h.tooLargeResponse(err))
+		return
+	} else if err != nil {
+		writeEnvelopedError(rw, tracer, format, &FormattedResponse{status, "decompress request body failed",
+			err.Error()})
+		return
+	}
+
+	bodyReader, err := h.bufferRequestBody(r)
+	if isBodyTooLarge(err) {
+		writeEnvelopedError(rw, tracer, format, h.tooLargeResponse(err))
+		return
+	}
+	if _, ok := err.(*bufferLimitError); ok {
+		writeEnvelopedError(rw, tracer, format, &FormattedResponse{413, "buffer request body failed", err.Error()})
+		return
+	} else if err != nil {
+		writeEnvelopedError(rw, tracer, format, &FormattedResponse{400, "read request body failed", err.Error()})
+		return
+	}
+
 	// extract arguments.
-	arg := reflect.New(h.method.argType.Elem())
-	err := h.parseArgument(r, params, arg.Interface())
+	arg, in := h.method.newArgument()
+	// the pooled argument is reused only if nothing of the request may still use it, see ArgumentResetter.
+	argumentInUse := false
+	defer func() {
+		if !argumentInUse {
+			h.method.releaseArgument(arg)
+		}
+	}()
+
+	err = h.parseArgument(r, params, arg.Interface())
+	// the server only removes the upload files of the original request, which may be replaced by the middlewares.
+	if r.MultipartForm != nil {
+		defer func() {
+			_ = r.MultipartForm.RemoveAll()
+		}()
+	}
+	if isBodyTooLarge(err) {
+		writeEnvelopedError(rw, tracer, format, h.tooLargeResponse(err))
+		return
+	}
 	if err != nil {
-		writeErrorResponse(rw, tracer, &FormattedResponse{400, "parse argument failed", err.Error()})
+		writeEnvelopedError(rw, tracer, format, parseFailedResponse(err))
 		return
 	}
 
+	arg, err = transformCryptFields(arg, h.decryptTransform)
+	if err != nil {
+		writeEnvelopedError(rw, tracer, format, &FormattedResponse{400, "decrypt argument failed", err.Error()})
+		return
+	}
+
+	err = h.validateArgument(arg)
+	if err != nil {
+		writeEnvelopedError(rw, tracer, format, validationFailedResponse(err))
+		return
+	}
+
+	if in.Kind() == reflect.Ptr {
+		in = arg
+	} else {
+		in = arg.Elem()
+	}
+
+	goroutines := newGoroutineGroup()
+	defer func() {
+		if h.closeGoroutines(rw, goroutines, h.methodLogger(r)) > 0 {
+			argumentInUse = true
+		}
+	}()
+	warnings := &warningList{}
+
+	// the method having the timeout writes the response by the guarded writer, see SetTimeout.
+	requestCtx := ctx
+	var tw *timeoutWriter
+	methodWriter, methodLogger := rw, h.methodLogger(r)
+	if h.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, h.timeout)
+		defer cancelTimeout()
+		tw = newTimeoutWriter(ctx, rw)
+		methodWriter, methodLogger = tw, tw.logger(methodLogger)
+	}
+
 	// do method call.
 	beginTime := time.Now()
+	events := &methodEventStream{w: methodWriter, heartbeat: h.eventHeartbeat}
 
 	respStatus := http.StatusOK
-	out, methodPanic := doServiceMethodCall(h.method, []reflect.Value{
-		reflect.ValueOf(&ServiceMethodContext{
-			Context:              r.Context(),
-			RemoteAddr:           r.RemoteAddr,
-			RequestHeader:        r.Header,
-			RequestBodyReader:    r.Body,
-			ResponseStatusSetter: func(status int) {
+	statusWritten := false
+	setStatus := func(status int) {
+		respStatus = status
+		statusWritten = true
+		methodWriter.WriteHeader(status)
+	}
+	if tw != nil {
+		// the method may still set the status after the timeout, while the handler reads it.
+		setStatus = func(status int) {
+			tw.setStatus(status, func() {
 				respStatus = status
-				rw.WriteHeader(status)
-			},
-			ResponseHeader:       rw.Header(),
-			ResponseBodyWriter:   rw,
-		}),
-		arg,
-	})
+				statusWritten = true
+			})
+		}
+	}
+
+	methodCtx := &ServiceMethodContext{
+		Context:              ctx,
+		RemoteAddr:           r.RemoteAddr,
+		RequestHeader:        r.Header,
+		RequestBodyReader:    bodyReader,
+		ResponseStatusSetter: setStatus,
+		ResponseHeader:       methodWriter.Header(),
+		ResponseBodyWriter:   methodWriter,
+		Metadata:             md,
+		Locales:              requestLocales(r),
+		Principal:            PrincipalFromContext(r.Context()),
+		goroutines:           goroutines,
+		warnings:             warnings,
+		logger:               methodLogger,
+		events:               events,
+		secureCookie:         h.secureCookie,
+	}
+	methodIn := []reflect.Value{reflect.ValueOf(methodCtx), in}
+
+	var out []reflect.Value
+	var methodPanic *panicStack
+	var timedOut, timeoutWritten bool
+	var loggedArgs string
+	fallbackIn := in
+	if tw == nil {
+		out, methodPanic = doServiceMethodCall(h.method, methodIn)
+	} else {
+		// the argument may be still used by the method after the timeout.
+		if h.methodLogger(r) != nil {
+			loggedArgs = h.marshalLoggedArgs(r, redactedArgument(arg))
+		}
+		if h.fallback != nil {
+			fallbackIn = copyArgument(in)
+		}
+		out, methodPanic, timedOut, timeoutWritten = tw.call(h.method, methodIn)
+		argumentInUse = timedOut
+	}
 
 	duration := time.Now().Sub(beginTime)
+	if ww != nil {
+		// the encoding is covered by the write timeout too, see SetWriteTimeout.
+		ww.start()
+	}
+
+	// the failures are replaced by the results of the fallback, see SetFallback.
+	var degradedCause string
+	if h.fallback != nil && !statusWritten && !timeoutWritten {
+		degradedCause = fallbackCause(out, methodPanic, timedOut)
+	}
+	if degradedCause != "" {
+		fallbackOut, fallbackWarnings := h.callFallback(rw, r, methodCtx, requestCtx, fallbackIn,
+			func(status int) {
+				respStatus = status
+				statusWritten = true
+				rw.WriteHeader(status)
+			})
+		if fallbackOut != nil {
+			if methodPanic != nil {
+				recordSpanPanic(oteltrace.SpanFromContext(ctx), methodPanic)
+				h.notifyPanic(r, "incidentId", methodPanic)
+			}
+			out, methodPanic, timedOut, warnings = fallbackOut, nil, false, fallbackWarnings
+		} else {
+			degradedCause = ""
+		}
+	}
 
 	var methodError error
 	var methodReturn interface{}
 	var respData interface{}
 
-	if methodPanic != nil {
-		respData = &FormattedResponse{500, "service method panicked", methodPanic}
-		writeErrorResponse(rw, tracer, respData.(*FormattedResponse))
+	if timedOut {
+		respData = methodTimeoutResponse(h.timeout)
+		if !timeoutWritten {
+			writeEnvelopedError(rw, tracer, format, respData.(*FormattedResponse))
+		}
+	} else if methodPanic != nil {
+		recordSpanPanic(oteltrace.SpanFromContext(ctx), methodPanic)
+		respData = h.panicResponse(rw, r, tracer, methodPanic)
+		if !events.isStarted() {
+			writeEnvelopedError(rw, tracer, format, respData.(*FormattedResponse))
+		}
 	} else if len(out) == 2 {
 		methodReturn = out[0].Interface()
 		if out[1].Interface() != nil {
@@ -276,45 +692,115 @@ func (h *ServiceHandler) ServeHTTPWithParams(rw http.ResponseWriter, r *http.Req
 		panic(fmt.Sprintf("return values error: %+v", out))
 	}
 
-	if methodError != nil {
+	if channel, ok := methodReturn.(<-chan *Event); ok {
+		methodReturn = nil
+		if methodError == nil && channel != nil {
+			// the method has returned, so the stream isn't limited by the method timeout.
+			events.w = rw
+			if err := events.pump(r.Context(), channel); err != nil {
+				methodError = err
+			}
+		}
+	}
+
+	if events.isStarted() {
+		// the response is the event stream, the failures of the method are sent as the error event.
+		if httpError, ok := methodError.(HTTPError); ok {
+			respData = h.httpErrorResponse(rw, r, tracer, httpError)
+		} else if methodError != nil {
+			respData = h.reportIncident(rw, r, tracer,
+				&FormattedResponse{500, "service method error", methodError.Error()})
+		}
+
+		failure, _ := respData.(*FormattedResponse)
+		events.close(failure)
+		methodError = nil
+	}
+
+	if httpError, ok := methodError.(HTTPError); ok {
+		respStatus = httpError.StatusCode()
+		if respStatus == 0 {
+			respStatus = http.StatusInternalServerError
+		}
+
+		respData = h.httpErrorResponse(rw, r, tracer, httpError)
+		writeEnvelopedStatusError(rw, tracer, format, respStatus, respData.(*FormattedResponse))
+	} else if methodError != nil {
+		if retryAfter, ok := methodError.(*RetryAfterError); ok {
+			respStatus = retryAfter.Status
+			if respStatus == 0 {
+				respStatus = http.StatusTooManyRequests
+			}
+			setRetryHeaders(rw.Header(), retryAfter.After)
+		}
+
 		if respStatus == http.StatusOK {
 			respStatus = 500
 		}
 
-		respData = &FormattedResponse{respStatus, "service method error", methodError.Error()}
-		writeErrorResponse(rw, tracer, respData.(*FormattedResponse))
+		respData = h.reportIncident(rw, r, tracer,
+			&FormattedResponse{respStatus, "service method error", methodError.Error()})
+		writeEnvelopedError(rw, tracer, format, respData.(*FormattedResponse))
 	} else if methodReturn != nil {
+		encrypted, err := transformCryptFields(reflect.ValueOf(methodReturn), h.encryptTransform)
+		if err == nil {
+			methodReturn = encrypted.Interface()
+		}
+
 		respData = methodReturn
-		writeResponse(rw, tracer, methodReturn)
+		if err != nil {
+			respData = h.reportIncident(rw, r, tracer, &FormattedResponse{500, "encrypt response failed", err.Error()})
+			writeEnvelopedError(rw, tracer, format, respData.(*FormattedResponse))
+		} else if tabularFormat := negotiateTabularFormat(r); h.tabularExport && tabularFormat != "" {
+			tracer.LazyPrintf("%s: %+v", tabularFormat, methodReturn)
+			err = writeTabularResponse(rw, tabularFormat, methodReturn)
+			if err != nil {
+				respData = h.reportIncident(rw, r, tracer,
+					&FormattedResponse{500, "encode response failed", err.Error()})
+				writeEnvelopedError(rw, tracer, format, respData.(*FormattedResponse))
+			}
+		} else {
+			if bulk, ok := methodReturn.(*BulkResult); ok && !statusWritten {
+				respStatus = bulk.Status()
+			}
+
+			writeEnvelopedResponse(rw, tracer, format, respStatus, statusWritten, methodReturn, warnings.list())
+		}
 	}
 
 	// record some thing if logger existed.
-	if h.loggerContextKey == nil {
+	logger := h.methodLogger(r)
+	if logger == nil {
 		return
 	}
 
-	v := r.Context().Value(h.loggerContextKey)
-	if v == nil {
-		return
+	if tw == nil {
+		loggedArgs = h.marshalLoggedArgs(r, redactedArgument(arg))
 	}
-
-	logger, ok := v.(MethodLogger)
-	if !ok {
-		return
+	logger.Record("args", h.truncateLogged(loggedArgs))
+	if timedOut {
+		logger.Record("timedOut", "true")
 	}
-
-	marshaledArgs, err := json.Marshal(arg.Interface())
-	if err != nil {
-		panic(err)
+	if degradedCause != "" {
+		logger.Record("degraded", "true")
+		logger.Record("fallbackCause", degradedCause)
 	}
-
-	marshaledData, err := json.Marshal(respData)
-	if err != nil {
-		panic(err)
+	if ww != nil && ww.isTimedOut() {
+		logger.Record("writeTimedOut", "true")
+	}
+	if events.isStarted() {
+		events.record(logger)
+	}
+	marshaledResp := h.marshalLoggedResp(respData, methodReturn)
+	logger.Record("resp", h.truncateLogged(marshaledResp))
+	h.recordBodies(logger, reqCapture, respCapture)
+	if _, failed := respData.(*FormattedResponse); !failed && respData != nil {
+		h.recordLogFields(logger, marshaledResp)
+	}
+	if list := warnings.list(); len(list) > 0 {
+		marshaledWarnings, _ := json.Marshal(list)
+		logger.Record("warnings", string(marshaledWarnings))
 	}
-
-	logger.Record("args", string(marshaledArgs))
-	logger.Record("resp", string(marshaledData))
 	logger.Record("methodBegin", beginTime.Format("2006-01-02 15:04:05.999999999"))
 	logger.Record("methodDuration", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64))
 }
@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 type dummyMethodLogger struct{}
@@ -75,6 +76,15 @@ func TestCheckServiceMethodPrototype(t *testing.T) {
 			t.Error(err)
 		}
 	})
+
+	t.Run("plain context method", func(t *testing.T) {
+		err := checkServiceMethodPrototype(reflect.TypeOf(
+			func(context.Context, *struct{}) (*struct{}, error) { return nil, nil },
+		))
+		if err != nil {
+			t.Error(err)
+		}
+	})
 }
 
 type testingRequest struct {
@@ -168,6 +178,42 @@ func TestServeHTTP(t *testing.T) {
 		)
 	})
 
+	for _, method := range []string{"PUT", "PATCH"} {
+		t.Run("json body of "+method, func(t *testing.T) {
+			doTest(
+				t,
+				&testingRequest{
+					method: method,
+					body:   "{\"A\":2}",
+					header: map[string]string{"content-type": "application/json"},
+				},
+				func(_ *ServiceMethodContext, args *struct{ A int }) error {
+					if args.A != 2 {
+						t.Error(args)
+					}
+					return nil
+				},
+			)
+		})
+	}
+
+	t.Run("json body of DELETE is not bound by default", func(t *testing.T) {
+		doTest(
+			t,
+			&testingRequest{
+				method: "DELETE",
+				body:   "{\"A\":2}",
+				header: map[string]string{"content-type": "application/json"},
+			},
+			func(_ *ServiceMethodContext, args *struct{ A int }) error {
+				if args.A != 0 {
+					t.Error(args)
+				}
+				return nil
+			},
+		)
+	})
+
 	t.Run("normal request while bypassed request body", func(t *testing.T) {
 		doTest(
 			t,
@@ -255,8 +301,240 @@ func TestServeHTTP(t *testing.T) {
 			},
 			func(*ServiceMethodContext, *struct{}) (*struct{ A int }, error) {
 				panic("expected panic")
-				return nil, nil
 			},
 		)
 	})
+
+	t.Run("csv request body bound into slice", func(t *testing.T) {
+		doTest(
+			t,
+			&testingRequest{
+				body:   "name,Age,unknown\nbob,18,x\n\"alice, jr\",20,y\n",
+				header: map[string]string{"content-type": "text/csv"},
+			},
+			func(_ *ServiceMethodContext, rows []*struct {
+				Name string `csv:"name"`
+				Age  int
+			}) error {
+				if len(rows) != 2 || rows[0].Name != "bob" || rows[1].Name != "alice, jr" || rows[1].Age != 20 {
+					t.Error(rows)
+				}
+				return nil
+			},
+		)
+	})
+
+	t.Run("csv request body with bad rows", func(t *testing.T) {
+		doTest(
+			t,
+			&testingRequest{
+				body:         "Age\n18\neighteen\n",
+				header:       map[string]string{"content-type": "text/csv"},
+				expectStatus: 400,
+			},
+			func(_ *ServiceMethodContext, rows []struct{ Age int }) error {
+				return nil
+			},
+		)
+	})
+
+	t.Run("metadata headers and timeout", func(t *testing.T) {
+		doTest(
+			t,
+			&testingRequest{
+				header: map[string]string{"x-md-tenant": "acme", "grpc-timeout": "2S"},
+			},
+			func(ctx *ServiceMethodContext, _ *struct{}) error {
+				md, ok := MetadataFromContext(ctx.Context)
+				if !ok || md.Get("Tenant") != "acme" || ctx.Metadata.Get("tenant") != "acme" {
+					t.Error(md)
+				}
+
+				if deadline, ok := ctx.Context.Deadline(); !ok || time.Until(deadline) > 2*time.Second {
+					t.Error(deadline)
+				}
+				return nil
+			},
+		)
+	})
+
+	t.Run("accept language", func(t *testing.T) {
+		doTest(
+			t,
+			&testingRequest{
+				header: map[string]string{"accept-language": "fr;q=0.5, zh-CN, en;q=0.8, *;q=0.1, de;q=0"},
+			},
+			func(ctx *ServiceMethodContext, _ *struct{}) error {
+				locales, _ := LocalesFromContext(ctx.Context)
+				if strings.Join(ctx.Locales, ",") != "zh-CN,en,fr" || len(locales) != 3 {
+					t.Error(ctx.Locales, locales)
+				}
+				return nil
+			},
+		)
+	})
+
+	type reportArgument struct {
+		Range  DateRange  `schema:"range" maxspan:"31d"`
+		Window TimeWindow `schema:"window" maxspan:"24h"`
+	}
+
+	t.Run("time ranges", func(t *testing.T) {
+		doTest(
+			t,
+			&testingRequest{
+				uri: "/?range=2021-01-01/2021-01-31&window=2021-01-01T00:00:00Z/2h",
+			},
+			func(_ *ServiceMethodContext, arg *reportArgument) error {
+				if arg.Range.Days() != 31 || arg.Window.Duration() != 2*time.Hour {
+					t.Error(arg)
+				}
+				return nil
+			},
+		)
+	})
+
+	for _, uri := range []string{
+		"/?range=2021-01-31/2021-01-01",
+		"/?range=2021-01-01/2021-02-01",
+		"/?window=2021-01-01T00:00:00Z/2021-01-03T00:00:00Z",
+		"/?window=2021-01-01",
+	} {
+		t.Run("invalid time range "+uri, func(t *testing.T) {
+			doTest(
+				t,
+				&testingRequest{
+					uri:          uri,
+					expectStatus: 400,
+				},
+				func(*ServiceMethodContext, *reportArgument) error {
+					return nil
+				},
+			)
+		})
+	}
+
+	type validatedArgument struct {
+		ID int `schema:"id" validate:"required,gt=0"`
+	}
+
+	t.Run("argument validated", func(t *testing.T) {
+		doTest(
+			t,
+			&testingRequest{
+				uri: "/?id=1",
+			},
+			func(*ServiceMethodContext, *validatedArgument) error {
+				return nil
+			},
+		)
+	})
+
+	t.Run("argument validation failed", func(t *testing.T) {
+		doTest(
+			t,
+			&testingRequest{
+				uri:          "/?id=-1",
+				expectStatus: 400,
+			},
+			func(*ServiceMethodContext, *validatedArgument) error {
+				t.Error("method called")
+				return nil
+			},
+		)
+	})
+
+	t.Run("method logger", func(t *testing.T) {
+		doTest(
+			t,
+			&testingRequest{},
+			func(ctx *ServiceMethodContext, _ *struct{}) error {
+				if ctx.Logger() != dummyLogger {
+					t.Error(ctx.Logger())
+				}
+				return nil
+			},
+		)
+
+		h, err := NewServiceHandler(func(ctx *ServiceMethodContext, _ *struct{}) error {
+			ctx.Logger().Record("field", "value")
+			return nil
+		}, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+		if recorder.Code != 200 {
+			t.Error(recorder.Code, recorder.Body)
+		}
+	})
+
+	t.Run("malformed timeout header", func(t *testing.T) {
+		doTest(
+			t,
+			&testingRequest{
+				header:       map[string]string{"grpc-timeout": "2s"},
+				expectStatus: 400,
+			},
+			func(*ServiceMethodContext, *struct{}) error {
+				return nil
+			},
+		)
+	})
+
+	t.Run("plain context method", func(t *testing.T) {
+		doTest(
+			t,
+			&testingRequest{
+				body:         `{"A":1}`,
+				header:       map[string]string{"Content-Type": "application/json", "X-Request-Id": "r1"},
+				expectStatus: 201,
+			},
+			func(ctx context.Context, arg *struct{ A int }) (*struct{ A int }, error) {
+				methodCtx := ServiceMethodContextFromContext(ctx)
+				if methodCtx == nil || methodCtx.RequestHeader.Get("X-Request-Id") != "r1" ||
+					methodCtx.Logger() != dummyLogger || arg.A != 1 {
+					return nil, errors.New("no service method context")
+				}
+
+				methodCtx.ResponseStatusSetter(201)
+				return arg, nil
+			},
+		)
+
+		if ServiceMethodContextFromContext(context.Background()) != nil {
+			t.Error("the plain context has a service method context")
+		}
+	})
+}
+
+func TestDisallowUnknownFields(t *testing.T) {
+	type pageArguments struct {
+		Page int `json:"page" schema:"page"`
+	}
+	handler, err := NewServiceHandler(func(ctx *ServiceMethodContext, arg *pageArguments) error {
+		return nil
+	}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.SetDisallowUnknownFields(true)
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/?pgae=2", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve(`{"page":1}`); w.Code != 200 {
+		t.Error("the unknown query key is rejected", w.Code, w.Body.String())
+	}
+
+	if w := serve(`{"pgae":1}`); w.Code != 400 || !strings.Contains(w.Body.String(), "pgae") {
+		t.Error(w.Code, w.Body.String())
+	}
 }
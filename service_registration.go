@@ -0,0 +1,84 @@
+package apihttpwrapper
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// ServiceRouter maps the method names to the routes like "GET /users/:id", "-" skips the method.
+type ServiceRouter interface {
+	HTTPRoute() map[string]string
+}
+
+// ServiceRoutes makes the routes like "POST /prefix/MethodName" of the service methods of svc.
+func ServiceRoutes(prefix string, svc interface{}) ([]*Route, error) {
+	v := reflect.ValueOf(svc)
+	if !v.IsValid() {
+		return nil, fmt.Errorf("the service is nil")
+	}
+
+	overrides := map[string]string{}
+	if router, ok := svc.(ServiceRouter); ok {
+		overrides = router.HTTPRoute()
+	}
+
+	prefix = strings.TrimSuffix(prefix, "/")
+	var routes []*Route
+	matched := map[string]bool{}
+	for i := 0; i < v.NumMethod(); i++ {
+		name := v.Type().Method(i).Name
+		function := v.Method(i).Interface()
+		if checkServiceMethodPrototype(reflect.TypeOf(function)) != nil {
+			continue
+		}
+		matched[name] = true
+
+		rt := &Route{Method: http.MethodPost, Path: prefix + "/" + name, Function: function}
+		if override, ok := overrides[name]; ok {
+			fields := strings.Fields(override)
+			switch {
+			case override == "-":
+				continue
+			case len(fields) == 1 && strings.HasPrefix(fields[0], "/"):
+				rt.Path = prefix + fields[0]
+			case len(fields) == 1:
+				rt.Method = strings.ToUpper(fields[0])
+			case len(fields) == 2 && strings.HasPrefix(fields[1], "/"):
+				rt.Method, rt.Path = strings.ToUpper(fields[0]), prefix+fields[1]
+			default:
+				return nil, fmt.Errorf("the route %q of method %s should be like \"GET /path\"", override, name)
+			}
+		}
+
+		routes = append(routes, rt)
+	}
+
+	for name := range overrides {
+		if !matched[name] {
+			return nil, fmt.Errorf("the route of %s is not of a service method", name)
+		}
+	}
+
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("the service %s has no service method", v.Type())
+	}
+
+	return routes, nil
+}
+
+// RegisterService registers the ServiceRoutes of svc like NewHTTPRouter.
+func RegisterService(r *httprouter.Router, prefix string, svc interface{}) error {
+	return RegisterServiceWithOptions(r, prefix, svc, nil)
+}
+
+func RegisterServiceWithOptions(r *httprouter.Router, prefix string, svc interface{}, options *RouterOptions) error {
+	routes, err := ServiceRoutes(prefix, svc)
+	if err != nil {
+		return err
+	}
+
+	return RegisterRoutesWithOptions(r, ServiceHandlerAccessLogRowFillerContextKey, routes, options)
+}
@@ -0,0 +1,95 @@
+package apihttpwrapper
+
+import (
+	"context"
+	"github.com/julienschmidt/httprouter"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type userService struct {
+	prefix string
+}
+
+type userArg struct {
+	ID int `json:"id" schema:"id"`
+}
+
+func (s *userService) Create(ctx *ServiceMethodContext, arg *userArg) (*userArg, error) {
+	return arg, nil
+}
+
+func (s *userService) Get(ctx context.Context, arg *userArg) (*struct{ Name string }, error) {
+	return &struct{ Name string }{s.prefix + strings.Repeat("x", arg.ID)}, nil
+}
+
+func (s *userService) Delete(ctx *ServiceMethodContext, arg *userArg) error {
+	return nil
+}
+
+func (s *userService) Internal(ctx *ServiceMethodContext, arg *userArg) error {
+	return nil
+}
+
+func (s *userService) Helper(n int) int {
+	return n
+}
+
+func (s *userService) HTTPRoute() map[string]string {
+	return map[string]string{"Get": "GET /users/:id", "Delete": "DELETE", "Internal": "-"}
+}
+
+func TestRegisterService(t *testing.T) {
+	routes, err := ServiceRoutes("/api/", &userService{prefix: "u"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for _, rt := range routes {
+		keys = append(keys, rt.Method+" "+rt.Path)
+	}
+	expected := []string{"POST /api/Create", "DELETE /api/Delete", "GET /api/users/:id"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Error(keys)
+	}
+
+	router := httprouter.New()
+	if err := RegisterService(router, "/api", &userService{prefix: "u"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/users/3", nil))
+	if w.Code != 200 || strings.TrimSpace(w.Body.String()) != `{"Name":"uxxx"}` {
+		t.Error(w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/Create", strings.NewReader(`{"id":7}`))
+	r.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, r)
+	if w.Code != 200 || strings.TrimSpace(w.Body.String()) != `{"id":7}` {
+		t.Error(w.Code, w.Body.String())
+	}
+
+	if _, err := ServiceRoutes("", struct{}{}); err == nil {
+		t.Error("the service without methods is registered")
+	}
+
+	if _, err := ServiceRoutes("", &badRouteService{}); err == nil {
+		t.Error("the unknown method of HTTPRoute is accepted")
+	}
+}
+
+type badRouteService struct{}
+
+func (s *badRouteService) Do(ctx *ServiceMethodContext, arg *userArg) error {
+	return nil
+}
+
+func (s *badRouteService) HTTPRoute() map[string]string {
+	return map[string]string{"Missing": "GET /missing"}
+}
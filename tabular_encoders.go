@@ -0,0 +1,321 @@
+package apihttpwrapper
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TabularFormatCSV  = "csv"
+	TabularFormatXLSX = "xlsx"
+)
+
+const (
+	csvMediaType  = "text/csv"
+	xlsxMediaType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+type tabularColumn struct {
+	name  string
+	index []int
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// negotiateTabularFormat picks the format by the "format" query parameter first, then the Accept header.
+func negotiateTabularFormat(r *http.Request) string {
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case TabularFormatCSV:
+		return TabularFormatCSV
+	case TabularFormatXLSX:
+		return TabularFormatXLSX
+	}
+
+	accept := strings.ToLower(r.Header.Get("Accept"))
+	if strings.Contains(accept, csvMediaType) {
+		return TabularFormatCSV
+	} else if strings.Contains(accept, xlsxMediaType) {
+		return TabularFormatXLSX
+	}
+
+	return ""
+}
+
+// tabularColumns names the columns by the `csv` tag, the `json` tag or the field name.
+func tabularColumns(t reflect.Type, parent []int) []*tabularColumn {
+	return structColumns(t, parent, "csv", "json")
+}
+
+// structColumns names the fields by the first non-empty tag in tags.
+func structColumns(t reflect.Type, parent []int, tags ...string) []*tabularColumn {
+	var columns []*tabularColumn
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		index := append(append([]int(nil), parent...), i)
+		tag := ""
+		for _, key := range tags {
+			if tag = strings.Split(field.Tag.Get(key), ",")[0]; tag != "" {
+				break
+			}
+		}
+
+		if tag == "-" {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		if field.Anonymous && fieldType.Kind() == reflect.Struct && tag == "" {
+			columns = append(columns, structColumns(fieldType, index, tags...)...)
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if tag == "" {
+			tag = field.Name
+		}
+
+		columns = append(columns, &tabularColumn{name: tag, index: index})
+	}
+
+	return columns
+}
+
+func tabularCell(v reflect.Value, index []int) string {
+	for _, i := range index {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return ""
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339Nano)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	}
+
+	marshaled, err := json.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Sprintf("%v", v.Interface())
+	}
+
+	return string(marshaled)
+}
+
+// tabularRows converts a slice of structs, or a single struct, into a table with header row.
+func tabularRows(data interface{}) ([][]string, error) {
+	v := reflect.ValueOf(data)
+	var rows []reflect.Value
+	switch {
+	case v.Kind() == reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			rows = append(rows, v.Index(i))
+		}
+	case v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Struct:
+		if !v.IsNil() {
+			rows = append(rows, v)
+		}
+	default:
+		return nil, fmt.Errorf("type %s can not be encoded as a table", v.Type())
+	}
+
+	elemType := v.Type()
+	if elemType.Kind() == reflect.Slice {
+		elemType = elemType.Elem()
+	}
+
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+
+	if elemType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("type %s can not be encoded as a table", v.Type())
+	}
+
+	columns := tabularColumns(elemType, nil)
+	table := make([][]string, 0, len(rows)+1)
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.name
+	}
+
+	table = append(table, header)
+	for _, row := range rows {
+		cells := make([]string, len(columns))
+		for i, c := range columns {
+			cells[i] = tabularCell(row, c.index)
+		}
+
+		table = append(table, cells)
+	}
+
+	return table, nil
+}
+
+func writeCSV(w io.Writer, table [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(table); err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
+
+func xlsxColumnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+
+	return name
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ` +
+	`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ` +
+	`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" ` +
+	`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" ` +
+	`Target="xl/workbook.xml"/></Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" ` +
+	`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" ` +
+	`Target="worksheets/sheet1.xml"/></Relationships>`
+
+// writeXLSX writes a minimal workbook with one sheet, all cells are inline strings.
+func writeXLSX(w io.Writer, table [][]string) error {
+	zw := zip.NewWriter(w)
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err != nil {
+		return err
+	}
+
+	for r, row := range table {
+		if _, err := fmt.Fprintf(sheet, `<row r="%d">`, r+1); err != nil {
+			return err
+		}
+
+		for c, cell := range row {
+			_, err := fmt.Fprintf(sheet, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">`,
+				xlsxColumnName(c), r+1)
+			if err != nil {
+				return err
+			}
+
+			if err := xml.EscapeText(sheet, []byte(cell)); err != nil {
+				return err
+			}
+
+			if _, err := io.WriteString(sheet, `</t></is></c>`); err != nil {
+				return err
+			}
+		}
+
+		if _, err := io.WriteString(sheet, `</row>`); err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.WriteString(sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeTabularResponse(w http.ResponseWriter, format string, data interface{}) error {
+	table, err := tabularRows(data)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("x-content-type-options", "nosniff")
+	switch format {
+	case TabularFormatXLSX:
+		w.Header().Set("Content-Type", xlsxMediaType)
+		w.Header().Set("Content-Disposition", "attachment; filename=\"export.xlsx\"")
+		return writeXLSX(w, table)
+	default:
+		w.Header().Set("Content-Type", csvMediaType+"; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"export.csv\"")
+		return writeCSV(w, table)
+	}
+}
@@ -0,0 +1,70 @@
+package apihttpwrapper
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+type tabularName struct {
+	Name string `json:"name"`
+}
+
+type tabularRow struct {
+	tabularName
+	Age    int    `csv:"age"`
+	Secret string `csv:"-"`
+	Tags   []string
+}
+
+func TestTabularExport(t *testing.T) {
+	h, err := NewServiceHandler(func(*ServiceMethodContext, *struct{}) ([]*tabularRow, error) {
+		return []*tabularRow{
+			{tabularName{"bob"}, 18, "x", []string{"a", "b"}},
+			{tabularName{"alice, jr"}, 20, "y", nil},
+		}, nil
+	}, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.SetTabularExport(true)
+
+	t.Run("csv by format parameter", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest("GET", "/?format=csv", nil))
+		expected := "name,age,Tags\nbob,18,\"[\"\"a\"\",\"\"b\"\"]\"\n\"alice, jr\",20,null\n"
+		if recorder.Code != 200 || recorder.Body.String() != expected {
+			t.Error(recorder.Code, recorder.Body.String())
+		}
+	})
+
+	t.Run("xlsx by accept header", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", xlsxMediaType)
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, r)
+		if recorder.Header().Get("Content-Type") != xlsxMediaType {
+			t.Fatal(recorder.Header())
+		}
+
+		body := recorder.Body.Bytes()
+		zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(zr.File) != 5 {
+			t.Error(zr.File)
+		}
+	})
+
+	t.Run("json as default", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+		if recorder.Header().Get("Content-Type") != "application/json" {
+			t.Error(recorder.Header())
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package apihttpwrapper
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateRange is an inclusive range of days like "2021-01-01/2021-01-31", limited by `maxspan:"31d"`.
+type DateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// TimeWindow is [Start, End) like "2021-01-01T00:00:00Z/24h", limited by the "maxspan" tag.
+type TimeWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+type timeSpan interface {
+	isZero() bool
+	span() time.Duration
+}
+
+const dateLayout = "2006-01-02"
+
+var timeSpanType = reflect.TypeOf((*timeSpan)(nil)).Elem()
+
+func splitInterval(text string) (string, string, error) {
+	parts := strings.Split(text, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid interval %q, should be start/end", text)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+func (d *DateRange) UnmarshalText(text []byte) error {
+	start, end, err := splitInterval(string(text))
+	if err != nil {
+		return err
+	}
+
+	if d.Start, err = time.Parse(dateLayout, start); err != nil {
+		return err
+	}
+
+	if d.End, err = time.Parse(dateLayout, end); err != nil {
+		return err
+	}
+
+	if d.End.Before(d.Start) {
+		return fmt.Errorf("the start of %q is after the end", text)
+	}
+
+	return nil
+}
+
+func (d DateRange) MarshalText() ([]byte, error) {
+	if d.isZero() {
+		return []byte{}, nil
+	}
+
+	return []byte(d.Start.Format(dateLayout) + "/" + d.End.Format(dateLayout)), nil
+}
+
+// Days returns the count of days in the range, both ends included.
+func (d DateRange) Days() int {
+	return int(d.span() / (24 * time.Hour))
+}
+
+func (d DateRange) isZero() bool {
+	return d.Start.IsZero() && d.End.IsZero()
+}
+
+func (d DateRange) span() time.Duration {
+	return d.End.Sub(d.Start) + 24*time.Hour
+}
+
+func (w *TimeWindow) UnmarshalText(text []byte) error {
+	start, end, err := splitInterval(string(text))
+	if err != nil {
+		return err
+	}
+
+	if w.Start, err = time.Parse(time.RFC3339, start); err != nil {
+		return err
+	}
+
+	if duration, err := time.ParseDuration(end); err == nil {
+		w.End = w.Start.Add(duration)
+	} else if w.End, err = time.Parse(time.RFC3339, end); err != nil {
+		return err
+	}
+
+	if w.End.Before(w.Start) {
+		return fmt.Errorf("the start of %q is after the end", text)
+	}
+
+	return nil
+}
+
+func (w TimeWindow) MarshalText() ([]byte, error) {
+	if w.isZero() {
+		return []byte{}, nil
+	}
+
+	return []byte(w.Start.Format(time.RFC3339Nano) + "/" + w.End.Format(time.RFC3339Nano)), nil
+}
+
+func (w TimeWindow) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+func (w TimeWindow) Duration() time.Duration {
+	return w.span()
+}
+
+func (w TimeWindow) isZero() bool {
+	return w.Start.IsZero() && w.End.IsZero()
+}
+
+func (w TimeWindow) span() time.Duration {
+	return w.End.Sub(w.Start)
+}
+
+// parseMaxSpan parses the durations of time.ParseDuration, or the days like "31d".
+func parseMaxSpan(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid maxspan %q", s)
+		}
+
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(s)
+}
+
+// validateTimeSpans checks the "maxspan" tags of the DateRange and TimeWindow fields, nested structs included.
+func validateTimeSpans(v reflect.Value) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		fv := v.Field(i)
+		if !field.Type.Implements(timeSpanType) {
+			if err := validateTimeSpans(fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		tag := field.Tag.Get("maxspan")
+		if tag == "" {
+			continue
+		}
+
+		maxSpan, err := parseMaxSpan(tag)
+		if err != nil {
+			return err
+		}
+
+		if !fv.CanInterface() {
+			continue
+		}
+
+		if span := fv.Interface().(timeSpan); !span.isZero() && span.span() > maxSpan {
+			return fmt.Errorf("%s spans longer than %s", field.Name, tag)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package apihttpwrapper
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// TypeProblem is a mistake in the argument or response type of a service method.
+type TypeProblem struct {
+	// Field is the path of the field, like "argument.Items[].Callback".
+	Field   string
+	Message string
+	// Fatal problems always fail at runtime, the others are warnings unless in the strict mode.
+	Fatal bool
+}
+
+func (p *TypeProblem) Error() string {
+	return fmt.Sprintf("%s: %s", p.Field, p.Message)
+}
+
+// TypeLintOptions lints the types at registration, rejecting the fatal problems.
+type TypeLintOptions struct {
+	// Strict rejects the warnings too, and the exported fields without json tags are warned.
+	Strict bool
+	// Warn is called with each warning not rejected, they are dropped if nil.
+	Warn func(rt *Route, problem *TypeProblem)
+}
+
+// LintServiceMethodTypes returns the fields which can't be encoded or bound, or lack json tags if strict.
+func LintServiceMethodTypes(function interface{}, strict bool) []*TypeProblem {
+	methodType := reflect.TypeOf(function)
+	if methodType == nil || methodType.Kind() != reflect.Func || methodType.NumIn() != 2 {
+		return nil
+	}
+
+	l := &typeLinter{strict: strict, visited: make(map[reflect.Type]bool)}
+	l.lint("argument", methodType.In(1), true)
+	if methodType.NumOut() == 2 && methodType.Out(0) != eventFilterType {
+		l.visited = make(map[reflect.Type]bool)
+		l.lint("response", methodType.Out(0), false)
+	}
+
+	return l.problems
+}
+
+func (o *TypeLintOptions) lintRoutes(routes []*Route) error {
+	var errs RouteErrors
+	for i, rt := range routes {
+		problems := LintServiceMethodTypes(rt.Function, o.Strict)
+		if rt.Canary != nil {
+			problems = append(problems, LintServiceMethodTypes(rt.Canary.Function, o.Strict)...)
+		}
+
+		for _, problem := range problems {
+			if problem.Fatal || o.Strict {
+				errs = append(errs, &RouteError{Index: i, Route: rt, Err: problem})
+			} else if o.Warn != nil {
+				o.Warn(rt, problem)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+type typeLinter struct {
+	strict   bool
+	visited  map[reflect.Type]bool
+	problems []*TypeProblem
+}
+
+func (l *typeLinter) report(field string, fatal bool, format string, args ...interface{}) {
+	l.problems = append(l.problems, &TypeProblem{Field: field, Message: fmt.Sprintf(format, args...), Fatal: fatal})
+}
+
+func (l *typeLinter) lint(path string, t reflect.Type, isArgument bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// the marshalers decide the encoding themselves.
+	if t == timeType || reflect.PtrTo(t).Implements(textMarshalerType) ||
+		reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		l.report(path, true, "%s can't be encoded as json", t.Kind())
+	case reflect.Slice, reflect.Array:
+		l.lint(path+"[]", t.Elem(), isArgument)
+	case reflect.Map:
+		l.lint(path+"[]", t.Elem(), isArgument)
+	case reflect.Struct:
+		if l.visited[t] {
+			return
+		}
+
+		l.visited[t] = true
+		l.lintStruct(path, t, isArgument)
+	}
+}
+
+func (l *typeLinter) lintStruct(path string, t reflect.Type, isArgument bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := path + "." + field.Name
+		jsonTag := strings.Split(field.Tag.Get("json"), ",")[0]
+		schemaTag := strings.Split(field.Tag.Get("schema"), ",")[0]
+		if field.PkgPath != "" && !field.Anonymous {
+			if jsonTag != "" || schemaTag != "" {
+				l.report(name, false, "the unexported field has tags, but it can never bind or be encoded")
+			}
+			continue
+		}
+
+		if jsonTag == "-" && (!isArgument || schemaTag == "-") {
+			if isArgument {
+				l.report(name, false, "the field ignored by both json and schema can never bind")
+			}
+			continue
+		}
+
+		if l.strict && !field.Anonymous && jsonTag == "" {
+			l.report(name, false, "the field has no json tag")
+		}
+
+		l.lint(name, field.Type, isArgument)
+	}
+}
@@ -0,0 +1,95 @@
+package apihttpwrapper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type lintedItem struct {
+	Name     string `json:"name"`
+	Callback func() `json:"callback"`
+	Next     *lintedItem
+}
+
+func TestLintServiceMethodTypes(t *testing.T) {
+	method := func(ctx *ServiceMethodContext, arg *struct {
+		ID       int    `json:"id"`
+		internal string `schema:"internal"`
+		Ignored  string `json:"-" schema:"-"`
+		FormOnly string `json:"-"`
+		Items    []*lintedItem
+	}) (*struct {
+		At     time.Time  `json:"at"`
+		Events chan int   `json:"-"`
+		Value  complex128 `json:"value"`
+	}, error) {
+		return nil, nil
+	}
+
+	var problems []string
+	var fatal []bool
+	for _, problem := range LintServiceMethodTypes(method, false) {
+		problems = append(problems, problem.Field)
+		fatal = append(fatal, problem.Fatal)
+	}
+
+	expected := []string{"argument.internal", "argument.Ignored", "argument.Items[].Callback", "response.Value"}
+	if !reflect.DeepEqual(problems, expected) || !reflect.DeepEqual(fatal, []bool{false, false, true, true}) {
+		t.Error(problems, fatal)
+	}
+
+	var strict []string
+	for _, problem := range LintServiceMethodTypes(method, true) {
+		if problem.Message == "the field has no json tag" {
+			strict = append(strict, problem.Field)
+		}
+	}
+
+	if !reflect.DeepEqual(strict, []string{"argument.Items", "argument.Items[].Next"}) {
+		t.Error(strict)
+	}
+}
+
+func TestTypeLintOptions(t *testing.T) {
+	warned := 0
+	options := &RouterOptions{TypeLint: &TypeLintOptions{Warn: func(rt *Route, problem *TypeProblem) {
+		warned++
+	}}}
+
+	_, err := NewHTTPRouterWithOptions([]*Route{{
+		Method: "GET",
+		Path:   "/",
+		Function: func(ctx *ServiceMethodContext, arg *struct {
+			Ignored string `json:"-" schema:"-"`
+		}) error {
+			return nil
+		},
+	}}, options)
+	if err != nil || warned != 1 {
+		t.Error(err, warned)
+	}
+
+	_, err = NewHTTPRouterWithOptions([]*Route{{
+		Method: "GET",
+		Path:   "/",
+		Function: func(ctx *ServiceMethodContext, arg *struct{ Done chan bool }) error {
+			return nil
+		},
+	}}, options)
+	if errs, ok := err.(RouteErrors); !ok || len(errs) != 1 {
+		t.Error(err)
+	}
+
+	options.TypeLint.Strict = true
+	_, err = NewHTTPRouterWithOptions([]*Route{{
+		Method: "GET",
+		Path:   "/",
+		Function: func(ctx *ServiceMethodContext, arg *struct{ ID int }) error {
+			return nil
+		},
+	}}, options)
+	if errs, ok := err.(RouteErrors); !ok || len(errs) != 1 {
+		t.Error(err)
+	}
+}
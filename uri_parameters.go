@@ -0,0 +1,205 @@
+package apihttpwrapper
+
+import (
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ArgumentParserExtension provides extra argument values, the returned params replace the original ones.
+type ArgumentParserExtension interface {
+	Parse(r *http.Request, params httprouter.Params) (url.Values, httprouter.Params, error)
+}
+
+// MatrixParameters binds the semicolon parameters in path segments, like "/user/bob;age=18;city=beijing".
+type MatrixParameters struct{}
+
+// URITemplate binds the variables of a RFC 6570 template matched against the request path.
+type URITemplate struct {
+	template    string
+	pattern     *regexp.Regexp
+	expressions []*uriTemplateExpression
+}
+
+type uriTemplateExpression struct {
+	operator  byte
+	variables []string
+}
+
+func splitMatrixParameters(segment string, values url.Values) (string, error) {
+	parts := strings.Split(segment, ";")
+	for _, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+
+		kv := strings.SplitN(part, "=", 2)
+		key, err := url.PathUnescape(kv[0])
+		if err != nil {
+			return "", err
+		}
+
+		value := ""
+		if len(kv) == 2 {
+			value, err = url.PathUnescape(kv[1])
+			if err != nil {
+				return "", err
+			}
+		}
+
+		for _, v := range strings.Split(value, ",") {
+			values.Add(key, v)
+		}
+	}
+
+	return parts[0], nil
+}
+
+func (MatrixParameters) Parse(r *http.Request, params httprouter.Params) (url.Values, httprouter.Params,
+	error) {
+	values := url.Values{}
+	for _, segment := range strings.Split(r.URL.EscapedPath(), "/") {
+		if _, err := splitMatrixParameters(segment, values); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	stripped := make(httprouter.Params, 0, len(params))
+	for _, param := range params {
+		value, err := splitMatrixParameters(param.Value, url.Values{})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		stripped = append(stripped, httprouter.Param{Key: param.Key, Value: value})
+	}
+
+	return values, stripped, nil
+}
+
+var uriTemplateExpressionPatterns = map[byte]string{
+	0:   `([^/;?#]*?)`,
+	'+': `([^?#]*?)`,
+	'.': `((?:\.[^/;?#.]*)*)`,
+	'/': `((?:/[^/;?#]*)*)`,
+	';': `((?:;[^/;?#]*)*)`,
+}
+
+func NewURITemplate(template string) (*URITemplate, error) {
+	t := &URITemplate{template: template}
+	pattern := strings.Builder{}
+	pattern.WriteString("^")
+
+	rest := template
+	for rest != "" {
+		begin := strings.IndexByte(rest, '{')
+		if begin < 0 {
+			pattern.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+
+		end := strings.IndexByte(rest[begin:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed expression in uri template %q", template)
+		}
+
+		pattern.WriteString(regexp.QuoteMeta(rest[:begin]))
+		expression := rest[begin+1 : begin+end]
+		rest = rest[begin+end+1:]
+		if expression == "" {
+			return nil, fmt.Errorf("empty expression in uri template %q", template)
+		}
+
+		exp := &uriTemplateExpression{}
+		if strings.IndexByte("+#./;?&", expression[0]) >= 0 {
+			exp.operator = expression[0]
+			expression = expression[1:]
+		}
+
+		for _, v := range strings.Split(expression, ",") {
+			// value modifiers don't change how the variables are matched.
+			v = strings.TrimSuffix(v, "*")
+			if i := strings.IndexByte(v, ':'); i >= 0 {
+				v = v[:i]
+			}
+
+			if v == "" {
+				return nil, fmt.Errorf("empty variable name in uri template %q", template)
+			}
+
+			exp.variables = append(exp.variables, v)
+		}
+
+		switch exp.operator {
+		case '?', '&':
+			// query string is parsed by the form decoding already.
+			pattern.WriteString(`(?:[?&].*)?`)
+			continue
+		case '#':
+			// fragments are never sent to servers.
+			continue
+		}
+
+		pattern.WriteString(uriTemplateExpressionPatterns[exp.operator])
+		t.expressions = append(t.expressions, exp)
+	}
+
+	pattern.WriteString("$")
+	compiled, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, err
+	}
+
+	t.pattern = compiled
+	return t, nil
+}
+
+func (t *URITemplate) Parse(r *http.Request, params httprouter.Params) (url.Values, httprouter.Params, error) {
+	matches := t.pattern.FindStringSubmatch(r.URL.EscapedPath())
+	if matches == nil {
+		return nil, nil, fmt.Errorf("path %q doesn't match uri template %q", r.URL.Path, t.template)
+	}
+
+	values := url.Values{}
+	for i, exp := range t.expressions {
+		matched := matches[i+1]
+		if exp.operator == ';' {
+			if _, err := splitMatrixParameters(matched, values); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		var parts []string
+		switch exp.operator {
+		case '.', '/':
+			if matched == "" {
+				continue
+			}
+
+			parts = strings.Split(matched[1:], string(exp.operator))
+		default:
+			parts = strings.Split(matched, ",")
+		}
+
+		for j, part := range parts {
+			value, err := url.PathUnescape(part)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			// the surplus parts belong to the last variable, as it is a list or exploded variable.
+			name := exp.variables[len(exp.variables)-1]
+			if j < len(exp.variables) {
+				name = exp.variables[j]
+			}
+
+			values.Add(name, value)
+		}
+	}
+
+	return values, params, nil
+}
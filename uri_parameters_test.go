@@ -0,0 +1,72 @@
+package apihttpwrapper
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestArgumentParserExtensions(t *testing.T) {
+	type args struct {
+		Name    string
+		Age     int
+		Tags    []string
+		Version int
+		Format  string
+	}
+
+	serve := func(extension ArgumentParserExtension, uri string, params httprouter.Params) *args {
+		var got *args
+		h, err := NewServiceHandler(func(_ *ServiceMethodContext, a *args) error {
+			got = a
+			return nil
+		}, nil, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h.SetArgumentParserExtensions(extension)
+		recorder := httptest.NewRecorder()
+		h.ServeHTTPWithParams(recorder, httptest.NewRequest("GET", uri, nil), params)
+		if recorder.Code != 200 {
+			t.Error(recorder.Code, recorder.Body)
+		}
+
+		return got
+	}
+
+	t.Run("matrix parameters", func(t *testing.T) {
+		a := serve(MatrixParameters{}, "/user/bob;Age=18;Tags=a,b",
+			httprouter.Params{{Key: "Name", Value: "bob;Age=18;Tags=a,b"}})
+		if a.Name != "bob" || a.Age != 18 || len(a.Tags) != 2 || a.Tags[1] != "b" {
+			t.Error(a)
+		}
+	})
+
+	t.Run("uri template", func(t *testing.T) {
+		template, err := NewURITemplate("/user/{Name}{.Format}{;Age,Version}/tags{/Tags*}{?x}")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a := serve(template, "/user/b%20ob.json;Age=18;Version=2/tags/a/b?x=1", nil)
+		if a.Name != "b ob" || a.Format != "json" || a.Age != 18 || a.Version != 2 || len(a.Tags) != 2 {
+			t.Error(a)
+		}
+	})
+
+	t.Run("uri template mismatch", func(t *testing.T) {
+		template, err := NewURITemplate("/user/{Name}")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h, _ := NewServiceHandler(func(*ServiceMethodContext, *args) error { return nil }, nil, true)
+		h.SetArgumentParserExtensions(template)
+		recorder := httptest.NewRecorder()
+		h.ServeHTTPWithParams(recorder, httptest.NewRequest("GET", "/users/bob", nil), nil)
+		if recorder.Code != 400 {
+			t.Error(recorder.Code)
+		}
+	})
+}
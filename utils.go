@@ -1,9 +1,14 @@
 package apihttpwrapper
 
 import (
+	"fmt"
+	"github.com/go-playground/validator/v10"
 	"github.com/julienschmidt/httprouter"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"io"
 	"net/http"
+	"reflect"
+	"time"
 )
 
 type methodLogger struct {
@@ -16,6 +21,10 @@ func (l *methodLogger) Record(field string, value string) {
 	l.row.SetRowField(field, value)
 }
 
+func (l *methodLogger) skipRow() {
+	l.row.skipped = true
+}
+
 func ServiceHandlerAccessLogRowFillerFactory(row *AccessLogRow) AccessLogRowFiller {
 	return &methodLogger{row}
 }
@@ -25,24 +34,483 @@ type Route struct {
 	Path              string
 	Function          interface{}
 	BypassRequestBody bool
+	// BindRequestBody binds the request body even if the group bypasses it.
+	BindRequestBody bool
+	Canary          *CanaryRoute
+	// ArgumentExtensions are the opt-in argument parsers, like MatrixParameters and URITemplate.
+	ArgumentExtensions []ArgumentParserExtension
+	// TabularExport allows the clients to download the result as csv or xlsx, see ServiceHandler.SetTabularExport.
+	TabularExport bool
+	// BufferRequestBody buffers the body for RequestBodyReader, see ServiceHandler.SetRequestBodyBuffer.
+	BufferRequestBody int64
+	// MaxBodyBytes rejects the larger request bodies with 413, 0 means unlimited. see ServiceHandler.SetMaxBodyBytes.
+	MaxBodyBytes int64
+	// MaxDecompressedBytes limits the decompressed request bodies, see ServiceHandler.SetMaxDecompressedBytes.
+	MaxDecompressedBytes int64
+	// RateLimit rejects the requests of the clients exceeding the rate with 429 before the middlewares, see RateLimit.
+	RateLimit *RateLimit
+	// ConcurrencyLimit rejects the requests beyond the concurrent ones with 503, see ConcurrencyLimit.
+	ConcurrencyLimit *ConcurrencyLimit
+	// Timeout limits the time of the service method call with 504, 0 means unlimited. see ServiceHandler.SetTimeout.
+	Timeout time.Duration
+	// WriteTimeout limits writing the response, see ServiceHandler.SetWriteTimeout.
+	WriteTimeout time.Duration
+	// EventHeartbeat is the heartbeat interval of the SSE responses of the method, see ServiceMethodContext.EventStream.
+	EventHeartbeat time.Duration
+	// Preflight answers the OPTIONS requests by the middlewares and the checks not needing the body.
+	Preflight bool
+	// CORS overrides RouterOptions.CORS for the route, see CORSPolicy.
+	CORS *CORSPolicy
+	// BodyLogging records the raw bodies into the access log while enabled, see BodyLogging.ServeHTTP.
+	BodyLogging *BodyLogging
+	// MaxCSVRows limits the rows of text/csv request body, 0 means DefaultMaxCSVRows and negative means unlimited.
+	MaxCSVRows int
+	// EventStream makes the route a subscription, Function should be a subscription method, see EventFilter.
+	EventStream *EventStream
+	// WebSocket makes the route a websocket endpoint, Function should be a websocket method, see WebSocket.
+	WebSocket *WebSocket
+	// Fake returns the generated data instead of calling Function, for the sandboxes, see FakeResponse.
+	Fake *FakeResponse
+	// Fallback is called when Function fails or times out, see ServiceHandler.SetFallback.
+	Fallback interface{}
+	// Compatibility rewrites the requests of legacy clients before binding, see CompatibilityRules.
+	Compatibility *CompatibilityRules
+	// EnvelopeVersion is EnvelopeV1 by default, see ServiceHandler.SetEnvelopeVersion.
+	EnvelopeVersion int
+	// WrapSuccessResponses wraps the results of EnvelopeV1, see ServiceHandler.SetWrapSuccessResponses.
+	WrapSuccessResponses bool
+	// Enveloper shapes the responses of the route instead of RouterOptions.Enveloper.
+	Enveloper ResponseEnveloper
+	// HotSwap allows replacing Function after registration, see HotSwap.Swap.
+	HotSwap *HotSwap
+	// Middlewares wrap the route in order inside the RouterOptions.Middlewares.
+	Middlewares []func(http.Handler) http.Handler
+	// LogFields records the values selected from the result into the access log, see ServiceHandler.SetLogFields.
+	LogFields map[string]string
+	// LogCoalescingWindow is for the hot polling routes, see ServiceHandler.SetLogCoalescingWindow.
+	LogCoalescingWindow time.Duration
+	// LogRedactedFields are masked besides RouterOptions.LogRedactedFields.
+	LogRedactedFields []string
+	// MaxLoggedBytes truncates the logged args and resp, see ServiceHandler.SetMaxLoggedBytes.
+	MaxLoggedBytes int
+	// Destructive requires the confirmation tokens, see DestructiveGuard.
+	Destructive *DestructiveGuard
+	// Undo makes the route accept the undo tokens of a destructive route, see UndoRoute.
+	Undo *UndoRoute
+	// DisallowUnknownFields rejects the unknown fields of the JSON bodies, see ServiceHandler.SetDisallowUnknownFields.
+	DisallowUnknownFields bool
+	// StructuredParseErrors responds the parse failures with 422, see ServiceHandler.SetStructuredParseErrors.
+	StructuredParseErrors bool
+	// MultipartMemory is of the multipart/form-data uploads, see ServiceHandler.SetMultipartMemory.
+	MultipartMemory int64
+	// ArgumentSources are the sources of the arguments lowest first, see ServiceHandler.SetArgumentSources.
+	ArgumentSources []ArgumentSource
+	// Deduplication rejects the double submits within the window with 409.
+	Deduplication *Deduplication
+}
+
+// RouterOptions are the settings shared by all routes registered together.
+type RouterOptions struct {
+	Metrics MetricsCollector
+	// Encoders negotiate the response encoding by the Accept header, JSON only if nil. see EncoderRegistry.
+	Encoders *EncoderRegistry
+	// Crypter is required by the routes having crypt tagged fields, see ServiceHandler.SetCrypter.
+	Crypter Crypter
+	// Middlewares wrap all routes in order, the first one is the outermost. see Route.Middlewares.
+	Middlewares []func(http.Handler) http.Handler
+	// RequirePrincipal rejects the requests not authenticated by the middlewares with 401.
+	RequirePrincipal bool
+	// Validator checks the validate tags of the arguments, the shared default one if nil. see ServiceHandler.SetValidator.
+	Validator *validator.Validate
+	// ResponseHeaders are copied into the response headers of all routes, see ResponseHeaderMapping.
+	ResponseHeaders []*ResponseHeaderMapping
+	// TracerProvider starts an OpenTelemetry span per request if set, see ServiceHandler.SetTracerProvider.
+	TracerProvider oteltrace.TracerProvider
+	// OpenAPI serves the OpenAPI document of the routes registered together, see GenerateOpenAPI.
+	OpenAPI *OpenAPIOptions
+	// Health mounts the liveness and readiness paths beside the routes, see RegisterHealthRoutes.
+	Health *HealthOptions
+	// AccessLogEnrichers are only used by NewLoggingHTTPRouterWithOptions, see AccessLogDecorator.SetEnrichers.
+	AccessLogEnrichers []AccessLogEnricher
+	// AccessLogSchema is only used by NewLoggingHTTPRouterWithOptions, see AccessLogDecorator.SetRowSchema.
+	AccessLogSchema []string
+	// AccessLogEncoder is only used by NewLoggingHTTPRouterWithOptions, see AccessLogDecorator.SetEncoder.
+	AccessLogEncoder AccessLogEncoder
+	// AccessLogSink is only used by NewLoggingHTTPRouterWithOptions, see AccessLogDecorator.AddSink.
+	AccessLogSink *AccessLogSink
+	// AccessLogSkipFilter is only used by NewLoggingHTTPRouterWithOptions, see AccessLogDecorator.SetSkipFilter.
+	AccessLogSkipFilter func(*http.Request) bool
+	// ConnTimings is only used by NewLoggingHTTPRouterWithOptions, see AccessLogDecorator.SetConnTimingTracker.
+	ConnTimings *ConnTimingTracker
+	// TypeLint checks the argument and response types of the routes at registration if set, see TypeLintOptions.
+	TypeLint *TypeLintOptions
+	// LogRedactedFields are masked in the logged args and resp of all routes, like "password".
+	LogRedactedFields []string
+	// MaxLoggedBytes is of the routes whose Route.MaxLoggedBytes is 0, see ServiceHandler.SetMaxLoggedBytes.
+	MaxLoggedBytes int
+	// Classifier tags the requests as human, bot or probe, see RequestClassifier.
+	Classifier RequestClassifier
+	// UnloggedTraffic are the classes of Classifier whose requests are not in the access log, like TrafficProbe.
+	UnloggedTraffic []TrafficClass
+	// SecureCookie enables the encrypted cookies, see ServiceHandler.SetSecureCookie.
+	SecureCookie *SecureCookie
+	// PanicHook receives the panics of all routes, see ServiceHandler.SetPanicHook.
+	PanicHook PanicHook
+	// ExposePanicDetails is only for the development, see ServiceHandler.SetExposePanicDetails.
+	ExposePanicDetails bool
+	// ResponseDigests is only used by NewLoggingHTTPRouterWithOptions, see ResponseDigestDecorator.
+	ResponseDigests *ResponseDigestOptions
+	// Compression is only used by NewLoggingHTTPRouterWithOptions, see CompressionDecorator.
+	Compression *CompressionOptions
+	// Enveloper shapes the responses of all routes instead of the envelope versions, see ResponseEnveloper.
+	Enveloper ResponseEnveloper
+	// CORS allows the cross-origin requests of all routes, see CORSPolicy.
+	CORS *CORSPolicy
+	// DisallowUnknownFields is Route.DisallowUnknownFields of all routes.
+	DisallowUnknownFields bool
+	// StructuredParseErrors is Route.StructuredParseErrors of all routes.
+	StructuredParseErrors bool
+	// IdentityLogClaims are logged, DefaultIdentityLogClaims if nil and none if empty.
+	IdentityLogClaims []string
+	// Experiments assigns the experiment variants of the requests inside the middlewares, see ExperimentOptions.
+	Experiments *ExperimentOptions
+}
+
+type CompressionOptions struct {
+	// Level is of compress/gzip, 0 means gzip.DefaultCompression.
+	Level int
+	// Encodings are in the order of preference, only gzip if nil. see CompressionDecorator.SetEncodings.
+	Encodings []string
+	// MinSize and ContentTypes are of CompressionDecorator.SetMinSize and CompressionDecorator.SetContentTypes.
+	MinSize      int
+	ContentTypes []string
+}
+
+type ResponseDigestOptions struct {
+	// ETag makes the digests also be the ETag headers, see ResponseDigestDecorator.SetETag.
+	ETag bool
+}
+
+func newRouteServiceHandler(rt *Route, function interface{}, loggerContextKey interface{},
+	options *RouterOptions) (*ServiceHandler, error) {
+	handler, err := NewServiceHandler(function, loggerContextKey, rt.BypassRequestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	methodType := reflect.TypeOf(function)
+	if options.Crypter == nil && (hasCryptFields(methodType.In(1)) ||
+		(methodType.NumOut() == 2 && hasCryptFields(methodType.Out(0)))) {
+		return nil, fmt.Errorf("crypt fields need a Crypter")
+	}
+
+	if err := setRouteServiceHandler(handler, rt, options); err != nil {
+		return nil, err
+	}
+
+	return handler, nil
+}
+
+// setRouteServiceHandler applies the settings of the route and the router to the handler.
+func setRouteServiceHandler(handler *ServiceHandler, rt *Route, options *RouterOptions) error {
+	handler.SetArgumentParserExtensions(rt.ArgumentExtensions...)
+	handler.SetTabularExport(rt.TabularExport)
+	handler.SetEnvelopeVersion(rt.EnvelopeVersion)
+	handler.SetWrapSuccessResponses(rt.WrapSuccessResponses)
+	handler.SetCrypter(options.Crypter)
+	handler.SetEncoders(options.Encoders)
+	handler.SetResponseHeaderMappings(options.ResponseHeaders...)
+	handler.SetTracerProvider(options.TracerProvider, rt.Path)
+	handler.SetLogCoalescingWindow(rt.LogCoalescingWindow)
+	handler.SetRequestBodyBuffer(rt.BufferRequestBody)
+	handler.SetMaxBodyBytes(rt.MaxBodyBytes)
+	handler.SetMaxDecompressedBytes(rt.MaxDecompressedBytes)
+	handler.SetTimeout(rt.Timeout)
+	handler.SetWriteTimeout(rt.WriteTimeout)
+	handler.SetEventHeartbeat(rt.EventHeartbeat)
+	handler.SetDisallowUnknownFields(rt.DisallowUnknownFields || options.DisallowUnknownFields)
+	handler.SetStructuredParseErrors(rt.StructuredParseErrors || options.StructuredParseErrors)
+	handler.SetMultipartMemory(rt.MultipartMemory)
+	handler.SetBodyLogging(rt.BodyLogging)
+	handler.SetPanicHook(options.PanicHook)
+	handler.SetExposePanicDetails(options.ExposePanicDetails)
+	if rt.Enveloper != nil {
+		handler.SetResponseEnveloper(rt.Enveloper)
+	} else {
+		handler.SetResponseEnveloper(options.Enveloper)
+	}
+	handler.SetSecureCookie(options.SecureCookie)
+	handler.SetLogRedactedFields(append(append([]string(nil), options.LogRedactedFields...), rt.LogRedactedFields...)...)
+	if rt.MaxLoggedBytes != 0 {
+		handler.SetMaxLoggedBytes(rt.MaxLoggedBytes)
+	} else {
+		handler.SetMaxLoggedBytes(options.MaxLoggedBytes)
+	}
+
+	if err := handler.SetLogFields(rt.LogFields); err != nil {
+		return err
+	}
+
+	if err := handler.SetArgumentSources(rt.ArgumentSources...); err != nil {
+		return err
+	}
+
+	if err := handler.SetFallback(rt.Fallback); err != nil {
+		return err
+	}
+
+	if options.Validator != nil {
+		handler.SetValidator(options.Validator)
+	}
+
+	if rt.MaxCSVRows != 0 {
+		handler.SetMaxCSVRows(rt.MaxCSVRows)
+	}
+	return nil
+}
+
+// checkRoutePrototype checks the function by the kind of the route.
+func checkRoutePrototype(rt *Route) error {
+	if rt.EventStream != nil {
+		return checkEventStreamMethodPrototype(reflect.TypeOf(rt.Function))
+	}
+
+	if rt.WebSocket != nil {
+		return checkWebSocketMethodPrototype(reflect.TypeOf(rt.Function))
+	}
+
+	return checkServiceMethodPrototype(reflect.TypeOf(rt.Function))
+}
+
+// routeMiddlewares has the principal check innermost, so both the global and the route middlewares can authenticate.
+func routeMiddlewares(rt *Route, options *RouterOptions, handler *ServiceHandler) []func(http.Handler) http.Handler {
+	middlewares := make([]func(http.Handler) http.Handler, 0, len(options.Middlewares)+len(rt.Middlewares)+1)
+	middlewares = append(append(middlewares, options.Middlewares...), rt.Middlewares...)
+	if options.RequirePrincipal {
+		middlewares = append(middlewares, requirePrincipal(handler))
+	}
+
+	return middlewares
+}
+
+func routeIdentityLogClaims(options *RouterOptions) []string {
+	if options.IdentityLogClaims == nil {
+		return DefaultIdentityLogClaims
+	}
+
+	return options.IdentityLogClaims
+}
+
+func newRouteHandle(rt *Route, loggerContextKey interface{}, options *RouterOptions) (httprouter.Handle, error) {
+	if rt.HotSwap != nil {
+		return rt.HotSwap.bind(rt.Function, func(function interface{}) (httprouter.Handle, error) {
+			swapped := *rt
+			swapped.Function = function
+			swapped.HotSwap = nil
+			return newRouteHandle(&swapped, loggerContextKey, options)
+		})
+	}
+
+	if rt.EventStream != nil || rt.WebSocket != nil {
+		if err := checkRoutePrototype(rt); err != nil {
+			return nil, err
+		}
+
+		handler, err := newStreamServiceHandler(rt, loggerContextKey, options)
+		if err != nil {
+			return nil, err
+		}
+
+		var handle httprouter.Handle
+		if rt.EventStream != nil {
+			handle, err = newEventStreamHandle(rt.EventStream, handler, loggerContextKey, routeCORSPolicy(rt, options))
+		} else {
+			handle = newWebSocketHandle(rt.WebSocket, handler, routeCORSPolicy(rt, options))
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if options.Experiments != nil {
+			handle = newExperimentHandle(handle, options.Experiments, loggerContextKey)
+		}
+
+		if claims := routeIdentityLogClaims(options); len(claims) > 0 {
+			handle = newIdentityLogHandle(handle, claims, loggerContextKey)
+		}
+
+		handle = newMiddlewareHandle(handle, routeMiddlewares(rt, options, handler))
+		if rt.RateLimit != nil {
+			handle = newRateLimitHandle(handle, rt.RateLimit, handler)
+		}
+
+		if policy := routeCORSPolicy(rt, options); policy != nil {
+			handle = newCORSHandle(handle, policy)
+		}
+
+		if options.Metrics != nil {
+			handle = newMetricsHandle(handle, rt.Method, rt.Path, options.Metrics, options.TracerProvider != nil)
+		}
+
+		if options.Classifier != nil {
+			handle = newClassifierHandle(handle, options.Classifier, options.UnloggedTraffic, loggerContextKey)
+		}
+
+		return handle, nil
+	}
+
+	function := rt.Function
+	if rt.Fake != nil {
+		function = rt.Fake.function(function)
+	}
+
+	handler, err := newRouteServiceHandler(rt, function, loggerContextKey, options)
+	if err != nil {
+		return nil, err
+	}
+
+	handle := httprouter.Handle(handler.ServeHTTPWithParams)
+	if rt.Canary != nil && rt.Fake == nil {
+		canaryHandler, err := newRouteServiceHandler(rt, rt.Canary.Function, loggerContextKey, options)
+		if err != nil {
+			return nil, err
+		}
+
+		handle = newCanaryHandle(rt.Canary, handler, canaryHandler, loggerContextKey)
+	}
+
+	if rt.Compatibility != nil {
+		handle = newCompatibilityHandle(handle, rt.Compatibility)
+	}
+
+	// the tokens are bound to the principal, so they are checked inside the authentication middlewares.
+	if rt.Destructive != nil {
+		handle = newDestructiveHandle(handle, rt, loggerContextKey)
+	}
+
+	if rt.Undo != nil {
+		handle = newUndoHandle(handle, rt.Undo, loggerContextKey)
+	}
+
+	if rt.Deduplication != nil {
+		handle = newDeduplicationHandle(handle, rt.Deduplication, handler, loggerContextKey)
+	}
+
+	if options.Experiments != nil {
+		handle = newExperimentHandle(handle, options.Experiments, loggerContextKey)
+	}
+
+	if claims := routeIdentityLogClaims(options); len(claims) > 0 {
+		handle = newIdentityLogHandle(handle, claims, loggerContextKey)
+	}
+
+	handle = newMiddlewareHandle(handle, routeMiddlewares(rt, options, handler))
+	if rt.ConcurrencyLimit != nil {
+		handle = newConcurrencyLimitHandle(handle, rt.ConcurrencyLimit, handler, rt.Method, rt.Path,
+			options.Metrics)
+	}
+
+	if rt.RateLimit != nil {
+		handle = newRateLimitHandle(handle, rt.RateLimit, handler)
+	}
+
+	// the CORS headers are outside the middlewares, so the browsers can read their errors like 401.
+	if policy := routeCORSPolicy(rt, options); policy != nil {
+		handle = newCORSHandle(handle, policy)
+	}
+
+	if options.Metrics != nil {
+		handle = newMetricsHandle(handle, rt.Method, rt.Path, options.Metrics, options.TracerProvider != nil)
+	}
+
+	// the class is in the context of all the others, including the metrics.
+	if options.Classifier != nil {
+		handle = newClassifierHandle(handle, options.Classifier, options.UnloggedTraffic, loggerContextKey)
+	}
+
+	return handle, nil
 }
 
 func RegisterRoutes(r *httprouter.Router, loggerContextKey interface{}, routes []*Route) error {
-	for _, rt := range routes {
-		handler, err := NewServiceHandler(rt.Function, loggerContextKey, rt.BypassRequestBody)
+	return RegisterRoutesWithOptions(r, loggerContextKey, routes, nil)
+}
+
+func RegisterRoutesWithOptions(r *httprouter.Router, loggerContextKey interface{}, routes []*Route,
+	options *RouterOptions) error {
+	keys := make([]interface{}, len(routes))
+	for i := range keys {
+		keys[i] = loggerContextKey
+	}
+
+	return registerRoutes(r, keys, routes, options)
+}
+
+// registerRoutes registers each route with the logger context key of the same index.
+func registerRoutes(r *httprouter.Router, loggerContextKeys []interface{}, routes []*Route,
+	options *RouterOptions) error {
+	if options == nil {
+		options = &RouterOptions{}
+	}
+
+	if err := ValidateRoutes(routes); err != nil {
+		return err
+	}
+
+	if options.SecureCookie != nil {
+		if err := options.SecureCookie.Check(); err != nil {
+			return err
+		}
+	}
+
+	if options.CORS != nil {
+		if err := options.CORS.Check(); err != nil {
+			return err
+		}
+	}
+
+	if options.TypeLint != nil {
+		if err := options.TypeLint.lintRoutes(routes); err != nil {
+			return err
+		}
+	}
+
+	for i, rt := range routes {
+		handle, err := newRouteHandle(rt, loggerContextKeys[i], options)
 		if err != nil {
+			return &RouteError{Index: i, Route: rt, Err: err}
+		}
+
+		// the router may have the conflicting routes registered before.
+		if err := handleRoute(r, rt.Method, rt.Path, handle); err != nil {
+			return &RouteError{Index: i, Route: rt, Err: err}
+		}
+	}
+
+	if err := registerPreflights(r, loggerContextKeys, routes, options); err != nil {
+		return err
+	}
+
+	if options.Health != nil {
+		if err := RegisterHealthRoutes(r, options.Health); err != nil {
 			return err
 		}
+	}
 
-		r.Handle(rt.Method, rt.Path, handler.ServeHTTPWithParams)
+	if options.OpenAPI != nil {
+		return registerOpenAPI(r, routes, options.OpenAPI)
 	}
 
 	return nil
 }
 
 func NewHTTPRouter(routes []*Route) (*httprouter.Router, error) {
+	return NewHTTPRouterWithOptions(routes, nil)
+}
+
+func NewHTTPRouterWithOptions(routes []*Route, options *RouterOptions) (*httprouter.Router, error) {
 	router := httprouter.New()
-	err := RegisterRoutes(router, ServiceHandlerAccessLogRowFillerContextKey, routes)
+	err := RegisterRoutesWithOptions(router, ServiceHandlerAccessLogRowFillerContextKey, routes, options)
 	if err != nil {
 		return nil, err
 	}
@@ -51,11 +519,69 @@ func NewHTTPRouter(routes []*Route) (*httprouter.Router, error) {
 }
 
 func NewLoggingHTTPRouter(routes []*Route, loggingHeaders []string, logWriter io.Writer) (http.Handler, error) {
-	router, err := NewHTTPRouter(routes)
+	return NewLoggingHTTPRouterWithOptions(routes, loggingHeaders, logWriter, nil)
+}
+
+func NewLoggingHTTPRouterWithOptions(routes []*Route, loggingHeaders []string, logWriter io.Writer,
+	options *RouterOptions) (http.Handler, error) {
+	router, err := NewHTTPRouterWithOptions(routes, options)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewAccessLogDecorator(router, logWriter, loggingHeaders, ServiceHandlerAccessLogRowFillerContextKey,
-		ServiceHandlerAccessLogRowFillerFactory), nil
+	return newLoggingHandler(router, loggingHeaders, logWriter, options)
+}
+
+// newLoggingHandler wraps the router by the decorators of the options, which may be nil.
+func newLoggingHandler(router http.Handler, loggingHeaders []string, logWriter io.Writer,
+	options *RouterOptions) (http.Handler, error) {
+	handler := router
+	if options != nil && options.Compression != nil {
+		compression := NewCompressionDecorator(handler, ServiceHandlerAccessLogRowFillerContextKey,
+			options.Compression.Level)
+		if options.Compression.Encodings != nil {
+			if err := compression.SetEncodings(options.Compression.Encodings...); err != nil {
+				return nil, err
+			}
+		}
+
+		compression.SetMinSize(options.Compression.MinSize)
+		compression.SetContentTypes(options.Compression.ContentTypes...)
+		handler = compression
+	}
+
+	// the digests are of the compressed bodies, the same as the clients receive.
+	if options != nil && options.ResponseDigests != nil {
+		digests := NewResponseDigestDecorator(handler, ServiceHandlerAccessLogRowFillerContextKey)
+		digests.SetETag(options.ResponseDigests.ETag)
+		handler = digests
+	}
+
+	return newAccessLogDecorator(handler, loggingHeaders, logWriter, options), nil
+}
+
+// newAccessLogDecorator applies the access log settings of the options, which may be nil.
+func newAccessLogDecorator(handler http.Handler, loggingHeaders []string, logWriter io.Writer,
+	options *RouterOptions) *AccessLogDecorator {
+	decorator := NewAccessLogDecorator(handler, logWriter, loggingHeaders, ServiceHandlerAccessLogRowFillerContextKey,
+		ServiceHandlerAccessLogRowFillerFactory)
+	if options != nil {
+		decorator.SetEnrichers(options.AccessLogEnrichers...)
+		if options.AccessLogSchema != nil {
+			decorator.SetRowSchema(options.AccessLogSchema...)
+		}
+
+		if options.AccessLogSink != nil {
+			decorator.AddSink(options.AccessLogSink)
+		}
+
+		if options.AccessLogEncoder != nil {
+			decorator.SetEncoder(options.AccessLogEncoder)
+		}
+
+		decorator.SetConnTimingTracker(options.ConnTimings)
+		decorator.SetSkipFilter(options.AccessLogSkipFilter)
+	}
+
+	return decorator
 }
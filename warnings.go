@@ -0,0 +1,53 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// WarningsHeader carries the JSON array of the warnings in the legacy envelope, whose results are written as is.
+const WarningsHeader = "X-Warnings"
+
+// Warning is a machine-readable note of a partially succeeded request, like a skipped item of a bulk operation.
+type Warning struct {
+	Code    string `json:"code" xml:"code"`
+	Message string `json:"message" xml:"message"`
+	// Target locates the part of the request the warning is about, like the index of the item.
+	Target string `json:"target,omitempty" xml:"target,omitempty"`
+}
+
+type warningList struct {
+	mu       sync.Mutex
+	warnings []*Warning
+}
+
+// AddWarning adds a warning to the response, it is safe to call from the goroutines spawned by Go.
+func (ctx *ServiceMethodContext) AddWarning(warning *Warning) {
+	if ctx.warnings == nil {
+		// the context is not made by the framework.
+		ctx.warnings = &warningList{}
+	}
+
+	ctx.warnings.mu.Lock()
+	ctx.warnings.warnings = append(ctx.warnings.warnings, warning)
+	ctx.warnings.mu.Unlock()
+}
+
+func (l *warningList) list() []*Warning {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]*Warning(nil), l.warnings...)
+}
+
+// setWarningsHeader is for the legacy envelope, it should be called before the response header is written.
+func setWarningsHeader(w http.ResponseWriter, warnings []*Warning) {
+	if len(warnings) == 0 {
+		return
+	}
+
+	if b, err := json.Marshal(warnings); err == nil {
+		w.Header().Set(WarningsHeader, string(b))
+	}
+}
@@ -0,0 +1,432 @@
+package apihttpwrapper
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	mrand "math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type WebhookEvent struct {
+	URL     string
+	Type    string
+	Payload interface{}
+}
+
+type WebhookDelivery struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Type        string    `json:"type"`
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"lastError,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	LastAttempt time.Time `json:"lastAttempt"`
+}
+
+type WebhookDispatcherConfig struct {
+	Client          *http.Client
+	KeyRing         *SigningKeyRing
+	SignatureHeader string
+	MaxAttempts     int
+	InitialBackoff  time.Duration
+	MaxBackoff      time.Duration
+	Workers         int
+	QueueSize       int
+	// delivered records are dropped after Retention, dead letters after DeadLetterRetention unless retried.
+	Retention           time.Duration
+	DeadLetterRetention time.Duration
+	LogWriter           io.Writer
+}
+
+type WebhookDispatcher struct {
+	config     WebhookDispatcherConfig
+	logger     *logrus.Logger
+	queue      chan *webhookTask
+	mu         sync.RWMutex
+	deliveries map[string]*webhookTask
+	stopping   chan struct{}
+	wg         sync.WaitGroup
+	stopOnce   sync.Once
+}
+
+type webhookTask struct {
+	delivery WebhookDelivery
+	body     []byte
+	// deadAt tells the retention timer whether the task died again after it was started.
+	deadAt time.Time
+}
+
+const (
+	WebhookStatusPending   = "pending"
+	WebhookStatusDelivered = "delivered"
+	WebhookStatusDead      = "dead"
+)
+
+const (
+	webhookEventIDHeader   = "X-Webhook-Id"
+	webhookEventTypeHeader = "X-Webhook-Event"
+)
+
+func NewWebhookDispatcher(config WebhookDispatcherConfig) *WebhookDispatcher {
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	if config.SignatureHeader == "" {
+		config.SignatureHeader = DefaultSignatureHeader
+	}
+
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = time.Second
+	}
+
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 5 * time.Minute
+	}
+
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+
+	if config.QueueSize <= 0 {
+		config.QueueSize = 1024
+	}
+
+	if config.Retention <= 0 {
+		config.Retention = time.Hour
+	}
+
+	if config.DeadLetterRetention <= 0 {
+		config.DeadLetterRetention = 7 * 24 * time.Hour
+	}
+
+	logger := logrus.New()
+	logger.Formatter = &logrus.TextFormatter{DisableTimestamp: true}
+	if config.LogWriter != nil {
+		logger.Out = config.LogWriter
+	} else {
+		logger.Out = ioutil.Discard
+	}
+
+	d := &WebhookDispatcher{
+		config:     config,
+		logger:     logger,
+		queue:      make(chan *webhookTask, config.QueueSize),
+		deliveries: make(map[string]*webhookTask),
+		stopping:   make(chan struct{}),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+
+	return d
+}
+
+func newWebhookID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Enqueue queues the event for delivery, the id is of the delivery status.
+func (d *WebhookDispatcher) Enqueue(event *WebhookEvent) (string, error) {
+	body, err := json.Marshal(event.Payload)
+	if err != nil {
+		return "", err
+	}
+
+	task := &webhookTask{
+		delivery: WebhookDelivery{
+			ID:        newWebhookID(),
+			URL:       event.URL,
+			Type:      event.Type,
+			Status:    WebhookStatusPending,
+			CreatedAt: time.Now(),
+		},
+		body: body,
+	}
+
+	d.mu.Lock()
+	d.deliveries[task.delivery.ID] = task
+	d.mu.Unlock()
+
+	if err := d.push(task); err != nil {
+		d.mu.Lock()
+		delete(d.deliveries, task.delivery.ID)
+		d.mu.Unlock()
+		return "", err
+	}
+
+	return task.delivery.ID, nil
+}
+
+func (d *WebhookDispatcher) push(task *webhookTask) error {
+	select {
+	case <-d.stopping:
+		return fmt.Errorf("webhook dispatcher has been stopped")
+	default:
+	}
+
+	select {
+	case d.queue <- task:
+		return nil
+	default:
+		return fmt.Errorf("webhook queue is full")
+	}
+}
+
+func (d *WebhookDispatcher) Delivery(id string) (*WebhookDelivery, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	task, ok := d.deliveries[id]
+	if !ok {
+		return nil, false
+	}
+
+	delivery := task.delivery
+	return &delivery, true
+}
+
+func (d *WebhookDispatcher) DeadLetters() []*WebhookDelivery {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	deliveries := make([]*WebhookDelivery, 0)
+	for _, task := range d.deliveries {
+		if task.delivery.Status == WebhookStatusDead {
+			delivery := task.delivery
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+
+	return deliveries
+}
+
+// Redeliver requeues a dead letter with its attempts reset.
+func (d *WebhookDispatcher) Redeliver(id string) error {
+	d.mu.Lock()
+	task, ok := d.deliveries[id]
+	if !ok || task.delivery.Status != WebhookStatusDead {
+		d.mu.Unlock()
+		return &StatusError{Status: http.StatusNotFound, Message: fmt.Sprintf("dead letter %q not found", id)}
+	}
+
+	attempts := task.delivery.Attempts
+	task.delivery.Status = WebhookStatusPending
+	task.delivery.Attempts = 0
+	d.mu.Unlock()
+
+	if err := d.push(task); err != nil {
+		d.mu.Lock()
+		task.delivery.Attempts = attempts
+		d.markDead(task, task.delivery.LastError)
+		d.mu.Unlock()
+		return &StatusError{Status: http.StatusServiceUnavailable, Message: err.Error()}
+	}
+
+	return nil
+}
+
+// markDead is called with mu locked, the dead letter is dropped after DeadLetterRetention unless it's redelivered.
+func (d *WebhookDispatcher) markDead(task *webhookTask, lastError string) {
+	deadAt := time.Now()
+	task.delivery.Status = WebhookStatusDead
+	task.delivery.LastError = lastError
+	task.deadAt = deadAt
+	time.AfterFunc(d.config.DeadLetterRetention, func() {
+		d.mu.Lock()
+		if task.delivery.Status == WebhookStatusDead && task.deadAt.Equal(deadAt) {
+			delete(d.deliveries, task.delivery.ID)
+		}
+		d.mu.Unlock()
+	})
+}
+
+// Stop waits the deliveries in progress, the queued events become dead.
+func (d *WebhookDispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stopping)
+		d.wg.Wait()
+
+		for {
+			select {
+			case task := <-d.queue:
+				d.mu.Lock()
+				d.markDead(task, "webhook dispatcher has been stopped")
+				d.mu.Unlock()
+			default:
+				return
+			}
+		}
+	})
+}
+
+func (d *WebhookDispatcher) work() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.stopping:
+			return
+		case task := <-d.queue:
+			d.deliver(task)
+		}
+	}
+}
+
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	backoff := d.config.InitialBackoff
+	for i := 1; i < attempts && backoff < d.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > d.config.MaxBackoff {
+		backoff = d.config.MaxBackoff
+	}
+
+	// add up to 20% jitter so that retries of a failed receiver don't arrive in bursts.
+	return backoff + time.Duration(mrand.Int63n(int64(backoff)/5+1))
+}
+
+func (d *WebhookDispatcher) send(task *webhookTask) error {
+	req, err := http.NewRequest("POST", task.delivery.URL, bytes.NewReader(task.body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventIDHeader, task.delivery.ID)
+	req.Header.Set(webhookEventTypeHeader, task.delivery.Type)
+	if d.config.KeyRing != nil {
+		signature, err := d.config.KeyRing.SignatureHeaderValue(task.body)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set(d.config.SignatureHeader, signature)
+	}
+
+	resp, err := d.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("receiver responded status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (d *WebhookDispatcher) deliver(task *webhookTask) {
+	beginTime := time.Now()
+	err := d.send(task)
+
+	d.mu.Lock()
+	task.delivery.Attempts++
+	task.delivery.LastAttempt = beginTime
+	attempts := task.delivery.Attempts
+	if err == nil {
+		task.delivery.Status = WebhookStatusDelivered
+		task.delivery.LastError = ""
+	} else {
+		task.delivery.LastError = err.Error()
+		if attempts >= d.config.MaxAttempts {
+			d.markDead(task, err.Error())
+		}
+	}
+	status := task.delivery.Status
+	d.mu.Unlock()
+
+	fields := logrus.Fields{
+		"webhookId": task.delivery.ID,
+		"url":       task.delivery.URL,
+		"event":     task.delivery.Type,
+		"attempt":   attempts,
+		"status":    status,
+		"duration":  time.Now().Sub(beginTime).Seconds(),
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+		d.logger.WithFields(fields).Error()
+	} else {
+		d.logger.WithFields(fields).Info()
+	}
+
+	switch status {
+	case WebhookStatusDelivered:
+		time.AfterFunc(d.config.Retention, func() {
+			d.mu.Lock()
+			if task.delivery.Status == WebhookStatusDelivered {
+				delete(d.deliveries, task.delivery.ID)
+			}
+			d.mu.Unlock()
+		})
+	case WebhookStatusPending:
+		time.AfterFunc(d.backoff(attempts), func() {
+			if err := d.push(task); err != nil {
+				d.mu.Lock()
+				d.markDead(task, err.Error())
+				d.mu.Unlock()
+			}
+		})
+	}
+}
+
+type webhookDeliveryArgs struct {
+	ID string
+}
+
+func (d *WebhookDispatcher) getDelivery(_ *ServiceMethodContext, args *webhookDeliveryArgs) (*WebhookDelivery,
+	error) {
+	delivery, ok := d.Delivery(args.ID)
+	if !ok {
+		return nil, &StatusError{Status: http.StatusNotFound, Message: fmt.Sprintf("delivery %q not found", args.ID)}
+	}
+
+	return delivery, nil
+}
+
+func (d *WebhookDispatcher) listDeadLetters(_ *ServiceMethodContext, _ *struct{}) (*struct {
+	DeadLetters []*WebhookDelivery `json:"deadLetters"`
+}, error) {
+	return &struct {
+		DeadLetters []*WebhookDelivery `json:"deadLetters"`
+	}{d.DeadLetters()}, nil
+}
+
+func (d *WebhookDispatcher) redeliver(_ *ServiceMethodContext, args *webhookDeliveryArgs) (*WebhookDelivery,
+	error) {
+	if err := d.Redeliver(args.ID); err != nil {
+		return nil, err
+	}
+
+	delivery, _ := d.Delivery(args.ID)
+	return delivery, nil
+}
+
+// Routes returns the delivery status routes mounted under the prefix.
+func (d *WebhookDispatcher) Routes(prefix string) []*Route {
+	prefix = strings.TrimRight(prefix, "/")
+	return []*Route{
+		{Method: "GET", Path: prefix + "/deliveries/:ID", Function: d.getDelivery, BypassRequestBody: true},
+		{Method: "GET", Path: prefix + "/dead-letters", Function: d.listDeadLetters, BypassRequestBody: true},
+		{Method: "POST", Path: prefix + "/dead-letters/:ID/redeliver", Function: d.redeliver, BypassRequestBody: true},
+	}
+}
@@ -0,0 +1,127 @@
+package apihttpwrapper
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitDeliveryStatus(t *testing.T, d *WebhookDispatcher, id string, status string) *WebhookDelivery {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if delivery, ok := d.Delivery(id); ok && delivery.Status == status {
+			return delivery
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("delivery %s hasn't become %s", id, status)
+	return nil
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	signer := &HMACSigner{ID: "k1", Secret: []byte("secret")}
+	var failures int32 = 2
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "{\"A\":1}" || !strings.Contains(r.Header.Get(DefaultSignatureHeader), "keyid=\"k1\"") {
+			t.Error(string(body), r.Header)
+		}
+
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	d := NewWebhookDispatcher(WebhookDispatcherConfig{
+		KeyRing:        NewSigningKeyRing(signer),
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	})
+	defer d.Stop()
+
+	t.Run("delivered after retries", func(t *testing.T) {
+		id, err := d.Enqueue(&WebhookEvent{URL: receiver.URL, Type: "test", Payload: &struct{ A int }{1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		delivery := waitDeliveryStatus(t, d, id, WebhookStatusDelivered)
+		if delivery.Attempts != 3 {
+			t.Error(delivery)
+		}
+	})
+
+	t.Run("dead letter and redeliver", func(t *testing.T) {
+		atomic.StoreInt32(&failures, 3)
+		id, err := d.Enqueue(&WebhookEvent{URL: receiver.URL, Type: "test", Payload: &struct{ A int }{1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		waitDeliveryStatus(t, d, id, WebhookStatusDead)
+		if len(d.DeadLetters()) != 1 {
+			t.Error(d.DeadLetters())
+		}
+
+		router, err := NewHTTPRouter(d.Routes("/webhooks/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("POST", "/webhooks/dead-letters/"+id+"/redeliver", nil))
+		if recorder.Code != http.StatusOK {
+			t.Error(recorder.Code, recorder.Body)
+		}
+
+		waitDeliveryStatus(t, d, id, WebhookStatusDelivered)
+
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", "/webhooks/deliveries/missing", nil))
+		if recorder.Code != http.StatusNotFound || !strings.Contains(recorder.Body.String(), "not found") {
+			t.Error(recorder.Code, recorder.Body)
+		}
+	})
+
+	t.Run("dead letter retention", func(t *testing.T) {
+		stopped := NewWebhookDispatcher(WebhookDispatcherConfig{
+			KeyRing:             NewSigningKeyRing(signer),
+			MaxAttempts:         1,
+			DeadLetterRetention: 200 * time.Millisecond,
+		})
+		atomic.StoreInt32(&failures, 1)
+		id, err := stopped.Enqueue(&WebhookEvent{URL: receiver.URL, Type: "test", Payload: &struct{ A int }{1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		waitDeliveryStatus(t, stopped, id, WebhookStatusDead)
+		stopped.Stop()
+
+		// the dead letter which can't be queued is still dead.
+		err = stopped.Redeliver(id)
+		if httpError, ok := err.(HTTPError); !ok || httpError.StatusCode() != http.StatusServiceUnavailable {
+			t.Error(err)
+		}
+
+		if delivery, ok := stopped.Delivery(id); !ok || delivery.Status != WebhookStatusDead || delivery.Attempts != 1 {
+			t.Error(delivery)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for len(stopped.DeadLetters()) > 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if _, ok := stopped.Delivery(id); ok {
+			t.Error("the dead letter is kept after the retention")
+		}
+	})
+}
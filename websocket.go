@@ -0,0 +1,256 @@
+package apihttpwrapper
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// a minimal RFC 6455 server side implementation, enough for pushing messages and answering control frames.
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xa
+)
+
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const wsMaxControlPayload = 125
+
+type wsConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex
+}
+
+type wsFrame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+func headerContainsToken(h http.Header, name string, token string) bool {
+	for _, value := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func isWebSocketRequest(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+// webSocketOriginAllowed allows the same origin, the origins of policy and the clients which are not browsers.
+func webSocketOriginAllowed(r *http.Request, policy *CORSPolicy) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || (policy != nil && policy.originAllowed(origin)) {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
+
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if r.Method != "GET" || !isWebSocketRequest(r) {
+		return nil, fmt.Errorf("not a websocket handshake")
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, fmt.Errorf("unsupported websocket version")
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, fmt.Errorf("missing Sec-WebSocket-Key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, fmt.Errorf("the response writer doesn't support hijacking")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha1.Sum([]byte(key + wsAcceptGUID))
+	_, err = fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(digest[:]))
+	if err == nil {
+		err = rw.Flush()
+	}
+
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &wsConn{conn: conn, reader: rw.Reader}, nil
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte, deadline time.Time) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header[1] = 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+
+	_ = c.conn.SetWriteDeadline(deadline)
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+func (c *wsConn) WriteText(payload []byte, deadline time.Time) error {
+	return c.writeFrame(wsOpText, payload, deadline)
+}
+
+func (c *wsConn) WriteBinary(payload []byte, deadline time.Time) error {
+	return c.writeFrame(wsOpBinary, payload, deadline)
+}
+
+func (c *wsConn) WritePing(deadline time.Time) error {
+	return c.writeFrame(wsOpPing, nil, deadline)
+}
+
+// WriteClose sends a close frame with the status code, like 1000 for normal closure or 1008 for policy violation.
+func (c *wsConn) WriteClose(code uint16, reason string, deadline time.Time) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, code)
+	payload = append(payload, reason...)
+	if len(payload) > wsMaxControlPayload {
+		payload = payload[:wsMaxControlPayload]
+	}
+
+	return c.writeFrame(wsOpClose, payload, deadline)
+}
+
+func (c *wsConn) readFrame(maxPayload int64) (*wsFrame, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return nil, err
+	}
+
+	frame := &wsFrame{fin: header[0]&0x80 != 0, opcode: header[0] & 0x0f}
+	if header[1]&0x80 == 0 {
+		return nil, fmt.Errorf("client frames must be masked")
+	}
+
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(c.reader, ext); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(c.reader, ext); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext))
+	}
+
+	if length < 0 || (maxPayload > 0 && length > maxPayload) {
+		return nil, fmt.Errorf("websocket frame too large")
+	}
+
+	mask := make([]byte, 4)
+	if _, err := io.ReadFull(c.reader, mask); err != nil {
+		return nil, err
+	}
+
+	frame.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, frame.payload); err != nil {
+		return nil, err
+	}
+
+	for i := range frame.payload {
+		frame.payload[i] ^= mask[i%4]
+	}
+
+	return frame, nil
+}
+
+// ReadMessage answers the pings and reassembles the fragments, io.EOF means the client closed.
+func (c *wsConn) ReadMessage(maxPayload int64) (opcode byte, payload []byte, err error) {
+	for {
+		frame, err := c.readFrame(maxPayload)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch frame.opcode {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, frame.payload, time.Now().Add(10*time.Second)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			_ = c.writeFrame(wsOpClose, frame.payload, time.Now().Add(time.Second))
+			return 0, nil, io.EOF
+		case wsOpContinuation:
+			if opcode == 0 {
+				return 0, nil, fmt.Errorf("unexpected continuation frame")
+			}
+		default:
+			if opcode != 0 {
+				return 0, nil, fmt.Errorf("unexpected data frame in fragmented message")
+			}
+			opcode = frame.opcode
+		}
+
+		payload = append(payload, frame.payload...)
+		if maxPayload > 0 && int64(len(payload)) > maxPayload {
+			return 0, nil, fmt.Errorf("websocket message too large")
+		}
+
+		if frame.fin {
+			return opcode, payload, nil
+		}
+	}
+}
+
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,223 @@
+package apihttpwrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/trace"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// WebSocket makes the route a websocket endpoint of 'func(*ServiceMethodContext, *struct, *WebSocketConn) error'.
+type WebSocket struct {
+	// MaxMessageBytes limits the messages read, DefaultWebSocketMaxMessageBytes if not positive.
+	MaxMessageBytes int64
+	// WriteTimeout is the deadline of each write, 10 seconds if not positive.
+	WriteTimeout time.Duration
+}
+
+const DefaultWebSocketMaxMessageBytes = 1 << 20
+
+// the message types of WebSocketConn, the same as the opcodes.
+const (
+	WebSocketTextMessage   = wsOpText
+	WebSocketBinaryMessage = wsOpBinary
+)
+
+// WebSocketConn is read by one goroutine, the writes may be concurrent.
+type WebSocketConn struct {
+	conn            *wsConn
+	maxMessageBytes int64
+	writeTimeout    time.Duration
+	received        int64
+	sent            int64
+}
+
+var webSocketConnType = reflect.TypeOf((*WebSocketConn)(nil))
+
+// ReadMessage returns the next message and its type, io.EOF is returned once the client closes the connection.
+func (c *WebSocketConn) ReadMessage() (int, []byte, error) {
+	opcode, payload, err := c.conn.ReadMessage(c.maxMessageBytes)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	atomic.AddInt64(&c.received, 1)
+	return int(opcode), payload, nil
+}
+
+// ReadJSON reads the next message into v.
+func (c *WebSocketConn) ReadJSON(v interface{}) error {
+	_, payload, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(payload, v)
+}
+
+func (c *WebSocketConn) WriteMessage(messageType int, payload []byte) error {
+	if messageType != WebSocketTextMessage && messageType != WebSocketBinaryMessage {
+		return fmt.Errorf("invalid websocket message type %d", messageType)
+	}
+
+	if err := c.conn.writeFrame(byte(messageType), payload, time.Now().Add(c.writeTimeout)); err != nil {
+		return err
+	}
+
+	atomic.AddInt64(&c.sent, 1)
+	return nil
+}
+
+// WriteJSON writes v as a text message.
+func (c *WebSocketConn) WriteJSON(v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return c.WriteMessage(WebSocketTextMessage, payload)
+}
+
+func checkWebSocketMethodPrototype(methodType reflect.Type) error {
+	if methodType == nil || methodType.Kind() != reflect.Func {
+		return fmt.Errorf("you should provide a function or object method")
+	}
+
+	if methodType.NumIn() != 3 || !isTypeServiceMethodContext(methodType.In(0)) ||
+		!isStructPointer(methodType.In(1)) || methodType.In(2) != webSocketConnType {
+		return fmt.Errorf("the websocket method should have arguments (*ServiceMethodContext, *struct, *WebSocketConn)")
+	}
+
+	if !isCustomResponseBodyFunction(methodType) {
+		return fmt.Errorf("the websocket method should return error")
+	}
+
+	return nil
+}
+
+type webSocketHandler struct {
+	ws      *WebSocket
+	handler *ServiceHandler
+	cors    *CORSPolicy
+}
+
+func newWebSocketHandle(ws *WebSocket, handler *ServiceHandler, cors *CORSPolicy) httprouter.Handle {
+	h := &webSocketHandler{ws: ws, handler: handler, cors: cors}
+	return h.serve
+}
+
+// webSocketCloseReason is the message of resp with the incident id, the details are only in the access log.
+func webSocketCloseReason(resp *FormattedResponse) string {
+	if incident, ok := resp.Data.(*Incident); ok {
+		return resp.Msg + ", incident " + incident.ID
+	}
+
+	return resp.Msg
+}
+
+func (h *webSocketHandler) serve(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	tracer := trace.New(traceFamily, r.URL.Path)
+	defer tracer.Finish()
+
+	w, r, endSpan := h.handler.startSpan(w, r)
+	defer endSpan()
+
+	format := h.handler.negotiateResponseFormat(w, r)
+	if !isWebSocketRequest(r) {
+		w.Header().Set("Upgrade", "websocket")
+		writeEnvelopedError(w, tracer, format, &FormattedResponse{426, "websocket upgrade required", nil})
+		return
+	}
+
+	if !webSocketOriginAllowed(r, h.cors) {
+		writeEnvelopedError(w, tracer, format, &FormattedResponse{403, "websocket origin not allowed", nil})
+		return
+	}
+
+	ctx, cancel, md, err := requestContext(r)
+	if err != nil {
+		writeEnvelopedError(w, tracer, format, &FormattedResponse{400, "parse request metadata failed", err.Error()})
+		return
+	}
+	defer cancel()
+	h.handler.setMappedResponseHeaders(w, ctx)
+
+	arg, in := h.handler.method.newArgument()
+	if err := h.handler.parseArgument(r, params, arg.Interface()); err != nil {
+		writeEnvelopedError(w, tracer, format, parseFailedResponse(err))
+		return
+	}
+
+	if err := h.handler.validateArgument(arg); err != nil {
+		writeEnvelopedError(w, tracer, format, validationFailedResponse(err))
+		return
+	}
+
+	raw, err := upgradeWebSocket(w, r)
+	if err != nil {
+		writeEnvelopedError(w, tracer, format, &FormattedResponse{400, "websocket handshake failed", err.Error()})
+		return
+	}
+	defer raw.Close()
+
+	conn := &WebSocketConn{conn: raw, maxMessageBytes: h.ws.MaxMessageBytes, writeTimeout: h.ws.WriteTimeout}
+	if conn.maxMessageBytes <= 0 {
+		conn.maxMessageBytes = DefaultWebSocketMaxMessageBytes
+	}
+
+	if conn.writeTimeout <= 0 {
+		conn.writeTimeout = defaultEventWriteTimeout
+	}
+
+	goroutines := newGoroutineGroup()
+	defer h.handler.closeGoroutines(w, goroutines, h.handler.methodLogger(r))
+
+	logger := h.handler.methodLogger(r)
+	out, methodPanic := doServiceMethodCall(h.handler.method, []reflect.Value{
+		reflect.ValueOf(&ServiceMethodContext{
+			Context:              ctx,
+			RemoteAddr:           r.RemoteAddr,
+			RequestHeader:        r.Header,
+			RequestBodyReader:    r.Body,
+			ResponseStatusSetter: func(int) {},
+			ResponseHeader:       make(http.Header),
+			ResponseBodyWriter:   ioutil.Discard,
+			Metadata:             md,
+			Locales:              requestLocales(r),
+			Principal:            PrincipalFromContext(r.Context()),
+			goroutines:           goroutines,
+			logger:               logger,
+		}),
+		in,
+		reflect.ValueOf(conn),
+	})
+
+	code, reason := uint16(1000), ""
+	if methodPanic != nil {
+		recordSpanPanic(oteltrace.SpanFromContext(ctx), methodPanic)
+		code, reason = 1011, webSocketCloseReason(h.handler.panicResponse(w, r, tracer, methodPanic))
+	} else if httpError, ok := out[0].Interface().(HTTPError); ok {
+		code, reason = 1011, webSocketCloseReason(h.handler.httpErrorResponse(w, r, tracer, httpError))
+		if httpError.StatusCode() >= 400 && httpError.StatusCode() < 500 {
+			code = 1008
+		}
+	} else if methodError, _ := out[0].Interface().(error); methodError != nil {
+		code, reason = 1011, webSocketCloseReason(h.handler.reportIncident(w, r, tracer,
+			&FormattedResponse{500, "service method error", methodError.Error()}))
+	}
+
+	_ = raw.WriteClose(code, reason, time.Now().Add(time.Second))
+	tracer.LazyPrintf("websocket closed with %d: %s", code, reason)
+	if logger != nil {
+		logger.Record("wsReceived", strconv.FormatInt(atomic.LoadInt64(&conn.received), 10))
+		logger.Record("wsSent", strconv.FormatInt(atomic.LoadInt64(&conn.sent), 10))
+		logger.Record("wsClosed", strconv.Itoa(int(code)))
+	}
+}
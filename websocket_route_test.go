@@ -0,0 +1,238 @@
+package apihttpwrapper
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeClientFrame writes a masked frame of the client, the payload is short.
+func writeClientFrame(w io.Writer, opcode byte, payload []byte) error {
+	return writeClientFragment(w, true, opcode, payload)
+}
+
+// writeClientFragment writes a masked frame which is the final fragment of the message if fin.
+func writeClientFragment(w io.Writer, fin bool, opcode byte, payload []byte) error {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{first, 0x80 | byte(len(payload))}, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// readServerFrame reads an unmasked short frame of the server.
+func readServerFrame(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	payload := make([]byte, header[1]&0x7f)
+	_, err := io.ReadFull(r, payload)
+	return header[0] & 0x0f, payload, err
+}
+
+func TestWebSocketRoute(t *testing.T) {
+	type echoArguments struct {
+		Room   string `uri:"room"`
+		Prefix string `form:"prefix"`
+	}
+
+	routes := []*Route{
+		{Method: "GET", Path: "/rooms/:room", WebSocket: &WebSocket{},
+			Function: func(ctx *ServiceMethodContext, arg *echoArguments, conn *WebSocketConn) error {
+				for {
+					_, payload, err := conn.ReadMessage()
+					if err == io.EOF {
+						return nil
+					} else if err != nil {
+						return err
+					}
+
+					if string(payload) == "bye" {
+						return &StatusError{Status: 400, Message: "bye"}
+					} else if string(payload) == "fail" {
+						return errors.New("dial db://admin:secret@db failed")
+					}
+
+					err = conn.WriteMessage(WebSocketTextMessage, []byte(arg.Room+":"+arg.Prefix+string(payload)))
+					if err != nil {
+						return err
+					}
+				}
+			}},
+		{Method: "GET", Path: "/users", Function: func(ctx *ServiceMethodContext, arg *struct{}) error {
+			return nil
+		}},
+	}
+
+	rows := make(logRowWriter, 2)
+	router, err := NewLoggingHTTPRouter(routes, nil, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/rooms/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != 426 {
+		t.Error("the plain request is not rejected", resp.StatusCode)
+	}
+	<-rows
+
+	handshake := func(origin string) (net.Conn, *bufio.Reader, *http.Response) {
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+		_, err = io.WriteString(conn, "GET /rooms/1?prefix=> HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\n"+
+			"Upgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+			origin+"\r\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reader := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(reader, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn, reader, resp
+	}
+
+	dial := func(origin string) (net.Conn, *bufio.Reader) {
+		conn, reader, resp := handshake(origin)
+		if resp.StatusCode != 101 || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+			t.Fatal(resp)
+		}
+		return conn, reader
+	}
+
+	// the handshakes of the other sites are rejected, since the browsers send the cookies with them.
+	rejected, _, resp := handshake("Origin: https://evil.example\r\n")
+	_ = rejected.Close()
+	if resp.StatusCode != 403 {
+		t.Error("the cross-origin handshake is accepted", resp.StatusCode)
+	}
+	<-rows
+
+	conn, reader := dial("Origin: http://test\r\n")
+	defer conn.Close()
+
+	if err := writeClientFrame(conn, wsOpText, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	if opcode, payload, err := readServerFrame(reader); err != nil || opcode != wsOpText ||
+		string(payload) != "1:>hello" {
+		t.Error(opcode, string(payload), err)
+	}
+
+	// the fragments are reassembled, and the pings between them are answered.
+	for _, frame := range []struct {
+		fin     bool
+		opcode  byte
+		payload string
+	}{{false, wsOpText, "wor"}, {true, wsOpPing, "p"}, {true, wsOpContinuation, "ld"}} {
+		if err := writeClientFragment(conn, frame.fin, frame.opcode, []byte(frame.payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if opcode, payload, err := readServerFrame(reader); err != nil || opcode != wsOpPong || string(payload) != "p" {
+		t.Error(opcode, string(payload), err)
+	}
+
+	if opcode, payload, err := readServerFrame(reader); err != nil || opcode != wsOpText ||
+		string(payload) != "1:>world" {
+		t.Error(opcode, string(payload), err)
+	}
+
+	if err := writeClientFrame(conn, wsOpText, []byte("bye")); err != nil {
+		t.Fatal(err)
+	}
+
+	if opcode, payload, err := readServerFrame(reader); err != nil || opcode != wsOpClose ||
+		len(payload) < 2 || payload[0] != 1008>>8 || payload[1] != 1008&0xff {
+		t.Error(opcode, payload, err)
+	}
+
+	row := <-rows
+	for _, field := range []string{"status=101", "wsReceived=3", "wsSent=2", "wsClosed=1008"} {
+		if !strings.Contains(row, field) {
+			t.Error(field, row)
+		}
+	}
+
+	// the method returns once the client closes the connection.
+	closing, reader := dial("")
+	defer closing.Close()
+	if err := writeClientFrame(closing, wsOpClose, []byte{1000 >> 8, 1000 & 0xff}); err != nil {
+		t.Fatal(err)
+	}
+
+	if opcode, payload, err := readServerFrame(reader); err != nil || opcode != wsOpClose ||
+		len(payload) < 2 || payload[0] != 1000>>8 || payload[1] != 1000&0xff {
+		t.Error(opcode, payload, err)
+	}
+
+	row = <-rows
+	for _, field := range []string{"status=101", "wsReceived=0", "wsClosed=1000"} {
+		if !strings.Contains(row, field) {
+			t.Error(field, row)
+		}
+	}
+
+	// the errors of the method are only in the access log.
+	failing, reader := dial("")
+	defer failing.Close()
+	if err := writeClientFrame(failing, wsOpText, []byte("fail")); err != nil {
+		t.Fatal(err)
+	}
+
+	if opcode, payload, err := readServerFrame(reader); err != nil || opcode != wsOpClose || len(payload) < 2 ||
+		payload[0] != 1011>>8 || payload[1] != 1011&0xff ||
+		!strings.HasPrefix(string(payload[2:]), "service method error, incident ") {
+		t.Error(opcode, string(payload), err)
+	}
+
+	row = <-rows
+	for _, field := range []string{"wsClosed=1011", "admin:secret"} {
+		if !strings.Contains(row, field) {
+			t.Error(field, row)
+		}
+	}
+
+	for _, rt := range []*Route{
+		{Method: "POST", Path: "/", Function: routes[0].Function, WebSocket: &WebSocket{}},
+		{Method: "GET", Path: "/", Function: routes[0].Function, WebSocket: &WebSocket{},
+			ConcurrencyLimit: &ConcurrencyLimit{MaxConcurrent: 1}},
+		{Method: "GET", Path: "/", Function: routes[0].Function, WebSocket: &WebSocket{},
+			Compatibility: &CompatibilityRules{}},
+	} {
+		if err := ValidateRoutes([]*Route{rt}); err == nil {
+			t.Error("the websocket route is accepted", rt.Method, rt.ConcurrencyLimit, rt.Compatibility)
+		}
+	}
+}
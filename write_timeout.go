@@ -0,0 +1,85 @@
+package apihttpwrapper
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SetWriteTimeout limits writing the response after the method returns, 0 means unlimited.
+func (h *ServiceHandler) SetWriteTimeout(timeout time.Duration) {
+	h.writeTimeout = timeout
+}
+
+// writeDeadlineSetter is implemented by the responses of net/http since go1.20, see http.ResponseController.
+type writeDeadlineSetter interface {
+	SetWriteDeadline(deadline time.Time) error
+}
+
+// setResponseWriteDeadline looks for the writeDeadlineSetter through the writers wrapping the response.
+func setResponseWriteDeadline(w http.ResponseWriter, deadline time.Time) bool {
+	for {
+		if setter, ok := w.(writeDeadlineSetter); ok {
+			return setter.SetWriteDeadline(deadline) == nil
+		}
+
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// writeTimeoutWriter sets the write deadline on start, and remembers whether the writes failed by it.
+type writeTimeoutWriter struct {
+	http.ResponseWriter
+	timeout  time.Duration
+	once     sync.Once
+	mutex    sync.Mutex
+	timedOut bool
+}
+
+func (w *writeTimeoutWriter) start() {
+	w.once.Do(func() {
+		setResponseWriteDeadline(w.ResponseWriter, time.Now().Add(w.timeout))
+	})
+}
+
+func (w *writeTimeoutWriter) observe(err error) {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		w.mutex.Lock()
+		w.timedOut = true
+		w.mutex.Unlock()
+	}
+}
+
+func (w *writeTimeoutWriter) isTimedOut() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.timedOut
+}
+
+func (w *writeTimeoutWriter) WriteHeader(status int) {
+	w.start()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *writeTimeoutWriter) Write(b []byte) (int, error) {
+	w.start()
+	n, err := w.ResponseWriter.Write(b)
+	w.observe(err)
+	return n, err
+}
+
+func (w *writeTimeoutWriter) Flush() {
+	w.start()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *writeTimeoutWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
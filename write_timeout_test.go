@@ -0,0 +1,61 @@
+package apihttpwrapper
+
+import (
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type logRowWriter chan string
+
+func (w logRowWriter) Write(b []byte) (int, error) {
+	w <- string(b)
+	return len(b), nil
+}
+
+func TestWriteTimeout(t *testing.T) {
+	type largeResponse struct {
+		Data string `json:"data"`
+	}
+
+	routes := []*Route{{Method: "GET", Path: "/large", WriteTimeout: 100 * time.Millisecond,
+		Function: func(ctx *ServiceMethodContext, arg *struct{}) (*largeResponse, error) {
+			return &largeResponse{strings.Repeat("x", 16<<20)}, nil
+		}}}
+
+	rows := make(logRowWriter, 1)
+	router, err := NewLoggingHTTPRouter(routes, nil, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// the client never reads the response.
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("GET /large HTTP/1.1\r\nHost: test\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case row := <-rows:
+		if !strings.Contains(row, "writeTimedOut=true") {
+			t.Error(row)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the slow reader pins the handler")
+	}
+
+	if err := ValidateRoutes([]*Route{{Method: "GET", Path: "/", Function: routes[0].Function,
+		WriteTimeout: -time.Second}}); err == nil {
+		t.Error("the negative write timeout is accepted")
+	}
+}